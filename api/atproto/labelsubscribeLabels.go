@@ -4,6 +4,14 @@ package atproto
 
 // schema: com.atproto.label.subscribeLabels
 
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/bluesky-social/indigo/xrpc"
+)
+
 // LabelSubscribeLabels_Info is a "info" in the com.atproto.label.subscribeLabels schema.
 type LabelSubscribeLabels_Info struct {
 	Message *string `json:"message,omitempty" cborgen:"message,omitempty"`
@@ -15,3 +23,54 @@ type LabelSubscribeLabels_Labels struct {
 	Labels []*LabelDefs_Label `json:"labels" cborgen:"labels"`
 	Seq    int64              `json:"seq" cborgen:"seq"`
 }
+
+// LabelSubscribeLabels_Callbacks holds typed message handlers for the XRPC subscription "com.atproto.label.subscribeLabels". It implements xrpc.StreamHandler. Nil callbacks are skipped.
+type LabelSubscribeLabels_Callbacks struct {
+	Labels func(ctx context.Context, evt *LabelSubscribeLabels_Labels) error
+	Info   func(ctx context.Context, evt *LabelSubscribeLabels_Info) error
+	// Called for message types not otherwise handled
+	Unknown func(ctx context.Context, msgType string, body io.Reader) error
+}
+
+func (cb *LabelSubscribeLabels_Callbacks) HandleStreamMessage(ctx context.Context, msgType string, body io.Reader) (int64, error) {
+	switch msgType {
+	case "#labels":
+		var evt LabelSubscribeLabels_Labels
+		if err := evt.UnmarshalCBOR(body); err != nil {
+			return 0, fmt.Errorf("decoding %s message: %w", msgType, err)
+		}
+		if cb.Labels != nil {
+			if err := cb.Labels(ctx, &evt); err != nil {
+				return evt.Seq, err
+			}
+		}
+		return evt.Seq, nil
+	case "#info":
+		var evt LabelSubscribeLabels_Info
+		if err := evt.UnmarshalCBOR(body); err != nil {
+			return 0, fmt.Errorf("decoding %s message: %w", msgType, err)
+		}
+		if cb.Info != nil {
+			if err := cb.Info(ctx, &evt); err != nil {
+				return 0, err
+			}
+		}
+		return 0, nil
+	default:
+		if cb.Unknown != nil {
+			return 0, cb.Unknown(ctx, msgType, body)
+		}
+		return 0, nil
+	}
+}
+
+// LabelSubscribeLabels subscribes to the XRPC event stream "com.atproto.label.subscribeLabels", blocking until the subscription ends.
+//
+// cursor: The last known event seq number to backfill from.
+func LabelSubscribeLabels(ctx context.Context, c *xrpc.Client, cb *LabelSubscribeLabels_Callbacks, cursor int64, opts *xrpc.SubscribeOptions) error {
+	params := map[string]interface{}{}
+	if cursor != 0 {
+		params["cursor"] = cursor
+	}
+	return c.Subscribe(ctx, "com.atproto.label.subscribeLabels", params, cb, opts)
+}
@@ -5,7 +5,12 @@ package atproto
 // schema: com.atproto.sync.subscribeRepos
 
 import (
+	"context"
+	"fmt"
+	"io"
+
 	"github.com/bluesky-social/indigo/lex/util"
+	"github.com/bluesky-social/indigo/xrpc"
 )
 
 // SyncSubscribeRepos_Account is a "account" in the com.atproto.sync.subscribeRepos schema.
@@ -104,3 +109,120 @@ type SyncSubscribeRepos_Tombstone struct {
 	Seq  int64  `json:"seq" cborgen:"seq"`
 	Time string `json:"time" cborgen:"time"`
 }
+
+// SyncSubscribeRepos_Callbacks holds typed message handlers for the XRPC subscription "com.atproto.sync.subscribeRepos". It implements xrpc.StreamHandler. Nil callbacks are skipped.
+type SyncSubscribeRepos_Callbacks struct {
+	// Represents an update of repository state. Note that empty commits are allowed, which include no repo data changes, but an update to rev and signature.
+	Commit func(ctx context.Context, evt *SyncSubscribeRepos_Commit) error
+	// Represents a change to an account's identity. Could be an updated handle, signing key, or pds hosting endpoint. Serves as a prod to all downstream services to refresh their identity cache.
+	Identity func(ctx context.Context, evt *SyncSubscribeRepos_Identity) error
+	// Represents a change to an account's status on a host (eg, PDS or Relay). The semantics of this event are that the status is at the host which emitted the event, not necessarily that at the currently active PDS. Eg, a Relay takedown would emit a takedown with active=false, even if the PDS is still active.
+	Account func(ctx context.Context, evt *SyncSubscribeRepos_Account) error
+	// DEPRECATED -- Use #identity event instead
+	Handle func(ctx context.Context, evt *SyncSubscribeRepos_Handle) error
+	// DEPRECATED -- Use #account event instead
+	Migrate func(ctx context.Context, evt *SyncSubscribeRepos_Migrate) error
+	// DEPRECATED -- Use #account event instead
+	Tombstone func(ctx context.Context, evt *SyncSubscribeRepos_Tombstone) error
+	Info      func(ctx context.Context, evt *SyncSubscribeRepos_Info) error
+	// Called for message types not otherwise handled
+	Unknown func(ctx context.Context, msgType string, body io.Reader) error
+}
+
+func (cb *SyncSubscribeRepos_Callbacks) HandleStreamMessage(ctx context.Context, msgType string, body io.Reader) (int64, error) {
+	switch msgType {
+	case "#commit":
+		var evt SyncSubscribeRepos_Commit
+		if err := evt.UnmarshalCBOR(body); err != nil {
+			return 0, fmt.Errorf("decoding %s message: %w", msgType, err)
+		}
+		if cb.Commit != nil {
+			if err := cb.Commit(ctx, &evt); err != nil {
+				return evt.Seq, err
+			}
+		}
+		return evt.Seq, nil
+	case "#identity":
+		var evt SyncSubscribeRepos_Identity
+		if err := evt.UnmarshalCBOR(body); err != nil {
+			return 0, fmt.Errorf("decoding %s message: %w", msgType, err)
+		}
+		if cb.Identity != nil {
+			if err := cb.Identity(ctx, &evt); err != nil {
+				return evt.Seq, err
+			}
+		}
+		return evt.Seq, nil
+	case "#account":
+		var evt SyncSubscribeRepos_Account
+		if err := evt.UnmarshalCBOR(body); err != nil {
+			return 0, fmt.Errorf("decoding %s message: %w", msgType, err)
+		}
+		if cb.Account != nil {
+			if err := cb.Account(ctx, &evt); err != nil {
+				return evt.Seq, err
+			}
+		}
+		return evt.Seq, nil
+	case "#handle":
+		var evt SyncSubscribeRepos_Handle
+		if err := evt.UnmarshalCBOR(body); err != nil {
+			return 0, fmt.Errorf("decoding %s message: %w", msgType, err)
+		}
+		if cb.Handle != nil {
+			if err := cb.Handle(ctx, &evt); err != nil {
+				return evt.Seq, err
+			}
+		}
+		return evt.Seq, nil
+	case "#migrate":
+		var evt SyncSubscribeRepos_Migrate
+		if err := evt.UnmarshalCBOR(body); err != nil {
+			return 0, fmt.Errorf("decoding %s message: %w", msgType, err)
+		}
+		if cb.Migrate != nil {
+			if err := cb.Migrate(ctx, &evt); err != nil {
+				return evt.Seq, err
+			}
+		}
+		return evt.Seq, nil
+	case "#tombstone":
+		var evt SyncSubscribeRepos_Tombstone
+		if err := evt.UnmarshalCBOR(body); err != nil {
+			return 0, fmt.Errorf("decoding %s message: %w", msgType, err)
+		}
+		if cb.Tombstone != nil {
+			if err := cb.Tombstone(ctx, &evt); err != nil {
+				return evt.Seq, err
+			}
+		}
+		return evt.Seq, nil
+	case "#info":
+		var evt SyncSubscribeRepos_Info
+		if err := evt.UnmarshalCBOR(body); err != nil {
+			return 0, fmt.Errorf("decoding %s message: %w", msgType, err)
+		}
+		if cb.Info != nil {
+			if err := cb.Info(ctx, &evt); err != nil {
+				return 0, err
+			}
+		}
+		return 0, nil
+	default:
+		if cb.Unknown != nil {
+			return 0, cb.Unknown(ctx, msgType, body)
+		}
+		return 0, nil
+	}
+}
+
+// SyncSubscribeRepos subscribes to the XRPC event stream "com.atproto.sync.subscribeRepos", blocking until the subscription ends.
+//
+// cursor: The last known event seq number to backfill from.
+func SyncSubscribeRepos(ctx context.Context, c *xrpc.Client, cb *SyncSubscribeRepos_Callbacks, cursor int64, opts *xrpc.SubscribeOptions) error {
+	params := map[string]interface{}{}
+	if cursor != 0 {
+		params["cursor"] = cursor
+	}
+	return c.Subscribe(ctx, "com.atproto.sync.subscribeRepos", params, cb, opts)
+}
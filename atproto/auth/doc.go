@@ -0,0 +1,8 @@
+/*
+Package auth provides helpers for atproto authentication between services.
+
+[ServiceAuthValidator] verifies inter-service auth JWTs, which are signed by the issuer's atproto signing key as declared in their DID document. Middleware is provided for both 'net/http' and echo servers; the verified issuer DID is stored in the request context and can be retrieved with [ServiceAuthDID].
+
+The OAuth client implementation is in the [github.com/bluesky-social/indigo/atproto/auth/oauth] sub-package.
+*/
+package auth
@@ -0,0 +1,14 @@
+/*
+Package oauth implements an OAuth client for the atproto profile of OAuth 2.1.
+
+This includes authorization server discovery (starting from an account's PDS), pushed authorization requests (PAR), PKCE, DPoP-bound access tokens (with server-provided nonces), and token refresh. Sessions are persisted through the pluggable [Store] interface; [MemStore] is a trivial in-memory implementation.
+
+The typical flow for a web service is:
+
+  - call [ClientApp.StartAuthFlow] with the account's handle or DID, and redirect the user to the returned URL
+  - when the user is redirected back to the client's redirect URI, call [ClientApp.ProcessCallback] with the query parameters
+  - use [ClientSession.APIClient] to get an [xrpc.Client] which signs requests to the account's PDS with DPoP
+
+Later requests can resume an existing session with [ClientApp.ResumeSession].
+*/
+package oauth
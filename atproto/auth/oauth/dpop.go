@@ -0,0 +1,141 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/crypto"
+)
+
+// JSON Web Key representation of a public key. Only the EC fields needed for atproto (P-256 and K-256) are included.
+type JWK struct {
+	KeyType string `json:"kty"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+	KeyID   string `json:"kid,omitempty"`
+	Use     string `json:"use,omitempty"`
+	Alg     string `json:"alg,omitempty"`
+}
+
+// Converts an atproto public key to JWK format.
+func PublicJWK(pub crypto.PublicKey) (*JWK, error) {
+	raw := pub.UncompressedBytes()
+	if len(raw) != 65 || raw[0] != 0x04 {
+		return nil, fmt.Errorf("unexpected uncompressed public key format")
+	}
+	jwk := JWK{
+		KeyType: "EC",
+		X:       base64.RawURLEncoding.EncodeToString(raw[1:33]),
+		Y:       base64.RawURLEncoding.EncodeToString(raw[33:]),
+	}
+	switch pub.(type) {
+	case *crypto.PublicKeyP256:
+		jwk.Curve = "P-256"
+		jwk.Alg = "ES256"
+	case *crypto.PublicKeyK256:
+		jwk.Curve = "secp256k1"
+		jwk.Alg = "ES256K"
+	default:
+		return nil, fmt.Errorf("unsupported public key type for JWK")
+	}
+	return &jwk, nil
+}
+
+// Returns the JWS algorithm name corresponding to an atproto private key type.
+func jwtAlgorithm(priv crypto.PrivateKey) (string, error) {
+	switch priv.(type) {
+	case *crypto.PrivateKeyP256:
+		return "ES256", nil
+	case *crypto.PrivateKeyK256:
+		return "ES256K", nil
+	default:
+		return "", fmt.Errorf("unsupported private key type for JWT signing")
+	}
+}
+
+// Encodes and signs a compact JWS. The "alg" header is set automatically based on the key type.
+func signJWT(header map[string]any, claims map[string]any, priv crypto.PrivateKey) (string, error) {
+	alg, err := jwtAlgorithm(priv)
+	if err != nil {
+		return "", err
+	}
+	header["alg"] = alg
+	hb, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	cb, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(hb) + "." + base64.RawURLEncoding.EncodeToString(cb)
+	// atproto signatures are already in the 64-byte "r || s" format which JWS uses for ECDSA
+	sig, err := priv.HashAndSign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// Returns a random base64url string, with the given number of random bytes
+func randomToken(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic("failed to read random bytes: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Creates a DPoP proof JWT for a single HTTP request.
+//
+// 'nonce' and 'accessToken' are optional. If an access token is provided, its hash is included as the "ath" claim, as required for requests to resource servers.
+func NewDPoPProof(key crypto.PrivateKey, method, rawURL, nonce, accessToken string) (string, error) {
+	pub, err := key.PublicKey()
+	if err != nil {
+		return "", err
+	}
+	jwk, err := PublicJWK(pub)
+	if err != nil {
+		return "", err
+	}
+	// "htu" must not include query or fragment
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+
+	header := map[string]any{
+		"typ": "dpop+jwt",
+		"jwk": jwk,
+	}
+	claims := map[string]any{
+		"jti": randomToken(16),
+		"htm": method,
+		"htu": u.String(),
+		"iat": time.Now().Unix(),
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	if accessToken != "" {
+		h := sha256.Sum256([]byte(accessToken))
+		claims["ath"] = base64.RawURLEncoding.EncodeToString(h[:])
+	}
+	return signJWT(header, claims, key)
+}
+
+// Generates a PKCE code verifier and corresponding "S256" challenge.
+func newPKCE() (verifier, challenge string) {
+	verifier = randomToken(32)
+	h := sha256.Sum256([]byte(verifier))
+	challenge = base64.RawURLEncoding.EncodeToString(h[:])
+	return verifier, challenge
+}
@@ -0,0 +1,138 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Metadata document for an OAuth protected resource (eg, a PDS), from the "/.well-known/oauth-protected-resource" endpoint.
+type ProtectedResourceMetadata struct {
+	Resource             string   `json:"resource"`
+	AuthorizationServers []string `json:"authorization_servers"`
+	ScopesSupported      []string `json:"scopes_supported,omitempty"`
+}
+
+// Metadata document for an OAuth authorization server, from the "/.well-known/oauth-authorization-server" endpoint.
+//
+// Only the subset of fields relevant to atproto clients is included.
+type AuthServerMetadata struct {
+	Issuer                                     string   `json:"issuer"`
+	AuthorizationEndpoint                      string   `json:"authorization_endpoint"`
+	TokenEndpoint                              string   `json:"token_endpoint"`
+	PushedAuthorizationRequestEndpoint         string   `json:"pushed_authorization_request_endpoint"`
+	RequirePushedAuthorizationRequests         bool     `json:"require_pushed_authorization_requests"`
+	ResponseTypesSupported                     []string `json:"response_types_supported"`
+	GrantTypesSupported                        []string `json:"grant_types_supported"`
+	CodeChallengeMethodsSupported              []string `json:"code_challenge_methods_supported"`
+	TokenEndpointAuthMethodsSupported          []string `json:"token_endpoint_auth_methods_supported"`
+	TokenEndpointAuthSigningAlgValuesSupported []string `json:"token_endpoint_auth_signing_alg_values_supported"`
+	ScopesSupported                            []string `json:"scopes_supported"`
+	DPoPSigningAlgValuesSupported              []string `json:"dpop_signing_alg_values_supported"`
+	AuthorizationResponseIssParameterSupported bool     `json:"authorization_response_iss_parameter_supported"`
+	ClientIDMetadataDocumentSupported          bool     `json:"client_id_metadata_document_supported"`
+}
+
+func contains(list []string, val string) bool {
+	for _, v := range list {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks that the authorization server supports the features required by the atproto OAuth profile.
+func (m *AuthServerMetadata) Validate(issuer string) error {
+	if m.Issuer != issuer {
+		return fmt.Errorf("authorization server issuer mismatch: %s != %s", m.Issuer, issuer)
+	}
+	if m.PushedAuthorizationRequestEndpoint == "" {
+		return fmt.Errorf("authorization server does not support PAR")
+	}
+	if m.AuthorizationEndpoint == "" || m.TokenEndpoint == "" {
+		return fmt.Errorf("authorization server metadata missing endpoints")
+	}
+	if !contains(m.ResponseTypesSupported, "code") {
+		return fmt.Errorf("authorization server does not support 'code' response type")
+	}
+	if !contains(m.CodeChallengeMethodsSupported, "S256") {
+		return fmt.Errorf("authorization server does not support S256 PKCE")
+	}
+	if !contains(m.DPoPSigningAlgValuesSupported, "ES256") {
+		return fmt.Errorf("authorization server does not support ES256 DPoP")
+	}
+	if !contains(m.ScopesSupported, "atproto") {
+		return fmt.Errorf("authorization server does not support 'atproto' scope")
+	}
+	return nil
+}
+
+// Helper to fetch a JSON document with a GET request. Redirects are not followed.
+func fetchJSON(ctx context.Context, c *http.Client, u string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	// don't follow redirects for metadata requests
+	noRedirect := *c
+	noRedirect.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := noRedirect.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetching %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching %s: HTTP status %d", u, resp.StatusCode)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
+// Fetches the protected resource metadata for a host (eg, a PDS), and returns the URL of the authorization server.
+func (app *ClientApp) ResolveAuthServerURL(ctx context.Context, hostURL string) (string, error) {
+	u, err := url.Parse(hostURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "https" && !app.Config.AllowHTTP {
+		return "", fmt.Errorf("resource server URL must be https: %s", hostURL)
+	}
+	var meta ProtectedResourceMetadata
+	if err := fetchJSON(ctx, app.httpClient(), strings.TrimSuffix(hostURL, "/")+"/.well-known/oauth-protected-resource", &meta); err != nil {
+		return "", err
+	}
+	if len(meta.AuthorizationServers) == 0 {
+		return "", fmt.Errorf("no authorization servers listed for resource: %s", hostURL)
+	}
+	return meta.AuthorizationServers[0], nil
+}
+
+// Fetches and validates the authorization server metadata for an issuer URL.
+func (app *ClientApp) ResolveAuthServerMetadata(ctx context.Context, issuer string) (*AuthServerMetadata, error) {
+	u, err := url.Parse(issuer)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" && !app.Config.AllowHTTP {
+		return nil, fmt.Errorf("authorization server URL must be https: %s", issuer)
+	}
+	var meta AuthServerMetadata
+	if err := fetchJSON(ctx, app.httpClient(), strings.TrimSuffix(issuer, "/")+"/.well-known/oauth-authorization-server", &meta); err != nil {
+		return nil, err
+	}
+	if err := meta.Validate(issuer); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
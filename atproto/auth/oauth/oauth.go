@@ -0,0 +1,472 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/crypto"
+	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+
+	"github.com/carlmjohnson/versioninfo"
+)
+
+// Static configuration for an OAuth client application.
+type ClientConfig struct {
+	// Client identifier: the URL of the client metadata document, or a special "http://localhost" URL for development clients
+	ClientID string
+
+	// Where the authorization server sends the user after they approve (or reject) the request
+	RedirectURI string
+
+	// Requested scopes. Must include "atproto".
+	Scopes []string
+
+	// Optional private key for confidential clients, used to sign client assertions ("private_key_jwt" auth method). If nil, the client is a public client.
+	ClientSecretKey crypto.PrivateKey
+
+	// Key ID ("kid") of ClientSecretKey, as listed in the client metadata JWKS
+	ClientSecretKeyID string
+
+	// Permits non-HTTPS server URLs. Only intended for local development and tests.
+	AllowHTTP bool
+
+	UserAgent string
+}
+
+// Creates a configuration for a public client, identified by the URL of its metadata document.
+func NewPublicConfig(clientID, redirectURI string, scopes []string) ClientConfig {
+	return ClientConfig{
+		ClientID:    clientID,
+		RedirectURI: redirectURI,
+		Scopes:      scopes,
+		UserAgent:   "indigo-oauth/" + versioninfo.Short(),
+	}
+}
+
+// Creates a configuration for a development client which runs on localhost, and does not have a published client metadata document.
+func NewLocalhostConfig(redirectURI string, scopes []string) ClientConfig {
+	params := url.Values{}
+	params.Set("redirect_uri", redirectURI)
+	params.Set("scope", strings.Join(scopes, " "))
+	return NewPublicConfig("http://localhost?"+params.Encode(), redirectURI, scopes)
+}
+
+// Whether this client is a confidential client (authenticates to the token endpoint with a private key).
+func (c *ClientConfig) IsConfidential() bool {
+	return c.ClientSecretKey != nil
+}
+
+// Client metadata document, as published at the ClientID URL.
+type ClientMetadata struct {
+	ClientID                    string      `json:"client_id"`
+	ApplicationType             string      `json:"application_type,omitempty"`
+	GrantTypes                  []string    `json:"grant_types"`
+	ResponseTypes               []string    `json:"response_types"`
+	RedirectURIs                []string    `json:"redirect_uris"`
+	Scope                       string      `json:"scope"`
+	DPoPBoundAccessTokens       bool        `json:"dpop_bound_access_tokens"`
+	TokenEndpointAuthMethod     string      `json:"token_endpoint_auth_method"`
+	TokenEndpointAuthSigningAlg string      `json:"token_endpoint_auth_signing_alg,omitempty"`
+	JWKS                        *ClientJWKS `json:"jwks,omitempty"`
+	ClientName                  string      `json:"client_name,omitempty"`
+	ClientURI                   string      `json:"client_uri,omitempty"`
+}
+
+type ClientJWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Returns the client metadata document corresponding to this configuration.
+func (c *ClientConfig) ClientMetadata() (*ClientMetadata, error) {
+	meta := ClientMetadata{
+		ClientID:                c.ClientID,
+		ApplicationType:         "web",
+		GrantTypes:              []string{"authorization_code", "refresh_token"},
+		ResponseTypes:           []string{"code"},
+		RedirectURIs:            []string{c.RedirectURI},
+		Scope:                   strings.Join(c.Scopes, " "),
+		DPoPBoundAccessTokens:   true,
+		TokenEndpointAuthMethod: "none",
+	}
+	if c.IsConfidential() {
+		pub, err := c.ClientSecretKey.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+		jwk, err := PublicJWK(pub)
+		if err != nil {
+			return nil, err
+		}
+		jwk.KeyID = c.ClientSecretKeyID
+		jwk.Use = "sig"
+		meta.TokenEndpointAuthMethod = "private_key_jwt"
+		meta.TokenEndpointAuthSigningAlg = jwk.Alg
+		meta.JWKS = &ClientJWKS{Keys: []JWK{*jwk}}
+	}
+	return &meta, nil
+}
+
+// An OAuth client application: configuration, plus the identity directory, HTTP client, and state store used for auth flows.
+type ClientApp struct {
+	Config *ClientConfig
+	Dir    identity.Directory
+	Store  Store
+	Client *http.Client
+}
+
+func NewClientApp(config *ClientConfig, store Store) *ClientApp {
+	return &ClientApp{
+		Config: config,
+		Dir:    identity.DefaultDirectory(),
+		Store:  store,
+	}
+}
+
+func (app *ClientApp) httpClient() *http.Client {
+	if app.Client == nil {
+		return &http.Client{Timeout: 10 * time.Second}
+	}
+	return app.Client
+}
+
+// Error response from an authorization server.
+type AuthServerError struct {
+	StatusCode  int
+	ErrorCode   string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *AuthServerError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("OAuth error (HTTP %d): %s: %s", e.StatusCode, e.ErrorCode, e.Description)
+	}
+	return fmt.Sprintf("OAuth error (HTTP %d): %s", e.StatusCode, e.ErrorCode)
+}
+
+// Response body from a token endpoint, for both initial and refresh requests.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	Scope        string `json:"scope"`
+	Sub          string `json:"sub"`
+}
+
+// Creates a signed client assertion JWT, for confidential clients.
+func (app *ClientApp) clientAssertion(issuer string) (string, error) {
+	header := map[string]any{
+		"kid": app.Config.ClientSecretKeyID,
+	}
+	now := time.Now()
+	claims := map[string]any{
+		"iss": app.Config.ClientID,
+		"sub": app.Config.ClientID,
+		"aud": issuer,
+		"jti": randomToken(16),
+		"iat": now.Unix(),
+		"exp": now.Add(time.Minute).Unix(),
+	}
+	return signJWT(header, claims, app.Config.ClientSecretKey)
+}
+
+// Sends a form-encoded POST request to an authorization server endpoint, with client authentication and a DPoP proof.
+//
+// If the server responds with a "use_dpop_nonce" error, the request is retried once with the new nonce. The 'nonce' argument is updated in-place with the latest nonce from the server.
+func (app *ClientApp) authServerPost(ctx context.Context, endpoint, issuer string, form url.Values, dpopKey crypto.PrivateKey, nonce *string, out any) error {
+	for attempt := 0; attempt < 2; attempt++ {
+		body := url.Values{}
+		for k, v := range form {
+			body[k] = v
+		}
+		body.Set("client_id", app.Config.ClientID)
+		if app.Config.IsConfidential() {
+			assertion, err := app.clientAssertion(issuer)
+			if err != nil {
+				return err
+			}
+			body.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+			body.Set("client_assertion", assertion)
+		}
+
+		proof, err := NewDPoPProof(dpopKey, "POST", endpoint, *nonce, "")
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(body.Encode()))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("DPoP", proof)
+		if app.Config.UserAgent != "" {
+			req.Header.Set("User-Agent", app.Config.UserAgent)
+		}
+
+		resp, err := app.httpClient().Do(req)
+		if err != nil {
+			return fmt.Errorf("auth server request failed: %w", err)
+		}
+		respBytes, err := io.ReadAll(io.LimitReader(resp.Body, 256*1024))
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if n := resp.Header.Get("DPoP-Nonce"); n != "" {
+			*nonce = n
+		}
+
+		if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated {
+			return json.Unmarshal(respBytes, out)
+		}
+
+		aerr := AuthServerError{StatusCode: resp.StatusCode}
+		if err := json.Unmarshal(respBytes, &aerr); err != nil || aerr.ErrorCode == "" {
+			return &AuthServerError{StatusCode: resp.StatusCode, ErrorCode: "unknown"}
+		}
+		if aerr.ErrorCode == "use_dpop_nonce" && attempt == 0 {
+			slog.Debug("retrying OAuth request with new DPoP nonce", "endpoint", endpoint)
+			continue
+		}
+		return &aerr
+	}
+	return fmt.Errorf("OAuth request failed after DPoP nonce retry")
+}
+
+type parResponse struct {
+	RequestURI string `json:"request_uri"`
+	ExpiresIn  int    `json:"expires_in"`
+}
+
+// Starts an authorization flow, returning the URL which the user should be redirected to.
+//
+// 'identifier' can be an account handle or DID (in which case the account's PDS is used to find the authorization server), or the URL of a PDS or authorization server (when the account is not known ahead of time).
+func (app *ClientApp) StartAuthFlow(ctx context.Context, identifier string) (string, error) {
+	var accountDID *syntax.DID
+	var loginHint string
+	var authServerURL string
+
+	if strings.HasPrefix(identifier, "https://") || strings.HasPrefix(identifier, "http://") {
+		// might be either a resource server (PDS) or authorization server (entryway)
+		u, err := app.ResolveAuthServerURL(ctx, identifier)
+		if err != nil {
+			slog.Debug("failed to resolve as protected resource, assuming authorization server", "url", identifier, "err", err)
+			u = strings.TrimSuffix(identifier, "/")
+		}
+		authServerURL = u
+	} else {
+		atid, err := syntax.ParseAtIdentifier(identifier)
+		if err != nil {
+			return "", fmt.Errorf("not a valid account identifier or server URL: %w", err)
+		}
+		ident, err := app.Dir.Lookup(ctx, *atid)
+		if err != nil {
+			return "", fmt.Errorf("resolving account identity: %w", err)
+		}
+		host := ident.PDSEndpoint()
+		if host == "" {
+			return "", fmt.Errorf("account has no PDS registered: %s", ident.DID)
+		}
+		authServerURL, err = app.ResolveAuthServerURL(ctx, host)
+		if err != nil {
+			return "", err
+		}
+		accountDID = &ident.DID
+		loginHint = identifier
+	}
+
+	meta, err := app.ResolveAuthServerMetadata(ctx, authServerURL)
+	if err != nil {
+		return "", err
+	}
+
+	dpopKey, err := crypto.GeneratePrivateKeyP256()
+	if err != nil {
+		return "", err
+	}
+	verifier, challenge := newPKCE()
+	state := randomToken(16)
+
+	form := url.Values{}
+	form.Set("response_type", "code")
+	form.Set("code_challenge", challenge)
+	form.Set("code_challenge_method", "S256")
+	form.Set("state", state)
+	form.Set("redirect_uri", app.Config.RedirectURI)
+	form.Set("scope", strings.Join(app.Config.Scopes, " "))
+	if loginHint != "" {
+		form.Set("login_hint", loginHint)
+	}
+
+	var nonce string
+	var par parResponse
+	if err := app.authServerPost(ctx, meta.PushedAuthorizationRequestEndpoint, meta.Issuer, form, dpopKey, &nonce, &par); err != nil {
+		return "", fmt.Errorf("pushed authorization request failed: %w", err)
+	}
+	if par.RequestURI == "" {
+		return "", fmt.Errorf("PAR response missing request_uri")
+	}
+
+	authReq := AuthRequestData{
+		State:                   state,
+		AuthServerURL:           meta.Issuer,
+		AccountDID:              accountDID,
+		TokenEndpoint:           meta.TokenEndpoint,
+		PKCEVerifier:            verifier,
+		DPoPPrivateKeyMultibase: dpopKey.Multibase(),
+		DPoPAuthServerNonce:     nonce,
+		CreatedAt:               time.Now(),
+	}
+	if err := app.Store.SaveAuthRequest(ctx, &authReq); err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("client_id", app.Config.ClientID)
+	params.Set("request_uri", par.RequestURI)
+	return meta.AuthorizationEndpoint + "?" + params.Encode(), nil
+}
+
+// Completes an authorization flow, using the query parameters which the authorization server included when redirecting back to the client.
+//
+// On success, the new session is persisted to the store.
+func (app *ClientApp) ProcessCallback(ctx context.Context, params url.Values) (*ClientSession, error) {
+	state := params.Get("state")
+	if state == "" {
+		return nil, fmt.Errorf("OAuth callback missing state parameter")
+	}
+	authReq, err := app.Store.GetAuthRequest(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	// auth requests can only be used once
+	if err := app.Store.DeleteAuthRequest(ctx, state); err != nil {
+		return nil, err
+	}
+
+	if e := params.Get("error"); e != "" {
+		return nil, &AuthServerError{ErrorCode: e, Description: params.Get("error_description")}
+	}
+	if params.Get("iss") != authReq.AuthServerURL {
+		return nil, fmt.Errorf("OAuth callback issuer mismatch: %s", params.Get("iss"))
+	}
+	code := params.Get("code")
+	if code == "" {
+		return nil, fmt.Errorf("OAuth callback missing code parameter")
+	}
+
+	dpopKey, err := crypto.ParsePrivateMultibase(authReq.DPoPPrivateKeyMultibase)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", app.Config.RedirectURI)
+	form.Set("code_verifier", authReq.PKCEVerifier)
+
+	nonce := authReq.DPoPAuthServerNonce
+	var tok TokenResponse
+	if err := app.authServerPost(ctx, authReq.TokenEndpoint, authReq.AuthServerURL, form, dpopKey, &nonce, &tok); err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	if err := checkTokenResponse(&tok); err != nil {
+		return nil, err
+	}
+
+	did, err := syntax.ParseDID(tok.Sub)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token subject: %w", err)
+	}
+	if authReq.AccountDID != nil && *authReq.AccountDID != did {
+		return nil, fmt.Errorf("token subject did not match requested account: %s", did)
+	}
+
+	// verify that the authorization server is actually authoritative for this account
+	ident, err := app.Dir.LookupDID(ctx, did)
+	if err != nil {
+		return nil, fmt.Errorf("resolving account identity: %w", err)
+	}
+	host := ident.PDSEndpoint()
+	if host == "" {
+		return nil, fmt.Errorf("account has no PDS registered: %s", did)
+	}
+	if authReq.AccountDID == nil {
+		hostAuthServer, err := app.ResolveAuthServerURL(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if hostAuthServer != authReq.AuthServerURL {
+			return nil, fmt.Errorf("authorization server is not authoritative for account: %s", did)
+		}
+	}
+
+	sess := SessionData{
+		AccountDID:              did,
+		HostURL:                 host,
+		AuthServerURL:           authReq.AuthServerURL,
+		TokenEndpoint:           authReq.TokenEndpoint,
+		Scopes:                  strings.Split(tok.Scope, " "),
+		AccessToken:             tok.AccessToken,
+		RefreshToken:            tok.RefreshToken,
+		ExpiresAt:               tokenExpiry(&tok),
+		DPoPPrivateKeyMultibase: authReq.DPoPPrivateKeyMultibase,
+		DPoPAuthServerNonce:     nonce,
+	}
+	if err := app.Store.SaveSession(ctx, &sess); err != nil {
+		return nil, err
+	}
+	return app.newClientSession(&sess, dpopKey), nil
+}
+
+func checkTokenResponse(tok *TokenResponse) error {
+	if tok.AccessToken == "" {
+		return fmt.Errorf("token response missing access token")
+	}
+	if !strings.EqualFold(tok.TokenType, "DPoP") {
+		return fmt.Errorf("unexpected token type: %s", tok.TokenType)
+	}
+	if !contains(strings.Split(tok.Scope, " "), "atproto") {
+		return fmt.Errorf("token response missing 'atproto' scope")
+	}
+	return nil
+}
+
+func tokenExpiry(tok *TokenResponse) time.Time {
+	if tok.ExpiresIn <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+}
+
+// Loads a previously authenticated session from the store.
+func (app *ClientApp) ResumeSession(ctx context.Context, did syntax.DID) (*ClientSession, error) {
+	sess, err := app.Store.GetSession(ctx, did)
+	if err != nil {
+		return nil, err
+	}
+	dpopKey, err := crypto.ParsePrivateMultibase(sess.DPoPPrivateKeyMultibase)
+	if err != nil {
+		return nil, err
+	}
+	return app.newClientSession(sess, dpopKey), nil
+}
+
+// Removes a session from the store. Tokens are not revoked with the authorization server.
+func (app *ClientApp) Logout(ctx context.Context, did syntax.DID) error {
+	err := app.Store.DeleteSession(ctx, did)
+	if errors.Is(err, ErrSessionNotFound) {
+		return nil
+	}
+	return err
+}
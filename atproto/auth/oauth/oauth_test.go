@@ -0,0 +1,342 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bluesky-social/indigo/atproto/crypto"
+	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// parses and verifies a DPoP proof JWT, returning the claims
+func verifyDPoPProof(proof, method string) (map[string]any, error) {
+	parts := strings.Split(proof, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid JWT")
+	}
+	hb, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	var header struct {
+		Typ string `json:"typ"`
+		Alg string `json:"alg"`
+		JWK JWK    `json:"jwk"`
+	}
+	if err := json.Unmarshal(hb, &header); err != nil {
+		return nil, err
+	}
+	if header.Typ != "dpop+jwt" || header.Alg != "ES256" {
+		return nil, fmt.Errorf("unexpected DPoP header")
+	}
+	x, _ := base64.RawURLEncoding.DecodeString(header.JWK.X)
+	y, _ := base64.RawURLEncoding.DecodeString(header.JWK.Y)
+	pub, err := crypto.ParsePublicUncompressedBytesP256(append(append([]byte{0x04}, x...), y...))
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	if err := pub.HashAndVerify([]byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+	cb, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(cb, &claims); err != nil {
+		return nil, err
+	}
+	if claims["htm"] != method {
+		return nil, fmt.Errorf("DPoP method mismatch")
+	}
+	return claims, nil
+}
+
+// minimal stand-in for a combined PDS and authorization server
+type testServer struct {
+	srv *httptest.Server
+	did syntax.DID
+
+	lk            sync.Mutex
+	authNonce     string
+	hostNonce     string
+	pkceChallenge string
+	accessToken   string
+	refreshToken  string
+	refreshCount  int
+	expireAccess  bool
+}
+
+func (ts *testServer) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (ts *testServer) checkAuthServerDPoP(w http.ResponseWriter, r *http.Request) bool {
+	claims, err := verifyDPoPProof(r.Header.Get("DPoP"), "POST")
+	if err != nil {
+		ts.writeJSON(w, 400, map[string]string{"error": "invalid_dpop_proof"})
+		return false
+	}
+	w.Header().Set("DPoP-Nonce", ts.authNonce)
+	if claims["nonce"] != ts.authNonce {
+		ts.writeJSON(w, 400, map[string]string{"error": "use_dpop_nonce"})
+		return false
+	}
+	return true
+}
+
+func (ts *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ts.lk.Lock()
+	defer ts.lk.Unlock()
+	base := ts.srv.URL
+
+	switch r.URL.Path {
+	case "/.well-known/oauth-protected-resource":
+		ts.writeJSON(w, 200, ProtectedResourceMetadata{
+			Resource:             base,
+			AuthorizationServers: []string{base},
+		})
+	case "/.well-known/oauth-authorization-server":
+		ts.writeJSON(w, 200, AuthServerMetadata{
+			Issuer:                                     base,
+			AuthorizationEndpoint:                      base + "/oauth/authorize",
+			TokenEndpoint:                              base + "/oauth/token",
+			PushedAuthorizationRequestEndpoint:         base + "/oauth/par",
+			RequirePushedAuthorizationRequests:         true,
+			ResponseTypesSupported:                     []string{"code"},
+			GrantTypesSupported:                        []string{"authorization_code", "refresh_token"},
+			CodeChallengeMethodsSupported:              []string{"S256"},
+			TokenEndpointAuthMethodsSupported:          []string{"none", "private_key_jwt"},
+			ScopesSupported:                            []string{"atproto", "transition:generic"},
+			DPoPSigningAlgValuesSupported:              []string{"ES256"},
+			AuthorizationResponseIssParameterSupported: true,
+			ClientIDMetadataDocumentSupported:          true,
+		})
+	case "/oauth/par":
+		if !ts.checkAuthServerDPoP(w, r) {
+			return
+		}
+		_ = r.ParseForm()
+		if r.Form.Get("code_challenge_method") != "S256" || r.Form.Get("client_id") == "" {
+			ts.writeJSON(w, 400, map[string]string{"error": "invalid_request"})
+			return
+		}
+		ts.pkceChallenge = r.Form.Get("code_challenge")
+		ts.writeJSON(w, 201, parResponse{RequestURI: "urn:ietf:params:oauth:request_uri:test", ExpiresIn: 60})
+	case "/oauth/token":
+		if !ts.checkAuthServerDPoP(w, r) {
+			return
+		}
+		_ = r.ParseForm()
+		switch r.Form.Get("grant_type") {
+		case "authorization_code":
+			h := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+			if base64.RawURLEncoding.EncodeToString(h[:]) != ts.pkceChallenge || r.Form.Get("code") != "test-code" {
+				ts.writeJSON(w, 400, map[string]string{"error": "invalid_grant"})
+				return
+			}
+		case "refresh_token":
+			if r.Form.Get("refresh_token") != ts.refreshToken {
+				ts.writeJSON(w, 400, map[string]string{"error": "invalid_grant"})
+				return
+			}
+			ts.refreshCount++
+		default:
+			ts.writeJSON(w, 400, map[string]string{"error": "unsupported_grant_type"})
+			return
+		}
+		ts.accessToken = fmt.Sprintf("access-%d", ts.refreshCount)
+		ts.refreshToken = fmt.Sprintf("refresh-%d", ts.refreshCount)
+		ts.expireAccess = false
+		ts.writeJSON(w, 200, TokenResponse{
+			AccessToken:  ts.accessToken,
+			TokenType:    "DPoP",
+			RefreshToken: ts.refreshToken,
+			ExpiresIn:    3600,
+			Scope:        "atproto transition:generic",
+			Sub:          ts.did.String(),
+		})
+	case "/xrpc/com.example.ping":
+		claims, err := verifyDPoPProof(r.Header.Get("DPoP"), "GET")
+		if err != nil || r.Header.Get("Authorization") != "DPoP "+ts.accessToken {
+			ts.writeJSON(w, 401, map[string]string{"error": "AuthenticationRequired"})
+			return
+		}
+		w.Header().Set("DPoP-Nonce", ts.hostNonce)
+		if claims["nonce"] != ts.hostNonce {
+			w.Header().Set("WWW-Authenticate", `DPoP error="use_dpop_nonce"`)
+			ts.writeJSON(w, 401, map[string]string{"error": "use_dpop_nonce"})
+			return
+		}
+		if ts.expireAccess {
+			w.Header().Set("WWW-Authenticate", `DPoP error="invalid_token"`)
+			ts.writeJSON(w, 401, map[string]string{"error": "invalid_token"})
+			return
+		}
+		ts.writeJSON(w, 200, map[string]string{"did": ts.did.String()})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestOAuthFlow(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	ts := &testServer{
+		did:       syntax.DID("did:plc:abc111"),
+		authNonce: "auth-nonce-1",
+		hostNonce: "host-nonce-1",
+	}
+	ts.srv = httptest.NewServer(ts)
+	defer ts.srv.Close()
+
+	dir := identity.NewMockDirectory()
+	dir.Insert(identity.Identity{
+		DID:    ts.did,
+		Handle: syntax.Handle("alice.example.com"),
+		Services: map[string]identity.Service{
+			"atproto_pds": {
+				Type: "AtprotoPersonalDataServer",
+				URL:  ts.srv.URL,
+			},
+		},
+	})
+
+	config := NewLocalhostConfig("http://127.0.0.1/callback", []string{"atproto", "transition:generic"})
+	config.AllowHTTP = true
+	store := NewMemStore()
+	app := NewClientApp(&config, store)
+	app.Dir = &dir
+
+	redirect, err := app.StartAuthFlow(ctx, "alice.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(redirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal("/oauth/authorize", u.Path)
+	assert.Equal("urn:ietf:params:oauth:request_uri:test", u.Query().Get("request_uri"))
+	assert.Equal(1, len(store.requests))
+
+	var state string
+	for k := range store.requests {
+		state = k
+	}
+
+	// wrong issuer is rejected (and consumes the request)
+	params := url.Values{}
+	params.Set("state", state)
+	params.Set("iss", "https://evil.example.com")
+	params.Set("code", "test-code")
+	_, err = app.ProcessCallback(ctx, params)
+	assert.Error(err)
+	_, err = app.ProcessCallback(ctx, params)
+	assert.ErrorIs(err, ErrAuthRequestNotFound)
+
+	// start over, and complete the flow
+	_, err = app.StartAuthFlow(ctx, ts.did.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k := range store.requests {
+		state = k
+	}
+	params.Set("state", state)
+	params.Set("iss", ts.srv.URL)
+	sess, err := app.ProcessCallback(ctx, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(ts.did, sess.Data().AccountDID)
+	assert.Equal("access-0", sess.Data().AccessToken)
+
+	// first request needs a nonce update, which is handled transparently
+	var out map[string]string
+	client := sess.APIClient()
+	assert.NoError(client.Do(ctx, 0, "", "com.example.ping", nil, nil, &out))
+	assert.Equal(ts.did.String(), out["did"])
+	assert.Equal("host-nonce-1", sess.Data().DPoPHostNonce)
+
+	// expired access token is refreshed, with a rotated auth server nonce
+	ts.lk.Lock()
+	ts.expireAccess = true
+	ts.authNonce = "auth-nonce-2"
+	ts.lk.Unlock()
+	assert.NoError(client.Do(ctx, 0, "", "com.example.ping", nil, nil, &out))
+	assert.Equal("access-1", sess.Data().AccessToken)
+
+	// session can be resumed from the store
+	resumed, err := app.ResumeSession(ctx, ts.did)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal("refresh-1", resumed.Data().RefreshToken)
+	assert.NoError(resumed.APIClient().Do(ctx, 0, "", "com.example.ping", nil, nil, &out))
+
+	assert.NoError(app.Logout(ctx, ts.did))
+	_, err = app.ResumeSession(ctx, ts.did)
+	assert.ErrorIs(err, ErrSessionNotFound)
+}
+
+func TestClientMetadata(t *testing.T) {
+	assert := assert.New(t)
+
+	config := NewPublicConfig("https://app.example.com/client-metadata.json", "https://app.example.com/callback", []string{"atproto"})
+	meta, err := config.ClientMetadata()
+	assert.NoError(err)
+	assert.Equal("none", meta.TokenEndpointAuthMethod)
+	assert.Nil(meta.JWKS)
+
+	key, err := crypto.GeneratePrivateKeyP256()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ClientSecretKey = key
+	config.ClientSecretKeyID = "key1"
+	meta, err = config.ClientMetadata()
+	assert.NoError(err)
+	assert.Equal("private_key_jwt", meta.TokenEndpointAuthMethod)
+	assert.Equal("ES256", meta.TokenEndpointAuthSigningAlg)
+	assert.Equal("key1", meta.JWKS.Keys[0].KeyID)
+}
+
+func TestDPoPProof(t *testing.T) {
+	assert := assert.New(t)
+
+	key, err := crypto.GeneratePrivateKeyP256()
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := NewDPoPProof(key, "GET", "https://pds.example.com/xrpc/com.example.ping?a=b", "nonce", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := verifyDPoPProof(proof, "GET")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal("https://pds.example.com/xrpc/com.example.ping", claims["htu"])
+	assert.Equal("nonce", claims["nonce"])
+	assert.NotEmpty(claims["ath"])
+}
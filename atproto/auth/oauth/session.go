@@ -0,0 +1,170 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/crypto"
+	"github.com/bluesky-social/indigo/xrpc"
+)
+
+// Access tokens are refreshed proactively if they expire within this duration.
+var tokenRefreshMargin = 30 * time.Second
+
+// An authenticated OAuth session, which can sign requests to the account's PDS.
+//
+// Implements [xrpc.RequestSigner]: DPoP nonces are updated automatically, and tokens are refreshed when they expire. Any updates are persisted to the client app's store.
+type ClientSession struct {
+	app     *ClientApp
+	dpopKey crypto.PrivateKey
+
+	lk   sync.Mutex
+	data SessionData
+}
+
+var _ xrpc.RequestSigner = (*ClientSession)(nil)
+
+func (app *ClientApp) newClientSession(data *SessionData, dpopKey crypto.PrivateKey) *ClientSession {
+	return &ClientSession{
+		app:     app,
+		dpopKey: dpopKey,
+		data:    *data,
+	}
+}
+
+// Returns a copy of the current session data.
+func (s *ClientSession) Data() SessionData {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return s.data
+}
+
+// Returns an [xrpc.Client] which sends DPoP-authenticated requests to the account's PDS.
+func (s *ClientSession) APIClient() *xrpc.Client {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	c := xrpc.Client{
+		Client: s.app.Client,
+		Host:   s.data.HostURL,
+		Signer: s,
+	}
+	if s.app.Config.UserAgent != "" {
+		ua := s.app.Config.UserAgent
+		c.UserAgent = &ua
+	}
+	return &c
+}
+
+func (s *ClientSession) persist(ctx context.Context) {
+	if err := s.app.Store.SaveSession(ctx, &s.data); err != nil {
+		slog.Error("failed to persist OAuth session", "did", s.data.AccountDID, "err", err)
+	}
+}
+
+// Adds DPoP proof and access token headers to a request. If the access token has expired, it is refreshed first.
+func (s *ClientSession) SignRequest(req *http.Request) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	if !s.data.ExpiresAt.IsZero() && time.Until(s.data.ExpiresAt) < tokenRefreshMargin && s.data.RefreshToken != "" {
+		if err := s.refreshLocked(req.Context()); err != nil {
+			return err
+		}
+	}
+
+	proof, err := NewDPoPProof(s.dpopKey, req.Method, req.URL.String(), s.data.DPoPHostNonce, s.data.AccessToken)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "DPoP "+s.data.AccessToken)
+	req.Header.Set("DPoP", proof)
+	return nil
+}
+
+// Parses the "error" parameter from a 'WWW-Authenticate: DPoP ...' response header.
+func authenticateError(resp *http.Response) string {
+	for _, h := range resp.Header.Values("WWW-Authenticate") {
+		if !strings.HasPrefix(strings.ToLower(h), "dpop") {
+			continue
+		}
+		idx := strings.Index(h, `error="`)
+		if idx < 0 {
+			continue
+		}
+		rest := h[idx+len(`error="`):]
+		if end := strings.Index(rest, `"`); end >= 0 {
+			return rest[:end]
+		}
+	}
+	return ""
+}
+
+// Handles DPoP nonce updates and expired tokens. Returns true if the request should be re-signed and sent again.
+func (s *ClientSession) ShouldRetry(req *http.Request, resp *http.Response) bool {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	ctx := req.Context()
+	if n := resp.Header.Get("DPoP-Nonce"); n != "" && n != s.data.DPoPHostNonce {
+		s.data.DPoPHostNonce = n
+		s.persist(ctx)
+	}
+	if resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	switch authenticateError(resp) {
+	case "use_dpop_nonce":
+		return true
+	case "invalid_token":
+		if s.data.RefreshToken == "" {
+			return false
+		}
+		if err := s.refreshLocked(ctx); err != nil {
+			slog.Warn("failed to refresh OAuth session", "did", s.data.AccountDID, "err", err)
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// Fetches new access and refresh tokens from the authorization server, and persists the updated session.
+func (s *ClientSession) RefreshTokens(ctx context.Context) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return s.refreshLocked(ctx)
+}
+
+func (s *ClientSession) refreshLocked(ctx context.Context) error {
+	if s.data.RefreshToken == "" {
+		return fmt.Errorf("OAuth session has no refresh token")
+	}
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", s.data.RefreshToken)
+
+	var tok TokenResponse
+	if err := s.app.authServerPost(ctx, s.data.TokenEndpoint, s.data.AuthServerURL, form, s.dpopKey, &s.data.DPoPAuthServerNonce, &tok); err != nil {
+		return fmt.Errorf("token refresh failed: %w", err)
+	}
+	if err := checkTokenResponse(&tok); err != nil {
+		return err
+	}
+	if tok.Sub != s.data.AccountDID.String() {
+		return fmt.Errorf("refreshed token subject did not match session: %s", tok.Sub)
+	}
+	s.data.AccessToken = tok.AccessToken
+	if tok.RefreshToken != "" {
+		s.data.RefreshToken = tok.RefreshToken
+	}
+	s.data.Scopes = strings.Split(tok.Scope, " ")
+	s.data.ExpiresAt = tokenExpiry(&tok)
+	s.persist(ctx)
+	return nil
+}
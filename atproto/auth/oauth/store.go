@@ -0,0 +1,143 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+)
+
+var ErrAuthRequestNotFound = errors.New("OAuth auth request not found")
+var ErrSessionNotFound = errors.New("OAuth session not found")
+
+// State for an in-progress authorization flow, persisted between [ClientApp.StartAuthFlow] and [ClientApp.ProcessCallback].
+type AuthRequestData struct {
+	// Random "state" value, which is also the lookup key
+	State string `json:"state"`
+
+	// Authorization server issuer URL
+	AuthServerURL string `json:"authServerUrl"`
+
+	// Account DID, if known at the start of the flow (eg, login with handle)
+	AccountDID *syntax.DID `json:"accountDid,omitempty"`
+
+	// Token endpoint URL
+	TokenEndpoint string `json:"tokenEndpoint"`
+
+	PKCEVerifier string `json:"pkceVerifier"`
+
+	// Multibase-encoded private key used for DPoP with the authorization server
+	DPoPPrivateKeyMultibase string `json:"dpopPrivateKeyMultibase"`
+
+	// Most recent DPoP nonce from the authorization server
+	DPoPAuthServerNonce string `json:"dpopAuthServerNonce"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Persisted state for an authenticated OAuth session.
+type SessionData struct {
+	AccountDID syntax.DID `json:"accountDid"`
+
+	// URL of the account's PDS (resource server)
+	HostURL string `json:"hostUrl"`
+
+	// Authorization server issuer URL
+	AuthServerURL string `json:"authServerUrl"`
+
+	// Token endpoint URL, for refresh requests
+	TokenEndpoint string `json:"tokenEndpoint"`
+
+	Scopes       []string  `json:"scopes"`
+	AccessToken  string    `json:"accessToken"`
+	RefreshToken string    `json:"refreshToken"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+
+	// Multibase-encoded private key which tokens are bound to with DPoP
+	DPoPPrivateKeyMultibase string `json:"dpopPrivateKeyMultibase"`
+
+	// Most recent DPoP nonces from the authorization server and the PDS
+	DPoPAuthServerNonce string `json:"dpopAuthServerNonce"`
+	DPoPHostNonce       string `json:"dpopHostNonce"`
+}
+
+// Interface for persisting OAuth client state. Implementations must be safe for concurrent use.
+type Store interface {
+	SaveAuthRequest(ctx context.Context, req *AuthRequestData) error
+	// Returns [ErrAuthRequestNotFound] if there is no matching request.
+	GetAuthRequest(ctx context.Context, state string) (*AuthRequestData, error)
+	DeleteAuthRequest(ctx context.Context, state string) error
+
+	SaveSession(ctx context.Context, sess *SessionData) error
+	// Returns [ErrSessionNotFound] if there is no matching session.
+	GetSession(ctx context.Context, did syntax.DID) (*SessionData, error)
+	DeleteSession(ctx context.Context, did syntax.DID) error
+}
+
+// Simple in-memory implementation of [Store]. All state is lost on restart, so this is mostly useful for testing and CLI tools.
+type MemStore struct {
+	lk       sync.Mutex
+	requests map[string]AuthRequestData
+	sessions map[syntax.DID]SessionData
+}
+
+var _ Store = (*MemStore)(nil)
+
+func NewMemStore() *MemStore {
+	return &MemStore{
+		requests: make(map[string]AuthRequestData),
+		sessions: make(map[syntax.DID]SessionData),
+	}
+}
+
+func (s *MemStore) SaveAuthRequest(ctx context.Context, req *AuthRequestData) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.requests[req.State] = *req
+	return nil
+}
+
+func (s *MemStore) GetAuthRequest(ctx context.Context, state string) (*AuthRequestData, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	req, ok := s.requests[state]
+	if !ok {
+		return nil, ErrAuthRequestNotFound
+	}
+	return &req, nil
+}
+
+func (s *MemStore) DeleteAuthRequest(ctx context.Context, state string) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	delete(s.requests, state)
+	return nil
+}
+
+func (s *MemStore) SaveSession(ctx context.Context, sess *SessionData) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	cp := *sess
+	cp.Scopes = append([]string{}, sess.Scopes...)
+	s.sessions[sess.AccountDID] = cp
+	return nil
+}
+
+func (s *MemStore) GetSession(ctx context.Context, did syntax.DID) (*SessionData, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	sess, ok := s.sessions[did]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return &sess, nil
+}
+
+func (s *MemStore) DeleteSession(ctx context.Context, did syntax.DID) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	delete(s.sessions, did)
+	return nil
+}
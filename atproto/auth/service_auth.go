@@ -0,0 +1,250 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/crypto"
+	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+)
+
+var (
+	// Token was not a well-formed JWT, or had unexpected header fields
+	ErrMalformedToken = errors.New("malformed service auth JWT")
+	// Token signature did not verify against the issuer's current public key
+	ErrBadSignature = errors.New("service auth JWT signature invalid")
+	// Token "exp" is in the past
+	ErrTokenExpired = errors.New("service auth JWT expired")
+	// Token "aud" did not match the expected service
+	ErrBadAudience = errors.New("service auth JWT audience mismatch")
+	// Token "lxm" did not match the endpoint being called
+	ErrBadLexMethod = errors.New("service auth JWT method binding mismatch")
+)
+
+// Parsed and verified claims from an inter-service auth token.
+type ServiceAuthClaims struct {
+	// Account (or service) DID which signed the token
+	Issuer syntax.DID
+	// Optional service identifier from the "iss" claim (the part after '#'), eg "atproto_labeler"
+	IssuerService string
+	Audience      string
+	IssuedAt      time.Time
+	ExpiresAt     time.Time
+	// Lexicon method (endpoint NSID) which the token is bound to, if any
+	LexMethod *syntax.NSID
+	// Unique token identifier, if included
+	Nonce string
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+type serviceAuthPayload struct {
+	Iss string `json:"iss"`
+	Aud string `json:"aud"`
+	Exp int64  `json:"exp"`
+	Iat int64  `json:"iat,omitempty"`
+	Lxm string `json:"lxm,omitempty"`
+	Jti string `json:"jti,omitempty"`
+}
+
+// Verifies atproto inter-service auth tokens: JWTs signed with the issuer's atproto signing key (ES256K or ES256), as resolved from their DID document.
+type ServiceAuthValidator struct {
+	// DID (with optional service fragment) which tokens must be addressed to. Required.
+	Audience string
+	Dir      identity.Directory
+	// How much clock skew to tolerate when checking token expiration
+	TimestampLeeway time.Duration
+	// If true, tokens without an "lxm" claim are accepted for any endpoint. By default they are rejected whenever an endpoint method is being checked. Older implementations may not include the claim.
+	AllowMissingLexMethod bool
+}
+
+// Returns the JWS "alg" value for an atproto public key type.
+func keyAlgorithm(k crypto.PublicKey) string {
+	switch k.(type) {
+	case *crypto.PublicKeyK256:
+		return "ES256K"
+	case *crypto.PublicKeyP256:
+		return "ES256"
+	default:
+		return ""
+	}
+}
+
+// Determines which DID document key is used to sign tokens for a given issuer service fragment.
+func issuerKeyID(service string) string {
+	if service == "atproto_labeler" {
+		return "atproto_label"
+	}
+	return "atproto"
+}
+
+// Parses and fully verifies a service auth token.
+//
+// 'lexMethod' is the NSID of the endpoint being called. If it is not nil, then the token must have a matching "lxm" claim (a missing claim is only accepted if AllowMissingLexMethod is set). If the token's signature does not verify, the issuer's identity is purged from the directory cache and the check is retried once, in case the key has been rotated recently.
+func (v *ServiceAuthValidator) Validate(ctx context.Context, token string, lexMethod *syntax.NSID) (*ServiceAuthClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedToken, err)
+	}
+	// reject other atproto token types which might be signed with the same key
+	switch header.Typ {
+	case "at+jwt", "refresh+jwt", "dpop+jwt":
+		return nil, fmt.Errorf("%w: unexpected token type: %s", ErrMalformedToken, header.Typ)
+	}
+	var payload serviceAuthPayload
+	if err := decodeSegment(parts[1], &payload); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedToken, err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedToken, err)
+	}
+
+	claims, err := parseClaims(&payload)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if now.After(claims.ExpiresAt.Add(v.TimestampLeeway)) {
+		return nil, ErrTokenExpired
+	}
+	if v.Audience == "" || claims.Audience != v.Audience {
+		return nil, fmt.Errorf("%w: %s", ErrBadAudience, claims.Audience)
+	}
+	if lexMethod != nil {
+		if claims.LexMethod == nil {
+			if !v.AllowMissingLexMethod {
+				return nil, fmt.Errorf("%w: missing lxm claim", ErrBadLexMethod)
+			}
+		} else if *claims.LexMethod != *lexMethod {
+			return nil, fmt.Errorf("%w: %s", ErrBadLexMethod, claims.LexMethod)
+		}
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	keyID := issuerKeyID(claims.IssuerService)
+	err = v.verifySignature(ctx, claims.Issuer, keyID, header.Alg, signed, sig)
+	if errors.Is(err, ErrBadSignature) {
+		// key may have been rotated; try again with fresh identity data
+		if perr := v.Dir.Purge(ctx, claims.Issuer.AtIdentifier()); perr != nil {
+			return nil, perr
+		}
+		err = v.verifySignature(ctx, claims.Issuer, keyID, header.Alg, signed, sig)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *ServiceAuthValidator) verifySignature(ctx context.Context, did syntax.DID, keyID, alg string, signed, sig []byte) error {
+	ident, err := v.Dir.LookupDID(ctx, did)
+	if err != nil {
+		return fmt.Errorf("resolving service auth issuer: %w", err)
+	}
+	pub, err := ident.GetPublicKey(keyID)
+	if err != nil {
+		return fmt.Errorf("service auth issuer signing key: %w", err)
+	}
+	if keyAlgorithm(pub) != alg {
+		return fmt.Errorf("%w: algorithm did not match issuer key type: %s", ErrBadSignature, alg)
+	}
+	// JWT signatures are not required to be "low-S"
+	if err := pub.HashAndVerifyLenient(signed, sig); err != nil {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+func decodeSegment(seg string, out any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
+func parseClaims(p *serviceAuthPayload) (*ServiceAuthClaims, error) {
+	iss, svc, _ := strings.Cut(p.Iss, "#")
+	did, err := syntax.ParseDID(iss)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid issuer: %w", ErrMalformedToken, err)
+	}
+	if p.Aud == "" {
+		return nil, fmt.Errorf("%w: missing audience", ErrMalformedToken)
+	}
+	if p.Exp == 0 {
+		return nil, fmt.Errorf("%w: missing expiration", ErrMalformedToken)
+	}
+	claims := ServiceAuthClaims{
+		Issuer:        did,
+		IssuerService: svc,
+		Audience:      p.Aud,
+		ExpiresAt:     time.Unix(p.Exp, 0),
+		Nonce:         p.Jti,
+	}
+	if p.Iat != 0 {
+		claims.IssuedAt = time.Unix(p.Iat, 0)
+	}
+	if p.Lxm != "" {
+		nsid, err := syntax.ParseNSID(p.Lxm)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid lxm: %w", ErrMalformedToken, err)
+		}
+		claims.LexMethod = &nsid
+	}
+	return &claims, nil
+}
+
+// Creates a signed service auth token. This is mostly useful for services making requests on their own behalf, and for tests.
+//
+// 'lexMethod' is optional; if not nil, the token is bound to that endpoint.
+func SignServiceAuth(iss syntax.DID, aud string, ttl time.Duration, lexMethod *syntax.NSID, priv crypto.PrivateKey) (string, error) {
+	pub, err := priv.PublicKey()
+	if err != nil {
+		return "", err
+	}
+	header := jwtHeader{Alg: keyAlgorithm(pub), Typ: "JWT"}
+	if header.Alg == "" {
+		return "", fmt.Errorf("unsupported key type for service auth")
+	}
+	now := time.Now()
+	payload := serviceAuthPayload{
+		Iss: iss.String(),
+		Aud: aud,
+		Iat: now.Unix(),
+		Exp: now.Add(ttl).Unix(),
+		Jti: randomNonce(),
+	}
+	if lexMethod != nil {
+		payload.Lxm = lexMethod.String()
+	}
+	hb, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	pb, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(hb) + "." + base64.RawURLEncoding.EncodeToString(pb)
+	sig, err := priv.HashAndSign([]byte(signed))
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/crypto"
+	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// wraps a mock directory, swapping in updated identities when purged (like a cache would)
+type rotatingDirectory struct {
+	identity.MockDirectory
+	next   map[syntax.DID]identity.Identity
+	purges int
+}
+
+func (d *rotatingDirectory) Purge(ctx context.Context, a syntax.AtIdentifier) error {
+	d.purges++
+	did, err := a.AsDID()
+	if err != nil {
+		return nil
+	}
+	if ident, ok := d.next[did]; ok {
+		d.Insert(ident)
+	}
+	return nil
+}
+
+func testIdentity(t *testing.T, did syntax.DID, priv crypto.PrivateKey) identity.Identity {
+	pub, err := priv.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return identity.Identity{
+		DID:    did,
+		Handle: syntax.Handle("handle.invalid"),
+		Keys: map[string]identity.Key{
+			"atproto": {
+				Type:               "Multikey",
+				PublicKeyMultibase: pub.Multibase(),
+			},
+		},
+	}
+}
+
+func TestServiceAuthValidate(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	did := syntax.DID("did:plc:abc111")
+	aud := "did:web:svc.example.com"
+	lxm := syntax.NSID("com.example.getThing")
+	otherLxm := syntax.NSID("com.example.otherThing")
+
+	k256, err := crypto.GeneratePrivateKeyK256()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256, err := crypto.GeneratePrivateKeyP256()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := rotatingDirectory{MockDirectory: identity.NewMockDirectory()}
+	dir.Insert(testIdentity(t, did, k256))
+	v := ServiceAuthValidator{Audience: aud, Dir: &dir}
+
+	tok, err := SignServiceAuth(did, aud, time.Minute, &lxm, k256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := v.Validate(ctx, tok, &lxm)
+	assert.NoError(err)
+	assert.Equal(did, claims.Issuer)
+	assert.Equal(lxm, *claims.LexMethod)
+
+	_, err = v.Validate(ctx, tok, &otherLxm)
+	assert.ErrorIs(err, ErrBadLexMethod)
+
+	// a token without "lxm" is only accepted for a method if explicitly allowed
+	tok, err = SignServiceAuth(did, aud, time.Minute, nil, k256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = v.Validate(ctx, tok, &lxm)
+	assert.ErrorIs(err, ErrBadLexMethod)
+	_, err = v.Validate(ctx, tok, nil)
+	assert.NoError(err)
+	lenient := ServiceAuthValidator{Audience: aud, Dir: &dir, AllowMissingLexMethod: true}
+	claims, err = lenient.Validate(ctx, tok, &lxm)
+	assert.NoError(err)
+	assert.Nil(claims.LexMethod)
+
+	tok, err = SignServiceAuth(did, "did:web:other.example.com", time.Minute, nil, k256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = v.Validate(ctx, tok, &lxm)
+	assert.ErrorIs(err, ErrBadAudience)
+
+	tok, err = SignServiceAuth(did, aud, -1*time.Minute, nil, k256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = v.Validate(ctx, tok, nil)
+	assert.ErrorIs(err, ErrTokenExpired)
+
+	_, err = v.Validate(ctx, "not-a-token", nil)
+	assert.ErrorIs(err, ErrMalformedToken)
+
+	// signed with a key which isn't in the DID document (yet)
+	tok, err = SignServiceAuth(did, aud, time.Minute, nil, p256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = v.Validate(ctx, tok, nil)
+	assert.ErrorIs(err, ErrBadSignature)
+	assert.Equal(1, dir.purges)
+
+	// after key rotation, a purge picks up the new key
+	dir.next = map[syntax.DID]identity.Identity{did: testIdentity(t, did, p256)}
+	claims, err = v.Validate(ctx, tok, nil)
+	assert.NoError(err)
+	assert.Equal(did, claims.Issuer)
+	assert.Equal(2, dir.purges)
+}
+
+func TestServiceAuthMiddleware(t *testing.T) {
+	assert := assert.New(t)
+
+	did := syntax.DID("did:plc:abc222")
+	aud := "did:web:svc.example.com"
+	lxm := syntax.NSID("com.example.getThing")
+
+	priv, err := crypto.GeneratePrivateKeyK256()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := identity.NewMockDirectory()
+	dir.Insert(testIdentity(t, did, priv))
+	v := ServiceAuthValidator{Audience: aud, Dir: &dir}
+
+	tok, err := SignServiceAuth(did, aud, time.Minute, &lxm, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// net/http
+	handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := ServiceAuthDID(r.Context())
+		assert.True(ok)
+		assert.Equal(did, got)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/xrpc/com.example.getThing", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(http.StatusUnauthorized, rec.Code)
+
+	req.Header.Set("Authorization", "Bearer "+tok)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest("GET", "/xrpc/com.example.otherThing", nil)
+	req.Header.Set("Authorization", "Bearer "+tok)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(http.StatusUnauthorized, rec.Code)
+	assert.Contains(rec.Body.String(), "BadJwtLexiconMethod")
+
+	// echo
+	e := echo.New()
+	e.Use(v.EchoMiddleware())
+	e.GET("/xrpc/com.example.getThing", func(c echo.Context) error {
+		assert.Equal(did, c.Get(EchoServiceAuthDIDKey))
+		return c.String(http.StatusOK, "ok")
+	})
+	req = httptest.NewRequest("GET", "/xrpc/com.example.getThing", nil)
+	req.Header.Set("Authorization", "Bearer "+tok)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(http.StatusOK, rec.Code)
+}
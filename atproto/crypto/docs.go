@@ -8,4 +8,6 @@
 //   - K-256/secp256r1, internally implemented using https://gitlab.com/yawning/secp256k1-voi
 //
 // "Low-S" signatures are enforced for both key types, both when creating signatures and during verification, as required by the atproto specification.
+//
+// Keys held outside the process (an HSM, PKCS#11 token, cloud KMS, or remote signing service) can be used anywhere a [PrivateKey] is accepted via [ExternalPrivateKey], which applies the same signature normalization. See the remotesigner sub-package for an HTTP signing client.
 package crypto
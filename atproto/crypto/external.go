@@ -0,0 +1,136 @@
+package crypto
+
+import (
+	"context"
+	gocrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	secp256k1secec "gitlab.com/yawning/secp256k1-voi/secec"
+)
+
+// A signing backend where the secret key material is held outside of this process: a hardware security module (HSM), PKCS#11 token, cloud key management service, or remote signing API.
+type ExternalSigner interface {
+	// Signs a SHA-256 digest (32 bytes), without further hashing. The signature may be either compact (r||s) or ASN.1 DER encoded, and does not need to be "low-S".
+	SignDigest(ctx context.Context, digest []byte) ([]byte, error)
+}
+
+// Implements the [PrivateKey] interface using an [ExternalSigner]. The secret key material is never available in memory, so this does not implement [PrivateKeyExportable].
+//
+// Signatures from the backend are normalized with [NormalizeSignature], and are verified against the public key before being returned. This catches misconfiguration (eg, the wrong key ID) before a bad signature is published in a repo commit or PLC operation.
+type ExternalPrivateKey struct {
+	signer ExternalSigner
+	pub    PublicKey
+
+	// Timeout for each signing request, because [PrivateKey.HashAndSign] does not take a context. Defaults to 10 seconds.
+	Timeout time.Duration
+}
+
+var _ PrivateKey = (*ExternalPrivateKey)(nil)
+
+// The public key must be a [PublicKeyP256] or [PublicKeyK256], and must correspond to the signer's secret key.
+func NewExternalPrivateKey(signer ExternalSigner, pub PublicKey) *ExternalPrivateKey {
+	return &ExternalPrivateKey{
+		signer:  signer,
+		pub:     pub,
+		Timeout: 10 * time.Second,
+	}
+}
+
+// Adapts a Go standard library [crypto.Signer] to an [ExternalPrivateKey]. The public key is derived from the signer, and must be P-256 or secp256k1 (K-256).
+//
+// This is the integration point for PKCS#11 modules (eg, via a wrapper library which exposes token keys as a crypto.Signer) and cloud KMS clients.
+func NewSignerPrivateKey(s gocrypto.Signer) (*ExternalPrivateKey, error) {
+	pub, err := publicKeyFromGo(s.Public())
+	if err != nil {
+		return nil, err
+	}
+	return NewExternalPrivateKey(&goSigner{s}, pub), nil
+}
+
+// Returns the Go standard library [crypto.Signer] for an in-memory key: an [*ecdsa.PrivateKey] for P-256, or a secp256k1 [*secp256k1secec.PrivateKey] for K-256. This is the inverse of [NewSignerPrivateKey], for interop with libraries which take Go keys.
+//
+// Signatures from the returned signer are ASN.1 DER encoded and may be "high-S"; use [NormalizeSignature] to get the atproto form.
+func GoSigner(priv PrivateKeyExportable) (gocrypto.Signer, error) {
+	switch k := priv.(type) {
+	case *PrivateKeyP256:
+		sk := k.privP256
+		return &sk, nil
+	case *PrivateKeyK256:
+		return k.privK256, nil
+	default:
+		return nil, fmt.Errorf("crypto: unsupported private key type: %T", priv)
+	}
+}
+
+// Checks if the two private keys have the same public key.
+func (k *ExternalPrivateKey) Equal(other PrivateKey) bool {
+	otherPub, err := other.PublicKey()
+	if err != nil {
+		return false
+	}
+	return k.pub.Equal(otherPub)
+}
+
+func (k *ExternalPrivateKey) PublicKey() (PublicKey, error) {
+	return k.pub, nil
+}
+
+// Hashes the raw bytes using SHA-256, then signs the digest with the external signer. Always returns a 64 byte "low-S" signature.
+func (k *ExternalPrivateKey) HashAndSign(content []byte) ([]byte, error) {
+	timeout := k.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	hash := sha256.Sum256(content)
+	raw, err := k.signer.SignDigest(ctx, hash[:])
+	if err != nil {
+		return nil, fmt.Errorf("crypto error signing with external key: %w", err)
+	}
+	sig, err := NormalizeSignature(k.pub, raw)
+	if err != nil {
+		return nil, fmt.Errorf("crypto error signing with external key: %w", err)
+	}
+	if err := k.pub.HashAndVerify(content, sig); err != nil {
+		return nil, fmt.Errorf("crypto error signing with external key: signature does not match public key: %w", err)
+	}
+	return sig, nil
+}
+
+type goSigner struct {
+	s gocrypto.Signer
+}
+
+func (g *goSigner) SignDigest(ctx context.Context, digest []byte) ([]byte, error) {
+	return g.s.Sign(rand.Reader, digest, gocrypto.SHA256)
+}
+
+// converts a public key from a Go standard library (or secp256k1 library) type
+func publicKeyFromGo(pub gocrypto.PublicKey) (PublicKey, error) {
+	switch p := pub.(type) {
+	case *ecdsa.PublicKey:
+		if p.Curve == elliptic.P256() {
+			return &PublicKeyP256{pubP256: *p}, nil
+		}
+		// third-party curve implementations (eg, in PKCS#11 wrappers) for secp256k1
+		if p.Curve != nil && p.Curve.Params().Name == "secp256k1" {
+			b := make([]byte, 65)
+			b[0] = 0x04
+			p.X.FillBytes(b[1:33])
+			p.Y.FillBytes(b[33:])
+			return ParsePublicUncompressedBytesK256(b)
+		}
+		return nil, fmt.Errorf("crypto: unsupported ECDSA curve for external signer")
+	case *secp256k1secec.PublicKey:
+		return ParsePublicUncompressedBytesK256(p.Bytes())
+	default:
+		return nil, fmt.Errorf("crypto: unsupported public key type for external signer: %T", pub)
+	}
+}
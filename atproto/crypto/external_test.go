@@ -0,0 +1,151 @@
+package crypto
+
+import (
+	"context"
+	gocrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	secp256k1secec "gitlab.com/yawning/secp256k1-voi/secec"
+)
+
+func TestNormalizeSignature(t *testing.T) {
+	assert := assert.New(t)
+	msg := []byte("test-message")
+
+	priv, err := GeneratePrivateKeyP256()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := priv.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := priv.HashAndSign(msg)
+	assert.NoError(err)
+
+	// flip to high-S, in both compact and DER encodings
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	highS := new(big.Int).Sub(curveN_P256, s)
+	compact := make([]byte, 64)
+	r.FillBytes(compact[:32])
+	highS.FillBytes(compact[32:])
+	assert.Error(pub.HashAndVerify(msg, compact))
+	der, err := asn1.Marshal(struct{ R, S *big.Int }{r, highS})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range [][]byte{compact, der, sig} {
+		out, err := NormalizeSignature(pub, in)
+		assert.NoError(err)
+		assert.Equal(sig, out)
+		assert.NoError(pub.HashAndVerify(msg, out))
+	}
+
+	_, err = NormalizeSignature(pub, []byte("not a signature"))
+	assert.Error(err)
+	_, err = NormalizeSignature(pub, make([]byte, 64))
+	assert.Error(err)
+}
+
+func TestSignerPrivateKey(t *testing.T) {
+	assert := assert.New(t)
+	msg := []byte("test-message")
+
+	skP256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	skK256, err := secp256k1secec.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, signer := range []gocrypto.Signer{skP256, skK256} {
+		priv, err := NewSignerPrivateKey(signer)
+		assert.NoError(err)
+		pub, err := priv.PublicKey()
+		assert.NoError(err)
+		assert.True(priv.Equal(priv))
+
+		// signers return DER and may be high-S; run enough times to hit both cases
+		for range 32 {
+			sig, err := priv.HashAndSign(msg)
+			assert.NoError(err)
+			assert.Equal(64, len(sig))
+			assert.NoError(pub.HashAndVerify(msg, sig))
+		}
+	}
+
+	// a backend signing with the wrong key is caught
+	other, err := GeneratePrivateKeyK256()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, err := other.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong := NewExternalPrivateKey(&goSigner{skK256}, otherPub)
+	_, err = wrong.HashAndSign(msg)
+	assert.Error(err)
+	right, err := NewSignerPrivateKey(skK256)
+	assert.NoError(err)
+	assert.False(wrong.Equal(right))
+}
+
+func TestGoSigner(t *testing.T) {
+	assert := assert.New(t)
+
+	p256, err := GeneratePrivateKeyP256()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k256, err := GeneratePrivateKeyK256()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := GoSigner(p256)
+	assert.NoError(err)
+	_, ok := signer.(*ecdsa.PrivateKey)
+	assert.True(ok)
+	ext, err := NewSignerPrivateKey(signer)
+	assert.NoError(err)
+	assert.True(ext.Equal(p256))
+
+	signer, err = GoSigner(k256)
+	assert.NoError(err)
+	_, ok = signer.(*secp256k1secec.PrivateKey)
+	assert.True(ok)
+	ext, err = NewSignerPrivateKey(signer)
+	assert.NoError(err)
+	assert.True(ext.Equal(k256))
+}
+
+// a signer which always fails
+type failingSigner struct{}
+
+func (failingSigner) SignDigest(ctx context.Context, digest []byte) ([]byte, error) {
+	return nil, context.DeadlineExceeded
+}
+
+func TestExternalPrivateKeyError(t *testing.T) {
+	assert := assert.New(t)
+	priv, err := GeneratePrivateKeyP256()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := priv.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewExternalPrivateKey(failingSigner{}, pub).HashAndSign([]byte("msg"))
+	assert.ErrorIs(err, context.DeadlineExceeded)
+}
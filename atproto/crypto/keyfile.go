@@ -0,0 +1,225 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Key derivation functions supported for encrypted key files.
+const (
+	KDFScrypt   = "scrypt"
+	KDFArgon2id = "argon2id"
+)
+
+const keyFileVersion = 1
+const keyFileCipher = "xchacha20-poly1305"
+
+// Returned when decrypting a key file fails authentication, which almost always means the passphrase was incorrect.
+var ErrWrongPassphrase = errors.New("crypto: wrong passphrase (or corrupted key file)")
+
+// Parameters for deriving the encryption key from a passphrase. Which fields are used depends on the algorithm.
+type KeyFileKDF struct {
+	Name string `json:"name"`
+	Salt []byte `json:"salt"`
+
+	// scrypt cost parameters
+	N int `json:"n,omitempty"`
+	R int `json:"r,omitempty"`
+	P int `json:"p,omitempty"`
+
+	// argon2id cost parameters (memory in KiB)
+	Time    uint32 `json:"time,omitempty"`
+	Memory  uint32 `json:"memory,omitempty"`
+	Threads uint8  `json:"threads,omitempty"`
+}
+
+// Encrypted-at-rest private key, serialized as JSON.
+//
+// The secret key is stored as a multibase string (which includes the key type), encrypted with XChaCha20-Poly1305 using a key derived from a passphrase. The public key is included in plaintext, so the file can be identified without the passphrase; it is covered by the AEAD authentication tag, along with the KDF parameters.
+type KeyFile struct {
+	Version    int        `json:"version"`
+	PublicKey  string     `json:"publicKey"`
+	KDF        KeyFileKDF `json:"kdf"`
+	Cipher     string     `json:"cipher"`
+	Nonce      []byte     `json:"nonce"`
+	Ciphertext []byte     `json:"ciphertext,omitempty"`
+}
+
+func defaultKDF(name string) (KeyFileKDF, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return KeyFileKDF{}, err
+	}
+	switch name {
+	case "", KDFScrypt:
+		return KeyFileKDF{Name: KDFScrypt, Salt: salt, N: 1 << 17, R: 8, P: 1}, nil
+	case KDFArgon2id:
+		return KeyFileKDF{Name: KDFArgon2id, Salt: salt, Time: 3, Memory: 64 * 1024, Threads: 4}, nil
+	default:
+		return KeyFileKDF{}, fmt.Errorf("crypto: unsupported key file KDF: %s", name)
+	}
+}
+
+func (kdf *KeyFileKDF) deriveKey(passphrase []byte) ([]byte, error) {
+	if len(kdf.Salt) < 16 {
+		return nil, fmt.Errorf("crypto: key file KDF salt too short")
+	}
+	switch kdf.Name {
+	case KDFScrypt:
+		return scrypt.Key(passphrase, kdf.Salt, kdf.N, kdf.R, kdf.P, chacha20poly1305.KeySize)
+	case KDFArgon2id:
+		if kdf.Time == 0 || kdf.Memory == 0 || kdf.Threads == 0 {
+			return nil, fmt.Errorf("crypto: invalid argon2id parameters")
+		}
+		return argon2.IDKey(passphrase, kdf.Salt, kdf.Time, kdf.Memory, kdf.Threads, chacha20poly1305.KeySize), nil
+	default:
+		return nil, fmt.Errorf("crypto: unsupported key file KDF: %s", kdf.Name)
+	}
+}
+
+// authenticated data: the file with the ciphertext removed
+func (kf *KeyFile) additionalData() ([]byte, error) {
+	hdr := *kf
+	hdr.Ciphertext = nil
+	return json.Marshal(hdr)
+}
+
+// Encrypts a private key with a passphrase, returning the JSON key file contents. The KDF is [KDFScrypt] or [KDFArgon2id]; an empty string selects scrypt.
+func EncryptPrivateKey(priv PrivateKeyExportable, passphrase []byte, kdfName string) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("crypto: empty passphrase")
+	}
+	mb, ok := priv.(interface{ Multibase() string })
+	if !ok {
+		return nil, fmt.Errorf("crypto: unsupported private key type: %T", priv)
+	}
+	pub, err := priv.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	kdf, err := defaultKDF(kdfName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := kdf.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	kf := KeyFile{
+		Version:   keyFileVersion,
+		PublicKey: pub.DIDKey(),
+		KDF:       kdf,
+		Cipher:    keyFileCipher,
+		Nonce:     nonce,
+	}
+	ad, err := kf.additionalData()
+	if err != nil {
+		return nil, err
+	}
+	kf.Ciphertext = aead.Seal(nil, nonce, []byte(mb.Multibase()), ad)
+	return json.MarshalIndent(kf, "", "  ")
+}
+
+// Parses a JSON key file, without decrypting it.
+func ParseKeyFile(data []byte) (*KeyFile, error) {
+	var kf KeyFile
+	if err := json.Unmarshal(data, &kf); err != nil {
+		return nil, fmt.Errorf("crypto: not an encrypted key file: %w", err)
+	}
+	if kf.Version != keyFileVersion {
+		return nil, fmt.Errorf("crypto: unsupported key file version: %d", kf.Version)
+	}
+	if kf.Cipher != keyFileCipher {
+		return nil, fmt.Errorf("crypto: unsupported key file cipher: %s", kf.Cipher)
+	}
+	return &kf, nil
+}
+
+// Decrypts a JSON key file with a passphrase. Returns [ErrWrongPassphrase] if authentication fails.
+func DecryptPrivateKey(data []byte, passphrase []byte) (PrivateKeyExportable, error) {
+	kf, err := ParseKeyFile(data)
+	if err != nil {
+		return nil, err
+	}
+	key, err := kf.KDF.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(kf.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("crypto: invalid key file nonce")
+	}
+	ad, err := kf.additionalData()
+	if err != nil {
+		return nil, err
+	}
+	plain, err := aead.Open(nil, kf.Nonce, kf.Ciphertext, ad)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	priv, err := ParsePrivateMultibase(string(plain))
+	if err != nil {
+		return nil, err
+	}
+	pub, err := priv.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	if pub.DIDKey() != kf.PublicKey {
+		return nil, fmt.Errorf("crypto: key file public key does not match secret key")
+	}
+	return priv, nil
+}
+
+// Encrypts a private key and writes it to a file (with owner-only permissions). The file is replaced atomically, so an existing key file is never left partially written.
+func SaveEncryptedKeyFile(path string, priv PrivateKeyExportable, passphrase []byte, kdfName string) error {
+	data, err := EncryptPrivateKey(priv, passphrase, kdfName)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Reads and decrypts a key file written by [SaveEncryptedKeyFile].
+func LoadEncryptedKeyFile(path string, passphrase []byte) (PrivateKeyExportable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return DecryptPrivateKey(data, passphrase)
+}
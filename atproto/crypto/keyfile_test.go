@@ -0,0 +1,72 @@
+package crypto
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyFile(t *testing.T) {
+	assert := assert.New(t)
+	pass := []byte("correct horse battery staple")
+
+	p256, err := GeneratePrivateKeyP256()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k256, err := GeneratePrivateKeyK256()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, kdf := range []string{KDFScrypt, KDFArgon2id} {
+		for _, priv := range []PrivateKeyExportable{p256, k256} {
+			data, err := EncryptPrivateKey(priv, pass, kdf)
+			assert.NoError(err)
+
+			kf, err := ParseKeyFile(data)
+			assert.NoError(err)
+			assert.Equal(kdf, kf.KDF.Name)
+			pub, err := priv.PublicKey()
+			assert.NoError(err)
+			assert.Equal(pub.DIDKey(), kf.PublicKey)
+
+			out, err := DecryptPrivateKey(data, pass)
+			assert.NoError(err)
+			assert.True(priv.Equal(out))
+
+			_, err = DecryptPrivateKey(data, []byte("wrong"))
+			assert.ErrorIs(err, ErrWrongPassphrase)
+		}
+	}
+
+	// the plaintext header is authenticated
+	data, err := EncryptPrivateKey(p256, pass, "")
+	assert.NoError(err)
+	var kf KeyFile
+	assert.NoError(json.Unmarshal(data, &kf))
+	kf.PublicKey = k256.Multibase()
+	tampered, err := json.Marshal(kf)
+	assert.NoError(err)
+	_, err = DecryptPrivateKey(tampered, pass)
+	assert.ErrorIs(err, ErrWrongPassphrase)
+
+	_, err = EncryptPrivateKey(p256, pass, "md5")
+	assert.Error(err)
+	_, err = EncryptPrivateKey(p256, nil, "")
+	assert.Error(err)
+	_, err = DecryptPrivateKey([]byte(p256.Multibase()), pass)
+	assert.Error(err)
+
+	path := filepath.Join(t.TempDir(), "signing.key")
+	assert.NoError(SaveEncryptedKeyFile(path, k256, pass, KDFArgon2id))
+	info, err := os.Stat(path)
+	assert.NoError(err)
+	assert.Equal(os.FileMode(0600), info.Mode().Perm())
+	out, err := LoadEncryptedKeyFile(path, pass)
+	assert.NoError(err)
+	assert.True(k256.Equal(out))
+}
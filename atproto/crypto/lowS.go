@@ -0,0 +1,71 @@
+package crypto
+
+import (
+	"crypto/elliptic"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+)
+
+var curveN_P256 *big.Int = elliptic.P256().Params().N
+var curveHalfOrder_P256 *big.Int = new(big.Int).Rsh(curveN_P256, 1)
+
+// secp256k1 group order, from SEC 2 (https://www.secg.org/sec2-v2.pdf)
+var curveN_K256, _ = new(big.Int).SetString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141", 16)
+var curveHalfOrder_K256 *big.Int = new(big.Int).Rsh(curveN_K256, 1)
+
+// Checks if 'S' value from a signature on the given curve order is "low-S".
+func sigSIsLowS(s, halfOrder *big.Int) bool {
+	return s.Cmp(halfOrder) != 1
+}
+
+// Ensures that 'S' value from a signature on the given curve order is "low-S" variant.
+func sigSToLowS(s, n, halfOrder *big.Int) *big.Int {
+	if !sigSIsLowS(s, halfOrder) {
+		// Set s to N - s that will be then in the lower part of signature space
+		// less or equal to half order
+		s.Sub(n, s)
+	}
+	return s
+}
+
+// Converts an ECDSA signature from an arbitrary signer in to the form required by atproto: 64 byte "compact" (r||s) encoding, with a "low-S" value.
+//
+// The input can be either compact encoding (of any S value), or ASN.1 DER (as returned by Go's [crypto.Signer] interface, and many HSMs and key management services). The curve is determined by the type of the public key, which must be a [PublicKeyP256] or [PublicKeyK256].
+//
+// This does not verify the signature.
+func NormalizeSignature(pub PublicKey, sig []byte) ([]byte, error) {
+	var n, halfOrder *big.Int
+	switch pub.(type) {
+	case *PublicKeyP256:
+		n, halfOrder = curveN_P256, curveHalfOrder_P256
+	case *PublicKeyK256:
+		n, halfOrder = curveN_K256, curveHalfOrder_K256
+	default:
+		return nil, fmt.Errorf("crypto: unsupported public key type for signature normalization: %T", pub)
+	}
+
+	r := big.NewInt(0)
+	s := big.NewInt(0)
+	if len(sig) == 64 {
+		r.SetBytes(sig[:32])
+		s.SetBytes(sig[32:])
+	} else {
+		var der struct {
+			R, S *big.Int
+		}
+		rest, err := asn1.Unmarshal(sig, &der)
+		if err != nil || len(rest) > 0 {
+			return nil, fmt.Errorf("crypto: signature is neither compact nor ASN.1 DER encoded (len=%d)", len(sig))
+		}
+		r, s = der.R, der.S
+	}
+	if r.Sign() <= 0 || s.Sign() <= 0 || r.Cmp(n) >= 0 || s.Cmp(n) >= 0 {
+		return nil, fmt.Errorf("crypto: signature values out of range")
+	}
+	s = sigSToLowS(s, n, halfOrder)
+	out := make([]byte, 64)
+	r.FillBytes(out[:32])
+	s.FillBytes(out[32:])
+	return out, nil
+}
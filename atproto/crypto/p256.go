@@ -113,7 +113,7 @@ func (k *PrivateKeyP256) HashAndSign(content []byte) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("crypto error signing with P-256/secp256r1 private key: %w", err)
 	}
-	s = sigSToLowS_P256(s)
+	s = sigSToLowS(s, curveN_P256, curveHalfOrder_P256)
 	sig := make([]byte, 64)
 	r.FillBytes(sig[:32])
 	s.FillBytes(sig[32:])
@@ -219,7 +219,7 @@ func (k *PublicKeyP256) HashAndVerify(content, sig []byte) error {
 	}
 
 	// ensure that signature is low-S
-	if !sigSIsLowS_P256(s) {
+	if !sigSIsLowS(s, curveHalfOrder_P256) {
 		return ErrInvalidSignature
 	}
 
@@ -0,0 +1,121 @@
+package remotesigner
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/crypto"
+)
+
+type keyResponse struct {
+	KeyID     string `json:"keyId"`
+	PublicKey string `json:"publicKey"`
+}
+
+type signRequest struct {
+	Digest string `json:"digest"`
+}
+
+type signResponse struct {
+	Signature string `json:"signature"`
+}
+
+// Client for a single key on a remote signing service. Implements [crypto.ExternalSigner].
+type Client struct {
+	// Base URL of the signing service, eg "https://signer.example.com"
+	Host  string
+	KeyID string
+	// Optional bearer token for the Authorization header
+	Token      string
+	HTTPClient http.Client
+}
+
+var _ crypto.ExternalSigner = (*Client)(nil)
+
+func NewClient(host, keyID, token string) *Client {
+	return &Client{
+		Host:  host,
+		KeyID: keyID,
+		Token: token,
+		HTTPClient: http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.Host+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("constructing remote signer request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("remote signer request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("remote signer HTTP status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(out); err != nil {
+		return fmt.Errorf("remote signer response: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) keyPath() string {
+	return "/keys/" + url.PathEscape(c.KeyID)
+}
+
+// Fetches the public key for the configured key ID.
+func (c *Client) PublicKey(ctx context.Context) (crypto.PublicKey, error) {
+	var out keyResponse
+	if err := c.do(ctx, http.MethodGet, c.keyPath(), nil, &out); err != nil {
+		return nil, err
+	}
+	return crypto.ParsePublicDIDKey(out.PublicKey)
+}
+
+// Requests a signature over a SHA-256 digest.
+func (c *Client) SignDigest(ctx context.Context, digest []byte) ([]byte, error) {
+	var out signResponse
+	req := signRequest{Digest: base64.StdEncoding.EncodeToString(digest)}
+	if err := c.do(ctx, http.MethodPost, c.keyPath()+"/sign", req, &out); err != nil {
+		return nil, err
+	}
+	sig, err := base64.StdEncoding.DecodeString(out.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("remote signer response: invalid signature encoding: %w", err)
+	}
+	return sig, nil
+}
+
+// Fetches the public key, and returns a [crypto.PrivateKey] which signs using the remote service.
+func (c *Client) PrivateKey(ctx context.Context) (*crypto.ExternalPrivateKey, error) {
+	pub, err := c.PublicKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.NewExternalPrivateKey(c, pub), nil
+}
@@ -0,0 +1,11 @@
+/*
+Package remotesigner implements a client for a simple HTTP signing API, which holds atproto signing keys in a separate service (eg, fronting an HSM), along with a stand-in [Server] for local development and tests.
+
+The API has two endpoints, both optionally authenticated with a bearer token:
+
+	GET  /keys/{keyID}       -> {"keyId": "...", "publicKey": "did:key:..."}
+	POST /keys/{keyID}/sign  {"digest": "<base64>"} -> {"signature": "<base64>"}
+
+The digest is a SHA-256 hash (32 bytes). The signature may be compact or ASN.1 DER encoded; it is normalized by [crypto.ExternalPrivateKey].
+*/
+package remotesigner
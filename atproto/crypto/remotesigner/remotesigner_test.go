@@ -0,0 +1,71 @@
+package remotesigner
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bluesky-social/indigo/atproto/crypto"
+	"github.com/bluesky-social/indigo/atproto/plc"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoteSigner(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	srv := NewServer("secret")
+	keys := map[string]crypto.PrivateKeyExportable{}
+	p256, err := crypto.GeneratePrivateKeyP256()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys["repo-p256"] = p256
+	k256, err := crypto.GeneratePrivateKeyK256()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys["repo-k256"] = k256
+	for id, priv := range keys {
+		assert.NoError(srv.AddKey(id, priv))
+	}
+	hs := httptest.NewServer(srv.Handler())
+	defer hs.Close()
+
+	msg := []byte("test-message")
+	for id, local := range keys {
+		priv, err := NewClient(hs.URL, id, "secret").PrivateKey(ctx)
+		assert.NoError(err)
+		pub, err := priv.PublicKey()
+		assert.NoError(err)
+		localPub, err := local.PublicKey()
+		assert.NoError(err)
+		assert.True(localPub.Equal(pub))
+		assert.True(priv.Equal(local))
+
+		for range 16 {
+			sig, err := priv.HashAndSign(msg)
+			assert.NoError(err)
+			assert.NoError(pub.HashAndVerify(msg, sig))
+		}
+
+		// PLC operations can be signed with a remote rotation key
+		op := &plc.RegularOp{
+			Type:                "plc_operation",
+			RotationKeys:        []string{pub.DIDKey()},
+			VerificationMethods: map[string]string{"atproto": pub.DIDKey()},
+			AlsoKnownAs:         []string{"at://alice.example.com"},
+			Services:            map[string]plc.OpService{},
+		}
+		assert.NoError(plc.SignOp(op, priv))
+		idx, err := plc.VerifySignature(op, op.RotationKeys)
+		assert.NoError(err)
+		assert.Equal(0, idx)
+	}
+
+	_, err = NewClient(hs.URL, "repo-p256", "wrong").PrivateKey(ctx)
+	assert.ErrorContains(err, "401")
+	_, err = NewClient(hs.URL, "missing", "secret").PrivateKey(ctx)
+	assert.ErrorContains(err, "404")
+}
@@ -0,0 +1,108 @@
+package remotesigner
+
+import (
+	gocrypto "crypto"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/bluesky-social/indigo/atproto/crypto"
+)
+
+// Stand-in signing service which holds keys in memory, for local development and tests. Signatures are returned ASN.1 DER encoded, and are not normalized to "low-S", like many HSM and KMS backends.
+type Server struct {
+	// If set, requests must include this bearer token
+	Token string
+
+	mu   sync.RWMutex
+	keys map[string]serverKey
+}
+
+type serverKey struct {
+	signer gocrypto.Signer
+	pub    crypto.PublicKey
+}
+
+func NewServer(token string) *Server {
+	return &Server{
+		Token: token,
+		keys:  make(map[string]serverKey),
+	}
+}
+
+// Registers a key under the given ID. Only P-256 and K-256 keys are supported.
+func (s *Server) AddKey(keyID string, priv crypto.PrivateKeyExportable) error {
+	pub, err := priv.PublicKey()
+	if err != nil {
+		return err
+	}
+	signer, err := crypto.GoSigner(priv)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[keyID] = serverKey{signer: signer, pub: pub}
+	return nil
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /keys/{keyID}", s.handleKey)
+	mux.HandleFunc("POST /keys/{keyID}/sign", s.handleSign)
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) (serverKey, bool) {
+	if s.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.Token {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return serverKey{}, false
+	}
+	s.mu.RLock()
+	k, ok := s.keys[r.PathValue("keyID")]
+	s.mu.RUnlock()
+	if !ok {
+		http.Error(w, "key not found", http.StatusNotFound)
+		return serverKey{}, false
+	}
+	return k, true
+}
+
+func (s *Server) handleKey(w http.ResponseWriter, r *http.Request) {
+	k, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, keyResponse{KeyID: r.PathValue("keyID"), PublicKey: k.pub.DIDKey()})
+}
+
+func (s *Server) handleSign(w http.ResponseWriter, r *http.Request) {
+	k, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	var req signRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	digest, err := base64.StdEncoding.DecodeString(req.Digest)
+	if err != nil || len(digest) != 32 {
+		http.Error(w, "digest must be 32 bytes, base64 encoded", http.StatusBadRequest)
+		return
+	}
+	sig, err := k.signer.Sign(rand.Reader, digest, gocrypto.SHA256)
+	if err != nil {
+		http.Error(w, "signing failed", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, signResponse{Signature: base64.StdEncoding.EncodeToString(sig)})
+}
@@ -0,0 +1,120 @@
+package identity
+
+import (
+	"context"
+	"sync"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+)
+
+// Configuration for [LookupDIDs].
+//
+// This only bounds the number of lookups in flight. Request rate limits against the network are applied by the underlying [BaseDirectory] (PLCLimiter and DIDWebLimitFunc), so that cache hits in a caching directory are not throttled.
+type BatchConfig struct {
+	// Maximum number of lookups in flight at once, across all DID methods. Values less than one are treated as one.
+	Concurrency int
+	// Optional additional limit on lookups in flight for each DID method (eg, "plc" or "web"). Methods not in the map are only bounded by Concurrency.
+	MethodConcurrency map[string]int
+}
+
+func DefaultBatchConfig() BatchConfig {
+	return BatchConfig{
+		Concurrency: 20,
+		MethodConcurrency: map[string]int{
+			// did:web lookups hit arbitrary hosts, which may be slow
+			"web": 4,
+		},
+	}
+}
+
+// Result of a single lookup in a batch.
+type BatchResult struct {
+	DID      syntax.DID
+	Identity *Identity
+	Err      error
+}
+
+// Looks up a set of DIDs in parallel, with bounded concurrency.
+//
+// Results are returned in the same order as the input. Duplicate DIDs in the input are only looked up once. Errors for individual DIDs are returned in the corresponding result; if the context is cancelled, remaining lookups fail with the context error.
+func LookupDIDs(ctx context.Context, dir Directory, dids []syntax.DID, config BatchConfig) []BatchResult {
+	results := make([]BatchResult, len(dids))
+	if len(dids) == 0 {
+		return results
+	}
+
+	// index of the first occurrence of each DID
+	first := make(map[syntax.DID]int, len(dids))
+	for i, did := range dids {
+		results[i].DID = did
+		if _, ok := first[did]; ok {
+			batchDuplicates.Inc()
+			continue
+		}
+		first[did] = i
+	}
+
+	methods := make(map[string]chan struct{}, len(config.MethodConcurrency))
+	for m, n := range config.MethodConcurrency {
+		methods[m] = make(chan struct{}, max(n, 1))
+	}
+
+	// a fixed pool of workers, so that large batches don't start a goroutine per DID
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for range max(config.Concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				did := dids[i]
+				if err := ctx.Err(); err != nil {
+					results[i].Err = err
+					continue
+				}
+				method := methods[did.Method()]
+				if err := acquire(ctx, method); err != nil {
+					results[i].Err = err
+					continue
+				}
+				batchLookups.WithLabelValues(did.Method()).Inc()
+				results[i].Identity, results[i].Err = dir.LookupDID(ctx, did)
+				release(method)
+			}
+		}()
+	}
+	for i, did := range dids {
+		if first[did] == i {
+			work <- i
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	for i, did := range dids {
+		if j := first[did]; j != i {
+			results[i].Identity = results[j].Identity
+			results[i].Err = results[j].Err
+		}
+	}
+	return results
+}
+
+// acquire and release are no-ops for a nil semaphore
+func acquire(ctx context.Context, sem chan struct{}) error {
+	if sem == nil {
+		return nil
+	}
+	select {
+	case sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func release(sem chan struct{}) {
+	if sem != nil {
+		<-sem
+	}
+}
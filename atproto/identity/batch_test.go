@@ -0,0 +1,105 @@
+package identity
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// wraps MockDirectory, counting DID lookups and tracking how many are in flight
+type slowDirectory struct {
+	MockDirectory
+	delay       time.Duration
+	calls       atomic.Int64
+	inFlight    atomic.Int64
+	maxInFlight atomic.Int64
+}
+
+func (d *slowDirectory) LookupDID(ctx context.Context, did syntax.DID) (*Identity, error) {
+	d.calls.Add(1)
+	n := d.inFlight.Add(1)
+	defer d.inFlight.Add(-1)
+	for {
+		m := d.maxInFlight.Load()
+		if n <= m || d.maxInFlight.CompareAndSwap(m, n) {
+			break
+		}
+	}
+	time.Sleep(d.delay)
+	return d.MockDirectory.LookupDID(ctx, did)
+}
+
+func TestCacheDirectoryCoalesce(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	inner := slowDirectory{MockDirectory: NewMockDirectory(), delay: 50 * time.Millisecond}
+	did := syntax.DID("did:plc:abc111")
+	inner.Insert(Identity{DID: did, Handle: syntax.HandleInvalid})
+	dir := NewCacheDirectory(&inner, 1000, time.Hour, time.Minute, time.Minute)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ident, err := dir.LookupDID(ctx, did)
+			assert.NoError(err)
+			assert.Equal(did, ident.DID)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(int64(1), inner.calls.Load())
+
+	// a cancelled caller doesn't fail the shared lookup
+	missing := syntax.DID("did:plc:abc222")
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	go dir.LookupDID(cctx, missing)
+	time.Sleep(time.Millisecond)
+	_, err := dir.LookupDID(ctx, missing)
+	assert.ErrorIs(err, ErrDIDNotFound)
+}
+
+func TestLookupDIDs(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	inner := slowDirectory{MockDirectory: NewMockDirectory(), delay: 10 * time.Millisecond}
+	var dids []syntax.DID
+	for _, s := range []string{"did:plc:abc111", "did:plc:abc222", "did:web:one.example.com", "did:web:two.example.com", "did:web:three.example.com"} {
+		did := syntax.DID(s)
+		inner.Insert(Identity{DID: did, Handle: syntax.HandleInvalid})
+		dids = append(dids, did)
+	}
+	// duplicate, and unknown
+	dids = append(dids, "did:plc:abc111", "did:plc:abc333")
+
+	config := BatchConfig{Concurrency: 4, MethodConcurrency: map[string]int{"web": 1}}
+	results := LookupDIDs(ctx, &inner, dids, config)
+	assert.Equal(len(dids), len(results))
+	for i, res := range results {
+		assert.Equal(dids[i], res.DID)
+		if res.DID == "did:plc:abc333" {
+			assert.ErrorIs(res.Err, ErrDIDNotFound)
+			continue
+		}
+		assert.NoError(res.Err)
+		assert.Equal(dids[i], res.Identity.DID)
+	}
+	assert.Equal(int64(6), inner.calls.Load())
+	assert.LessOrEqual(inner.maxInFlight.Load(), int64(4))
+
+	// only did:web lookups, which are limited to one at a time
+	inner.maxInFlight.Store(0)
+	LookupDIDs(ctx, &inner, dids[2:5], config)
+	assert.Equal(int64(1), inner.maxInFlight.Load())
+
+	assert.Equal(0, len(LookupDIDs(ctx, &inner, nil, config)))
+}
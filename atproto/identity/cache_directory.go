@@ -3,22 +3,23 @@ package identity
 import (
 	"context"
 	"fmt"
-	"sync"
 	"time"
 
 	"github.com/bluesky-social/indigo/atproto/syntax"
 
 	"github.com/hashicorp/golang-lru/v2/expirable"
+	"golang.org/x/sync/singleflight"
 )
 
 type CacheDirectory struct {
-	Inner             Directory
-	ErrTTL            time.Duration
-	InvalidHandleTTL  time.Duration
-	handleCache       *expirable.LRU[syntax.Handle, HandleEntry]
-	identityCache     *expirable.LRU[syntax.DID, IdentityEntry]
-	didLookupChans    sync.Map
-	handleLookupChans sync.Map
+	Inner            Directory
+	ErrTTL           time.Duration
+	InvalidHandleTTL time.Duration
+	handleCache      *expirable.LRU[syntax.Handle, HandleEntry]
+	identityCache    *expirable.LRU[syntax.DID, IdentityEntry]
+	// coalesces concurrent lookups of the same identifier, so only one request goes to the inner directory
+	didLookups    singleflight.Group
+	handleLookups singleflight.Group
 }
 
 type HandleEntry struct {
@@ -35,6 +36,14 @@ type IdentityEntry struct {
 
 var _ Directory = (*CacheDirectory)(nil)
 
+// Upper bound on the duration of a coalesced lookup against an inner directory.
+var CoalescedLookupTimeout = time.Second * 30
+
+// Coalesced lookups are shared by every concurrent caller, so they should not be cancelled when the first caller gives up (which would also cache the cancellation as a lookup error). Values are preserved, but the cancellation is replaced by [CoalescedLookupTimeout].
+func detachedContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), CoalescedLookupTimeout)
+}
+
 // Capacity of zero means unlimited size. Similarly, ttl of zero means unlimited duration.
 func NewCacheDirectory(inner Directory, capacity int, hitTTL, errTTL, invalidHandleTTL time.Duration) CacheDirectory {
 	return CacheDirectory{
@@ -103,39 +112,29 @@ func (d *CacheDirectory) ResolveHandle(ctx context.Context, h syntax.Handle) (sy
 	handleCacheMisses.Inc()
 
 	// Coalesce multiple requests for the same Handle
-	res := make(chan struct{})
-	val, loaded := d.handleLookupChans.LoadOrStore(h.String(), res)
-	if loaded {
-		handleRequestsCoalesced.Inc()
-		// Wait for the result from the pending request
-		select {
-		case <-val.(chan struct{}):
-			// The result should now be in the cache
-			entry, ok := d.handleCache.Get(h)
-			if ok && !d.IsHandleStale(&entry) {
-				return entry.DID, entry.Err
-			}
-			return "", fmt.Errorf("identity not found in cache after coalesce returned")
-		case <-ctx.Done():
-			return "", ctx.Err()
+	leader := false
+	ch := d.handleLookups.DoChan(h.String(), func() (any, error) {
+		leader = true
+		lctx, cancel := detachedContext(ctx)
+		defer cancel()
+		return d.updateHandle(lctx, h), nil
+	})
+	select {
+	case res := <-ch:
+		if !leader {
+			handleRequestsCoalesced.Inc()
 		}
+		newEntry := res.Val.(HandleEntry)
+		if newEntry.Err != nil {
+			return "", newEntry.Err
+		}
+		if newEntry.DID != "" {
+			return newEntry.DID, nil
+		}
+		return "", fmt.Errorf("unexpected control-flow error")
+	case <-ctx.Done():
+		return "", ctx.Err()
 	}
-
-	// Update the Handle Entry from PLC and cache the result
-	newEntry := d.updateHandle(ctx, h)
-
-	// Cleanup the coalesce map and close the results channel
-	d.handleLookupChans.Delete(h.String())
-	// Callers waiting will now get the result from the cache
-	close(res)
-
-	if newEntry.Err != nil {
-		return "", newEntry.Err
-	}
-	if newEntry.DID != "" {
-		return newEntry.DID, nil
-	}
-	return "", fmt.Errorf("unexpected control-flow error")
 }
 
 func (d *CacheDirectory) updateDID(ctx context.Context, did syntax.DID) IdentityEntry {
@@ -177,39 +176,29 @@ func (d *CacheDirectory) LookupDIDWithCacheState(ctx context.Context, did syntax
 	identityCacheMisses.Inc()
 
 	// Coalesce multiple requests for the same DID
-	res := make(chan struct{})
-	val, loaded := d.didLookupChans.LoadOrStore(did.String(), res)
-	if loaded {
-		identityRequestsCoalesced.Inc()
-		// Wait for the result from the pending request
-		select {
-		case <-val.(chan struct{}):
-			// The result should now be in the cache
-			entry, ok := d.identityCache.Get(did)
-			if ok && !d.IsIdentityStale(&entry) {
-				return entry.Identity, false, entry.Err
-			}
-			return nil, false, fmt.Errorf("identity not found in cache after coalesce returned")
-		case <-ctx.Done():
-			return nil, false, ctx.Err()
+	leader := false
+	ch := d.didLookups.DoChan(did.String(), func() (any, error) {
+		leader = true
+		lctx, cancel := detachedContext(ctx)
+		defer cancel()
+		return d.updateDID(lctx, did), nil
+	})
+	select {
+	case res := <-ch:
+		if !leader {
+			identityRequestsCoalesced.Inc()
 		}
+		newEntry := res.Val.(IdentityEntry)
+		if newEntry.Err != nil {
+			return nil, false, newEntry.Err
+		}
+		if newEntry.Identity != nil {
+			return newEntry.Identity, false, nil
+		}
+		return nil, false, fmt.Errorf("unexpected control-flow error")
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
 	}
-
-	// Update the Identity Entry from PLC and cache the result
-	newEntry := d.updateDID(ctx, did)
-
-	// Cleanup the coalesce map and close the results channel
-	d.didLookupChans.Delete(did.String())
-	// Callers waiting will now get the result from the cache
-	close(res)
-
-	if newEntry.Err != nil {
-		return nil, false, newEntry.Err
-	}
-	if newEntry.Identity != nil {
-		return newEntry.Identity, false, nil
-	}
-	return nil, false, fmt.Errorf("unexpected control-flow error")
 }
 
 func (d *CacheDirectory) LookupHandle(ctx context.Context, h syntax.Handle) (*Identity, error) {
@@ -0,0 +1,363 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+)
+
+// Outcome of a single handle resolution method, as part of a [HandleDiagnosis].
+type HandleMethodResult struct {
+	// One of "dns", "dns-authoritative", "dns-fallback", or "well-known"
+	Method string `json:"method"`
+	// True if the method was not attempted (eg, no fallback DNS servers are configured)
+	Skipped bool       `json:"skipped,omitempty"`
+	DID     syntax.DID `json:"did,omitempty"`
+	Error   string     `json:"error,omitempty"`
+	// True if the error indicates the handle has no record for this method, as opposed to a resolution failure
+	NotFound bool `json:"notFound,omitempty"`
+	// DNS server queried, for the authoritative and fallback methods
+	Nameserver string `json:"nameserver,omitempty"`
+	// Raw TXT records returned, for DNS methods
+	Records []string `json:"records,omitempty"`
+	// HTTP status code and (truncated) response body, for the well-known method
+	HTTPStatus int    `json:"httpStatus,omitempty"`
+	Body       string `json:"body,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// Structured report from running all handle resolution methods independently, plus DID document verification. See [BaseDirectory.DiagnoseHandle].
+type HandleDiagnosis struct {
+	Handle syntax.Handle `json:"handle,omitempty"`
+	// The DID passed to [BaseDirectory.DiagnoseDID], or otherwise the DID which [BaseDirectory.ResolveHandle] would return for the handle
+	DID     syntax.DID           `json:"did,omitempty"`
+	Methods []HandleMethodResult `json:"methods,omitempty"`
+	// True if resolution methods returned different DIDs
+	Conflict bool `json:"conflict"`
+
+	DIDDocument *DIDDocument `json:"didDocument,omitempty"`
+	// Error resolving the DID document, if any
+	DIDError      string `json:"didError,omitempty"`
+	DIDDurationMs int64  `json:"didDurationMs,omitempty"`
+	// Handle declared in the DID document (alsoKnownAs)
+	DeclaredHandle syntax.Handle `json:"declaredHandle,omitempty"`
+
+	// True if the handle resolves to the DID
+	HandleToDID bool `json:"handleToDID"`
+	// True if the DID document declares the handle
+	DIDToHandle bool `json:"didToHandle"`
+	// True if both directions of verification succeeded; the handle is valid for the DID
+	Verified bool `json:"verified"`
+	// Human-readable descriptions of any issues found
+	Problems []string `json:"problems,omitempty"`
+}
+
+func (hd *HandleDiagnosis) problem(format string, args ...any) {
+	hd.Problems = append(hd.Problems, fmt.Sprintf(format, args...))
+}
+
+// Returns the result for the named method, or nil if it wasn't run.
+func (hd *HandleDiagnosis) Method(name string) *HandleMethodResult {
+	for i := range hd.Methods {
+		if hd.Methods[i].Method == name {
+			return &hd.Methods[i]
+		}
+	}
+	return nil
+}
+
+// Runs every handle resolution method (DNS TXT with the system resolver, authoritative nameserver, and fallback servers; and HTTPS well-known) independently and in parallel, then resolves the DID document and checks that it declares the handle.
+//
+// Unlike [BaseDirectory.ResolveHandle], a failure or success of one method does not prevent the others from running, and the raw responses are included in the report. The directory configuration (eg, TryAuthoritativeDNS and SkipDNSDomainSuffixes) is only used to determine which DID ResolveHandle would return.
+//
+// Problems are reported in the returned diagnosis, not as an error.
+func (d *BaseDirectory) DiagnoseHandle(ctx context.Context, handle syntax.Handle) *HandleDiagnosis {
+	hd := HandleDiagnosis{Handle: handle.Normalize()}
+	if !d.diagnoseHandleMethods(ctx, &hd) {
+		return &hd
+	}
+	if hd.DID == "" {
+		// fall back to any DID found, to still check the DID document
+		for _, res := range hd.Methods {
+			if res.DID != "" {
+				hd.DID = res.DID
+				break
+			}
+		}
+	}
+	if hd.DID == "" {
+		hd.problem("handle did not resolve to a DID with any method")
+		return &hd
+	}
+	d.diagnoseDIDDocument(ctx, &hd)
+	hd.verify()
+	return &hd
+}
+
+// Resolves the DID document, then diagnoses the handle it declares, as with [BaseDirectory.DiagnoseHandle].
+func (d *BaseDirectory) DiagnoseDID(ctx context.Context, did syntax.DID) *HandleDiagnosis {
+	hd := HandleDiagnosis{DID: did}
+	d.diagnoseDIDDocument(ctx, &hd)
+	if hd.DIDDocument == nil {
+		return &hd
+	}
+	if hd.DeclaredHandle == "" {
+		return &hd
+	}
+	hd.Handle = hd.DeclaredHandle
+	if !d.diagnoseHandleMethods(ctx, &hd) {
+		return &hd
+	}
+	hd.verify()
+	return &hd
+}
+
+func (d *BaseDirectory) diagnoseDIDDocument(ctx context.Context, hd *HandleDiagnosis) {
+	start := time.Now()
+	doc, err := d.ResolveDID(ctx, hd.DID)
+	hd.DIDDurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		hd.DIDError = err.Error()
+		hd.problem("DID document resolution failed: %s", err)
+		return
+	}
+	hd.DIDDocument = doc
+	ident := ParseIdentity(doc)
+	declared, err := ident.DeclaredHandle()
+	if err != nil {
+		hd.problem("DID document does not declare a valid handle: %s", err)
+		return
+	}
+	hd.DeclaredHandle = declared
+}
+
+// Runs all the handle resolution methods in parallel. Returns false if the handle can not be resolved at all (eg, a reserved TLD).
+func (d *BaseDirectory) diagnoseHandleMethods(ctx context.Context, hd *HandleDiagnosis) bool {
+	if hd.Handle.IsInvalidHandle() {
+		hd.problem("handle is the %q placeholder", syntax.HandleInvalid)
+		return false
+	}
+	if !hd.Handle.AllowedTLD() {
+		hd.problem("handle has a reserved TLD, which can not be resolved")
+		return false
+	}
+
+	probes := []func(context.Context, syntax.Handle) HandleMethodResult{
+		d.diagnoseDNS,
+		d.diagnoseDNSAuthoritative,
+		d.diagnoseDNSFallback,
+		d.diagnoseWellKnown,
+	}
+	hd.Methods = make([]HandleMethodResult, len(probes))
+	var wg sync.WaitGroup
+	for i, probe := range probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			res := probe(ctx, hd.Handle)
+			res.DurationMs = time.Since(start).Milliseconds()
+			hd.Methods[i] = res
+		}()
+	}
+	wg.Wait()
+
+	found := map[syntax.DID]bool{}
+	for _, res := range hd.Methods {
+		if res.DID != "" {
+			found[res.DID] = true
+		}
+		if res.Error != "" && !res.NotFound {
+			hd.problem("%s resolution failed: %s", res.Method, res.Error)
+		}
+	}
+	if len(found) > 1 {
+		hd.Conflict = true
+		var parts []string
+		for _, res := range hd.Methods {
+			if res.DID != "" {
+				parts = append(parts, fmt.Sprintf("%s=%s", res.Method, res.DID))
+			}
+		}
+		hd.problem("resolution methods returned different DIDs: %s", strings.Join(parts, ", "))
+	}
+
+	effective := d.effectiveHandleDID(hd)
+	if hd.DID == "" {
+		hd.DID = effective
+	}
+	hd.HandleToDID = effective != "" && effective == hd.DID
+	if !hd.HandleToDID && hd.DID != "" {
+		if effective == "" {
+			hd.problem("handle %s does not resolve to a DID", hd.Handle)
+		} else {
+			hd.problem("handle %s resolves to %s, not %s", hd.Handle, effective, hd.DID)
+		}
+	}
+	return true
+}
+
+// Determines which DID [BaseDirectory.ResolveHandle] would return, based on the individual method results and the directory configuration.
+func (d *BaseDirectory) effectiveHandleDID(hd *HandleDiagnosis) syntax.DID {
+	tryDNS := true
+	for _, suffix := range d.SkipDNSDomainSuffixes {
+		if strings.HasSuffix(hd.Handle.String(), suffix) {
+			tryDNS = false
+			break
+		}
+	}
+	if tryDNS {
+		order := []string{"dns"}
+		if d.TryAuthoritativeDNS {
+			order = append(order, "dns-authoritative")
+		}
+		order = append(order, "dns-fallback")
+		for _, name := range order {
+			res := hd.Method(name)
+			if res == nil || res.Skipped {
+				continue
+			}
+			if res.DID != "" {
+				return res.DID
+			}
+			// later DNS methods are only tried if the record was not found
+			if !res.NotFound {
+				break
+			}
+		}
+	}
+	if res := hd.Method("well-known"); res != nil {
+		return res.DID
+	}
+	return ""
+}
+
+func (hd *HandleDiagnosis) verify() {
+	hd.DIDToHandle = hd.DeclaredHandle != "" && hd.DeclaredHandle == hd.Handle
+	if hd.DIDDocument != nil && hd.DeclaredHandle != "" && !hd.DIDToHandle {
+		hd.problem("DID document declares handle %s, not %s", hd.DeclaredHandle, hd.Handle)
+	}
+	hd.Verified = hd.HandleToDID && hd.DIDToHandle
+}
+
+// records a TXT lookup outcome, using the same error classification as [BaseDirectory.ResolveHandleDNS]
+func diagnoseTXT(res *HandleMethodResult, records []string, err error) {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		res.NotFound = true
+		res.Error = err.Error()
+		return
+	}
+	if err != nil {
+		res.Error = err.Error()
+		return
+	}
+	res.Records = records
+	did, err := parseTXTResp(records)
+	if err != nil {
+		res.NotFound = errors.Is(err, ErrHandleNotFound)
+		res.Error = err.Error()
+		return
+	}
+	res.DID = did
+}
+
+func (d *BaseDirectory) diagnoseDNS(ctx context.Context, handle syntax.Handle) HandleMethodResult {
+	res := HandleMethodResult{Method: "dns"}
+	records, err := d.Resolver.LookupTXT(ctx, "_atproto."+handle.String())
+	diagnoseTXT(&res, records, err)
+	return res
+}
+
+func (d *BaseDirectory) diagnoseDNSAuthoritative(ctx context.Context, handle syntax.Handle) HandleMethodResult {
+	res := HandleMethodResult{Method: "dns-authoritative"}
+	resNS, err := d.Resolver.LookupNS(ctx, handle.String())
+	if err == nil && len(resNS) == 0 {
+		err = fmt.Errorf("no NS records for %s", handle)
+	}
+	if err != nil {
+		res.Error = fmt.Sprintf("nameserver lookup: %s", err)
+		var dnsErr *net.DNSError
+		res.NotFound = errors.As(err, &dnsErr) && dnsErr.IsNotFound
+		return res
+	}
+	ns := resNS[0].Host
+	if !strings.Contains(ns, ":") {
+		ns = ns + ":53"
+	}
+	res.Nameserver = ns
+	records, err := nameserverResolver(ns).LookupTXT(ctx, "_atproto."+handle.String())
+	diagnoseTXT(&res, records, err)
+	return res
+}
+
+// queries each fallback server; the reported result is from the first server which returned a DID, or otherwise the last server
+func (d *BaseDirectory) diagnoseDNSFallback(ctx context.Context, handle syntax.Handle) HandleMethodResult {
+	res := HandleMethodResult{Method: "dns-fallback"}
+	if len(d.FallbackDNSServers) == 0 {
+		res.Skipped = true
+		return res
+	}
+	for _, ns := range d.FallbackDNSServers {
+		res = HandleMethodResult{Method: "dns-fallback", Nameserver: ns}
+		records, err := nameserverResolver(ns).LookupTXT(ctx, "_atproto."+handle.String())
+		diagnoseTXT(&res, records, err)
+		if res.DID != "" {
+			break
+		}
+	}
+	return res
+}
+
+func (d *BaseDirectory) diagnoseWellKnown(ctx context.Context, handle syntax.Handle) HandleMethodResult {
+	res := HandleMethodResult{Method: "well-known"}
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://%s/.well-known/atproto-did", handle), nil)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	resp, err := d.HTTPClient.Do(req)
+	if err != nil {
+		var dnsErr *net.DNSError
+		res.NotFound = errors.As(err, &dnsErr) && dnsErr.IsNotFound
+		res.Error = err.Error()
+		return res
+	}
+	defer resp.Body.Close()
+	res.HTTPStatus = resp.StatusCode
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 2048))
+	if err != nil {
+		res.Error = fmt.Sprintf("reading body: %s", err)
+		return res
+	}
+	res.Body = string(b)
+	if resp.StatusCode == http.StatusNotFound {
+		res.NotFound = true
+		res.Error = "HTTP 404"
+		return res
+	}
+	if resp.StatusCode != http.StatusOK {
+		res.Error = fmt.Sprintf("HTTP status %d", resp.StatusCode)
+		return res
+	}
+	if resp.ContentLength > 2048 {
+		res.Error = "body too large"
+		return res
+	}
+	did, err := syntax.ParseDID(strings.TrimSpace(string(b)))
+	if err != nil {
+		res.Error = fmt.Sprintf("invalid DID in body: %s", err)
+		return res
+	}
+	res.DID = did
+	return res
+}
@@ -0,0 +1,99 @@
+package identity
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// returns a directory which sends all HTTPS requests to the test server, and fails all DNS queries
+func diagnoseTestDirectory(srv *httptest.Server) *BaseDirectory {
+	transport := srv.Client().Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", srv.Listener.Addr().String())
+	}
+	return &BaseDirectory{
+		HTTPClient: http.Client{Transport: transport},
+		Resolver: net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				return nil, errors.New("DNS disabled in tests")
+			},
+		},
+	}
+}
+
+func TestDiagnoseHandle(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	docs := map[string]*DIDDocument{}
+	for _, host := range []string{"alice.example.com", "other.example.com"} {
+		doc, err := NewDIDDocumentBuilder(syntax.DID("did:web:" + host)).Handle("alice.example.com").Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs[host] = doc
+	}
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/atproto-did":
+			if r.Host == "alice.example.com" {
+				w.Write([]byte("did:web:alice.example.com\n"))
+				return
+			}
+		case "/.well-known/did.json":
+			if doc, ok := docs[r.Host]; ok {
+				DIDDocumentHandler(doc).ServeHTTP(w, r)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+	dir := diagnoseTestDirectory(srv)
+
+	hd := dir.DiagnoseHandle(ctx, "Alice.Example.com")
+	assert.Equal(syntax.Handle("alice.example.com"), hd.Handle)
+	assert.Equal(syntax.DID("did:web:alice.example.com"), hd.DID)
+	assert.Equal(4, len(hd.Methods))
+	wk := hd.Method("well-known")
+	assert.Equal(http.StatusOK, wk.HTTPStatus)
+	assert.Equal("did:web:alice.example.com\n", wk.Body)
+	assert.NotEmpty(hd.Method("dns").Error)
+	assert.True(hd.Method("dns-fallback").Skipped)
+	assert.False(hd.Conflict)
+	assert.True(hd.HandleToDID)
+	assert.True(hd.DIDToHandle)
+	assert.True(hd.Verified)
+
+	// DID whose document claims the handle, but the handle doesn't resolve back to it
+	hd = dir.DiagnoseDID(ctx, "did:web:other.example.com")
+	assert.Equal(syntax.Handle("alice.example.com"), hd.Handle)
+	assert.Equal(syntax.DID("did:web:other.example.com"), hd.DID)
+	assert.True(hd.DIDToHandle)
+	assert.False(hd.HandleToDID)
+	assert.False(hd.Verified)
+	assert.Contains(hd.Problems, "handle alice.example.com resolves to did:web:alice.example.com, not did:web:other.example.com")
+
+	// handle which doesn't resolve at all
+	hd = dir.DiagnoseHandle(ctx, "nobody.example.com")
+	assert.True(hd.Method("well-known").NotFound)
+	assert.Equal(syntax.DID(""), hd.DID)
+	assert.False(hd.Verified)
+	assert.Contains(hd.Problems, "handle did not resolve to a DID with any method")
+
+	hd = dir.DiagnoseHandle(ctx, "alice.local")
+	assert.Nil(hd.Methods)
+	assert.False(hd.Verified)
+}
@@ -0,0 +1,187 @@
+package identity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/bluesky-social/indigo/atproto/crypto"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+)
+
+// JSON-LD context for DID documents with Multikey verification methods.
+var DIDDocumentContext = []string{
+	"https://www.w3.org/ns/did/v1",
+	"https://w3id.org/security/multikey/v1",
+}
+
+// Incrementally constructs a [DIDDocument] with atproto verification methods and services, such as for publishing a did:web.
+//
+// Errors from individual steps are accumulated and returned by [DIDDocumentBuilder.Build].
+type DIDDocumentBuilder struct {
+	doc  DIDDocument
+	errs []error
+}
+
+func NewDIDDocumentBuilder(did syntax.DID) *DIDDocumentBuilder {
+	return &DIDDocumentBuilder{
+		doc: DIDDocument{
+			DID:                did,
+			AlsoKnownAs:        []string{},
+			VerificationMethod: []DocVerificationMethod{},
+			Service:            []DocService{},
+		},
+	}
+}
+
+// Declares an atproto handle (as an "at://" URI in alsoKnownAs). The first handle declared is the one used by atproto.
+func (b *DIDDocumentBuilder) Handle(handle syntax.Handle) *DIDDocumentBuilder {
+	return b.AlsoKnownAs("at://" + handle.Normalize().String())
+}
+
+// Adds an arbitrary URI to alsoKnownAs.
+func (b *DIDDocumentBuilder) AlsoKnownAs(uri string) *DIDDocumentBuilder {
+	if _, err := url.Parse(uri); err != nil {
+		b.errs = append(b.errs, fmt.Errorf("alsoKnownAs URI: %w", err))
+		return b
+	}
+	b.doc.AlsoKnownAs = append(b.doc.AlsoKnownAs, uri)
+	return b
+}
+
+// Adds a Multikey verification method. The ID is the fragment only (eg, "atproto"), without the hash symbol.
+func (b *DIDDocumentBuilder) VerificationMethod(id string, pub crypto.PublicKey) *DIDDocumentBuilder {
+	if id == "" || strings.Contains(id, "#") {
+		b.errs = append(b.errs, fmt.Errorf("invalid verification method ID: %q", id))
+		return b
+	}
+	b.doc.VerificationMethod = append(b.doc.VerificationMethod, DocVerificationMethod{
+		ID:                 b.doc.DID.String() + "#" + id,
+		Type:               "Multikey",
+		Controller:         b.doc.DID.String(),
+		PublicKeyMultibase: pub.Multibase(),
+	})
+	return b
+}
+
+// Adds the atproto repo signing key.
+func (b *DIDDocumentBuilder) AtprotoKey(pub crypto.PublicKey) *DIDDocumentBuilder {
+	return b.VerificationMethod("atproto", pub)
+}
+
+// Adds a service entry. The ID is the fragment only (eg, "atproto_pds"), without the hash symbol. The endpoint must be an HTTP(S) URL.
+func (b *DIDDocumentBuilder) Service(id, serviceType, endpoint string) *DIDDocumentBuilder {
+	if id == "" || strings.Contains(id, "#") {
+		b.errs = append(b.errs, fmt.Errorf("invalid service ID: %q", id))
+		return b
+	}
+	b.doc.Service = append(b.doc.Service, DocService{
+		ID:              "#" + id,
+		Type:            serviceType,
+		ServiceEndpoint: endpoint,
+	})
+	return b
+}
+
+// Adds the atproto PDS service entry.
+func (b *DIDDocumentBuilder) PDS(endpoint string) *DIDDocumentBuilder {
+	return b.Service("atproto_pds", "AtprotoPersonalDataServer", endpoint)
+}
+
+// Returns the DID document, or any errors from building it. The document is checked with [DIDDocument.Validate].
+func (b *DIDDocumentBuilder) Build() (*DIDDocument, error) {
+	if len(b.errs) > 0 {
+		return nil, errors.Join(b.errs...)
+	}
+	doc := b.doc
+	if err := doc.Validate(); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// Checks that a DID document is well-formed for atproto use: the DID is valid; verification method and service IDs are unique; verification methods are controlled by the DID and have parseable public keys; service endpoints are HTTP(S) URLs; and any "at://" alsoKnownAs entries are valid handles.
+//
+// Does not require that any specific keys or services are present.
+func (d *DIDDocument) Validate() error {
+	if _, err := syntax.ParseDID(d.DID.String()); err != nil {
+		return err
+	}
+	ident := ParseIdentity(d)
+
+	seen := map[string]bool{}
+	for _, vm := range d.VerificationMethod {
+		_, frag, ok := strings.Cut(vm.ID, "#")
+		if !ok || frag == "" {
+			return fmt.Errorf("verification method ID missing fragment: %q", vm.ID)
+		}
+		if seen["vm#"+frag] {
+			return fmt.Errorf("duplicate verification method ID: %q", vm.ID)
+		}
+		seen["vm#"+frag] = true
+		if vm.Controller != d.DID.String() {
+			return fmt.Errorf("verification method %q controller does not match DID: %q", vm.ID, vm.Controller)
+		}
+		if _, err := ident.GetPublicKey(frag); err != nil {
+			return fmt.Errorf("verification method %q: %w", vm.ID, err)
+		}
+	}
+	for _, s := range d.Service {
+		_, frag, ok := strings.Cut(s.ID, "#")
+		if !ok || frag == "" {
+			return fmt.Errorf("service ID missing fragment: %q", s.ID)
+		}
+		if seen["svc#"+frag] {
+			return fmt.Errorf("duplicate service ID: %q", s.ID)
+		}
+		seen["svc#"+frag] = true
+		u, err := url.Parse(s.ServiceEndpoint)
+		if err != nil {
+			return fmt.Errorf("service %q endpoint: %w", s.ID, err)
+		}
+		if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("service %q endpoint not an HTTP URL: %q", s.ID, s.ServiceEndpoint)
+		}
+	}
+	for _, aka := range d.AlsoKnownAs {
+		if rest, ok := strings.CutPrefix(aka, "at://"); ok {
+			if _, err := syntax.ParseHandle(rest); err != nil {
+				return fmt.Errorf("alsoKnownAs handle: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Returns an HTTP handler which serves the DID document (with JSON-LD context), eg at the did:web "/.well-known/did.json" path.
+//
+// For use with echo, wrap with echo.WrapHandler.
+func DIDDocumentHandler(doc *DIDDocument) http.Handler {
+	body, err := json.Marshal(struct {
+		Context []string `json:"@context"`
+		*DIDDocument
+	}{DIDDocumentContext, doc})
+	if err != nil {
+		// the document is a simple struct of strings, so this is not expected
+		panic(fmt.Sprintf("failed to marshal DID document: %v", err))
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			if _, err := w.Write(body); err != nil {
+				slog.Warn("failed to write DID document response", "err", err)
+			}
+		}
+	})
+}
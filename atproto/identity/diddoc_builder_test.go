@@ -0,0 +1,108 @@
+package identity
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bluesky-social/indigo/atproto/crypto"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDIDDocumentBuilder(t *testing.T) {
+	assert := assert.New(t)
+
+	priv, err := crypto.GeneratePrivateKeyP256()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := priv.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	did := syntax.DID("did:web:pds.example.com")
+	doc, err := NewDIDDocumentBuilder(did).
+		Handle("Alice.Example.com").
+		AtprotoKey(pub).
+		PDS("https://pds.example.com").
+		Service("bsky_fg", "BskyFeedGenerator", "https://feeds.example.com").
+		Build()
+	assert.NoError(err)
+	assert.Equal([]string{"at://alice.example.com"}, doc.AlsoKnownAs)
+	assert.Equal("did:web:pds.example.com#atproto", doc.VerificationMethod[0].ID)
+
+	ident := ParseIdentity(doc)
+	k, err := ident.PublicKey()
+	assert.NoError(err)
+	assert.True(pub.Equal(k))
+	assert.Equal("https://pds.example.com", ident.PDSEndpoint())
+	handle, err := ident.DeclaredHandle()
+	assert.NoError(err)
+	assert.Equal(syntax.Handle("alice.example.com"), handle)
+
+	// invalid inputs
+	_, err = NewDIDDocumentBuilder(did).PDS("ftp://pds.example.com").Build()
+	assert.Error(err)
+	_, err = NewDIDDocumentBuilder(did).AtprotoKey(pub).AtprotoKey(pub).Build()
+	assert.Error(err)
+	_, err = NewDIDDocumentBuilder(did).Service("#bad", "Thing", "https://example.com").Build()
+	assert.Error(err)
+	_, err = NewDIDDocumentBuilder("not-a-did").Build()
+	assert.Error(err)
+
+	bad := *doc
+	bad.VerificationMethod = []DocVerificationMethod{{ID: "#atproto", Type: "Multikey", Controller: "did:web:other.example.com", PublicKeyMultibase: pub.Multibase()}}
+	assert.Error(bad.Validate())
+	bad.VerificationMethod = []DocVerificationMethod{{ID: "#atproto", Type: "Multikey", Controller: did.String(), PublicKeyMultibase: "zzz"}}
+	assert.Error(bad.Validate())
+}
+
+func TestDIDDocumentHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	priv, err := crypto.GeneratePrivateKeyK256()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := priv.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := NewDIDDocumentBuilder("did:web:example.com").AtprotoKey(pub).PDS("https://pds.example.com").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/.well-known/did.json", DIDDocumentHandler(doc))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/.well-known/did.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(http.StatusOK, resp.StatusCode)
+
+	var out struct {
+		Context []string `json:"@context"`
+		DIDDocument
+	}
+	assert.NoError(json.NewDecoder(resp.Body).Decode(&out))
+	assert.Equal(DIDDocumentContext, out.Context)
+	assert.Equal(doc.DID, out.DID)
+	assert.Equal(doc.VerificationMethod, out.VerificationMethod)
+	assert.Equal(doc.Service, out.Service)
+
+	resp, err = http.Post(srv.URL+"/.well-known/did.json", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(http.StatusMethodNotAllowed, resp.StatusCode)
+}
@@ -28,6 +28,19 @@ func parseTXTResp(res []string) (syntax.DID, error) {
 	return "", ErrHandleNotFound
 }
 
+// Returns a DNS resolver which sends all queries to a specific nameserver ("host:port").
+func nameserverResolver(ns string) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			rd := net.Dialer{
+				Timeout: time.Second * 5,
+			}
+			return rd.DialContext(ctx, network, ns)
+		},
+	}
+}
+
 // Does not cross-verify, only does the handle resolution step.
 func (d *BaseDirectory) ResolveHandleDNS(ctx context.Context, handle syntax.Handle) (syntax.DID, error) {
 	res, err := d.Resolver.LookupTXT(ctx, "_atproto."+handle.String())
@@ -67,15 +80,7 @@ func (d *BaseDirectory) ResolveHandleDNSAuthoritative(ctx context.Context, handl
 	}
 
 	// create a custom resolver to use the specific nameserver for TXT lookup
-	resolver := &net.Resolver{
-		PreferGo: true,
-		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-			rd := net.Dialer{
-				Timeout: time.Second * 5,
-			}
-			return rd.DialContext(ctx, network, ns)
-		},
-	}
+	resolver := nameserverResolver(ns)
 	res, err := resolver.LookupTXT(ctx, "_atproto."+handle.String())
 	// check for NXDOMAIN
 	if errors.As(err, &dnsErr) {
@@ -95,15 +100,7 @@ func (d *BaseDirectory) ResolveHandleDNSFallback(ctx context.Context, handle syn
 	var dnsErr *net.DNSError
 	for _, ns := range d.FallbackDNSServers {
 		// create a custom resolver to use the specific nameserver for TXT lookup
-		resolver := &net.Resolver{
-			PreferGo: true,
-			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-				rd := net.Dialer{
-					Timeout: time.Second * 5,
-				}
-				return rd.DialContext(ctx, network, ns)
-			},
-		}
+		resolver := nameserverResolver(ns)
 		res, err := resolver.LookupTXT(ctx, "_atproto."+handle.String())
 		// check for NXDOMAIN
 		if errors.As(err, &dnsErr) {
@@ -34,3 +34,13 @@ var handleRequestsCoalesced = promauto.NewCounter(prometheus.CounterOpts{
 	Name: "atproto_directory_handle_requests_coalesced",
 	Help: "Number of handle requests coalesced",
 })
+
+var batchLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "atproto_directory_batch_lookups",
+	Help: "Number of DID lookups made as part of batch lookups, by DID method",
+}, []string{"method"})
+
+var batchDuplicates = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "atproto_directory_batch_duplicates",
+	Help: "Number of duplicate DIDs in batch lookups, which were only looked up once",
+})
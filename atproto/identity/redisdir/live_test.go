@@ -134,3 +134,40 @@ func TestRedisCoalesce(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestRedisPurgeBroadcast(t *testing.T) {
+	t.Skip("TODO: skipping test which requires local redis")
+	assert := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	did := syntax.DID("did:plc:abc111")
+	inner := identity.NewMockDirectory()
+	inner.Insert(identity.Identity{DID: did, Handle: syntax.HandleInvalid})
+
+	// two processes sharing the same redis
+	d1, err := NewRedisDirectory(&inner, redisLocalTestURL, time.Hour*1, time.Hour*1, time.Hour*1, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2, err := NewRedisDirectory(&inner, redisLocalTestURL, time.Hour*1, time.Hour*1, time.Hour*1, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go d2.SubscribePurges(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	_, err = d1.LookupDID(ctx, did)
+	assert.NoError(err)
+	_, hit, err := d2.LookupDIDWithCacheState(ctx, did)
+	assert.NoError(err)
+	assert.True(hit)
+
+	delete(inner.Identities, did)
+	assert.NoError(d1.Purge(ctx, did.AtIdentifier()))
+	time.Sleep(100 * time.Millisecond)
+
+	// errors from the inner directory don't round-trip through the cache exactly
+	_, err = d2.LookupDID(ctx, did)
+	assert.Error(err)
+}
@@ -34,3 +34,8 @@ var handleRequestsCoalesced = promauto.NewCounter(prometheus.CounterOpts{
 	Name: "atproto_redis_directory_handle_requests_coalesced",
 	Help: "Number of handle requests coalesced",
 })
+
+var purgesReceived = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "atproto_redis_directory_purges_received",
+	Help: "Number of cache purges received from other processes over pub/sub",
+})
@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"sync"
 	"time"
 
 	"github.com/bluesky-social/indigo/atproto/identity"
@@ -12,11 +11,15 @@ import (
 
 	"github.com/go-redis/cache/v9"
 	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
 )
 
 // prefix string for all the Redis keys this cache uses
 var redisDirPrefix string = "dir/"
 
+// Redis pub/sub channel used to broadcast purged cache keys to all processes sharing the cache
+var redisPurgeChannel string = redisDirPrefix + "purge"
+
 // Uses redis as a cache for identity lookups.
 //
 // Includes an in-process LRU cache as well (provided by the redis client library), for hot key (identities).
@@ -26,10 +29,12 @@ type RedisDirectory struct {
 	HitTTL           time.Duration
 	InvalidHandleTTL time.Duration
 
-	handleCache       *cache.Cache
-	identityCache     *cache.Cache
-	didLookupChans    sync.Map
-	handleLookupChans sync.Map
+	rdb           *redis.Client
+	handleCache   *cache.Cache
+	identityCache *cache.Cache
+	// coalesces concurrent lookups of the same identifier, so only one request goes to the inner directory
+	didLookups    singleflight.Group
+	handleLookups singleflight.Group
 }
 
 type handleEntry struct {
@@ -47,6 +52,11 @@ type identityEntry struct {
 
 var _ identity.Directory = (*RedisDirectory)(nil)
 
+// Shared (coalesced) lookups are detached from the cancellation of the first caller; see [identity.CoalescedLookupTimeout].
+func detachedContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), identity.CoalescedLookupTimeout)
+}
+
 // Creates a new caching `identity.Directory` wrapper around an existing directory, using Redis and in-process LRU for caching.
 //
 // `redisURL` contains all the redis connection config options.
@@ -78,6 +88,7 @@ func NewRedisDirectory(inner identity.Directory, redisURL string, hitTTL, errTTL
 		ErrTTL:           errTTL,
 		HitTTL:           hitTTL,
 		InvalidHandleTTL: invalidHandleTTL,
+		rdb:              rdb,
 		handleCache:      handleCache,
 		identityCache:    identityCache,
 	}, nil
@@ -181,48 +192,29 @@ func (d *RedisDirectory) ResolveHandle(ctx context.Context, h syntax.Handle) (sy
 	handleCacheMisses.Inc()
 
 	// Coalesce multiple requests for the same Handle
-	res := make(chan struct{})
-	val, loaded := d.handleLookupChans.LoadOrStore(h.String(), res)
-	if loaded {
-		handleRequestsCoalesced.Inc()
-		// Wait for the result from the pending request
-		select {
-		case <-val.(chan struct{}):
-			// The result should now be in the cache
-			err := d.handleCache.Get(ctx, redisDirPrefix+h.String(), entry)
-			if err != nil && err != cache.ErrCacheMiss {
-				return "", fmt.Errorf("identity cache read failed: %w", err)
-			}
-			if err == nil && !d.isHandleStale(&entry) { // if no error...
-				if entry.Err != nil {
-					return "", entry.Err
-				} else if entry.DID != nil {
-					return *entry.DID, nil
-				} else {
-					return "", errors.New("code flow error in redis identity directory")
-				}
-			}
-			return "", errors.New("identity not found in cache after coalesce returned")
-		case <-ctx.Done():
-			return "", ctx.Err()
+	leader := false
+	ch := d.handleLookups.DoChan(h.String(), func() (any, error) {
+		leader = true
+		lctx, cancel := detachedContext(ctx)
+		defer cancel()
+		return d.updateHandle(lctx, h), nil
+	})
+	select {
+	case res := <-ch:
+		if !leader {
+			handleRequestsCoalesced.Inc()
 		}
+		newEntry := res.Val.(handleEntry)
+		if newEntry.Err != nil {
+			return "", newEntry.Err
+		}
+		if newEntry.DID != nil {
+			return *newEntry.DID, nil
+		}
+		return "", errors.New("unexpected control-flow error")
+	case <-ctx.Done():
+		return "", ctx.Err()
 	}
-
-	// Update the Handle Entry from PLC and cache the result
-	newEntry := d.updateHandle(ctx, h)
-
-	// Cleanup the coalesce map and close the results channel
-	d.handleLookupChans.Delete(h.String())
-	// Callers waiting will now get the result from the cache
-	close(res)
-
-	if newEntry.Err != nil {
-		return "", newEntry.Err
-	}
-	if newEntry.DID != nil {
-		return *newEntry.DID, nil
-	}
-	return "", errors.New("unexpected control-flow error")
 }
 
 func (d *RedisDirectory) updateDID(ctx context.Context, did syntax.DID) identityEntry {
@@ -288,42 +280,29 @@ func (d *RedisDirectory) LookupDIDWithCacheState(ctx context.Context, did syntax
 	identityCacheMisses.Inc()
 
 	// Coalesce multiple requests for the same DID
-	res := make(chan struct{})
-	val, loaded := d.didLookupChans.LoadOrStore(did.String(), res)
-	if loaded {
-		identityRequestsCoalesced.Inc()
-		// Wait for the result from the pending request
-		select {
-		case <-val.(chan struct{}):
-			// The result should now be in the cache
-			err = d.identityCache.Get(ctx, redisDirPrefix+did.String(), &entry)
-			if err != nil && err != cache.ErrCacheMiss {
-				return nil, false, fmt.Errorf("identity cache read failed: %w", err)
-			}
-			if err == nil && !d.isIdentityStale(&entry) { // if no error...
-				return entry.Identity, false, entry.Err
-			}
-			return nil, false, errors.New("identity not found in cache after coalesce returned")
-		case <-ctx.Done():
-			return nil, false, ctx.Err()
+	leader := false
+	ch := d.didLookups.DoChan(did.String(), func() (any, error) {
+		leader = true
+		lctx, cancel := detachedContext(ctx)
+		defer cancel()
+		return d.updateDID(lctx, did), nil
+	})
+	select {
+	case res := <-ch:
+		if !leader {
+			identityRequestsCoalesced.Inc()
 		}
+		newEntry := res.Val.(identityEntry)
+		if newEntry.Err != nil {
+			return nil, false, newEntry.Err
+		}
+		if newEntry.Identity != nil {
+			return newEntry.Identity, false, nil
+		}
+		return nil, false, errors.New("unexpected control-flow error")
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
 	}
-
-	// Update the Identity Entry from PLC and cache the result
-	newEntry := d.updateDID(ctx, did)
-
-	// Cleanup the coalesce map and close the results channel
-	d.didLookupChans.Delete(did.String())
-	// Callers waiting will now get the result from the cache
-	close(res)
-
-	if newEntry.Err != nil {
-		return nil, false, newEntry.Err
-	}
-	if newEntry.Identity != nil {
-		return newEntry.Identity, false, nil
-	}
-	return nil, false, errors.New("unexpected control-flow error")
 }
 
 func (d *RedisDirectory) LookupHandle(ctx context.Context, h syntax.Handle) (*identity.Identity, error) {
@@ -364,23 +343,54 @@ func (d *RedisDirectory) Lookup(ctx context.Context, a syntax.AtIdentifier) (*id
 	return nil, errors.New("at-identifier neither a Handle nor a DID")
 }
 
+// Removes the identifier from the shared Redis cache and the local in-process cache.
+//
+// The purged key is also published over Redis pub/sub, so that other processes running [RedisDirectory.SubscribePurges] drop it from their in-process caches.
 func (d *RedisDirectory) Purge(ctx context.Context, a syntax.AtIdentifier) error {
+	var key string
+	var c *cache.Cache
 	handle, err := a.AsHandle()
 	if err == nil { // if not an error, is a handle
-		handle = handle.Normalize()
-		err = d.handleCache.Delete(ctx, redisDirPrefix+handle.String())
-		if err == cache.ErrCacheMiss {
-			return nil
-		}
+		key = redisDirPrefix + handle.Normalize().String()
+		c = d.handleCache
+	} else if did, err := a.AsDID(); err == nil { // if not an error, is a DID
+		key = redisDirPrefix + did.String()
+		c = d.identityCache
+	} else {
+		return errors.New("at-identifier neither a Handle nor a DID")
+	}
+
+	err = c.Delete(ctx, key)
+	if err != nil && err != cache.ErrCacheMiss {
 		return err
 	}
-	did, err := a.AsDID()
-	if err == nil { // if not an error, is a DID
-		err = d.identityCache.Delete(ctx, redisDirPrefix+did.String())
-		if err == cache.ErrCacheMiss {
-			return nil
+	if err := d.rdb.Publish(ctx, redisPurgeChannel, key).Err(); err != nil {
+		return fmt.Errorf("identity cache purge broadcast failed: %w", err)
+	}
+	return nil
+}
+
+// Listens for purges broadcast by other processes sharing the Redis cache, and removes the purged keys from the in-process cache. Blocks until the context is cancelled.
+func (d *RedisDirectory) SubscribePurges(ctx context.Context) error {
+	sub := d.rdb.Subscribe(ctx, redisPurgeChannel)
+	defer sub.Close()
+	// wait for confirmation that subscription is active
+	if _, err := sub.Receive(ctx); err != nil {
+		return fmt.Errorf("subscribing to identity cache purges: %w", err)
+	}
+	msgs := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-msgs:
+			if !ok {
+				return errors.New("identity cache purge subscription closed")
+			}
+			// keys are unique across the two caches (handles and DIDs have distinct syntax)
+			d.handleCache.DeleteFromLocalCache(msg.Payload)
+			d.identityCache.DeleteFromLocalCache(msg.Payload)
+			purgesReceived.Inc()
 		}
-		return err
 	}
-	return errors.New("at-identifier neither a Handle nor a DID")
 }
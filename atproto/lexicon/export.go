@@ -0,0 +1,491 @@
+package lexicon
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Regex patterns corresponding to Lexicon string formats which don't have an equivalent JSON Schema "format". These are copied from the 'atproto/syntax' package.
+var exportFormatPatterns = map[string]string{
+	"at-uri":     `^at:\/\/([a-zA-Z0-9._:%-]+)(\/([a-zA-Z0-9-.]+)(\/([a-zA-Z0-9_~.:-]{1,512}))?)?$`,
+	"cid":        `^[a-zA-Z0-9+=]{8,256}$`,
+	"did":        `^did:[a-z]+:[a-zA-Z0-9._:%-]*[a-zA-Z0-9._-]$`,
+	"handle":     `^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)+[a-zA-Z]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`,
+	"language":   `^(i|[a-z]{2,3})(-[a-zA-Z0-9]+)*$`,
+	"nsid":       `^[a-zA-Z]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)+(\.[a-zA-Z]([a-zA-Z]{0,61}[a-zA-Z])?)$`,
+	"record-key": `^[a-zA-Z0-9_~.:-]{1,512}$`,
+	"tid":        `^[234567abcdefghij][234567abcdefghijklmnopqrstuvwxyz]{12}$`,
+}
+
+// Lexicon string formats which map directly to JSON Schema "format" values.
+var exportFormatNames = map[string]string{
+	"datetime": "date-time",
+	"uri":      "uri",
+}
+
+// URI of the JSON Schema dialect used for exported documents.
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// Converts a Lexicon reference (NSID with optional fragment) to a name usable as a JSON Schema or OpenAPI component key.
+//
+// The '#main' fragment is dropped, and any other fragment is appended with a '.' separator (eg, 'app.bsky.feed.defs#postView' becomes 'app.bsky.feed.defs.postView').
+func ExportName(ref string) string {
+	ref = strings.TrimSuffix(ref, "#main")
+	return strings.Replace(ref, "#", ".", 1)
+}
+
+// Returns the '$type' value which data matching a Lexicon reference would have in a union.
+func unionTypeName(ref string) string {
+	return strings.TrimSuffix(ref, "#main")
+}
+
+// internal helper type for converting Lexicon schema definitions to JSON Schema objects
+type schemaExporter struct {
+	// prefix prepended to exported names when generating "$ref" values
+	refPrefix string
+}
+
+func (e *schemaExporter) ref(fullRef string) map[string]any {
+	return map[string]any{"$ref": e.refPrefix + ExportName(fullRef)}
+}
+
+// Converts a single Lexicon schema definition (as found in `Schema.Def`) to a JSON Schema object.
+func (e *schemaExporter) convert(def any) (map[string]any, error) {
+	out := map[string]any{}
+	switch v := def.(type) {
+	case SchemaRecord:
+		obj, err := e.convert(v.Record)
+		if err != nil {
+			return nil, err
+		}
+		return obj, nil
+	case SchemaNull:
+		out["type"] = "null"
+		setDescription(out, v.Description)
+	case SchemaBoolean:
+		out["type"] = "boolean"
+		setDescription(out, v.Description)
+		if v.Default != nil {
+			out["default"] = *v.Default
+		}
+		if v.Const != nil {
+			out["const"] = *v.Const
+		}
+	case SchemaInteger:
+		out["type"] = "integer"
+		setDescription(out, v.Description)
+		if v.Minimum != nil {
+			out["minimum"] = *v.Minimum
+		}
+		if v.Maximum != nil {
+			out["maximum"] = *v.Maximum
+		}
+		if len(v.Enum) > 0 {
+			out["enum"] = v.Enum
+		}
+		if v.Default != nil {
+			out["default"] = *v.Default
+		}
+		if v.Const != nil {
+			out["const"] = *v.Const
+		}
+	case SchemaString:
+		out["type"] = "string"
+		setDescription(out, v.Description)
+		// Lexicon 'maxLength' counts UTF-8 bytes, which is always at least the number of code points, so it is safe to use as a (looser) JSON Schema bound. Likewise, 'minGraphemes' is a safe lower bound on code points. The other length limits can't be expressed in JSON Schema and are skipped.
+		if v.MaxLength != nil {
+			out["maxLength"] = *v.MaxLength
+		}
+		if v.MinGraphemes != nil {
+			out["minLength"] = *v.MinGraphemes
+		}
+		if len(v.Enum) > 0 {
+			out["enum"] = v.Enum
+		}
+		if len(v.KnownValues) > 0 {
+			out["examples"] = v.KnownValues
+		}
+		if v.Default != nil {
+			out["default"] = *v.Default
+		}
+		if v.Const != nil {
+			out["const"] = *v.Const
+		}
+		if v.Format != nil {
+			if f, ok := exportFormatNames[*v.Format]; ok {
+				out["format"] = f
+			} else if p, ok := exportFormatPatterns[*v.Format]; ok {
+				out["pattern"] = p
+			} else if *v.Format == "at-identifier" {
+				out["anyOf"] = []any{
+					map[string]any{"pattern": exportFormatPatterns["did"]},
+					map[string]any{"pattern": exportFormatPatterns["handle"]},
+				}
+			}
+		}
+	case SchemaBytes:
+		// bytes are represented in JSON as an object with base64-encoded '$bytes' field
+		b64 := map[string]any{
+			"type":             "string",
+			"contentEncoding":  "base64",
+			"contentMediaType": "application/octet-stream",
+		}
+		out["type"] = "object"
+		setDescription(out, v.Description)
+		out["properties"] = map[string]any{"$bytes": b64}
+		out["required"] = []string{"$bytes"}
+	case SchemaCIDLink:
+		out["type"] = "object"
+		setDescription(out, v.Description)
+		out["properties"] = map[string]any{"$link": map[string]any{"type": "string"}}
+		out["required"] = []string{"$link"}
+	case SchemaArray:
+		items, err := e.convert(v.Items.Inner)
+		if err != nil {
+			return nil, err
+		}
+		out["type"] = "array"
+		setDescription(out, v.Description)
+		out["items"] = items
+		if v.MinLength != nil {
+			out["minItems"] = *v.MinLength
+		}
+		if v.MaxLength != nil {
+			out["maxItems"] = *v.MaxLength
+		}
+	case SchemaObject:
+		props := map[string]any{}
+		for k, p := range v.Properties {
+			ps, err := e.convert(p.Inner)
+			if err != nil {
+				return nil, fmt.Errorf("object property %s: %w", k, err)
+			}
+			if v.IsNullable(k) {
+				ps = map[string]any{"anyOf": []any{ps, map[string]any{"type": "null"}}}
+			}
+			props[k] = ps
+		}
+		out["type"] = "object"
+		setDescription(out, v.Description)
+		out["properties"] = props
+		if len(v.Required) > 0 {
+			out["required"] = v.Required
+		}
+	case SchemaBlob:
+		size := map[string]any{"type": "integer"}
+		if v.MaxSize != nil {
+			size["maximum"] = *v.MaxSize
+		}
+		mimeType := map[string]any{"type": "string"}
+		if len(v.Accept) > 0 {
+			mimeType["examples"] = v.Accept
+		}
+		out["type"] = "object"
+		setDescription(out, v.Description)
+		out["properties"] = map[string]any{
+			"$type": map[string]any{"const": "blob"},
+			"ref": map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"$link": map[string]any{"type": "string"}},
+				"required":   []string{"$link"},
+			},
+			"mimeType": mimeType,
+			"size":     size,
+		}
+		out["required"] = []string{"$type", "ref", "mimeType", "size"}
+	case SchemaParams:
+		props := map[string]any{}
+		for k, p := range v.Properties {
+			ps, err := e.convert(p.Inner)
+			if err != nil {
+				return nil, fmt.Errorf("params property %s: %w", k, err)
+			}
+			props[k] = ps
+		}
+		out["type"] = "object"
+		setDescription(out, v.Description)
+		out["properties"] = props
+		if len(v.Required) > 0 {
+			out["required"] = v.Required
+		}
+	case SchemaToken:
+		out["type"] = "string"
+		setDescription(out, v.Description)
+		out["const"] = v.fullName
+	case SchemaRef:
+		out = e.ref(v.fullRef)
+		setDescription(out, v.Description)
+	case SchemaUnion:
+		variants := []any{}
+		for _, ref := range v.fullRefs {
+			variants = append(variants, map[string]any{
+				"allOf": []any{
+					e.ref(ref),
+					map[string]any{
+						"properties": map[string]any{"$type": map[string]any{"const": unionTypeName(ref)}},
+						"required":   []string{"$type"},
+					},
+				},
+			})
+		}
+		if v.Closed == nil || !*v.Closed {
+			// open unions may contain any object with a '$type'
+			variants = append(variants, map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"$type": map[string]any{"type": "string"}},
+				"required":   []string{"$type"},
+			})
+		}
+		setDescription(out, v.Description)
+		out["anyOf"] = variants
+	case SchemaUnknown:
+		out["type"] = "object"
+		setDescription(out, v.Description)
+	default:
+		return nil, fmt.Errorf("can't export schema type: %v", reflect.TypeOf(v))
+	}
+	return out, nil
+}
+
+func setDescription(out map[string]any, desc *string) {
+	if desc != nil && *desc != "" {
+		out["description"] = *desc
+	}
+}
+
+// Returns all schema IDs in the catalog, in sorted order.
+func (c *BaseCatalog) sortedIDs() []string {
+	ids := make([]string, 0, len(c.schemas))
+	for id := range c.schemas {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Converts all definitions in the catalog which describe data (records, objects, tokens, etc) in to JSON Schema definitions, keyed by `ExportName()`.
+//
+// Queries, procedures, and subscriptions are skipped. 'refPrefix' is prepended to names in any "$ref" values: eg, "#/$defs/" or "#/components/schemas/".
+func (c *BaseCatalog) exportDefs(refPrefix string) (map[string]any, error) {
+	e := schemaExporter{refPrefix: refPrefix}
+	defs := map[string]any{}
+	for _, id := range c.sortedIDs() {
+		s := c.schemas[id]
+		switch s.Def.(type) {
+		case SchemaQuery, SchemaProcedure, SchemaSubscription:
+			continue
+		}
+		name := ExportName(id)
+		if _, ok := defs[name]; ok {
+			return nil, fmt.Errorf("conflicting export name for schema: %s", id)
+		}
+		out, err := e.convert(s.Def)
+		if err != nil {
+			return nil, fmt.Errorf("exporting %s: %w", id, err)
+		}
+		if rec, ok := s.Def.(SchemaRecord); ok {
+			// records always include their own type
+			props, _ := out["properties"].(map[string]any)
+			props["$type"] = map[string]any{"const": unionTypeName(id)}
+			out["required"] = append([]string{"$type"}, rec.Record.Required...)
+			if rec.Description != nil && *rec.Description != "" {
+				out["description"] = *rec.Description
+			}
+		}
+		defs[name] = out
+	}
+	return defs, nil
+}
+
+// Exports all record and data definitions in the catalog as a single JSON Schema (draft 2020-12) document.
+//
+// Definitions are found under "$defs", keyed by `ExportName()`. The returned document can be serialized directly with 'encoding/json'.
+func (c *BaseCatalog) ExportJSONSchema() (map[string]any, error) {
+	defs, err := c.exportDefs("#/$defs/")
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"$schema": JSONSchemaDialect,
+		"$defs":   defs,
+	}, nil
+}
+
+// Exports all query and procedure endpoints in the catalog as an OpenAPI 3.1 document.
+//
+// Endpoints are mapped to "/xrpc/{nsid}" paths, with queries as GET requests and procedures as POST requests. All data definitions are included under "components/schemas". Subscriptions can not be described by OpenAPI and are skipped.
+func (c *BaseCatalog) ExportOpenAPI(title, version string) (map[string]any, error) {
+	schemas, err := c.exportDefs("#/components/schemas/")
+	if err != nil {
+		return nil, err
+	}
+	schemas["XRPCError"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"error":   map[string]any{"type": "string"},
+			"message": map[string]any{"type": "string"},
+		},
+		"required": []string{"error"},
+	}
+
+	e := schemaExporter{refPrefix: "#/components/schemas/"}
+	paths := map[string]any{}
+	for _, id := range c.sortedIDs() {
+		s := c.schemas[id]
+		nsid := strings.TrimSuffix(id, "#main")
+		var op map[string]any
+		var method string
+		switch v := s.Def.(type) {
+		case SchemaQuery:
+			method = "get"
+			op, err = e.operation(nsid, v.Description, v.Parameters, nil, v.Output, v.Errors)
+		case SchemaProcedure:
+			method = "post"
+			op, err = e.operation(nsid, v.Description, v.Parameters, v.Input, v.Output, v.Errors)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("exporting %s: %w", id, err)
+		}
+		paths["/xrpc/"+nsid] = map[string]any{method: op}
+	}
+
+	return map[string]any{
+		"openapi":           "3.1.0",
+		"jsonSchemaDialect": JSONSchemaDialect,
+		"info": map[string]any{
+			"title":   title,
+			"version": version,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+		},
+	}, nil
+}
+
+// Converts XRPC endpoint metadata in to an OpenAPI operation object.
+func (e *schemaExporter) operation(nsid string, desc *string, params SchemaParams, input, output *SchemaBody, errs []SchemaError) (map[string]any, error) {
+	op := map[string]any{
+		"operationId": nsid,
+	}
+	if idx := strings.LastIndex(nsid, "."); idx > 0 {
+		op["tags"] = []string{nsid[:idx]}
+	}
+	setDescription(op, desc)
+
+	names := make([]string, 0, len(params.Properties))
+	for k := range params.Properties {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	parameters := []any{}
+	for _, k := range names {
+		ps, err := e.convert(params.Properties[k].Inner)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", k, err)
+		}
+		p := map[string]any{
+			"name":     k,
+			"in":       "query",
+			"required": false,
+			"schema":   ps,
+		}
+		for _, r := range params.Required {
+			if r == k {
+				p["required"] = true
+			}
+		}
+		if _, ok := params.Properties[k].Inner.(SchemaArray); ok {
+			// repeated query parameters: ?tag=a&tag=b
+			p["style"] = "form"
+			p["explode"] = true
+		}
+		if d, ok := ps["description"]; ok {
+			p["description"] = d
+		}
+		parameters = append(parameters, p)
+	}
+	if len(parameters) > 0 {
+		op["parameters"] = parameters
+	}
+
+	if input != nil {
+		content, err := e.bodyContent(input)
+		if err != nil {
+			return nil, fmt.Errorf("input: %w", err)
+		}
+		reqBody := map[string]any{
+			"required": true,
+			"content":  content,
+		}
+		setDescription(reqBody, input.Description)
+		op["requestBody"] = reqBody
+	}
+
+	success := map[string]any{"description": "OK"}
+	if output != nil {
+		content, err := e.bodyContent(output)
+		if err != nil {
+			return nil, fmt.Errorf("output: %w", err)
+		}
+		success["content"] = content
+		setDescription(success, output.Description)
+	}
+
+	errSchema := map[string]any{"$ref": e.refPrefix + "XRPCError"}
+	if len(errs) > 0 {
+		errNames := []string{}
+		for _, se := range errs {
+			errNames = append(errNames, se.Name)
+		}
+		errSchema = map[string]any{
+			"allOf": []any{
+				errSchema,
+				map[string]any{
+					"properties": map[string]any{
+						"error": map[string]any{"type": "string", "examples": errNames},
+					},
+				},
+			},
+		}
+	}
+	errContent := map[string]any{
+		"application/json": map[string]any{"schema": errSchema},
+	}
+	op["responses"] = map[string]any{
+		"200": success,
+		"400": map[string]any{
+			"description": "Bad Request (including endpoint-specific errors)",
+			"content":     errContent,
+		},
+		"401": map[string]any{
+			"description": "Authentication Required",
+			"content":     errContent,
+		},
+		"500": map[string]any{
+			"description": "Internal Server Error",
+			"content":     errContent,
+		},
+	}
+	return op, nil
+}
+
+// Converts an XRPC body definition to an OpenAPI "content" map, keyed by encoding (mimetype).
+func (e *schemaExporter) bodyContent(body *SchemaBody) (map[string]any, error) {
+	media := map[string]any{}
+	if body.Schema != nil {
+		s, err := e.convert(body.Schema.Inner)
+		if err != nil {
+			return nil, err
+		}
+		media["schema"] = s
+	} else if body.Encoding != "application/json" {
+		// arbitrary binary body (eg, blob uploads or CAR files)
+		media["schema"] = map[string]any{"contentMediaType": body.Encoding}
+	}
+	return map[string]any{body.Encoding: media}, nil
+}
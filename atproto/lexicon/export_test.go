@@ -0,0 +1,97 @@
+package lexicon
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportJSONSchema(t *testing.T) {
+	assert := assert.New(t)
+
+	cat := NewBaseCatalog()
+	if err := cat.LoadDirectory("testdata/catalog"); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := cat.ExportJSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = json.Marshal(doc)
+	assert.NoError(err)
+
+	defs := doc["$defs"].(map[string]any)
+	assert.NotContains(defs, "example.lexicon.query")
+	assert.Contains(defs, "com.atproto.label.defs.label")
+
+	rec := defs["example.lexicon.record"].(map[string]any)
+	assert.Equal("object", rec["type"])
+	assert.Equal([]string{"$type", "integer"}, rec["required"])
+	props := rec["properties"].(map[string]any)
+	assert.Equal(map[string]any{"const": "example.lexicon.record"}, props["$type"])
+
+	nullable := props["nullableString"].(map[string]any)
+	assert.Contains(nullable, "anyOf")
+
+	label := defs["com.atproto.label.defs.label"].(map[string]any)
+	labelProps := label["properties"].(map[string]any)
+	src := labelProps["src"].(map[string]any)
+	assert.Equal(exportFormatPatterns["did"], src["pattern"])
+	cts := labelProps["cts"].(map[string]any)
+	assert.Equal("date-time", cts["format"])
+}
+
+func TestExportOpenAPI(t *testing.T) {
+	assert := assert.New(t)
+
+	cat := NewBaseCatalog()
+	if err := cat.LoadDirectory("testdata/catalog"); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := cat.ExportOpenAPI("Example API", "0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = json.Marshal(doc)
+	assert.NoError(err)
+
+	assert.Equal("3.1.0", doc["openapi"])
+	paths := doc["paths"].(map[string]any)
+	path := paths["/xrpc/example.lexicon.query"].(map[string]any)
+	op := path["get"].(map[string]any)
+	assert.Equal("example.lexicon.query", op["operationId"])
+
+	params := op["parameters"].([]any)
+	assert.Equal(6, len(params))
+	for _, raw := range params {
+		p := raw.(map[string]any)
+		switch p["name"] {
+		case "string":
+			assert.Equal(true, p["required"])
+		case "array":
+			assert.Equal(true, p["explode"])
+		case "handle":
+			assert.Equal(exportFormatPatterns["handle"], p["schema"].(map[string]any)["pattern"])
+		}
+	}
+
+	responses := op["responses"].(map[string]any)
+	assert.Contains(responses, "200")
+	assert.Contains(responses, "400")
+
+	components := doc["components"].(map[string]any)
+	schemas := components["schemas"].(map[string]any)
+	assert.Contains(schemas, "example.lexicon.record")
+	assert.Contains(schemas, "XRPCError")
+}
+
+func TestExportName(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("app.bsky.feed.post", ExportName("app.bsky.feed.post"))
+	assert.Equal("app.bsky.feed.post", ExportName("app.bsky.feed.post#main"))
+	assert.Equal("app.bsky.feed.defs.postView", ExportName("app.bsky.feed.defs#postView"))
+}
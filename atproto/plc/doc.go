@@ -0,0 +1,8 @@
+/*
+Package plc implements the did:plc operation log: parsing, signing, and verification of operations, and derivation of DID documents.
+
+Operations come in three types: [RegularOp] ("plc_operation"), [TombstoneOp] ("plc_tombstone"), and the deprecated [LegacyOp] ("create"), which is only valid as the genesis operation. The DID for an identity is derived from the hash of its signed genesis operation.
+
+[VerifyOpLog] checks a linear operation log (as returned by the "/{did}/log" PLC directory endpoint). [VerifyAuditLog] checks a full audit log (including nullified operations and timestamps, as returned by "/{did}/log/audit" or the "/export" stream), and enforces the rules for recovery of an identity by a higher-priority rotation key within the 72 hour recovery window.
+*/
+package plc
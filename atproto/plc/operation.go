@@ -0,0 +1,342 @@
+package plc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bluesky-social/indigo/atproto/crypto"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/multiformats/go-multihash"
+)
+
+var (
+	ErrInvalidOperation = errors.New("invalid PLC operation")
+	ErrInvalidSignature = errors.New("PLC operation signature not valid for any rotation key")
+)
+
+// Common interface for all PLC operation types.
+type Operation interface {
+	// The "type" field: "plc_operation", "plc_tombstone", or "create"
+	OpType() string
+	// CID of the previous operation, or empty string for a genesis operation
+	PrevCID() string
+	// Raw signature bytes (decoded from base64url), or nil for an unsigned operation
+	Signature() ([]byte, error)
+	// DAG-CBOR encoding of the operation without the "sig" field. This is the data which gets signed.
+	UnsignedCBOR() ([]byte, error)
+	// DAG-CBOR encoding of the full (signed) operation
+	SignedCBOR() ([]byte, error)
+	// Checks field syntax, not including the signature
+	Validate() error
+
+	setSig(sig string)
+}
+
+// A service entry in a PLC operation
+type OpService struct {
+	Type     string `json:"type"`
+	Endpoint string `json:"endpoint"`
+}
+
+// Regular ("plc_operation") PLC operation, used both for genesis and updates.
+type RegularOp struct {
+	Type                string               `json:"type"`
+	RotationKeys        []string             `json:"rotationKeys"`
+	VerificationMethods map[string]string    `json:"verificationMethods"`
+	AlsoKnownAs         []string             `json:"alsoKnownAs"`
+	Services            map[string]OpService `json:"services"`
+	Prev                *string              `json:"prev"`
+	Sig                 *string              `json:"sig,omitempty"`
+}
+
+// Tombstone ("plc_tombstone") PLC operation, which deactivates the DID.
+type TombstoneOp struct {
+	Type string  `json:"type"`
+	Prev string  `json:"prev"`
+	Sig  *string `json:"sig,omitempty"`
+}
+
+// Deprecated genesis operation format ("create"). Only valid as the first operation in a log.
+type LegacyOp struct {
+	Type        string  `json:"type"`
+	SigningKey  string  `json:"signingKey"`
+	RecoveryKey string  `json:"recoveryKey"`
+	Handle      string  `json:"handle"`
+	Service     string  `json:"service"`
+	Prev        *string `json:"prev"`
+	Sig         *string `json:"sig,omitempty"`
+}
+
+// Parses the JSON representation of any PLC operation type.
+//
+// Unknown fields are rejected, because they would be silently dropped from the signed data.
+func ParseOperation(b []byte) (Operation, error) {
+	var typ struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(b, &typ); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidOperation, err)
+	}
+	var op Operation
+	switch typ.Type {
+	case "plc_operation":
+		op = &RegularOp{}
+	case "plc_tombstone":
+		op = &TombstoneOp{}
+	case "create":
+		op = &LegacyOp{}
+	default:
+		return nil, fmt.Errorf("%w: unknown type: %q", ErrInvalidOperation, typ.Type)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(op); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidOperation, err)
+	}
+	if err := op.Validate(); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// encodes any operation struct as DAG-CBOR, optionally removing the signature
+func opCBOR(op any, withSig bool) ([]byte, error) {
+	b, err := json.Marshal(op)
+	if err != nil {
+		return nil, err
+	}
+	var obj map[string]any
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return nil, err
+	}
+	if !withSig {
+		delete(obj, "sig")
+	} else if _, ok := obj["sig"]; !ok {
+		return nil, fmt.Errorf("%w: operation is not signed", ErrInvalidOperation)
+	}
+	return cbor.DumpObject(obj)
+}
+
+func decodeSig(sig *string) ([]byte, error) {
+	if sig == nil {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(*sig)
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature encoding: %w", ErrInvalidOperation, err)
+	}
+	return b, nil
+}
+
+func validateDIDKey(k string) error {
+	if _, err := crypto.ParsePublicDIDKey(k); err != nil {
+		return fmt.Errorf("%w: invalid key %q: %w", ErrInvalidOperation, k, err)
+	}
+	return nil
+}
+
+func (op *RegularOp) OpType() string { return op.Type }
+
+func (op *RegularOp) PrevCID() string {
+	if op.Prev == nil {
+		return ""
+	}
+	return *op.Prev
+}
+
+func (op *RegularOp) Signature() ([]byte, error) { return decodeSig(op.Sig) }
+
+func (op *RegularOp) UnsignedCBOR() ([]byte, error) { return opCBOR(op, false) }
+
+func (op *RegularOp) SignedCBOR() ([]byte, error) { return opCBOR(op, true) }
+
+func (op *RegularOp) setSig(sig string) { op.Sig = &sig }
+
+func (op *RegularOp) Validate() error {
+	if op.Type != "plc_operation" {
+		return fmt.Errorf("%w: unexpected type: %q", ErrInvalidOperation, op.Type)
+	}
+	if len(op.RotationKeys) < 1 || len(op.RotationKeys) > 5 {
+		return fmt.Errorf("%w: must have between 1 and 5 rotation keys", ErrInvalidOperation)
+	}
+	seen := map[string]bool{}
+	for _, k := range op.RotationKeys {
+		if seen[k] {
+			return fmt.Errorf("%w: duplicate rotation key: %s", ErrInvalidOperation, k)
+		}
+		seen[k] = true
+		if err := validateDIDKey(k); err != nil {
+			return err
+		}
+	}
+	if op.VerificationMethods == nil || op.AlsoKnownAs == nil || op.Services == nil {
+		return fmt.Errorf("%w: missing required field", ErrInvalidOperation)
+	}
+	for _, k := range op.VerificationMethods {
+		if err := validateDIDKey(k); err != nil {
+			return err
+		}
+	}
+	if op.Prev != nil {
+		if _, err := syntax.ParseCID(*op.Prev); err != nil {
+			return fmt.Errorf("%w: prev: %w", ErrInvalidOperation, err)
+		}
+	}
+	return nil
+}
+
+func (op *TombstoneOp) OpType() string { return op.Type }
+
+func (op *TombstoneOp) PrevCID() string { return op.Prev }
+
+func (op *TombstoneOp) Signature() ([]byte, error) { return decodeSig(op.Sig) }
+
+func (op *TombstoneOp) UnsignedCBOR() ([]byte, error) { return opCBOR(op, false) }
+
+func (op *TombstoneOp) SignedCBOR() ([]byte, error) { return opCBOR(op, true) }
+
+func (op *TombstoneOp) setSig(sig string) { op.Sig = &sig }
+
+func (op *TombstoneOp) Validate() error {
+	if op.Type != "plc_tombstone" {
+		return fmt.Errorf("%w: unexpected type: %q", ErrInvalidOperation, op.Type)
+	}
+	if _, err := syntax.ParseCID(op.Prev); err != nil {
+		return fmt.Errorf("%w: prev: %w", ErrInvalidOperation, err)
+	}
+	return nil
+}
+
+func (op *LegacyOp) OpType() string { return op.Type }
+
+func (op *LegacyOp) PrevCID() string {
+	if op.Prev == nil {
+		return ""
+	}
+	return *op.Prev
+}
+
+func (op *LegacyOp) Signature() ([]byte, error) { return decodeSig(op.Sig) }
+
+func (op *LegacyOp) UnsignedCBOR() ([]byte, error) { return opCBOR(op, false) }
+
+func (op *LegacyOp) SignedCBOR() ([]byte, error) { return opCBOR(op, true) }
+
+func (op *LegacyOp) setSig(sig string) { op.Sig = &sig }
+
+func (op *LegacyOp) Validate() error {
+	if op.Type != "create" {
+		return fmt.Errorf("%w: unexpected type: %q", ErrInvalidOperation, op.Type)
+	}
+	if op.Prev != nil {
+		return fmt.Errorf("%w: legacy create operation must be genesis", ErrInvalidOperation)
+	}
+	if err := validateDIDKey(op.SigningKey); err != nil {
+		return err
+	}
+	return validateDIDKey(op.RecoveryKey)
+}
+
+// Converts a legacy "create" operation to the equivalent regular operation, as specified by the PLC method.
+func (op *LegacyOp) Normalize() *RegularOp {
+	handle := op.Handle
+	if !strings.HasPrefix(handle, "at://") {
+		handle = "at://" + handle
+	}
+	endpoint := op.Service
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		endpoint = "https://" + endpoint
+	}
+	return &RegularOp{
+		Type:         "plc_operation",
+		RotationKeys: []string{op.RecoveryKey, op.SigningKey},
+		VerificationMethods: map[string]string{
+			"atproto": op.SigningKey,
+		},
+		AlsoKnownAs: []string{handle},
+		Services: map[string]OpService{
+			"atproto_pds": {
+				Type:     "AtprotoPersonalDataServer",
+				Endpoint: endpoint,
+			},
+		},
+		Prev: nil,
+		Sig:  op.Sig,
+	}
+}
+
+// Computes the CID (string) of a signed operation: CIDv1, dag-cbor, sha2-256.
+func OpCID(op Operation) (string, error) {
+	b, err := op.SignedCBOR()
+	if err != nil {
+		return "", err
+	}
+	c, err := cid.NewPrefixV1(cid.DagCBOR, multihash.SHA2_256).Sum(b)
+	if err != nil {
+		return "", err
+	}
+	return c.String(), nil
+}
+
+// Computes the DID for a signed genesis operation.
+func DIDForGenesis(op Operation) (syntax.DID, error) {
+	if op.PrevCID() != "" || op.OpType() == "plc_tombstone" {
+		return "", fmt.Errorf("%w: not a genesis operation", ErrInvalidOperation)
+	}
+	b, err := op.SignedCBOR()
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(b)
+	enc := strings.ToLower(base32.StdEncoding.EncodeToString(h[:]))
+	return syntax.DID("did:plc:" + enc[:24]), nil
+}
+
+// Signs the operation in-place with the given rotation key.
+func SignOp(op Operation, priv crypto.PrivateKey) error {
+	b, err := op.UnsignedCBOR()
+	if err != nil {
+		return err
+	}
+	sig, err := priv.HashAndSign(b)
+	if err != nil {
+		return err
+	}
+	op.setSig(base64.RawURLEncoding.EncodeToString(sig))
+	return nil
+}
+
+// Verifies the operation signature against a list of rotation keys (as did:key strings), returning the index of the matching key.
+func VerifySignature(op Operation, rotationKeys []string) (int, error) {
+	sig, err := op.Signature()
+	if err != nil {
+		return -1, err
+	}
+	if sig == nil {
+		return -1, fmt.Errorf("%w: operation is not signed", ErrInvalidOperation)
+	}
+	b, err := op.UnsignedCBOR()
+	if err != nil {
+		return -1, err
+	}
+	for i, k := range rotationKeys {
+		pub, err := crypto.ParsePublicDIDKey(k)
+		if err != nil {
+			continue
+		}
+		// historical operations include some high-S signatures
+		if err := pub.HashAndVerifyLenient(b, sig); err == nil {
+			return i, nil
+		}
+	}
+	return -1, ErrInvalidSignature
+}
@@ -0,0 +1,270 @@
+package plc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bluesky-social/indigo/api"
+	"github.com/bluesky-social/indigo/atproto/crypto"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func genKey(t *testing.T) (crypto.PrivateKey, string) {
+	priv, err := crypto.GeneratePrivateKeyK256()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := priv.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv, pub.DIDKey()
+}
+
+func testOp(rotationKeys []string, handle string, prev string) *RegularOp {
+	op := &RegularOp{
+		Type:                "plc_operation",
+		RotationKeys:        rotationKeys,
+		VerificationMethods: map[string]string{"atproto": rotationKeys[0]},
+		AlsoKnownAs:         []string{"at://" + handle},
+		Services: map[string]OpService{
+			"atproto_pds": {Type: "AtprotoPersonalDataServer", Endpoint: "https://pds.example.com"},
+		},
+	}
+	if prev != "" {
+		op.Prev = &prev
+	}
+	return op
+}
+
+func signed(t *testing.T, op Operation, priv crypto.PrivateKey) Operation {
+	if err := SignOp(op, priv); err != nil {
+		t.Fatal(err)
+	}
+	return op
+}
+
+func mustCID(t *testing.T, op Operation) string {
+	c, err := OpCID(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestLegacyGenesisDID(t *testing.T) {
+	assert := assert.New(t)
+
+	priv, signingKey := genKey(t)
+	_, recoveryKey := genKey(t)
+	op := &LegacyOp{
+		Type:        "create",
+		SigningKey:  signingKey,
+		RecoveryKey: recoveryKey,
+		Handle:      "alice.example.com",
+		Service:     "https://pds.example.com",
+	}
+	signed(t, op, priv)
+
+	// compare against the original (cbor-gen) implementation
+	ref := api.CreateOp{
+		Type:        op.Type,
+		SigningKey:  op.SigningKey,
+		RecoveryKey: op.RecoveryKey,
+		Handle:      op.Handle,
+		Service:     op.Service,
+		Sig:         *op.Sig,
+	}
+	buf := new(bytes.Buffer)
+	assert.NoError(ref.MarshalCBOR(buf))
+	h := sha256.Sum256(buf.Bytes())
+	expected := "did:plc:" + strings.ToLower(base32.StdEncoding.EncodeToString(h[:]))[:24]
+
+	did, err := DIDForGenesis(op)
+	assert.NoError(err)
+	assert.Equal(expected, did.String())
+
+	state, err := VerifyOpLog(did, []Operation{op})
+	assert.NoError(err)
+	assert.Equal([]string{recoveryKey, signingKey}, state.Data.RotationKeys)
+	assert.Equal([]string{"at://alice.example.com"}, state.Data.AlsoKnownAs)
+
+	doc := state.Data.DIDDocument()
+	assert.Equal(did, doc.DID)
+	assert.Equal(did.String()+"#atproto", doc.VerificationMethod[0].ID)
+	assert.Equal(strings.TrimPrefix(signingKey, "did:key:"), doc.VerificationMethod[0].PublicKeyMultibase)
+	assert.Equal("https://pds.example.com", doc.Service[0].ServiceEndpoint)
+}
+
+func TestParseOperation(t *testing.T) {
+	assert := assert.New(t)
+
+	priv, key := genKey(t)
+	op := signed(t, testOp([]string{key}, "alice.example.com", ""), priv)
+	b, err := json.Marshal(op)
+	assert.NoError(err)
+
+	parsed, err := ParseOperation(b)
+	assert.NoError(err)
+	assert.Equal(mustCID(t, op), mustCID(t, parsed))
+
+	// unknown fields would not be covered by the signature
+	var obj map[string]any
+	assert.NoError(json.Unmarshal(b, &obj))
+	obj["extra"] = "field"
+	b, err = json.Marshal(obj)
+	assert.NoError(err)
+	_, err = ParseOperation(b)
+	assert.ErrorIs(err, ErrInvalidOperation)
+
+	_, err = ParseOperation([]byte(`{"type": "plc_operation", "rotationKeys": ["did:key:bogus"], "verificationMethods": {}, "alsoKnownAs": [], "services": {}, "prev": null}`))
+	assert.ErrorIs(err, ErrInvalidOperation)
+}
+
+func TestVerifyOpLog(t *testing.T) {
+	assert := assert.New(t)
+
+	rotPriv, rotKey := genKey(t)
+	otherPriv, _ := genKey(t)
+
+	genesis := signed(t, testOp([]string{rotKey}, "alice.example.com", ""), rotPriv)
+	did, err := DIDForGenesis(genesis)
+	assert.NoError(err)
+	update := signed(t, testOp([]string{rotKey}, "alice2.example.com", mustCID(t, genesis)), rotPriv)
+
+	state, err := VerifyOpLog(did, []Operation{genesis, update})
+	assert.NoError(err)
+	assert.Equal([]string{"at://alice2.example.com"}, state.Data.AlsoKnownAs)
+	assert.Equal(mustCID(t, update), state.Head)
+
+	_, err = VerifyOpLog(syntax.DID("did:plc:aaaaaaaaaaaaaaaaaaaaaaaa"), []Operation{genesis})
+	assert.ErrorIs(err, ErrDIDMismatch)
+
+	bad := signed(t, testOp([]string{rotKey}, "mallory.example.com", mustCID(t, genesis)), otherPriv)
+	_, err = VerifyOpLog(did, []Operation{genesis, bad})
+	assert.ErrorIs(err, ErrInvalidSignature)
+
+	tomb := signed(t, &TombstoneOp{Type: "plc_tombstone", Prev: mustCID(t, update)}, rotPriv)
+	state, err = VerifyOpLog(did, []Operation{genesis, update, tomb})
+	assert.NoError(err)
+	assert.True(state.Tombstoned)
+	assert.Nil(state.Data)
+
+	after := signed(t, testOp([]string{rotKey}, "alice3.example.com", mustCID(t, tomb)), rotPriv)
+	_, err = VerifyOpLog(did, []Operation{genesis, update, tomb, after})
+	assert.ErrorIs(err, ErrDIDTombstoned)
+}
+
+func auditEntry(t *testing.T, did syntax.DID, op Operation, createdAt time.Time, nullified bool) LogEntry {
+	b, err := json.Marshal(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return LogEntry{
+		DID:       did,
+		Operation: b,
+		CID:       mustCID(t, op),
+		Nullified: nullified,
+		CreatedAt: createdAt.UTC().Format(syntax.AtprotoDatetimeLayout),
+	}
+}
+
+func TestVerifyAuditLogRecovery(t *testing.T) {
+	assert := assert.New(t)
+
+	recPriv, recKey := genKey(t)
+	pdsPriv, pdsKey := genKey(t)
+	start := time.Now().Add(-240 * time.Hour)
+
+	genesis := signed(t, testOp([]string{recKey, pdsKey}, "alice.example.com", ""), recPriv)
+	did, err := DIDForGenesis(genesis)
+	assert.NoError(err)
+
+	// the lower-priority key (eg, a compromised PDS) makes an update
+	hijack := signed(t, testOp([]string{pdsKey}, "mallory.example.com", mustCID(t, genesis)), pdsPriv)
+	// the recovery key forks from genesis, nullifying the hijack
+	recovery := signed(t, testOp([]string{recKey}, "alice.example.com", mustCID(t, genesis)), recPriv)
+
+	entries := []LogEntry{
+		auditEntry(t, did, genesis, start, false),
+		auditEntry(t, did, hijack, start.Add(time.Hour), true),
+		auditEntry(t, did, recovery, start.Add(48*time.Hour), false),
+	}
+	state, err := VerifyAuditLog(did, entries)
+	assert.NoError(err)
+	assert.Equal([]string{mustCID(t, hijack)}, state.Nullified)
+	assert.Equal(mustCID(t, recovery), state.Head)
+
+	// nullified flags must be consistent
+	entries[1].Nullified = false
+	_, err = VerifyAuditLog(did, entries)
+	assert.ErrorIs(err, ErrNullifiedState)
+
+	// too late
+	entries[1].Nullified = true
+	entries[2] = auditEntry(t, did, recovery, start.Add(74*time.Hour), false)
+	_, err = VerifyAuditLog(did, entries)
+	assert.ErrorIs(err, ErrLateRecovery)
+
+	// the lower-priority key can not nullify operations by the higher-priority key
+	update := signed(t, testOp([]string{recKey, pdsKey}, "alice2.example.com", mustCID(t, genesis)), recPriv)
+	fork := signed(t, testOp([]string{pdsKey}, "mallory.example.com", mustCID(t, genesis)), pdsPriv)
+	entries = []LogEntry{
+		auditEntry(t, did, genesis, start, false),
+		auditEntry(t, did, update, start.Add(time.Hour), false),
+		auditEntry(t, did, fork, start.Add(2*time.Hour), false),
+	}
+	_, err = VerifyAuditLog(did, entries)
+	assert.ErrorIs(err, ErrInvalidSignature)
+}
+
+func TestVerifierSnapshot(t *testing.T) {
+	assert := assert.New(t)
+
+	recPriv, recKey := genKey(t)
+	pdsPriv, pdsKey := genKey(t)
+	start := time.Now().Add(-240 * time.Hour)
+
+	genesis := signed(t, testOp([]string{recKey, pdsKey}, "alice.example.com", ""), recPriv)
+	did, err := DIDForGenesis(genesis)
+	assert.NoError(err)
+	old := signed(t, testOp([]string{recKey, pdsKey}, "alice2.example.com", mustCID(t, genesis)), pdsPriv)
+	hijack := signed(t, testOp([]string{recKey, pdsKey}, "mallory.example.com", mustCID(t, old)), pdsPriv)
+
+	v := NewVerifier(did)
+	_, err = v.Apply(genesis, start)
+	assert.NoError(err)
+	_, err = v.Apply(old, start.Add(time.Hour))
+	assert.NoError(err)
+	_, err = v.Apply(hijack, start.Add(100*time.Hour))
+	assert.NoError(err)
+
+	// round-trips through JSON, and leaves out operations which can no longer be forked
+	b, err := json.Marshal(v.Snapshot())
+	assert.NoError(err)
+	var snap VerifierState
+	assert.NoError(json.Unmarshal(b, &snap))
+	assert.Equal(2, len(snap.Active))
+	assert.Equal(mustCID(t, old), snap.Active[0].CID)
+
+	// recovery from a restored verifier works the same as from a full replay
+	recovery := signed(t, testOp([]string{recKey}, "alice.example.com", mustCID(t, old)), recPriv)
+	restored := RestoreVerifier(&snap)
+	nullified, err := restored.Apply(recovery, start.Add(101*time.Hour))
+	assert.NoError(err)
+	assert.Equal([]string{mustCID(t, hijack)}, nullified)
+	assert.Equal(mustCID(t, recovery), restored.State().Head)
+	assert.Equal([]string{"at://alice.example.com"}, restored.State().Data.AlsoKnownAs)
+
+	// operations outside the snapshot can not be built on
+	late := signed(t, testOp([]string{recKey}, "alice.example.com", mustCID(t, genesis)), recPriv)
+	_, err = RestoreVerifier(&snap).Apply(late, start.Add(101*time.Hour))
+	assert.ErrorIs(err, ErrPrevNotFound)
+}
@@ -0,0 +1,8 @@
+/*
+Package plctest provides an in-memory did:plc directory [Server] for use in tests.
+
+Unlike a stub resolver, the server accepts real signed PLC operations, and enforces the same rules as the production directory: genesis operations must hash to the DID, updates must be signed by a current rotation key, and recovery operations by a higher-priority rotation key nullify later operations within the recovery window. It serves the regular directory read endpoints, so it can be used with [net/http/httptest] as the PLC host for identity resolution and tooling.
+
+Operations are verified with [plc.Verifier], and the read endpoints are served by [plc.ReadServer], the same as in the PLC mirror service.
+*/
+package plctest
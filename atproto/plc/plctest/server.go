@@ -0,0 +1,217 @@
+package plctest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/plc"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+)
+
+// Returned for DIDs which have not been registered; wraps [plc.ErrDIDNotFound]
+var ErrNotFound = fmt.Errorf("%w: not registered", plc.ErrDIDNotFound)
+
+// fixed-width timestamps (always three fractional digits), so that "createdAt" values sort lexically for the export cursor
+const createdAtLayout = "2006-01-02T15:04:05.000Z"
+
+// In-memory PLC directory, intended for use in tests (eg, with [net/http/httptest]).
+//
+// Submitted operations are fully validated: signatures are checked against the current rotation keys, and recovery operations nullify later operations according to the key priority and recovery window rules. Both the write ("POST /{did}") and read endpoints of the directory HTTP API are implemented; the read endpoints are served by [plc.ReadServer].
+type Server struct {
+	// Returns the current time, which is recorded as the "createdAt" of submitted operations. Tests can override this to simulate the passage of time (eg, to exceed the recovery window).
+	Now func() time.Time
+
+	mu   sync.Mutex
+	logs map[syntax.DID]*didLog
+	// global order of accepted operations, for the export endpoint
+	export []exportRef
+	last   time.Time
+}
+
+// Audit log of a single DID, and the verifier state at its head
+type didLog struct {
+	entries  []plc.LogEntry
+	verifier *plc.Verifier
+}
+
+type exportRef struct {
+	did syntax.DID
+	idx int
+}
+
+func NewServer() *Server {
+	return &Server{
+		Now:  time.Now,
+		logs: make(map[syntax.DID]*didLog),
+	}
+}
+
+// Validates and applies a signed operation for the given DID. For a genesis operation, the DID must match the operation hash.
+//
+// Returns an error wrapping one of the [plc] verification errors if the operation is rejected.
+func (s *Server) Submit(did syntax.DID, op plc.Operation) error {
+	if err := op.Validate(); err != nil {
+		return err
+	}
+	opCID, err := plc.OpCID(op)
+	if err != nil {
+		return err
+	}
+	opJSON, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	log, ok := s.logs[did]
+	if !ok {
+		log = &didLog{verifier: plc.NewVerifier(did)}
+	}
+	for _, e := range log.entries {
+		if e.CID == opCID {
+			return fmt.Errorf("%w: operation already submitted: %s", plc.ErrInvalidLog, opCID)
+		}
+	}
+
+	// createdAt must be strictly increasing, so that export cursors are unambiguous
+	now := s.Now().UTC().Truncate(time.Millisecond)
+	if !now.After(s.last) {
+		now = s.last.Add(time.Millisecond)
+	}
+	nullified, err := log.verifier.Apply(op, now)
+	if err != nil {
+		return err
+	}
+	s.last = now
+
+	// copy, so the slices previously returned by AuditLog are not modified
+	entries := append([]plc.LogEntry{}, log.entries...)
+	for _, n := range nullified {
+		for i := range entries {
+			if entries[i].CID == n {
+				entries[i].Nullified = true
+			}
+		}
+	}
+	log.entries = append(entries, plc.LogEntry{
+		DID:       did,
+		Operation: opJSON,
+		CID:       opCID,
+		CreatedAt: now.Format(createdAtLayout),
+	})
+	s.logs[did] = log
+	s.export = append(s.export, exportRef{did: did, idx: len(log.entries) - 1})
+	return nil
+}
+
+// Returns the full audit log for a DID (including nullified operations), or [ErrNotFound].
+func (s *Server) AuditLog(did syntax.DID) ([]plc.LogEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log, ok := s.logs[did]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return log.entries, nil
+}
+
+// Returns the current verified state for a DID, or [ErrNotFound].
+func (s *Server) State(did syntax.DID) (*plc.LogState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log, ok := s.logs[did]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return log.verifier.State(), nil
+}
+
+// Adapts [Server] to [plc.LogReader], for the read endpoints
+type logReader struct {
+	s *Server
+}
+
+func (r logReader) GetLogState(did syntax.DID) (*plc.LogState, error) {
+	return r.s.State(did)
+}
+
+func (r logReader) GetAuditLog(did syntax.DID) ([]plc.LogEntry, error) {
+	return r.s.AuditLog(did)
+}
+
+// Returns an HTTP handler implementing the PLC directory API: "POST /{did}" to submit operations, the "/{did}", "/{did}/data", "/{did}/log", "/{did}/log/audit", and "/{did}/log/last" read endpoints, and "/export".
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /{did}", s.handleSubmit)
+	mux.HandleFunc("GET /export", s.handleExport)
+	reads := &plc.ReadServer{Logs: logReader{s: s}}
+	mux.Handle("/", reads.Handler())
+	return mux
+}
+
+func writeMessage(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"message": msg})
+}
+
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	raw := r.PathValue("did")
+	did, err := syntax.ParseDID(raw)
+	if err != nil || did.Method() != "plc" {
+		writeMessage(w, http.StatusBadRequest, "Invalid DID: "+raw)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeMessage(w, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+	op, err := plc.ParseOperation(body)
+	if err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := s.Submit(did, op); err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	count := 1000
+	if c := r.URL.Query().Get("count"); c != "" {
+		n, err := strconv.Atoi(c)
+		if err != nil || n < 1 {
+			writeMessage(w, http.StatusBadRequest, "Invalid count parameter")
+			return
+		}
+		count = min(n, 1000)
+	}
+	after := r.URL.Query().Get("after")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/jsonlines")
+	enc := json.NewEncoder(w)
+	for _, ref := range s.export {
+		if count <= 0 {
+			break
+		}
+		// entries are looked up in the current log, so that nullified flags are current
+		e := s.logs[ref.did].entries[ref.idx]
+		if after != "" && e.CreatedAt <= after {
+			continue
+		}
+		enc.Encode(e)
+		count--
+	}
+}
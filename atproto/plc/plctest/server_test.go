@@ -0,0 +1,182 @@
+package plctest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/crypto"
+	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/plc"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func genKey(t *testing.T) (crypto.PrivateKey, string) {
+	priv, err := crypto.GeneratePrivateKeyK256()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := priv.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv, pub.DIDKey()
+}
+
+func signedOp(t *testing.T, priv crypto.PrivateKey, rotationKeys []string, handle string, prev string) *plc.RegularOp {
+	op := &plc.RegularOp{
+		Type:                "plc_operation",
+		RotationKeys:        rotationKeys,
+		VerificationMethods: map[string]string{"atproto": rotationKeys[len(rotationKeys)-1]},
+		AlsoKnownAs:         []string{"at://" + handle},
+		Services: map[string]plc.OpService{
+			"atproto_pds": {Type: "AtprotoPersonalDataServer", Endpoint: "https://pds.example.com"},
+		},
+	}
+	if prev != "" {
+		op.Prev = &prev
+	}
+	if err := plc.SignOp(op, priv); err != nil {
+		t.Fatal(err)
+	}
+	return op
+}
+
+func mustCID(t *testing.T, op plc.Operation) string {
+	c, err := plc.OpCID(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func post(t *testing.T, srv *httptest.Server, did syntax.DID, op plc.Operation) int {
+	b, err := json.Marshal(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(srv.URL+"/"+did.String(), "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestServerHTTP(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	priv, key := genKey(t)
+	dir := NewServer()
+	srv := httptest.NewServer(dir.Handler())
+	defer srv.Close()
+
+	genesis := signedOp(t, priv, []string{key}, "alice.example.com", "")
+	did, err := plc.DIDForGenesis(genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// genesis must be submitted under the matching DID
+	assert.Equal(http.StatusBadRequest, post(t, srv, "did:plc:aaaaaaaaaaaaaaaaaaaaaaaa", genesis))
+	assert.Equal(http.StatusOK, post(t, srv, did, genesis))
+	assert.Equal(http.StatusBadRequest, post(t, srv, did, genesis))
+
+	// update signed by an unrelated key is rejected
+	otherPriv, _ := genKey(t)
+	assert.Equal(http.StatusBadRequest, post(t, srv, did, signedOp(t, otherPriv, []string{key}, "mallory.example.com", mustCID(t, genesis))))
+	assert.Equal(http.StatusOK, post(t, srv, did, signedOp(t, priv, []string{key}, "alice2.example.com", mustCID(t, genesis))))
+
+	base := identity.BaseDirectory{PLCURL: srv.URL, HTTPClient: *srv.Client()}
+	doc, err := base.ResolveDIDPLC(ctx, did)
+	assert.NoError(err)
+	assert.Equal([]string{"at://alice2.example.com"}, doc.AlsoKnownAs)
+
+	resp, err := http.Get(srv.URL + "/" + did.String() + "/log/audit")
+	assert.NoError(err)
+	var entries []plc.LogEntry
+	assert.NoError(json.NewDecoder(resp.Body).Decode(&entries))
+	resp.Body.Close()
+	assert.Equal(2, len(entries))
+	_, err = plc.VerifyAuditLog(did, entries)
+	assert.NoError(err)
+
+	resp, err = http.Get(srv.URL + "/export?count=1&after=" + entries[0].CreatedAt)
+	assert.NoError(err)
+	var exported plc.LogEntry
+	assert.NoError(json.NewDecoder(resp.Body).Decode(&exported))
+	resp.Body.Close()
+	assert.Equal(entries[1].CID, exported.CID)
+
+	resp, err = http.Get(srv.URL + "/did:plc:aaaaaaaaaaaaaaaaaaaaaaaa")
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+
+	// tombstone
+	tomb := &plc.TombstoneOp{Type: "plc_tombstone", Prev: entries[1].CID}
+	assert.NoError(plc.SignOp(tomb, priv))
+	assert.Equal(http.StatusOK, post(t, srv, did, tomb))
+	resp, err = http.Get(srv.URL + "/" + did.String())
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusGone, resp.StatusCode)
+}
+
+func TestServerRecovery(t *testing.T) {
+	assert := assert.New(t)
+
+	recoveryPriv, recoveryKey := genKey(t)
+	signingPriv, signingKey := genKey(t)
+	keys := []string{recoveryKey, signingKey}
+
+	now := time.Now()
+	dir := NewServer()
+	dir.Now = func() time.Time { return now }
+
+	genesis := signedOp(t, signingPriv, keys, "alice.example.com", "")
+	did, err := plc.DIDForGenesis(genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(dir.Submit(did, genesis))
+
+	// the lower-priority key makes an update, which the recovery key disputes
+	hijack := signedOp(t, signingPriv, keys, "mallory.example.com", mustCID(t, genesis))
+	assert.NoError(dir.Submit(did, hijack))
+
+	// can't be overridden by the same key
+	err = dir.Submit(did, signedOp(t, signingPriv, keys, "alice2.example.com", mustCID(t, genesis)))
+	assert.ErrorIs(err, plc.ErrInvalidSignature)
+
+	now = now.Add(time.Hour)
+	assert.NoError(dir.Submit(did, signedOp(t, recoveryPriv, keys, "alice3.example.com", mustCID(t, genesis))))
+
+	entries, err := dir.AuditLog(did)
+	assert.NoError(err)
+	assert.Equal(3, len(entries))
+	assert.True(entries[1].Nullified)
+	_, err = plc.VerifyAuditLog(did, entries)
+	assert.NoError(err)
+
+	state, err := dir.State(did)
+	assert.NoError(err)
+	assert.Equal([]string{"at://alice3.example.com"}, state.Data.AlsoKnownAs)
+
+	// recovery is only allowed within the window
+	hijack2 := signedOp(t, signingPriv, keys, "mallory2.example.com", state.Head)
+	assert.NoError(dir.Submit(did, hijack2))
+	now = now.Add(plc.RecoveryWindow + time.Hour)
+	err = dir.Submit(did, signedOp(t, recoveryPriv, keys, "alice4.example.com", state.Head))
+	assert.ErrorIs(err, plc.ErrLateRecovery)
+
+	_, err = dir.AuditLog("did:plc:aaaaaaaaaaaaaaaaaaaaaaaa")
+	assert.ErrorIs(err, ErrNotFound)
+}
@@ -0,0 +1,164 @@
+package plc
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+)
+
+// Returned (possibly wrapped) by [LogReader] implementations for DIDs which are not in the directory.
+var ErrDIDNotFound = errors.New("PLC DID not found")
+
+// Read access to verified PLC audit logs, as served by [ReadServer].
+type LogReader interface {
+	// Returns the verified current state of a DID, or an error wrapping [ErrDIDNotFound].
+	GetLogState(did syntax.DID) (*LogState, error)
+	// Returns the full audit log for a DID (including nullified operations), or an error wrapping [ErrDIDNotFound].
+	GetAuditLog(did syntax.DID) ([]LogEntry, error)
+}
+
+var didDocContext = []string{
+	"https://www.w3.org/ns/did/v1",
+	"https://w3id.org/security/multikey/v1",
+	"https://w3id.org/security/suites/secp256k1-2019/v1",
+}
+
+// DID document with JSON-LD context, as served by the PLC directory
+type contextDIDDocument struct {
+	Context []string `json:"@context"`
+	identity.DIDDocument
+}
+
+// Serves the read endpoints of the PLC directory HTTP API from already-verified audit logs.
+type ReadServer struct {
+	Logs   LogReader
+	Logger *slog.Logger
+	// Optional: called after each request with the endpoint name ("doc", "data", "log", "audit", or "last") and HTTP status code, eg for metrics
+	OnRequest func(endpoint string, status int)
+}
+
+// Returns an HTTP handler for the "/{did}", "/{did}/data", "/{did}/log", "/{did}/log/audit", and "/{did}/log/last" endpoints.
+func (s *ReadServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{did}", s.handleDID("doc", false, s.writeDoc))
+	mux.HandleFunc("GET /{did}/data", s.handleDID("data", false, s.writeData))
+	mux.HandleFunc("GET /{did}/log", s.handleDID("log", true, s.writeLog))
+	mux.HandleFunc("GET /{did}/log/audit", s.handleDID("audit", true, s.writeAudit))
+	mux.HandleFunc("GET /{did}/log/last", s.handleDID("last", true, s.writeLast))
+	return mux
+}
+
+func (s *ReadServer) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.Default()
+}
+
+func (s *ReadServer) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.logger().Warn("failed to write HTTP response", "err", err)
+	}
+}
+
+func (s *ReadServer) writeMessage(w http.ResponseWriter, status int, msg string) {
+	s.writeJSON(w, status, map[string]string{"message": msg})
+}
+
+type didWriter func(w http.ResponseWriter, did syntax.DID, entries []LogEntry, state *LogState) int
+
+// Common request handling: parses the DID, loads the verified state (and the audit log, if needed), and reports the request.
+func (s *ReadServer) handleDID(endpoint string, needEntries bool, write didWriter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := s.serveDID(w, r, needEntries, write)
+		if s.OnRequest != nil {
+			s.OnRequest(endpoint, status)
+		}
+	}
+}
+
+func (s *ReadServer) serveDID(w http.ResponseWriter, r *http.Request, needEntries bool, write didWriter) int {
+	raw := r.PathValue("did")
+	did, err := syntax.ParseDID(raw)
+	if err != nil || did.Method() != "plc" {
+		s.writeMessage(w, http.StatusBadRequest, "Invalid DID: "+raw)
+		return http.StatusBadRequest
+	}
+	// operations were verified when they were stored; this loads the state at the head of the log
+	state, err := s.Logs.GetLogState(did)
+	if errors.Is(err, ErrDIDNotFound) {
+		s.writeMessage(w, http.StatusNotFound, "DID not registered: "+did.String())
+		return http.StatusNotFound
+	}
+	if err != nil {
+		s.logger().Error("failed to load PLC DID state", "did", did, "err", err)
+		s.writeMessage(w, http.StatusInternalServerError, "internal error")
+		return http.StatusInternalServerError
+	}
+	var entries []LogEntry
+	if needEntries {
+		entries, err = s.Logs.GetAuditLog(did)
+		if err != nil {
+			s.logger().Error("failed to load PLC audit log", "did", did, "err", err)
+			s.writeMessage(w, http.StatusInternalServerError, "internal error")
+			return http.StatusInternalServerError
+		}
+	}
+	return write(w, did, entries, state)
+}
+
+func (s *ReadServer) writeDoc(w http.ResponseWriter, did syntax.DID, entries []LogEntry, state *LogState) int {
+	if state.Tombstoned {
+		s.writeMessage(w, http.StatusGone, "DID not available: "+did.String())
+		return http.StatusGone
+	}
+	doc := contextDIDDocument{Context: didDocContext, DIDDocument: state.Data.DIDDocument()}
+	w.Header().Set("Content-Type", "application/did+ld+json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(doc); err != nil {
+		s.logger().Warn("failed to write HTTP response", "err", err)
+	}
+	return http.StatusOK
+}
+
+func (s *ReadServer) writeData(w http.ResponseWriter, did syntax.DID, entries []LogEntry, state *LogState) int {
+	if state.Tombstoned {
+		s.writeMessage(w, http.StatusGone, "DID not available: "+did.String())
+		return http.StatusGone
+	}
+	s.writeJSON(w, http.StatusOK, state.Data)
+	return http.StatusOK
+}
+
+func (s *ReadServer) writeLog(w http.ResponseWriter, did syntax.DID, entries []LogEntry, state *LogState) int {
+	ops := []json.RawMessage{}
+	for _, e := range entries {
+		if !e.Nullified {
+			ops = append(ops, e.Operation)
+		}
+	}
+	s.writeJSON(w, http.StatusOK, ops)
+	return http.StatusOK
+}
+
+func (s *ReadServer) writeAudit(w http.ResponseWriter, did syntax.DID, entries []LogEntry, state *LogState) int {
+	s.writeJSON(w, http.StatusOK, entries)
+	return http.StatusOK
+}
+
+func (s *ReadServer) writeLast(w http.ResponseWriter, did syntax.DID, entries []LogEntry, state *LogState) int {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].CID == state.Head {
+			s.writeJSON(w, http.StatusOK, entries[i].Operation)
+			return http.StatusOK
+		}
+	}
+	s.writeMessage(w, http.StatusNotFound, "DID not registered: "+did.String())
+	return http.StatusNotFound
+}
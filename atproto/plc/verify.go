@@ -0,0 +1,337 @@
+package plc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+)
+
+// How long after an operation a higher-priority rotation key can "fork" the log to nullify it.
+const RecoveryWindow = 72 * time.Hour
+
+var (
+	ErrInvalidLog     = errors.New("invalid PLC operation log")
+	ErrLateRecovery   = errors.New("PLC recovery operation outside of recovery window")
+	ErrDIDMismatch    = errors.New("PLC genesis operation does not match DID")
+	ErrDIDTombstoned  = errors.New("PLC DID is tombstoned")
+	ErrPrevNotFound   = errors.New("PLC operation prev not found in log")
+	ErrNullifiedState = errors.New("PLC audit log nullified status does not match verification")
+)
+
+// Current state of a DID, as derived from the operation log. This is the "/{did}/data" representation in the PLC directory API.
+type DIDData struct {
+	DID                 syntax.DID           `json:"did"`
+	VerificationMethods map[string]string    `json:"verificationMethods"`
+	RotationKeys        []string             `json:"rotationKeys"`
+	AlsoKnownAs         []string             `json:"alsoKnownAs"`
+	Services            map[string]OpService `json:"services"`
+}
+
+// Entry in a PLC audit log or export stream.
+type LogEntry struct {
+	DID       syntax.DID      `json:"did"`
+	Operation json.RawMessage `json:"operation"`
+	CID       string          `json:"cid"`
+	Nullified bool            `json:"nullified"`
+	CreatedAt string          `json:"createdAt"`
+}
+
+// Summary of a verified operation log.
+type LogState struct {
+	DID syntax.DID
+	// Current DID data; nil if the DID has been tombstoned
+	Data       *DIDData
+	Tombstoned bool
+	// CID of the most recent (non-nullified) operation
+	Head string
+	// CIDs of operations which were nullified by recovery operations
+	Nullified []string
+}
+
+// Returns the DID state after applying a non-tombstone operation.
+func dataForOp(did syntax.DID, op Operation) (*DIDData, error) {
+	var reg *RegularOp
+	switch v := op.(type) {
+	case *RegularOp:
+		reg = v
+	case *LegacyOp:
+		reg = v.Normalize()
+	default:
+		return nil, fmt.Errorf("no DID data for operation type: %s", op.OpType())
+	}
+	return &DIDData{
+		DID:                 did,
+		VerificationMethods: reg.VerificationMethods,
+		RotationKeys:        reg.RotationKeys,
+		AlsoKnownAs:         reg.AlsoKnownAs,
+		Services:            reg.Services,
+	}, nil
+}
+
+// Renders the DID data as a DID document.
+func (d *DIDData) DIDDocument() identity.DIDDocument {
+	doc := identity.DIDDocument{
+		DID:                d.DID,
+		AlsoKnownAs:        d.AlsoKnownAs,
+		VerificationMethod: []identity.DocVerificationMethod{},
+		Service:            []identity.DocService{},
+	}
+	orderedKeys(d.VerificationMethods, func(name string) {
+		doc.VerificationMethod = append(doc.VerificationMethod, identity.DocVerificationMethod{
+			ID:                 d.DID.String() + "#" + name,
+			Type:               "Multikey",
+			Controller:         d.DID.String(),
+			PublicKeyMultibase: strings.TrimPrefix(d.VerificationMethods[name], "did:key:"),
+		})
+	})
+	orderedKeys(d.Services, func(name string) {
+		doc.Service = append(doc.Service, identity.DocService{
+			ID:              "#" + name,
+			Type:            d.Services[name].Type,
+			ServiceEndpoint: d.Services[name].Endpoint,
+		})
+	})
+	return doc
+}
+
+// An operation in the active (non-nullified) chain of a log, along with the DID state it resulted in.
+type VerifiedOp struct {
+	CID string `json:"cid"`
+	// DID state after the operation; nil for a tombstone
+	Data      *DIDData  `json:"data"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Index of the rotation key which signed the operation
+	KeyIndex int `json:"keyIndex"`
+}
+
+// Serializable snapshot of a [Verifier], so that verification of new operations can resume from the head of a log, without replaying the log from genesis.
+type VerifierState struct {
+	DID syntax.DID `json:"did"`
+	// Tail of the active chain: only the operations which could still be forked by a recovery operation, and the operation before them
+	Active    []VerifiedOp `json:"active"`
+	Nullified []string     `json:"nullified,omitempty"`
+}
+
+// Incrementally verifies the operation history of a single DID, including recovery forks.
+//
+// Operations must be applied in the order they were accepted by the directory.
+type Verifier struct {
+	DID       syntax.DID
+	active    []VerifiedOp
+	nullified []string
+}
+
+func NewVerifier(did syntax.DID) *Verifier {
+	return &Verifier{DID: did}
+}
+
+// Resumes verification from a snapshot (see [Verifier.Snapshot]).
+func RestoreVerifier(s *VerifierState) *Verifier {
+	return &Verifier{
+		DID:       s.DID,
+		active:    append([]VerifiedOp{}, s.Active...),
+		nullified: append([]string{}, s.Nullified...),
+	}
+}
+
+// Returns a snapshot of the verifier state. Returns nil if no operations have been applied.
+//
+// Operations which are too old to be nullified by any future recovery operation are left out, except for the latest of them (which a recovery operation could build on). A future operation which references an older operation fails with [ErrPrevNotFound]; it would be rejected as a late recovery anyways.
+func (v *Verifier) Snapshot() *VerifierState {
+	if len(v.active) == 0 {
+		return nil
+	}
+	last := v.active[len(v.active)-1]
+	start := 0
+	for i, a := range v.active {
+		if last.CreatedAt.Sub(a.CreatedAt) <= RecoveryWindow {
+			start = max(0, i-1)
+			break
+		}
+	}
+	return &VerifierState{
+		DID:       v.DID,
+		Active:    append([]VerifiedOp{}, v.active[start:]...),
+		Nullified: append([]string{}, v.nullified...),
+	}
+}
+
+// Checks that an operation is a valid next operation, and if so updates the verifier state. 'createdAt' is the time the operation was accepted by the directory, and is used to enforce the recovery window.
+//
+// Returns the CIDs of any operations which were nullified by this operation.
+func (v *Verifier) Apply(op Operation, createdAt time.Time) ([]string, error) {
+	opCID, err := OpCID(op)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(v.active) == 0 {
+		did, err := DIDForGenesis(op)
+		if err != nil {
+			return nil, err
+		}
+		if did != v.DID {
+			return nil, fmt.Errorf("%w: computed %s", ErrDIDMismatch, did)
+		}
+		data, err := dataForOp(did, op)
+		if err != nil {
+			return nil, err
+		}
+		idx, err := VerifySignature(op, data.RotationKeys)
+		if err != nil {
+			return nil, err
+		}
+		v.active = append(v.active, VerifiedOp{CID: opCID, Data: data, CreatedAt: createdAt, KeyIndex: idx})
+		return nil, nil
+	}
+
+	if op.PrevCID() == "" {
+		return nil, fmt.Errorf("%w: duplicate genesis operation", ErrInvalidLog)
+	}
+	if op.OpType() == "create" {
+		return nil, fmt.Errorf("%w: legacy create operation after genesis", ErrInvalidLog)
+	}
+	last := v.active[len(v.active)-1]
+	if createdAt.Before(last.CreatedAt) {
+		return nil, fmt.Errorf("%w: operations out of order", ErrInvalidLog)
+	}
+	prevIdx := -1
+	for i := range v.active {
+		if v.active[i].CID == op.PrevCID() {
+			prevIdx = i
+			break
+		}
+	}
+	if prevIdx < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPrevNotFound, op.PrevCID())
+	}
+	prev := v.active[prevIdx]
+	if prev.Data == nil {
+		return nil, ErrDIDTombstoned
+	}
+
+	idx, err := VerifySignature(op, prev.Data.RotationKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	var nullified []string
+	if prevIdx < len(v.active)-1 {
+		// recovery fork: must be signed by a higher-priority key than the first nullified operation, within the recovery window
+		disputed := v.active[prevIdx+1]
+		if idx >= disputed.KeyIndex {
+			return nil, fmt.Errorf("%w: recovery operation not signed by a higher-priority rotation key", ErrInvalidSignature)
+		}
+		if createdAt.Sub(disputed.CreatedAt) > RecoveryWindow {
+			return nil, ErrLateRecovery
+		}
+		for _, a := range v.active[prevIdx+1:] {
+			nullified = append(nullified, a.CID)
+		}
+		v.active = v.active[:prevIdx+1]
+		v.nullified = append(v.nullified, nullified...)
+	}
+
+	var data *DIDData
+	if op.OpType() != "plc_tombstone" {
+		data, err = dataForOp(v.DID, op)
+		if err != nil {
+			return nil, err
+		}
+	}
+	v.active = append(v.active, VerifiedOp{CID: opCID, Data: data, CreatedAt: createdAt, KeyIndex: idx})
+	return nullified, nil
+}
+
+// Returns the current state of the verified log. Returns nil if no operations have been applied.
+func (v *Verifier) State() *LogState {
+	if len(v.active) == 0 {
+		return nil
+	}
+	last := v.active[len(v.active)-1]
+	return &LogState{
+		DID:        v.DID,
+		Data:       last.Data,
+		Tombstoned: last.Data == nil,
+		Head:       last.CID,
+		Nullified:  v.nullified,
+	}
+}
+
+// Verifies a linear operation log (no nullified operations), such as returned by the "/{did}/log" directory endpoint.
+func VerifyOpLog(did syntax.DID, ops []Operation) (*LogState, error) {
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("%w: empty operation log", ErrInvalidLog)
+	}
+	v := NewVerifier(did)
+	for i, op := range ops {
+		if i > 0 && op.PrevCID() != v.State().Head {
+			return nil, fmt.Errorf("%w: operation %d does not reference previous operation", ErrInvalidLog, i)
+		}
+		if _, err := v.Apply(op, time.Time{}); err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	return v.State(), nil
+}
+
+// Verifies a full audit log, such as returned by the "/{did}/log/audit" directory endpoint.
+//
+// In addition to signatures and the operation chain, checks that entry CIDs are correct and that the "nullified" flags match the result of replaying recovery operations.
+func VerifyAuditLog(did syntax.DID, entries []LogEntry) (*LogState, error) {
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: empty audit log", ErrInvalidLog)
+	}
+	v := NewVerifier(did)
+	nullified := map[string]bool{}
+	for i, e := range entries {
+		if e.DID != did {
+			return nil, fmt.Errorf("%w: entry %d has unexpected DID: %s", ErrInvalidLog, i, e.DID)
+		}
+		op, err := ParseOperation(e.Operation)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+		c, err := OpCID(op)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+		if c != e.CID {
+			return nil, fmt.Errorf("%w: entry %d CID mismatch (computed %s)", ErrInvalidLog, i, c)
+		}
+		createdAt, err := syntax.ParseDatetimeLenient(e.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%w: entry %d createdAt: %w", ErrInvalidLog, i, err)
+		}
+		nulled, err := v.Apply(op, createdAt.Time())
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+		for _, n := range nulled {
+			nullified[n] = true
+		}
+	}
+	for i, e := range entries {
+		if e.Nullified != nullified[e.CID] {
+			return nil, fmt.Errorf("%w: entry %d (%s)", ErrNullifiedState, i, e.CID)
+		}
+	}
+	return v.State(), nil
+}
+
+func orderedKeys[T any](m map[string]T, cb func(string)) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		cb(k)
+	}
+}
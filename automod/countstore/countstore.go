@@ -13,12 +13,67 @@ const (
 	PeriodHour  = "hour"
 )
 
+// number of sub-buckets ("slots") a sliding window is divided in to
+const windowSlots = 60
+
+// minimum width of a sliding window slot
+const minSlotWidth = time.Second
+
+// Returns the period string for a sliding window of the given duration (eg, "10m0s"). Any string which parses as a positive Go duration (eg, "10m") is a sliding window period.
+func PeriodWindow(d time.Duration) string {
+	return d.String()
+}
+
+// Returns the duration of a sliding window period, or false if the period is not a sliding window.
+func ParseWindow(period string) (time.Duration, bool) {
+	switch period {
+	case PeriodTotal, PeriodDay, PeriodHour:
+		return 0, false
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return 0, false
+	}
+	return d, true
+}
+
+// True if the period is one of the fixed periods, or a sliding window.
+func ValidPeriod(period string) bool {
+	switch period {
+	case PeriodTotal, PeriodDay, PeriodHour:
+		return true
+	}
+	_, ok := ParseWindow(period)
+	return ok
+}
+
+// A single counter increment, as part of a batch.
+type CountIncrement struct {
+	Name string
+	// Counter value; for distinct counters, the bucket
+	Val string
+	// If true, this is a distinct counter increment, marking DistinctVal as seen
+	Distinct bool
+	// Value to mark as seen in a distinct counter (may be empty)
+	DistinctVal string
+	// Single period to increment (like IncrementPeriod). If empty, all of the fixed periods are incremented (like Increment)
+	Period string
+}
+
 // CountStore is an interface for storing incrementing event counts, bucketed into periods.
 // It is implemented by MemCountStore and by RedisCountStore.
 //
 // Period bucketing works on the basis of the current date (as determined mid-call).
 // See the `Period*` consts for the available period types.
 //
+// In addition to the fixed periods, which are aligned to wall-clock boundaries, a period can be a
+// sliding window of any duration (see PeriodWindow), such as "10m" for "the last ten minutes".
+// Sliding windows are divided in to 60 slots (of at least one second each), so counts are
+// precise to within one slot at the trailing edge of the window. Sliding window counters are only
+// incremented explicitly, with the "*Period" variants (or IncrementBatch), with the same period
+// string as is used to read them. Distinct counts over sliding windows merge the per-slot sets
+// (or HyperLogLogs, with Redis).
+//
 // The "GetCount" and "Increment" methods perform actual counting.
 // The "*Distinct" methods have a different behavior:
 // "IncrementDistinct" marks a value as seen at least once,
@@ -29,6 +84,7 @@ const (
 // In other words, one call to CountStore.Increment causes three increments internally:
 // one to the count for the hour, one to the count for the day, and one to the all-time count.
 // The "IncrementPeriod" method allows only incrementing a single period bucket. Care must be taken to match the "GetCount" period with the incremented period when using this variant.
+// "IncrementBatch" applies several increments at once (in a single round-trip, with Redis).
 //
 // The exact implementation and precision of the "*Distinct" methods may vary:
 // in the MemCountStore implementation, it is precise (it's based on large maps);
@@ -45,23 +101,58 @@ type CountStore interface {
 	GetCount(ctx context.Context, name, val, period string) (int, error)
 	Increment(ctx context.Context, name, val string) error
 	IncrementPeriod(ctx context.Context, name, val, period string) error
-	// TODO: batch increment method
+	IncrementBatch(ctx context.Context, incs []CountIncrement) error
 	GetCountDistinct(ctx context.Context, name, bucket, period string) (int, error)
 	IncrementDistinct(ctx context.Context, name, bucket, val string) error
+	IncrementDistinctPeriod(ctx context.Context, name, bucket, val, period string) error
 }
 
 func periodBucket(name, val, period string) string {
+	return periodBucketAt(name, val, period, time.Now())
+}
+
+// Same as periodBucket, for the period containing the given time. For sliding windows, this is the current slot.
+func periodBucketAt(name, val, period string, now time.Time) string {
+	if window, ok := ParseWindow(period); ok {
+		width := slotWidth(window)
+		return windowSlotBucket(name, val, window, now.UnixNano()/int64(width))
+	}
 	switch period {
 	case PeriodTotal:
 		return fmt.Sprintf("%s/%s", name, val)
 	case PeriodDay:
-		t := time.Now().UTC().Format(time.DateOnly)
+		t := now.UTC().Format(time.DateOnly)
 		return fmt.Sprintf("%s/%s/%s", name, val, t)
 	case PeriodHour:
-		t := time.Now().UTC().Format(time.RFC3339)[0:13]
+		t := now.UTC().Format(time.RFC3339)[0:13]
 		return fmt.Sprintf("%s/%s/%s", name, val, t)
 	default:
 		slog.Warn("unhandled counter period", "period", period)
 		return fmt.Sprintf("%s/%s", name, val)
 	}
 }
+
+func slotWidth(window time.Duration) time.Duration {
+	return max(window/windowSlots, minSlotWidth)
+}
+
+func windowSlotBucket(name, val string, window time.Duration, slot int64) string {
+	return fmt.Sprintf("%s/%s/w%s/%d", name, val, window, slot)
+}
+
+// Returns the buckets of every slot in a sliding window ending at the given time, oldest first.
+func windowBuckets(name, val string, window time.Duration, now time.Time) []string {
+	width := slotWidth(window)
+	n := int64((window + width - 1) / width)
+	cur := now.UnixNano() / int64(width)
+	out := make([]string, 0, n)
+	for slot := cur - n + 1; slot <= cur; slot++ {
+		out = append(out, windowSlotBucket(name, val, window, slot))
+	}
+	return out
+}
+
+// How long a sliding window slot needs to be kept.
+func windowExpiration(window time.Duration) time.Duration {
+	return window + 2*slotWidth(window)
+}
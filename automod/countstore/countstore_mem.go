@@ -2,6 +2,7 @@ package countstore
 
 import (
 	"context"
+	"time"
 
 	"github.com/puzpuzpuz/xsync/v3"
 )
@@ -9,11 +10,14 @@ import (
 type MemCountStore struct {
 	// Counts is keyed by a string that is a munge of "{name}/{val}[/{period}]",
 	// where period is either absent (meaning all-time total)
-	// or a string describing that timeperiod (either "YYYY-MM-DD" or that plus a literal "T" and "HH").
+	// or a string describing that timeperiod (either "YYYY-MM-DD" or that plus a literal "T" and "HH"),
+	// or for sliding windows, "w{window}/{slot}".
 	//
 	// (Using a values for `name` and `val` with slashes in them is perhaps inadvisable, as it may be ambiguous.)
 	Counts         *xsync.MapOf[string, int]
 	DistinctCounts *xsync.MapOf[string, *xsync.MapOf[string, bool]]
+	// Optional source of the current time, used to select period buckets (eg, a simulated clock when replaying events). Defaults to time.Now.
+	Clock func() time.Time
 }
 
 func NewMemCountStore() MemCountStore {
@@ -23,8 +27,27 @@ func NewMemCountStore() MemCountStore {
 	}
 }
 
+func (s MemCountStore) bucket(name, val, period string) string {
+	return periodBucketAt(name, val, period, s.now())
+}
+
+func (s MemCountStore) now() time.Time {
+	if s.Clock != nil {
+		return s.Clock()
+	}
+	return time.Now()
+}
+
 func (s MemCountStore) GetCount(ctx context.Context, name, val, period string) (int, error) {
-	v, ok := s.Counts.Load(periodBucket(name, val, period))
+	if window, ok := ParseWindow(period); ok {
+		total := 0
+		for _, k := range windowBuckets(name, val, window, s.now()) {
+			v, _ := s.Counts.Load(k)
+			total += v
+		}
+		return total, nil
+	}
+	v, ok := s.Counts.Load(s.bucket(name, val, period))
 	if !ok {
 		return 0, nil
 	}
@@ -41,15 +64,47 @@ func (s MemCountStore) Increment(ctx context.Context, name, val string) error {
 }
 
 func (s MemCountStore) IncrementPeriod(ctx context.Context, name, val, period string) error {
-	k := periodBucket(name, val, period)
+	k := s.bucket(name, val, period)
 	s.Counts.Compute(k, func(oldVal int, _ bool) (int, bool) {
 		return oldVal + 1, false
 	})
 	return nil
 }
 
+func (s MemCountStore) IncrementBatch(ctx context.Context, incs []CountIncrement) error {
+	for _, inc := range incs {
+		var err error
+		switch {
+		case inc.Distinct && inc.Period != "":
+			err = s.IncrementDistinctPeriod(ctx, inc.Name, inc.Val, inc.DistinctVal, inc.Period)
+		case inc.Distinct:
+			err = s.IncrementDistinct(ctx, inc.Name, inc.Val, inc.DistinctVal)
+		case inc.Period != "":
+			err = s.IncrementPeriod(ctx, inc.Name, inc.Val, inc.Period)
+		default:
+			err = s.Increment(ctx, inc.Name, inc.Val)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s MemCountStore) GetCountDistinct(ctx context.Context, name, bucket, period string) (int, error) {
-	v, ok := s.DistinctCounts.Load(periodBucket(name, bucket, period))
+	if window, ok := ParseWindow(period); ok {
+		seen := make(map[string]bool)
+		for _, k := range windowBuckets(name, bucket, window, s.now()) {
+			if nested, ok := s.DistinctCounts.Load(k); ok {
+				nested.Range(func(val string, _ bool) bool {
+					seen[val] = true
+					return true
+				})
+			}
+		}
+		return len(seen), nil
+	}
+	v, ok := s.DistinctCounts.Load(s.bucket(name, bucket, period))
 	if !ok {
 		return 0, nil
 	}
@@ -58,14 +113,21 @@ func (s MemCountStore) GetCountDistinct(ctx context.Context, name, bucket, perio
 
 func (s MemCountStore) IncrementDistinct(ctx context.Context, name, bucket, val string) error {
 	for _, p := range []string{PeriodTotal, PeriodDay, PeriodHour} {
-		k := periodBucket(name, bucket, p)
-		s.DistinctCounts.Compute(k, func(nested *xsync.MapOf[string, bool], _ bool) (*xsync.MapOf[string, bool], bool) {
-			if nested == nil {
-				nested = xsync.NewMapOf[string, bool]()
-			}
-			nested.Store(val, true)
-			return nested, false
-		})
+		if err := s.IncrementDistinctPeriod(ctx, name, bucket, val, p); err != nil {
+			return err
+		}
 	}
 	return nil
 }
+
+func (s MemCountStore) IncrementDistinctPeriod(ctx context.Context, name, bucket, val, period string) error {
+	k := s.bucket(name, bucket, period)
+	s.DistinctCounts.Compute(k, func(nested *xsync.MapOf[string, bool], _ bool) (*xsync.MapOf[string, bool], bool) {
+		if nested == nil {
+			nested = xsync.NewMapOf[string, bool]()
+		}
+		nested.Store(val, true)
+		return nested, false
+	})
+	return nil
+}
@@ -2,6 +2,7 @@ package countstore
 
 import (
 	"context"
+	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -33,6 +34,9 @@ func NewRedisCountStore(redisURL string) (*RedisCountStore, error) {
 }
 
 func (s *RedisCountStore) GetCount(ctx context.Context, name, val, period string) (int, error) {
+	if window, ok := ParseWindow(period); ok {
+		return s.getCountWindow(ctx, name, val, window)
+	}
 	key := redisCountPrefix + periodBucket(name, val, period)
 	c, err := s.Client.Get(ctx, key).Int()
 	if err == redis.Nil {
@@ -43,52 +47,119 @@ func (s *RedisCountStore) GetCount(ctx context.Context, name, val, period string
 	return c, nil
 }
 
-func (s *RedisCountStore) Increment(ctx context.Context, name, val string) error {
-
-	var key string
-
-	// increment multiple counters in a single redis round-trip
-	multi := s.Client.Pipeline()
+// sums the counts of every slot in the window
+func (s *RedisCountStore) getCountWindow(ctx context.Context, name, val string, window time.Duration) (int, error) {
+	buckets := windowBuckets(name, val, window, time.Now())
+	keys := make([]string, len(buckets))
+	for i, b := range buckets {
+		keys[i] = redisCountPrefix + b
+	}
+	vals, err := s.Client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, v := range vals {
+		str, ok := v.(string)
+		if !ok {
+			// missing slot
+			continue
+		}
+		c, err := strconv.Atoi(str)
+		if err != nil {
+			return 0, err
+		}
+		total += c
+	}
+	return total, nil
+}
 
-	key = redisCountPrefix + periodBucket(name, val, PeriodHour)
+// adds the commands to increment a single period bucket to a pipeline
+func pipeIncrementPeriod(ctx context.Context, multi redis.Pipeliner, name, val, period string) {
+	key := redisCountPrefix + periodBucket(name, val, period)
 	multi.Incr(ctx, key)
-	multi.Expire(ctx, key, 2*time.Hour)
+	if exp := periodExpiration(period); exp > 0 {
+		multi.Expire(ctx, key, exp)
+	}
+}
 
-	key = redisCountPrefix + periodBucket(name, val, PeriodDay)
-	multi.Incr(ctx, key)
-	multi.Expire(ctx, key, 48*time.Hour)
+// adds the commands to mark a value as seen in a single period bucket to a pipeline
+func pipeIncrementDistinctPeriod(ctx context.Context, multi redis.Pipeliner, name, bucket, val, period string) {
+	key := redisDistinctPrefix + periodBucket(name, bucket, period)
+	multi.PFAdd(ctx, key, val)
+	if exp := periodExpiration(period); exp > 0 {
+		multi.Expire(ctx, key, exp)
+	}
+}
 
-	key = redisCountPrefix + periodBucket(name, val, PeriodTotal)
-	multi.Incr(ctx, key)
+// how long a period bucket is kept; zero means forever
+func periodExpiration(period string) time.Duration {
+	if window, ok := ParseWindow(period); ok {
+		return windowExpiration(window)
+	}
+	switch period {
+	case PeriodHour:
+		return 2 * time.Hour
+	case PeriodDay:
+		return 48 * time.Hour
+	}
 	// no expiration for total
+	return 0
+}
 
+func (s *RedisCountStore) Increment(ctx context.Context, name, val string) error {
+	// increment multiple counters in a single redis round-trip
+	multi := s.Client.Pipeline()
+	for _, p := range []string{PeriodHour, PeriodDay, PeriodTotal} {
+		pipeIncrementPeriod(ctx, multi, name, val, p)
+	}
 	_, err := multi.Exec(ctx)
 	return err
 }
 
 // Variant of Increment() which only acts on a single specified time period. The intended us of this variant is to control the total number of counters persisted, by using a relatively short time period, for which the counters will expire.
 func (s *RedisCountStore) IncrementPeriod(ctx context.Context, name, val, period string) error {
-
 	// multiple ops in a single redis round-trip
 	multi := s.Client.Pipeline()
+	pipeIncrementPeriod(ctx, multi, name, val, period)
+	_, err := multi.Exec(ctx)
+	return err
+}
 
-	key := redisCountPrefix + periodBucket(name, val, period)
-	multi.Incr(ctx, key)
-
-	switch period {
-	case PeriodHour:
-		multi.Expire(ctx, key, 2*time.Hour)
-	case PeriodDay:
-		multi.Expire(ctx, key, 48*time.Hour)
+// Applies all of the increments in a single redis round-trip.
+func (s *RedisCountStore) IncrementBatch(ctx context.Context, incs []CountIncrement) error {
+	if len(incs) == 0 {
+		return nil
+	}
+	multi := s.Client.Pipeline()
+	for _, inc := range incs {
+		periods := []string{PeriodHour, PeriodDay, PeriodTotal}
+		if inc.Period != "" {
+			periods = []string{inc.Period}
+		}
+		for _, p := range periods {
+			if inc.Distinct {
+				pipeIncrementDistinctPeriod(ctx, multi, inc.Name, inc.Val, inc.DistinctVal, p)
+			} else {
+				pipeIncrementPeriod(ctx, multi, inc.Name, inc.Val, p)
+			}
+		}
 	}
-
 	_, err := multi.Exec(ctx)
 	return err
 }
 
 func (s *RedisCountStore) GetCountDistinct(ctx context.Context, name, val, period string) (int, error) {
-	key := redisDistinctPrefix + periodBucket(name, val, period)
-	c, err := s.Client.PFCount(ctx, key).Result()
+	var keys []string
+	if window, ok := ParseWindow(period); ok {
+		// PFCOUNT of multiple keys is the cardinality of their merged HyperLogLogs
+		for _, b := range windowBuckets(name, val, window, time.Now()) {
+			keys = append(keys, redisDistinctPrefix+b)
+		}
+	} else {
+		keys = []string{redisDistinctPrefix + periodBucket(name, val, period)}
+	}
+	c, err := s.Client.PFCount(ctx, keys...).Result()
 	if err == redis.Nil {
 		return 0, nil
 	} else if err != nil {
@@ -98,24 +169,18 @@ func (s *RedisCountStore) GetCountDistinct(ctx context.Context, name, val, perio
 }
 
 func (s *RedisCountStore) IncrementDistinct(ctx context.Context, name, bucket, val string) error {
-
-	var key string
-
 	// increment multiple counters in a single redis round-trip
 	multi := s.Client.Pipeline()
+	for _, p := range []string{PeriodHour, PeriodDay, PeriodTotal} {
+		pipeIncrementDistinctPeriod(ctx, multi, name, bucket, val, p)
+	}
+	_, err := multi.Exec(ctx)
+	return err
+}
 
-	key = redisDistinctPrefix + periodBucket(name, bucket, PeriodHour)
-	multi.PFAdd(ctx, key, val)
-	multi.Expire(ctx, key, 2*time.Hour)
-
-	key = redisDistinctPrefix + periodBucket(name, bucket, PeriodDay)
-	multi.PFAdd(ctx, key, val)
-	multi.Expire(ctx, key, 48*time.Hour)
-
-	key = redisDistinctPrefix + periodBucket(name, bucket, PeriodTotal)
-	multi.PFAdd(ctx, key, val)
-	// no expiration for total
-
+func (s *RedisCountStore) IncrementDistinctPeriod(ctx context.Context, name, bucket, val, period string) error {
+	multi := s.Client.Pipeline()
+	pipeIncrementDistinctPeriod(ctx, multi, name, bucket, val, period)
 	_, err := multi.Exec(ctx)
 	return err
 }
@@ -0,0 +1,32 @@
+package countstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisCountStoreWindow(t *testing.T) {
+	t.Skip("live test, need redis running locally")
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	cs, err := NewRedisCountStore("redis://localhost:6379/0")
+	if err != nil {
+		t.Fail()
+	}
+
+	assert.NoError(cs.IncrementBatch(ctx, []CountIncrement{
+		{Name: "test-window", Val: "val1", Period: "10m"},
+		{Name: "test-window", Val: "val1", Period: "10m"},
+		{Name: "test-window", Val: "bucket", Distinct: true, DistinctVal: "one", Period: "10m"},
+		{Name: "test-window", Val: "bucket", Distinct: true, DistinctVal: "two", Period: "10m"},
+	}))
+	c, err := cs.GetCount(ctx, "test-window", "val1", "10m")
+	assert.NoError(err)
+	assert.Equal(2, c)
+	c, err = cs.GetCountDistinct(ctx, "test-window", "bucket", "10m")
+	assert.NoError(err)
+	assert.Equal(2, c)
+}
@@ -104,3 +104,127 @@ func TestMemCountStoreConcurrent(t *testing.T) {
 	assert.NoError(err)
 	assert.Equal(1, c)
 }
+
+func TestParseWindow(t *testing.T) {
+	assert := assert.New(t)
+
+	w, ok := ParseWindow("10m")
+	assert.True(ok)
+	assert.Equal(10*time.Minute, w)
+	assert.Equal("10m0s", PeriodWindow(w))
+
+	for _, p := range []string{PeriodTotal, PeriodDay, PeriodHour, "", "-5m", "0s", "junk"} {
+		_, ok := ParseWindow(p)
+		assert.False(ok, p)
+	}
+	assert.True(ValidPeriod(PeriodHour))
+	assert.True(ValidPeriod("90s"))
+	assert.False(ValidPeriod("hours"))
+}
+
+func TestMemCountStoreWindow(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	now := time.Date(2024, 1, 1, 10, 55, 0, 0, time.UTC)
+	cs := NewMemCountStore()
+	cs.Clock = func() time.Time { return now }
+
+	// five increments, straddling an hour boundary
+	for i := 0; i < 5; i++ {
+		assert.NoError(cs.IncrementPeriod(ctx, "posts", "did:plc:abc111", "10m"))
+		now = now.Add(2 * time.Minute)
+	}
+	// 11:04; all increments are within the window
+	now = now.Add(-time.Minute)
+	c, err := cs.GetCount(ctx, "posts", "did:plc:abc111", "10m")
+	assert.NoError(err)
+	assert.Equal(5, c)
+	// same window, written differently
+	c, err = cs.GetCount(ctx, "posts", "did:plc:abc111", "600s")
+	assert.NoError(err)
+	assert.Equal(5, c)
+	// 11:05; the first increment (10:55) has just left the window
+	now = now.Add(time.Minute)
+	c, err = cs.GetCount(ctx, "posts", "did:plc:abc111", "10m")
+	assert.NoError(err)
+	assert.Equal(4, c)
+	// a different window is a different counter
+	c, err = cs.GetCount(ctx, "posts", "did:plc:abc111", "1h")
+	assert.NoError(err)
+	assert.Equal(0, c)
+
+	now = now.Add(3 * time.Minute)
+	c, err = cs.GetCount(ctx, "posts", "did:plc:abc111", "10m")
+	assert.NoError(err)
+	assert.Equal(3, c)
+
+	now = now.Add(time.Hour)
+	c, err = cs.GetCount(ctx, "posts", "did:plc:abc111", "10m")
+	assert.NoError(err)
+	assert.Equal(0, c)
+}
+
+func TestMemCountStoreDistinctWindow(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	cs := NewMemCountStore()
+	cs.Clock = func() time.Time { return now }
+
+	for _, val := range []string{"one", "two", "one"} {
+		assert.NoError(cs.IncrementDistinctPeriod(ctx, "mentions", "did:plc:abc111", val, "5m"))
+		now = now.Add(time.Minute)
+	}
+	now = now.Add(2 * time.Minute)
+	assert.NoError(cs.IncrementDistinctPeriod(ctx, "mentions", "did:plc:abc111", "three", "5m"))
+
+	// "one" was first seen more than five minutes ago, but seen again since
+	c, err := cs.GetCountDistinct(ctx, "mentions", "did:plc:abc111", "5m")
+	assert.NoError(err)
+	assert.Equal(3, c)
+
+	now = now.Add(3 * time.Minute)
+	c, err = cs.GetCountDistinct(ctx, "mentions", "did:plc:abc111", "5m")
+	assert.NoError(err)
+	assert.Equal(1, c)
+}
+
+func TestMemCountStoreBatch(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	cs := NewMemCountStore()
+	assert.NoError(cs.IncrementBatch(ctx, []CountIncrement{
+		{Name: "test1", Val: "val1"},
+		{Name: "test1", Val: "val1", Period: PeriodHour},
+		{Name: "test1", Val: "val1", Period: "10m"},
+		{Name: "test2", Val: "bucket", Distinct: true, DistinctVal: "one"},
+		{Name: "test2", Val: "bucket", Distinct: true, DistinctVal: "two", Period: "10m"},
+		// an empty distinct value is still a distinct counter increment
+		{Name: "test3", Val: "bucket", Distinct: true},
+	}))
+
+	c, err := cs.GetCount(ctx, "test1", "val1", PeriodTotal)
+	assert.NoError(err)
+	assert.Equal(1, c)
+	c, err = cs.GetCount(ctx, "test1", "val1", PeriodHour)
+	assert.NoError(err)
+	assert.Equal(2, c)
+	c, err = cs.GetCount(ctx, "test1", "val1", "10m")
+	assert.NoError(err)
+	assert.Equal(1, c)
+	c, err = cs.GetCountDistinct(ctx, "test2", "bucket", PeriodDay)
+	assert.NoError(err)
+	assert.Equal(1, c)
+	c, err = cs.GetCountDistinct(ctx, "test2", "bucket", "10m")
+	assert.NoError(err)
+	assert.Equal(1, c)
+	c, err = cs.GetCountDistinct(ctx, "test3", "bucket", PeriodTotal)
+	assert.NoError(err)
+	assert.Equal(1, c)
+	c, err = cs.GetCount(ctx, "test3", "bucket", PeriodTotal)
+	assert.NoError(err)
+	assert.Equal(0, c)
+}
@@ -0,0 +1,355 @@
+package declarative
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	comatproto "github.com/bluesky-social/indigo/api/atproto"
+	appbsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/automod/engine"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testRecordContext(t *testing.T, eng *engine.Engine, post *appbsky.FeedPost) engine.RecordContext {
+	buf := new(bytes.Buffer)
+	if err := post.MarshalCBOR(buf); err != nil {
+		t.Fatal(err)
+	}
+	created := time.Now().Add(-24 * time.Hour)
+	am := engine.AccountMeta{
+		Identity: &identity.Identity{
+			DID:    syntax.DID("did:plc:abc111"),
+			Handle: syntax.Handle("handle.example.com"),
+		},
+		PostsCount: 3,
+		CreatedAt:  &created,
+	}
+	cid := syntax.CID("cid123")
+	op := engine.RecordOp{
+		Action:     engine.CreateOp,
+		DID:        am.Identity.DID,
+		Collection: syntax.NSID("app.bsky.feed.post"),
+		RecordKey:  syntax.RecordKey("abc123"),
+		CID:        &cid,
+		RecordCBOR: buf.Bytes(),
+	}
+	return engine.NewRecordContext(context.Background(), eng, am, op)
+}
+
+func TestEval(t *testing.T) {
+	assert := assert.New(t)
+	eng := engine.EngineTestFixture()
+	c := testRecordContext(t, &eng, &appbsky.FeedPost{
+		Text: "Hello World",
+		Tags: []string{"one", "slur"},
+		Reply: &appbsky.FeedPost_ReplyRef{
+			Parent: &comatproto.RepoStrongRef{Uri: "at://did:plc:other/app.bsky.feed.post/1", Cid: "cid1"},
+			Root:   &comatproto.RepoStrongRef{Uri: "at://did:plc:other/app.bsky.feed.post/1", Cid: "cid1"},
+		},
+	})
+
+	testCases := []struct {
+		expr string
+		val  any
+	}{
+		{`1 + 2 * 3`, int64(7)},
+		{`(1 + 2) * 3`, int64(9)},
+		{`7 / 2`, int64(3)},
+		{`7 / 2.0`, 3.5},
+		{`-3 + 1`, int64(-2)},
+		{`"a" + "b"`, "ab"},
+		{`1 == 1.0`, true},
+		{`!(1 < 2) || 2 >= 2 && false`, false},
+		{`"b" > "a"`, true},
+		{`did`, "did:plc:abc111"},
+		{`handle`, "handle.example.com"},
+		{`account.posts_count + 1`, int64(4)},
+		{`account.age_hours > 23 && account.age_hours < 25`, true},
+		{`account.email`, nil},
+		{`collection`, "app.bsky.feed.post"},
+		{`uri`, "at://did:plc:abc111/app.bsky.feed.post/abc123"},
+		{`record.text`, "Hello World"},
+		{`record.reply.parent.uri`, "at://did:plc:other/app.bsky.feed.post/1"},
+		{`record.missing.field`, nil},
+		{`record.missing > 3`, false},
+		{`record.embed == null`, true},
+		{`"slur" in record.tags`, true},
+		{`"World" in record.text`, true},
+		{`2 in [1, 2, 3]`, true},
+		{`len(record.tags)`, int64(2)},
+		{`len(record.missing)`, int64(0)},
+		{`lower(record.text)`, "hello world"},
+		{`tokens(record.text)`, []any{"hello", "world"}},
+		{`starts_with(record.text, "Hello")`, true},
+		{`matches("^[A-Z]", record.text)`, true},
+		{`in_set("bad-hashtags", "slur")`, true},
+		{`any_in_set("bad-hashtags", record.tags)`, true},
+		{`any_in_set("bad-hashtags", ["one"])`, false},
+		{`count("posts", did, "total")`, int64(0)},
+		{`count("posts", did, "10m")`, int64(0)},
+	}
+	for _, tc := range testCases {
+		n, err := parseExpr(tc.expr)
+		if !assert.NoError(err, tc.expr) {
+			continue
+		}
+		if _, err := n.check(&checkEnv{record: true}); !assert.NoError(err, tc.expr) {
+			continue
+		}
+		val, err := n.eval(newRecordEnv(&c))
+		assert.NoError(err, tc.expr)
+		assert.Equal(tc.val, val, tc.expr)
+	}
+
+	// runtime errors
+	for _, expr := range []string{`record.text + 1`, `1 / 0`, `record.text.foo`, `len(record.reply.parent.uri) > 1 && record.text`} {
+		n, err := parseExpr(expr)
+		if !assert.NoError(err, expr) {
+			continue
+		}
+		if _, err := n.check(&checkEnv{record: true}); !assert.NoError(err, expr) {
+			continue
+		}
+		_, err = evalBool(n, newRecordEnv(&c))
+		assert.Error(err, expr)
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, tc := range []struct {
+		expr   string
+		record bool
+	}{
+		{`1 +`, true},
+		{`(1`, true},
+		{`"unterminated`, true},
+		{`a..b`, true},
+		{`1 2`, true},
+		{`unknown_var`, true},
+		{`unknown_func()`, true},
+		{`len()`, true},
+		{`1 + "a"`, true},
+		{`!"a"`, true},
+		{`account.posts_count.foo`, true},
+		{`did < 3`, true},
+		{`1 in 2`, true},
+		{`record.text`, false},
+		{`collection`, false},
+		{`matches(record.text, "a")`, true},
+		{`matches("(", "a")`, true},
+		{`count("x", did, "week")`, true},
+		{`count("x", did, "-10m")`, true},
+		{`add_record_label("spam")`, true},
+	} {
+		n, err := parseExpr(tc.expr)
+		if err != nil {
+			continue
+		}
+		_, err = n.check(&checkEnv{record: tc.record})
+		assert.Error(err, tc.expr)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	assert := assert.New(t)
+
+	src, err := os.ReadFile("testdata/example_rules.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr, err := Compile(src)
+	assert.NoError(err)
+	assert.Equal(4, len(cr.Rules))
+	assert.Equal(2, len(cr.byEvent[EventRecord]))
+	assert.Equal(1, len(cr.byEvent[EventIdentity]))
+
+	for _, bad := range []string{
+		"rules:\n  - name: x\n    event: record\n    actions: ['add_record_label(\"a\")']\n    extra: field\n",
+		"rules:\n  - event: record\n    actions: ['add_record_label(\"a\")']\n",
+		"rules:\n  - name: x\n    event: bogus\n    actions: ['notify(\"a\")']\n",
+		"rules:\n  - name: x\n    event: record\n    when: 'did'\n    actions: ['notify(\"a\")']\n",
+		"rules:\n  - name: x\n    event: record\n    actions: []\n",
+		"rules:\n  - name: x\n    event: record\n    actions: ['1 + 2']\n",
+		"rules:\n  - name: x\n    event: identity\n    actions: ['add_record_label(\"a\")']\n",
+		"rules:\n  - name: x\n    event: identity\n    collections: [app.bsky.feed.post]\n    actions: ['notify(\"a\")']\n",
+		"rules:\n  - name: x\n    event: record\n    actions: ['report_record(\"bogus\", \"\")']\n",
+		"rules:\n  - name: x\n    event: record\n    actions: ['notify(\"a\")']\n  - name: x\n    event: record\n    actions: ['notify(\"a\")']\n",
+	} {
+		_, err := Compile([]byte(bad))
+		assert.Error(err, bad)
+	}
+
+	// every invalid rule is reported
+	_, err = Compile([]byte("rules:\n  - name: one\n    event: record\n    when: 'nope'\n    actions: ['notify(\"a\")']\n  - name: two\n    event: record\n    when: 'nope'\n    actions: ['notify(\"a\")']\n"))
+	assert.ErrorContains(err, "rule one")
+	assert.ErrorContains(err, "rule two")
+}
+
+func TestRecordRules(t *testing.T) {
+	assert := assert.New(t)
+	eng := engine.EngineTestFixture()
+
+	src, err := os.ReadFile("testdata/example_rules.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr, err := Compile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c1 := testRecordContext(t, &eng, &appbsky.FeedPost{Text: "some post", Tags: []string{"one"}})
+	assert.NoError(cr.RecordRule(&c1))
+	eff1 := engine.ExtractEffects(&c1.BaseContext)
+	assert.Empty(eff1.RecordLabels)
+
+	c2 := testRecordContext(t, &eng, &appbsky.FeedPost{Text: "some post", Tags: []string{"one", "slur"}})
+	assert.NoError(cr.RecordRule(&c2))
+	eff2 := engine.ExtractEffects(&c2.BaseContext)
+	assert.Equal([]string{"bad-hashtag"}, eff2.RecordLabels)
+	assert.Equal(1, len(eff2.CounterIncrements))
+	assert.Equal("did:plc:abc111", eff2.CounterIncrements[0].Val)
+
+	// collection filter
+	c3 := testRecordContext(t, &eng, &appbsky.FeedPost{Text: "some post", Tags: []string{"slur"}})
+	c3.RecordOp.Collection = syntax.NSID("app.bsky.feed.like")
+	assert.NoError(cr.RecordRule(&c3))
+	assert.Empty(engine.ExtractEffects(&c3.BaseContext).RecordLabels)
+
+	// runtime errors are returned, and don't prevent other rules from running
+	cr, err = Compile([]byte(`
+rules:
+  - name: broken
+    event: record
+    when: 'record.text + 1 > 2'
+    actions: ['add_record_flag("broken")']
+  - name: working
+    event: record
+    actions: ['add_record_flag("working")', 'report_record("spam", "example")']
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c4 := testRecordContext(t, &eng, &appbsky.FeedPost{Text: "some post"})
+	assert.ErrorContains(cr.RecordRule(&c4), "declarative rule broken")
+	eff4 := engine.ExtractEffects(&c4.BaseContext)
+	assert.Equal([]string{"working"}, eff4.RecordFlags)
+	assert.Equal(engine.ReportReasonSpam, eff4.RecordReports[0].ReasonType)
+}
+
+func TestShadowRules(t *testing.T) {
+	assert := assert.New(t)
+	eng := engine.EngineTestFixture()
+	eng.Outcomes = engine.NewOutcomeRecorder(time.Hour)
+
+	cr, err := Compile([]byte(`
+rules:
+  - name: live
+    event: record
+    actions: ['add_record_flag("live")']
+  - name: shadow
+    event: record
+    shadow: true
+    actions: ['add_record_label("spam")', 'increment("shadow", did)']
+  - name: gated
+    event: record
+    allowed_effects: [flag]
+    actions: ['add_record_flag("gated")', 'takedown_record()']
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := testRecordContext(t, &eng, &appbsky.FeedPost{Text: "some post"})
+	assert.NoError(cr.RecordRule(&c))
+	eff := engine.ExtractEffects(&c.BaseContext)
+	assert.Equal([]string{"live", "gated"}, eff.RecordFlags)
+	assert.Empty(eff.RecordLabels)
+	assert.False(eff.RecordTakedown)
+	// counters are persisted even for shadow rules
+	assert.Equal(1, len(eff.CounterIncrements))
+
+	// file-level shadow mode, and invalid effect types
+	cr, err = Compile([]byte("shadow: true\nrules:\n  - name: one\n    event: record\n    actions: ['add_record_flag(\"one\")']\n"))
+	assert.NoError(err)
+	assert.True(cr.Rules[0].Policy.Shadow)
+	_, err = Compile([]byte("rules:\n  - name: one\n    event: record\n    allowed_effects: [explode]\n    actions: ['add_record_flag(\"one\")']\n"))
+	assert.ErrorContains(err, "unknown effect type")
+}
+
+func TestLoader(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	v1 := "rules:\n  - name: one\n    event: record\n    actions: ['add_record_flag(\"one\")']\n"
+	v2 := "rules:\n  - name: two\n    event: record\n    actions: ['add_record_flag(\"two\")']\n"
+	assert.NoError(os.WriteFile(path, []byte(v1), 0644))
+
+	l := NewLoader(path)
+	rs := l.RuleSet()
+	eng := engine.EngineTestFixture()
+
+	// nothing loaded yet
+	c := testRecordContext(t, &eng, &appbsky.FeedPost{Text: "post"})
+	assert.NoError(rs.RecordRules[0](&c))
+	assert.Empty(engine.ExtractEffects(&c.BaseContext).RecordFlags)
+
+	updated, err := l.Load(ctx)
+	assert.NoError(err)
+	assert.True(updated)
+	updated, err = l.Load(ctx)
+	assert.NoError(err)
+	assert.False(updated)
+	c = testRecordContext(t, &eng, &appbsky.FeedPost{Text: "post"})
+	assert.NoError(rs.RecordRules[0](&c))
+	assert.Equal([]string{"one"}, engine.ExtractEffects(&c.BaseContext).RecordFlags)
+
+	// invalid update keeps the previous rules
+	assert.NoError(os.WriteFile(path, []byte("rules:\n  - name: bad\n"), 0644))
+	_, err = l.Load(ctx)
+	assert.Error(err)
+	assert.Equal("one", l.Rules().Rules[0].Name)
+
+	assert.NoError(os.WriteFile(path, []byte(v2), 0644))
+	updated, err = l.Load(ctx)
+	assert.NoError(err)
+	assert.True(updated)
+	c = testRecordContext(t, &eng, &appbsky.FeedPost{Text: "post"})
+	assert.NoError(rs.RecordRules[0](&c))
+	assert.Equal([]string{"two"}, engine.ExtractEffects(&c.BaseContext).RecordFlags)
+
+	// from URL
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(v1))
+	}))
+	defer srv.Close()
+	ul := NewLoader(srv.URL + "/rules.yaml")
+	ul.HTTPClient = srv.Client()
+	_, err = ul.Load(ctx)
+	assert.NoError(err)
+	assert.Equal("one", ul.Rules().Rules[0].Name)
+
+	// oversized files are rejected, not truncated
+	big := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(v2))
+		w.Write([]byte("# " + strings.Repeat("x", maxRuleFileSize) + "\n"))
+	}))
+	defer big.Close()
+	ul.Source = big.URL + "/rules.yaml"
+	ul.HTTPClient = big.Client()
+	_, err = ul.Load(ctx)
+	assert.ErrorContains(err, "maximum size")
+	assert.Equal("one", ul.Rules().Rules[0].Name)
+}
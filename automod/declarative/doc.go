@@ -0,0 +1,26 @@
+/*
+Package declarative implements automod rules which are written in a YAML file and loaded at runtime, instead of being compiled in to the binary as Go functions.
+
+A rule file contains a list of rules. Each rule runs on one event type ("record", "delete", "identity", or "account"), optionally filtered to a set of record collections, and has a condition ("when") and a list of actions:
+
+	rules:
+	  - name: young-account-bad-hashtag
+	    event: record
+	    collections: [app.bsky.feed.post]
+	    when: 'account.age_hours >= 0 && account.age_hours < 72 && any_in_set("bad-hashtags", record.tags)'
+	    actions:
+	      - 'add_record_label("spam")'
+	      - 'report_record("spam", "young account posting bad hashtag")'
+	      - 'increment("bad-hashtag", did)'
+
+Conditions and actions are written in a small expression language: literals (strings, ints, floats, true, false, null, and [lists]), variables, function calls, and the operators "!" and unary "-", "* / %", "+ -", "< <= > >= in", "== !=", "&&", and "||" (from highest to lowest precedence, with the usual meanings; "x in list" checks membership, and "s in string" checks for a substring).
+
+Variables available to all rules include "did", "handle", and account metadata ("account.followers_count", "account.labels", "account.age_hours", "account.email", etc). Record and delete rules additionally have "collection", "rkey", "uri", "cid", "action", and "record": the record data, whose fields can be accessed with dots ("record.reply.parent.uri"). Missing fields evaluate to null, which is treated as false in conditions, and ordered comparisons with null are always false.
+
+Functions available in conditions include counters and sets ("count", "count_distinct", "in_set", "any_in_set") and string helpers ("len", "lower", "contains", "starts_with", "ends_with", "slugify", "tokens", "matches"). Actions call effect functions: "add_record_label", "add_account_flag", "report_account", "takedown_record", "increment", "notify", and so on. See env.go for the full list.
+
+A rule with "shadow: true" runs normally, but its actions are only logged and counted (see [engine.RulePolicy]), never persisted; this is the way to try out a new rule in production. Setting "shadow: true" at the top level of the file does the same for every rule in it. A rule can instead list "allowed_effects" (eg, [label, flag]), in which case only those types of actions are persisted. Rule names match policies in the engine's RulePolicies configuration, which take precedence over the file.
+
+Rule files are fully parsed and type-checked by [Compile], so mistakes (unknown variables or functions, type mismatches, invalid regular expressions, counter periods, or report reasons) are reported when the file is loaded rather than when a rule runs. [Loader] loads a file from a local path or URL, and hot-reloads it; if a new version fails to compile, the previous version stays in effect. Per-rule evaluation counts and timing are exported as Prometheus metrics.
+*/
+package declarative
@@ -0,0 +1,363 @@
+package declarative
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bluesky-social/indigo/automod/countstore"
+	"github.com/bluesky-social/indigo/automod/engine"
+	"github.com/bluesky-social/indigo/automod/keyword"
+)
+
+// A named value available to rule expressions.
+type variable struct {
+	typ Type
+	// only available in record rules
+	record bool
+	get    func(ev *evalEnv) (any, error)
+}
+
+// A function available to rule expressions. Actions (which record effects) may only be called from rule actions, not conditions.
+type function struct {
+	args   []Type
+	ret    Type
+	record bool
+	action bool
+	// optional compile-time validation of the call, eg for literal arguments
+	prepare func(c *call) error
+	impl    func(ev *evalEnv, c *call, args []any) (any, error)
+}
+
+func stringList(vals []string) []any {
+	out := make([]any, len(vals))
+	for i, v := range vals {
+		out[i] = v
+	}
+	return out
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func accountVar(typ Type, get func(am *engine.AccountMeta) any) *variable {
+	return &variable{typ: typ, get: func(ev *evalEnv) (any, error) {
+		return get(&ev.acct.Account), nil
+	}}
+}
+
+func privateVar(typ Type, get func(ap *engine.AccountPrivate) any) *variable {
+	return &variable{typ: typ, get: func(ev *evalEnv) (any, error) {
+		if ev.acct.Account.Private == nil {
+			return nil, nil
+		}
+		return get(ev.acct.Account.Private), nil
+	}}
+}
+
+func recordVar(typ Type, get func(op *engine.RecordOp) any) *variable {
+	return &variable{typ: typ, record: true, get: func(ev *evalEnv) (any, error) {
+		return get(&ev.rec.RecordOp), nil
+	}}
+}
+
+var variables = map[string]*variable{
+	"did": accountVar(TypeString, func(am *engine.AccountMeta) any { return am.Identity.DID.String() }),
+	"handle": accountVar(TypeString, func(am *engine.AccountMeta) any {
+		return am.Identity.Handle.String()
+	}),
+	"account.labels":          accountVar(TypeList, func(am *engine.AccountMeta) any { return stringList(am.AccountLabels) }),
+	"account.negated_labels":  accountVar(TypeList, func(am *engine.AccountMeta) any { return stringList(am.AccountNegatedLabels) }),
+	"account.flags":           accountVar(TypeList, func(am *engine.AccountMeta) any { return stringList(am.AccountFlags) }),
+	"account.followers_count": accountVar(TypeInt, func(am *engine.AccountMeta) any { return am.FollowersCount }),
+	"account.follows_count":   accountVar(TypeInt, func(am *engine.AccountMeta) any { return am.FollowsCount }),
+	"account.posts_count":     accountVar(TypeInt, func(am *engine.AccountMeta) any { return am.PostsCount }),
+	"account.takendown":       accountVar(TypeBool, func(am *engine.AccountMeta) any { return am.Takendown }),
+	"account.deactivated":     accountVar(TypeBool, func(am *engine.AccountMeta) any { return am.Deactivated }),
+	// hours since account creation, or -1 if not known
+	"account.age_hours": accountVar(TypeFloat, func(am *engine.AccountMeta) any {
+		if am.CreatedAt == nil {
+			return float64(-1)
+		}
+		return time.Since(*am.CreatedAt).Hours()
+	}),
+	"account.has_avatar":   accountVar(TypeBool, func(am *engine.AccountMeta) any { return am.Profile.HasAvatar }),
+	"account.display_name": accountVar(TypeString, func(am *engine.AccountMeta) any { return derefString(am.Profile.DisplayName) }),
+	"account.description":  accountVar(TypeString, func(am *engine.AccountMeta) any { return derefString(am.Profile.Description) }),
+	// private account metadata is null if not available
+	"account.email":           privateVar(TypeString, func(ap *engine.AccountPrivate) any { return ap.Email }),
+	"account.email_confirmed": privateVar(TypeBool, func(ap *engine.AccountPrivate) any { return ap.EmailConfirmed }),
+	"account.tags":            privateVar(TypeList, func(ap *engine.AccountPrivate) any { return stringList(ap.AccountTags) }),
+	"account.review_state":    privateVar(TypeString, func(ap *engine.AccountPrivate) any { return ap.ReviewState }),
+	"account.appealed":        privateVar(TypeBool, func(ap *engine.AccountPrivate) any { return ap.Appealed }),
+
+	"action":     recordVar(TypeString, func(op *engine.RecordOp) any { return op.Action }),
+	"collection": recordVar(TypeString, func(op *engine.RecordOp) any { return op.Collection.String() }),
+	"rkey":       recordVar(TypeString, func(op *engine.RecordOp) any { return op.RecordKey.String() }),
+	"uri":        recordVar(TypeString, func(op *engine.RecordOp) any { return op.ATURI().String() }),
+	"cid": recordVar(TypeString, func(op *engine.RecordOp) any {
+		if op.CID == nil {
+			return nil
+		}
+		return op.CID.String()
+	}),
+	// the record data, as generic JSON-like values; null for deletions
+	"record": {typ: TypeAny, record: true, get: func(ev *evalEnv) (any, error) {
+		rec, err := ev.recordData()
+		if err != nil || rec == nil {
+			return nil, err
+		}
+		return rec, nil
+	}},
+}
+
+// runtime conversion of an argument which was type-checked as string (or any)
+func argString(v any) (string, error) {
+	switch s := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return s, nil
+	}
+	return "", fmt.Errorf("expected string, found %s", typeName(v))
+}
+
+func argList(v any) ([]any, error) {
+	switch l := v.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		return l, nil
+	}
+	return nil, fmt.Errorf("expected list, found %s", typeName(v))
+}
+
+func stringArgs(args []any) ([]string, error) {
+	out := make([]string, len(args))
+	for i, a := range args {
+		s, err := argString(a)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = s
+	}
+	return out, nil
+}
+
+// builds a function from a string-only implementation
+func stringFunc(nargs int, ret Type, f func(ev *evalEnv, args []string) (any, error)) *function {
+	types := make([]Type, nargs)
+	for i := range types {
+		types[i] = TypeString
+	}
+	return &function{args: types, ret: ret, impl: func(ev *evalEnv, c *call, args []any) (any, error) {
+		sargs, err := stringArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		return f(ev, sargs)
+	}}
+}
+
+func accountAction(nargs int, f func(c *engine.AccountContext, args []string)) *function {
+	fn := stringFunc(nargs, TypeNull, func(ev *evalEnv, args []string) (any, error) {
+		f(ev.acct, args)
+		return nil, nil
+	})
+	fn.action = true
+	return fn
+}
+
+func recordAction(nargs int, f func(c *engine.RecordContext, args []string)) *function {
+	fn := stringFunc(nargs, TypeNull, func(ev *evalEnv, args []string) (any, error) {
+		f(ev.rec, args)
+		return nil, nil
+	})
+	fn.action = true
+	fn.record = true
+	return fn
+}
+
+// validates that the argument at the given index, if a literal, is one of the fixed counter periods or a sliding window duration
+func literalPeriod(idx int) func(c *call) error {
+	return func(c *call) error {
+		s, ok := literalString(c.args[idx])
+		if !ok || countstore.ValidPeriod(s) {
+			return nil
+		}
+		return fmt.Errorf("invalid counter period %q (expected %s, %s, %s, or a duration like \"10m\")", s, countstore.PeriodTotal, countstore.PeriodDay, countstore.PeriodHour)
+	}
+}
+
+// short names for report reasons, as used in rule files
+var reportReasons = map[string]string{
+	"spam":       engine.ReportReasonSpam,
+	"violation":  engine.ReportReasonViolation,
+	"misleading": engine.ReportReasonMisleading,
+	"sexual":     engine.ReportReasonSexual,
+	"rude":       engine.ReportReasonRude,
+	"other":      engine.ReportReasonOther,
+}
+
+func reportReason(s string) string {
+	if r, ok := reportReasons[s]; ok {
+		return r
+	}
+	return s
+}
+
+func validReportReason(c *call) error {
+	s, ok := literalString(c.args[0])
+	if !ok {
+		return nil
+	}
+	for short, full := range reportReasons {
+		if s == short || s == full {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown report reason %q", s)
+}
+
+func withPrepare(fn *function, prepare func(c *call) error) *function {
+	fn.prepare = prepare
+	return fn
+}
+
+var functions = map[string]*function{
+	// state lookups
+	"count": withPrepare(stringFunc(3, TypeInt, func(ev *evalEnv, args []string) (any, error) {
+		return int64(ev.acct.GetCount(args[0], args[1], args[2])), nil
+	}), literalPeriod(2)),
+	"count_distinct": withPrepare(stringFunc(3, TypeInt, func(ev *evalEnv, args []string) (any, error) {
+		return int64(ev.acct.GetCountDistinct(args[0], args[1], args[2])), nil
+	}), literalPeriod(2)),
+	"in_set": stringFunc(2, TypeBool, func(ev *evalEnv, args []string) (any, error) {
+		return ev.acct.InSet(args[0], args[1]), nil
+	}),
+	"any_in_set": {args: []Type{TypeString, TypeList}, ret: TypeBool, impl: func(ev *evalEnv, c *call, args []any) (any, error) {
+		name, err := argString(args[0])
+		if err != nil {
+			return nil, err
+		}
+		vals, err := argList(args[1])
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range vals {
+			if s, ok := v.(string); ok && ev.acct.InSet(name, s) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}},
+
+	// string and list helpers
+	"len": {args: []Type{TypeAny}, ret: TypeInt, impl: func(ev *evalEnv, c *call, args []any) (any, error) {
+		switch v := args[0].(type) {
+		case nil:
+			return int64(0), nil
+		case string:
+			return int64(len([]rune(v))), nil
+		case []any:
+			return int64(len(v)), nil
+		case map[string]any:
+			return int64(len(v)), nil
+		}
+		return nil, fmt.Errorf("expected string or list, found %s", typeName(args[0]))
+	}},
+	"lower": stringFunc(1, TypeString, func(ev *evalEnv, args []string) (any, error) {
+		return strings.ToLower(args[0]), nil
+	}),
+	"contains": stringFunc(2, TypeBool, func(ev *evalEnv, args []string) (any, error) {
+		return strings.Contains(args[0], args[1]), nil
+	}),
+	"starts_with": stringFunc(2, TypeBool, func(ev *evalEnv, args []string) (any, error) {
+		return strings.HasPrefix(args[0], args[1]), nil
+	}),
+	"ends_with": stringFunc(2, TypeBool, func(ev *evalEnv, args []string) (any, error) {
+		return strings.HasSuffix(args[0], args[1]), nil
+	}),
+	"slugify": stringFunc(1, TypeString, func(ev *evalEnv, args []string) (any, error) {
+		return keyword.Slugify(args[0]), nil
+	}),
+	"tokens": stringFunc(1, TypeList, func(ev *evalEnv, args []string) (any, error) {
+		return stringList(keyword.TokenizeText(args[0])), nil
+	}),
+	// the pattern must be a string literal, so that it is compiled (and validated) at load time
+	"matches": {args: []Type{TypeString, TypeString}, ret: TypeBool,
+		prepare: func(c *call) error {
+			pattern, ok := literalString(c.args[0])
+			if !ok {
+				return fmt.Errorf("pattern must be a string literal")
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return err
+			}
+			c.re = re
+			return nil
+		},
+		impl: func(ev *evalEnv, c *call, args []any) (any, error) {
+			s, err := argString(args[1])
+			if err != nil {
+				return nil, err
+			}
+			return c.re.MatchString(s), nil
+		},
+	},
+
+	// generic actions
+	"increment": withAction(stringFunc(2, TypeNull, func(ev *evalEnv, args []string) (any, error) {
+		ev.acct.Increment(args[0], args[1])
+		return nil, nil
+	})),
+	"increment_period": withAction(withPrepare(stringFunc(3, TypeNull, func(ev *evalEnv, args []string) (any, error) {
+		ev.acct.IncrementPeriod(args[0], args[1], args[2])
+		return nil, nil
+	}), literalPeriod(2))),
+	"increment_distinct": withAction(stringFunc(3, TypeNull, func(ev *evalEnv, args []string) (any, error) {
+		ev.acct.IncrementDistinct(args[0], args[1], args[2])
+		return nil, nil
+	})),
+	"increment_distinct_period": withAction(withPrepare(stringFunc(4, TypeNull, func(ev *evalEnv, args []string) (any, error) {
+		ev.acct.IncrementDistinctPeriod(args[0], args[1], args[2], args[3])
+		return nil, nil
+	}), literalPeriod(3))),
+	"notify": withAction(stringFunc(1, TypeNull, func(ev *evalEnv, args []string) (any, error) {
+		ev.acct.Notify(args[0])
+		return nil, nil
+	})),
+
+	// account actions
+	"add_account_label": accountAction(1, func(c *engine.AccountContext, args []string) { c.AddAccountLabel(args[0]) }),
+	"add_account_flag":  accountAction(1, func(c *engine.AccountContext, args []string) { c.AddAccountFlag(args[0]) }),
+	"add_account_tag":   accountAction(1, func(c *engine.AccountContext, args []string) { c.AddAccountTag(args[0]) }),
+	"report_account": withPrepare(accountAction(2, func(c *engine.AccountContext, args []string) {
+		c.ReportAccount(reportReason(args[0]), args[1])
+	}), validReportReason),
+	"takedown_account":    accountAction(0, func(c *engine.AccountContext, args []string) { c.TakedownAccount() }),
+	"escalate_account":    accountAction(0, func(c *engine.AccountContext, args []string) { c.EscalateAccount() }),
+	"acknowledge_account": accountAction(0, func(c *engine.AccountContext, args []string) { c.AcknowledgeAccount() }),
+
+	// record actions
+	"add_record_label": recordAction(1, func(c *engine.RecordContext, args []string) { c.AddRecordLabel(args[0]) }),
+	"add_record_flag":  recordAction(1, func(c *engine.RecordContext, args []string) { c.AddRecordFlag(args[0]) }),
+	"add_record_tag":   recordAction(1, func(c *engine.RecordContext, args []string) { c.AddRecordTag(args[0]) }),
+	"report_record": withPrepare(recordAction(2, func(c *engine.RecordContext, args []string) {
+		c.ReportRecord(reportReason(args[0]), args[1])
+	}), validReportReason),
+	"takedown_record":    recordAction(0, func(c *engine.RecordContext, args []string) { c.TakedownRecord() }),
+	"escalate_record":    recordAction(0, func(c *engine.RecordContext, args []string) { c.EscalateRecord() }),
+	"acknowledge_record": recordAction(0, func(c *engine.RecordContext, args []string) { c.AcknowledgeRecord() }),
+}
+
+func withAction(fn *function) *function {
+	fn.action = true
+	return fn
+}
@@ -0,0 +1,300 @@
+package declarative
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/bluesky-social/indigo/atproto/data"
+	"github.com/bluesky-social/indigo/automod/engine"
+)
+
+// runtime context for evaluating a single rule
+type evalEnv struct {
+	acct *engine.AccountContext
+	// nil for account-level rules
+	rec *engine.RecordContext
+
+	// record data, decoded on first access
+	decoded   bool
+	record    map[string]any
+	recordErr error
+}
+
+func newAccountEnv(c *engine.AccountContext) *evalEnv {
+	return &evalEnv{acct: c}
+}
+
+func newRecordEnv(c *engine.RecordContext) *evalEnv {
+	return &evalEnv{acct: &c.AccountContext, rec: c}
+}
+
+func (ev *evalEnv) recordData() (map[string]any, error) {
+	if !ev.decoded {
+		ev.decoded = true
+		if ev.rec.RecordOp.RecordCBOR != nil {
+			ev.record, ev.recordErr = data.UnmarshalCBOR(ev.rec.RecordOp.RecordCBOR)
+		}
+	}
+	return ev.record, ev.recordErr
+}
+
+// evaluates a condition; null is treated as false
+func evalBool(n node, ev *evalEnv) (bool, error) {
+	v, err := n.eval(ev)
+	if err != nil {
+		return false, err
+	}
+	return truthy(v)
+}
+
+func truthy(v any) (bool, error) {
+	switch b := v.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return b, nil
+	default:
+		return false, fmt.Errorf("expected bool, found %s", typeName(v))
+	}
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case int64:
+		return "int"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	case []any:
+		return "list"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func equal(a, b any) bool {
+	if af, ok := toFloat(a); ok {
+		bf, ok := toFloat(b)
+		return ok && af == bf
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func (n *literal) eval(ev *evalEnv) (any, error) {
+	return n.val, nil
+}
+
+func (n *ident) eval(ev *evalEnv) (any, error) {
+	v, err := n.v.get(ev)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range n.fields {
+		switch obj := v.(type) {
+		case nil:
+			return nil, nil
+		case map[string]any:
+			v = obj[f]
+		default:
+			return nil, fmt.Errorf("%s: can not access field %q of %s", n.name, f, typeName(obj))
+		}
+	}
+	return v, nil
+}
+
+func (n *unary) eval(ev *evalEnv) (any, error) {
+	x, err := n.x.eval(ev)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "!" {
+		b, err := truthy(x)
+		return !b, err
+	}
+	switch v := x.(type) {
+	case int64:
+		return -v, nil
+	case float64:
+		return -v, nil
+	}
+	return nil, fmt.Errorf("operator - can not be applied to %s", typeName(x))
+}
+
+func (n *binary) eval(ev *evalEnv) (any, error) {
+	// short-circuit boolean operators
+	switch n.op {
+	case "&&", "||":
+		l, err := evalBool(n.l, ev)
+		if err != nil {
+			return nil, err
+		}
+		if (n.op == "&&" && !l) || (n.op == "||" && l) {
+			return l, nil
+		}
+		return evalBool(n.r, ev)
+	}
+
+	l, err := n.l.eval(ev)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.r.eval(ev)
+	if err != nil {
+		return nil, err
+	}
+	mismatch := fmt.Errorf("operator %s can not be applied to %s and %s", n.op, typeName(l), typeName(r))
+	switch n.op {
+	case "==":
+		return equal(l, r), nil
+	case "!=":
+		return !equal(l, r), nil
+	case "in":
+		switch coll := r.(type) {
+		case nil:
+			return false, nil
+		case []any:
+			for _, elem := range coll {
+				if equal(l, elem) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case string:
+			s, ok := l.(string)
+			if !ok {
+				return nil, mismatch
+			}
+			return strings.Contains(coll, s), nil
+		}
+		return nil, mismatch
+	case "<", "<=", ">", ">=":
+		// comparisons with missing values are always false
+		if l == nil || r == nil {
+			return false, nil
+		}
+		var cmp int
+		if ls, ok := l.(string); ok {
+			rs, ok := r.(string)
+			if !ok {
+				return nil, mismatch
+			}
+			cmp = strings.Compare(ls, rs)
+		} else {
+			lf, lok := toFloat(l)
+			rf, rok := toFloat(r)
+			if !lok || !rok {
+				return nil, mismatch
+			}
+			switch {
+			case lf < rf:
+				cmp = -1
+			case lf > rf:
+				cmp = 1
+			}
+		}
+		switch n.op {
+		case "<":
+			return cmp < 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		case ">":
+			return cmp > 0, nil
+		default:
+			return cmp >= 0, nil
+		}
+	}
+
+	// arithmetic
+	if ls, ok := l.(string); ok && n.op == "+" {
+		rs, ok := r.(string)
+		if !ok {
+			return nil, mismatch
+		}
+		return ls + rs, nil
+	}
+	li, lint := l.(int64)
+	ri, rint := r.(int64)
+	if lint && rint {
+		switch n.op {
+		case "+":
+			return li + ri, nil
+		case "-":
+			return li - ri, nil
+		case "*":
+			return li * ri, nil
+		case "/", "%":
+			if ri == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			if n.op == "/" {
+				return li / ri, nil
+			}
+			return li % ri, nil
+		}
+	}
+	lf, lok := toFloat(l)
+	rf, rok := toFloat(r)
+	if !lok || !rok {
+		return nil, mismatch
+	}
+	switch n.op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/":
+		if rf == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return lf / rf, nil
+	}
+	return nil, mismatch
+}
+
+func (n *call) eval(ev *evalEnv) (any, error) {
+	args := make([]any, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(ev)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	v, err := n.fn.impl(ev, n, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s(): %w", n.name, err)
+	}
+	return v, nil
+}
+
+func (n *listLit) eval(ev *evalEnv) (any, error) {
+	out := make([]any, len(n.elems))
+	for i, elem := range n.elems {
+		v, err := elem.eval(ev)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
@@ -0,0 +1,443 @@
+package declarative
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Static type of an expression, determined when a rule is compiled. Values with [TypeAny] (record fields) are checked at evaluation time instead.
+type Type int
+
+const (
+	TypeAny Type = iota
+	TypeNull
+	TypeBool
+	TypeInt
+	TypeFloat
+	TypeString
+	TypeList
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeNull:
+		return "null"
+	case TypeBool:
+		return "bool"
+	case TypeInt:
+		return "int"
+	case TypeFloat:
+		return "float"
+	case TypeString:
+		return "string"
+	case TypeList:
+		return "list"
+	default:
+		return "any"
+	}
+}
+
+// whether a value of static type 'have' can be used where 'want' is expected
+func assignable(have, want Type) bool {
+	return want == TypeAny || have == TypeAny || have == want || (want == TypeFloat && have == TypeInt)
+}
+
+func isNumeric(t Type) bool {
+	return t == TypeInt || t == TypeFloat
+}
+
+// A compiled expression: either a rule condition, or an action.
+type node interface {
+	check(env *checkEnv) (Type, error)
+	eval(ev *evalEnv) (any, error)
+}
+
+// compile-time context
+type checkEnv struct {
+	// whether record-level variables and actions are available
+	record bool
+	// whether action (effect) functions may be called
+	actions bool
+}
+
+type literal struct {
+	val any
+	typ Type
+}
+
+type ident struct {
+	name string
+	pos  int
+
+	// resolved during check
+	v      *variable
+	fields []string
+}
+
+type unary struct {
+	op string
+	x  node
+}
+
+type binary struct {
+	op   string
+	l, r node
+	pos  int
+}
+
+type call struct {
+	name string
+	args []node
+	pos  int
+
+	// resolved during check
+	fn *function
+	re *regexp.Regexp
+}
+
+type listLit struct {
+	elems []node
+}
+
+type parser struct {
+	toks []token
+	i    int
+}
+
+// Parses (but does not type-check) an expression.
+func parseExpr(src string) (node, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	n, err := p.parseBinary(1)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) expect(op string) error {
+	t := p.next()
+	if t.kind != tokOp || t.text != op {
+		if t.kind == tokEOF {
+			return fmt.Errorf("expected %q at end of expression", op)
+		}
+		return fmt.Errorf("expected %q at offset %d, found %q", op, t.pos, t.text)
+	}
+	return nil
+}
+
+// binding power of binary operators; zero for anything else
+func precedence(t token) int {
+	if t.kind == tokIdent && t.text == "in" {
+		return 4
+	}
+	if t.kind != tokOp {
+		return 0
+	}
+	switch t.text {
+	case "||":
+		return 1
+	case "&&":
+		return 2
+	case "==", "!=":
+		return 3
+	case "<", "<=", ">", ">=":
+		return 4
+	case "+", "-":
+		return 5
+	case "*", "/", "%":
+		return 6
+	}
+	return 0
+}
+
+func (p *parser) parseBinary(minPrec int) (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		prec := precedence(t)
+		if prec == 0 || prec < minPrec {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseBinary(prec + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{op: t.text, l: left, r: right, pos: t.pos}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	t := p.peek()
+	if t.kind == tokOp && (t.text == "!" || t.text == "-") {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unary{op: t.text, x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokInt:
+		return &literal{val: t.val, typ: TypeInt}, nil
+	case tokFloat:
+		return &literal{val: t.val, typ: TypeFloat}, nil
+	case tokString:
+		return &literal{val: t.val, typ: TypeString}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &literal{val: true, typ: TypeBool}, nil
+		case "false":
+			return &literal{val: false, typ: TypeBool}, nil
+		case "null":
+			return &literal{val: nil, typ: TypeNull}, nil
+		case "in":
+			return nil, fmt.Errorf("unexpected \"in\" at offset %d", t.pos)
+		}
+		if nt := p.peek(); nt.kind == tokOp && nt.text == "(" {
+			p.next()
+			c := &call{name: t.text, pos: t.pos}
+			if nt := p.peek(); nt.kind == tokOp && nt.text == ")" {
+				p.next()
+				return c, nil
+			}
+			for {
+				arg, err := p.parseBinary(1)
+				if err != nil {
+					return nil, err
+				}
+				c.args = append(c.args, arg)
+				if nt := p.peek(); nt.kind == tokOp && nt.text == "," {
+					p.next()
+					continue
+				}
+				if err := p.expect(")"); err != nil {
+					return nil, err
+				}
+				return c, nil
+			}
+		}
+		return &ident{name: t.text, pos: t.pos}, nil
+	case tokOp:
+		switch t.text {
+		case "(":
+			n, err := p.parseBinary(1)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return n, nil
+		case "[":
+			l := &listLit{}
+			if nt := p.peek(); nt.kind == tokOp && nt.text == "]" {
+				p.next()
+				return l, nil
+			}
+			for {
+				elem, err := p.parseBinary(1)
+				if err != nil {
+					return nil, err
+				}
+				l.elems = append(l.elems, elem)
+				if nt := p.peek(); nt.kind == tokOp && nt.text == "," {
+					p.next()
+					continue
+				}
+				if err := p.expect("]"); err != nil {
+					return nil, err
+				}
+				return l, nil
+			}
+		}
+		return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+	default:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+}
+
+func (n *literal) check(env *checkEnv) (Type, error) {
+	return n.typ, nil
+}
+
+func (n *ident) check(env *checkEnv) (Type, error) {
+	// find the longest dotted prefix which is a known variable; any remainder is field access
+	parts := strings.Split(n.name, ".")
+	for i := len(parts); i > 0; i-- {
+		v, ok := variables[strings.Join(parts[:i], ".")]
+		if !ok {
+			continue
+		}
+		if v.record && !env.record {
+			return TypeAny, fmt.Errorf("%q is only available in record rules", n.name)
+		}
+		n.v = v
+		n.fields = parts[i:]
+		if len(n.fields) == 0 {
+			return v.typ, nil
+		}
+		if v.typ != TypeAny {
+			return TypeAny, fmt.Errorf("%q has no field %q", strings.Join(parts[:i], "."), n.fields[0])
+		}
+		return TypeAny, nil
+	}
+	return TypeAny, fmt.Errorf("unknown variable %q at offset %d", n.name, n.pos)
+}
+
+func (n *unary) check(env *checkEnv) (Type, error) {
+	t, err := n.x.check(env)
+	if err != nil {
+		return t, err
+	}
+	switch n.op {
+	case "!":
+		if !assignable(t, TypeBool) {
+			return TypeAny, fmt.Errorf("operator ! requires bool, found %s", t)
+		}
+		return TypeBool, nil
+	default:
+		if t != TypeAny && !isNumeric(t) {
+			return TypeAny, fmt.Errorf("operator - requires a number, found %s", t)
+		}
+		return t, nil
+	}
+}
+
+func (n *binary) check(env *checkEnv) (Type, error) {
+	lt, err := n.l.check(env)
+	if err != nil {
+		return lt, err
+	}
+	rt, err := n.r.check(env)
+	if err != nil {
+		return rt, err
+	}
+	mismatch := fmt.Errorf("operator %s at offset %d can not be applied to %s and %s", n.op, n.pos, lt, rt)
+	switch n.op {
+	case "&&", "||":
+		if !assignable(lt, TypeBool) || !assignable(rt, TypeBool) {
+			return TypeAny, mismatch
+		}
+		return TypeBool, nil
+	case "==", "!=":
+		if lt != TypeAny && rt != TypeAny && lt != TypeNull && rt != TypeNull && lt != rt && !(isNumeric(lt) && isNumeric(rt)) {
+			return TypeAny, mismatch
+		}
+		return TypeBool, nil
+	case "<", "<=", ">", ">=":
+		if (lt == TypeString || rt == TypeString) && assignable(lt, TypeString) && assignable(rt, TypeString) {
+			return TypeBool, nil
+		}
+		if !(lt == TypeAny || isNumeric(lt)) || !(rt == TypeAny || isNumeric(rt)) {
+			return TypeAny, mismatch
+		}
+		return TypeBool, nil
+	case "in":
+		switch rt {
+		case TypeList, TypeAny:
+		case TypeString:
+			if !assignable(lt, TypeString) {
+				return TypeAny, mismatch
+			}
+		default:
+			return TypeAny, mismatch
+		}
+		return TypeBool, nil
+	case "+":
+		if (lt == TypeString || rt == TypeString) && assignable(lt, TypeString) && assignable(rt, TypeString) {
+			return TypeString, nil
+		}
+		fallthrough
+	default:
+		if !(lt == TypeAny || isNumeric(lt)) || !(rt == TypeAny || isNumeric(rt)) {
+			return TypeAny, mismatch
+		}
+		if lt == TypeInt && rt == TypeInt {
+			return TypeInt, nil
+		}
+		if lt == TypeAny || rt == TypeAny {
+			return TypeAny, nil
+		}
+		return TypeFloat, nil
+	}
+}
+
+func (n *call) check(env *checkEnv) (Type, error) {
+	fn, ok := functions[n.name]
+	if !ok {
+		return TypeAny, fmt.Errorf("unknown function %q at offset %d", n.name, n.pos)
+	}
+	if fn.action && !env.actions {
+		return TypeAny, fmt.Errorf("action %q can only be used in rule actions", n.name)
+	}
+	if fn.record && !env.record {
+		return TypeAny, fmt.Errorf("%q is only available in record rules", n.name)
+	}
+	if len(n.args) != len(fn.args) {
+		return TypeAny, fmt.Errorf("%s() takes %d arguments, found %d", n.name, len(fn.args), len(n.args))
+	}
+	for i, arg := range n.args {
+		t, err := arg.check(env)
+		if err != nil {
+			return TypeAny, err
+		}
+		if !assignable(t, fn.args[i]) {
+			return TypeAny, fmt.Errorf("argument %d of %s() must be %s, found %s", i+1, n.name, fn.args[i], t)
+		}
+	}
+	n.fn = fn
+	if fn.prepare != nil {
+		if err := fn.prepare(n); err != nil {
+			return TypeAny, fmt.Errorf("%s(): %w", n.name, err)
+		}
+	}
+	return fn.ret, nil
+}
+
+func (n *listLit) check(env *checkEnv) (Type, error) {
+	for _, elem := range n.elems {
+		if _, err := elem.check(env); err != nil {
+			return TypeAny, err
+		}
+	}
+	return TypeList, nil
+}
+
+// Returns the value of a literal string argument, for compile-time validation.
+func literalString(n node) (string, bool) {
+	lit, ok := n.(*literal)
+	if !ok || lit.typ != TypeString {
+		return "", false
+	}
+	return lit.val.(string), true
+}
@@ -0,0 +1,123 @@
+package declarative
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokInt
+	tokFloat
+	tokString
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// parsed value, for literals
+	val any
+	// byte offset in the source expression, for error messages
+	pos int
+}
+
+// operators, longest first so that eg "<=" is matched before "<"
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "*", "/", "%", "(", ")", "[", "]", ","}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(src) {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '_' || unicode.IsLetter(c):
+			start := i
+			for i < len(src) && (src[i] == '_' || src[i] == '.' || unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i]))) {
+				i++
+			}
+			text := src[start:i]
+			if strings.HasSuffix(text, ".") || strings.Contains(text, "..") {
+				return nil, fmt.Errorf("invalid identifier at offset %d: %q", start, text)
+			}
+			toks = append(toks, token{kind: tokIdent, text: text, pos: start})
+		case unicode.IsDigit(c):
+			start := i
+			isFloat := false
+			for i < len(src) && (unicode.IsDigit(rune(src[i])) || src[i] == '.' || src[i] == '_') {
+				if src[i] == '.' {
+					isFloat = true
+				}
+				i++
+			}
+			text := src[start:i]
+			if isFloat {
+				v, err := strconv.ParseFloat(text, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid number at offset %d: %q", start, text)
+				}
+				toks = append(toks, token{kind: tokFloat, text: text, val: v, pos: start})
+			} else {
+				v, err := strconv.ParseInt(text, 0, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid number at offset %d: %q", start, text)
+				}
+				toks = append(toks, token{kind: tokInt, text: text, val: v, pos: start})
+			}
+		case c == '"' || c == '\'':
+			start := i
+			i++
+			var sb strings.Builder
+			closed := false
+			for i < len(src) {
+				if src[i] == byte(c) {
+					closed = true
+					i++
+					break
+				}
+				if src[i] == '\\' && i+1 < len(src) {
+					switch src[i+1] {
+					case 'n':
+						sb.WriteByte('\n')
+					case 't':
+						sb.WriteByte('\t')
+					case '\\', '"', '\'':
+						sb.WriteByte(src[i+1])
+					default:
+						return nil, fmt.Errorf("invalid escape sequence at offset %d", i)
+					}
+					i += 2
+					continue
+				}
+				sb.WriteByte(src[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string at offset %d", start)
+			}
+			toks = append(toks, token{kind: tokString, text: src[start:i], val: sb.String(), pos: start})
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(src[i:], op) {
+					toks = append(toks, token{kind: tokOp, text: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character at offset %d: %q", i, c)
+			}
+		}
+	}
+	toks = append(toks, token{kind: tokEOF, pos: len(src)})
+	return toks, nil
+}
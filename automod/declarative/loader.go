@@ -0,0 +1,165 @@
+package declarative
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bluesky-social/indigo/automod/engine"
+	"github.com/bluesky-social/indigo/util"
+)
+
+// maximum size of a rule file fetched over HTTP
+const maxRuleFileSize = 8 << 20
+
+// Loads a rule file from a local path or HTTP(S) URL, and hot-reloads it when the content changes.
+//
+// The rules returned by [Loader.RuleSet] always dispatch to the most recently loaded version. If a new version of the file fails to compile, the previous version stays in effect.
+type Loader struct {
+	// Local file path, or "http://" or "https://" URL
+	Source     string
+	HTTPClient *http.Client
+	Logger     *slog.Logger
+
+	current atomic.Pointer[CompiledRules]
+	// protects lastHash, and serializes loads
+	lk       sync.Mutex
+	lastHash [32]byte
+}
+
+func NewLoader(source string) *Loader {
+	return &Loader{
+		Source:     source,
+		HTTPClient: util.RobustHTTPClient(),
+		Logger:     slog.Default().With("system", "automod-declarative"),
+	}
+}
+
+func (l *Loader) isURL() bool {
+	return strings.HasPrefix(l.Source, "https://") || strings.HasPrefix(l.Source, "http://")
+}
+
+func (l *Loader) fetch(ctx context.Context) ([]byte, error) {
+	if !l.isURL() {
+		return os.ReadFile(l.Source)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.Source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := l.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching rule file: HTTP status %d", resp.StatusCode)
+	}
+	// read one byte past the limit, so an oversized file is rejected instead of being truncated
+	src, err := io.ReadAll(io.LimitReader(resp.Body, maxRuleFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(src) > maxRuleFileSize {
+		return nil, fmt.Errorf("rule file exceeds maximum size (%d bytes)", maxRuleFileSize)
+	}
+	return src, nil
+}
+
+// Fetches and compiles the rule file, replacing the current rules if the content has changed. Returns true if the rules were updated.
+func (l *Loader) Load(ctx context.Context) (bool, error) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	src, err := l.fetch(ctx)
+	if err != nil {
+		ruleReloads.WithLabelValues("failed").Inc()
+		return false, fmt.Errorf("loading rule file: %w", err)
+	}
+	hash := sha256.Sum256(src)
+	if l.current.Load() != nil && hash == l.lastHash {
+		ruleReloads.WithLabelValues("unchanged").Inc()
+		return false, nil
+	}
+	cr, err := Compile(src)
+	if err != nil {
+		ruleReloads.WithLabelValues("failed").Inc()
+		return false, err
+	}
+	l.current.Store(cr)
+	l.lastHash = hash
+	enabled := 0
+	for _, r := range cr.Rules {
+		if !r.Disabled {
+			enabled++
+		}
+	}
+	rulesLoaded.Set(float64(enabled))
+	ruleReloads.WithLabelValues("updated").Inc()
+	l.Logger.Info("loaded declarative rules", "source", l.Source, "rules", len(cr.Rules), "enabled", enabled)
+	return true, nil
+}
+
+// Currently loaded rules, or nil if nothing has been loaded successfully.
+func (l *Loader) Rules() *CompiledRules {
+	return l.current.Load()
+}
+
+// Periodically re-loads the rule file until the context is cancelled. Load errors are logged, and do not stop the loop.
+func (l *Loader) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if _, err := l.Load(ctx); err != nil {
+				l.Logger.Error("failed to reload declarative rules; keeping previous version", "source", l.Source, "err", err)
+			}
+		}
+	}
+}
+
+// Returns a rule set which dispatches to the currently loaded rules.
+func (l *Loader) RuleSet() engine.RuleSet {
+	rs := engine.RuleSet{}
+	l.Extend(&rs)
+	return rs
+}
+
+// Appends rules which dispatch to the currently loaded rules to an existing rule set (eg, the compiled-in Go rules).
+func (l *Loader) Extend(rs *engine.RuleSet) {
+	rs.RecordRules = append(rs.RecordRules, func(c *engine.RecordContext) error {
+		if cr := l.current.Load(); cr != nil {
+			return cr.RecordRule(c)
+		}
+		return nil
+	})
+	rs.RecordDeleteRules = append(rs.RecordDeleteRules, func(c *engine.RecordContext) error {
+		if cr := l.current.Load(); cr != nil {
+			return cr.RecordDeleteRule(c)
+		}
+		return nil
+	})
+	rs.IdentityRules = append(rs.IdentityRules, func(c *engine.AccountContext) error {
+		if cr := l.current.Load(); cr != nil {
+			return cr.IdentityRule(c)
+		}
+		return nil
+	})
+	rs.AccountRules = append(rs.AccountRules, func(c *engine.AccountContext) error {
+		if cr := l.current.Load(); cr != nil {
+			return cr.AccountRule(c)
+		}
+		return nil
+	})
+}
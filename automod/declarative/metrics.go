@@ -0,0 +1,27 @@
+package declarative
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var ruleEvaluations = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "automod_declarative_rule_evaluations",
+	Help: "Number of declarative rule evaluations, by rule name and result (matched, not_matched, error)",
+}, []string{"rule", "result"})
+
+var ruleDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "automod_declarative_rule_duration_seconds",
+	Help:    "Time to evaluate a declarative rule (condition and actions)",
+	Buckets: prometheus.ExponentialBuckets(0.00001, 4, 10),
+}, []string{"rule"})
+
+var rulesLoaded = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "automod_declarative_rules_loaded",
+	Help: "Number of enabled declarative rules currently loaded",
+})
+
+var ruleReloads = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "automod_declarative_rule_reloads",
+	Help: "Number of declarative rule file loads, by status (updated, unchanged, failed)",
+}, []string{"status"})
@@ -0,0 +1,257 @@
+package declarative
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/automod/engine"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Event types which a declarative rule can run on.
+const (
+	// record creation or update
+	EventRecord = "record"
+	// record deletion
+	EventDelete = "delete"
+	// identity (handle or DID document) change
+	EventIdentity = "identity"
+	// account status change
+	EventAccount = "account"
+)
+
+// Top-level structure of a YAML rule file.
+type RuleFile struct {
+	// If true, every rule in the file runs in shadow mode (see [engine.RulePolicy]).
+	Shadow bool         `yaml:"shadow,omitempty"`
+	Rules  []RuleConfig `yaml:"rules"`
+}
+
+// A single rule, as written in a rule file.
+type RuleConfig struct {
+	// Unique name, used in logs and metrics.
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+	// One of the Event* constants.
+	Event string `yaml:"event"`
+	// Optional, for record and delete rules: only run on records in these collections (NSIDs).
+	Collections []string `yaml:"collections,omitempty"`
+	// Disabled rules are compiled (and validated), but never run.
+	Disabled bool `yaml:"disabled,omitempty"`
+	// Shadow rules run, but their actions are only logged and reported, never persisted.
+	Shadow bool `yaml:"shadow,omitempty"`
+	// Optional: only these effect types ("label", "report", "takedown", etc) are persisted; any other actions are handled as in shadow mode.
+	AllowedEffects []string `yaml:"allowed_effects,omitempty"`
+	// Condition expression, which must evaluate to a bool (or null, treated as false). If empty, actions always run.
+	When string `yaml:"when,omitempty"`
+	// Action expressions, run in order when the condition is true.
+	Actions []string `yaml:"actions"`
+}
+
+// A compiled rule.
+type Rule struct {
+	Name        string
+	Description string
+	Event       string
+	Disabled    bool
+	// Default policy for the rule; a policy for the same rule name in the engine configuration takes precedence.
+	Policy engine.RulePolicy
+
+	collections map[string]bool
+	when        node
+	actions     []node
+}
+
+// A compiled and validated set of rules, ready to run.
+type CompiledRules struct {
+	Rules   []*Rule
+	byEvent map[string][]*Rule
+}
+
+// Parses and compiles a YAML rule file. All expressions are parsed and type-checked, so that errors are reported at load time instead of when rules run; the returned error describes every invalid rule.
+func Compile(src []byte) (*CompiledRules, error) {
+	var rf RuleFile
+	dec := yaml.NewDecoder(bytes.NewReader(src))
+	dec.KnownFields(true)
+	if err := dec.Decode(&rf); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing rule file: %w", err)
+	}
+
+	cr := &CompiledRules{byEvent: make(map[string][]*Rule)}
+	seen := make(map[string]bool)
+	var errs []error
+	for i, cfg := range rf.Rules {
+		if cfg.Name == "" {
+			errs = append(errs, fmt.Errorf("rule %d: name is required", i+1))
+			continue
+		}
+		if seen[cfg.Name] {
+			errs = append(errs, fmt.Errorf("rule %s: duplicate name", cfg.Name))
+			continue
+		}
+		seen[cfg.Name] = true
+		if rf.Shadow {
+			cfg.Shadow = true
+		}
+		rule, err := compileRule(cfg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %s: %w", cfg.Name, err))
+			continue
+		}
+		cr.Rules = append(cr.Rules, rule)
+		if !rule.Disabled {
+			cr.byEvent[rule.Event] = append(cr.byEvent[rule.Event], rule)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return cr, nil
+}
+
+func compileRule(cfg RuleConfig) (*Rule, error) {
+	rule := &Rule{
+		Name:        cfg.Name,
+		Description: cfg.Description,
+		Event:       cfg.Event,
+		Disabled:    cfg.Disabled,
+		Policy: engine.RulePolicy{
+			Shadow:         cfg.Shadow,
+			AllowedEffects: cfg.AllowedEffects,
+		},
+	}
+	if err := rule.Policy.Validate(); err != nil {
+		return nil, err
+	}
+	env := &checkEnv{}
+	switch cfg.Event {
+	case EventRecord, EventDelete:
+		env.record = true
+	case EventIdentity, EventAccount:
+		if len(cfg.Collections) > 0 {
+			return nil, fmt.Errorf("collections can only be used with record and delete rules")
+		}
+	case "":
+		return nil, fmt.Errorf("event is required")
+	default:
+		return nil, fmt.Errorf("unknown event type: %s", cfg.Event)
+	}
+	if len(cfg.Collections) > 0 {
+		rule.collections = make(map[string]bool)
+		for _, c := range cfg.Collections {
+			nsid, err := syntax.ParseNSID(c)
+			if err != nil {
+				return nil, fmt.Errorf("invalid collection: %w", err)
+			}
+			rule.collections[nsid.String()] = true
+		}
+	}
+	if len(cfg.Actions) == 0 {
+		return nil, fmt.Errorf("at least one action is required")
+	}
+
+	if cfg.When != "" {
+		when, err := parseExpr(cfg.When)
+		if err != nil {
+			return nil, fmt.Errorf("condition: %w", err)
+		}
+		t, err := when.check(env)
+		if err != nil {
+			return nil, fmt.Errorf("condition: %w", err)
+		}
+		if !assignable(t, TypeBool) {
+			return nil, fmt.Errorf("condition must be bool, found %s", t)
+		}
+		rule.when = when
+	}
+
+	actionEnv := *env
+	actionEnv.actions = true
+	for i, src := range cfg.Actions {
+		act, err := parseExpr(src)
+		if err != nil {
+			return nil, fmt.Errorf("action %d: %w", i+1, err)
+		}
+		if _, ok := act.(*call); !ok {
+			return nil, fmt.Errorf("action %d: must be a function call", i+1)
+		}
+		if _, err := act.check(&actionEnv); err != nil {
+			return nil, fmt.Errorf("action %d: %w", i+1, err)
+		}
+		rule.actions = append(rule.actions, act)
+	}
+	return rule, nil
+}
+
+// Evaluates the rule condition, and runs actions if it matched.
+func (r *Rule) run(ev *evalEnv) (bool, error) {
+	start := time.Now()
+	matched, err := r.eval(ev)
+	ruleDuration.WithLabelValues(r.Name).Observe(time.Since(start).Seconds())
+	switch {
+	case err != nil:
+		ruleEvaluations.WithLabelValues(r.Name, "error").Inc()
+	case matched:
+		ruleEvaluations.WithLabelValues(r.Name, "matched").Inc()
+	default:
+		ruleEvaluations.WithLabelValues(r.Name, "not_matched").Inc()
+	}
+	return matched, err
+}
+
+func (r *Rule) eval(ev *evalEnv) (bool, error) {
+	if r.when != nil {
+		ok, err := evalBool(r.when, ev)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	for _, act := range r.actions {
+		if _, err := act.eval(ev); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+func (cr *CompiledRules) runAll(event string, ev *evalEnv, collection string) error {
+	var errs []error
+	for _, rule := range cr.byEvent[event] {
+		if rule.collections != nil && !rule.collections[collection] {
+			continue
+		}
+		err := ev.acct.RunRule(rule.Name, rule.Policy, func() error {
+			_, err := rule.run(ev)
+			return err
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("declarative rule %s: %w", rule.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Runs rules for a record creation or update. Has the signature of [engine.RecordRuleFunc].
+func (cr *CompiledRules) RecordRule(c *engine.RecordContext) error {
+	return cr.runAll(EventRecord, newRecordEnv(c), c.RecordOp.Collection.String())
+}
+
+// Runs rules for a record deletion. Has the signature of [engine.RecordRuleFunc].
+func (cr *CompiledRules) RecordDeleteRule(c *engine.RecordContext) error {
+	return cr.runAll(EventDelete, newRecordEnv(c), c.RecordOp.Collection.String())
+}
+
+// Runs rules for an identity event. Has the signature of [engine.IdentityRuleFunc].
+func (cr *CompiledRules) IdentityRule(c *engine.AccountContext) error {
+	return cr.runAll(EventIdentity, newAccountEnv(c), "")
+}
+
+// Runs rules for an account event. Has the signature of [engine.AccountRuleFunc].
+func (cr *CompiledRules) AccountRule(c *engine.AccountContext) error {
+	return cr.runAll(EventAccount, newAccountEnv(c), "")
+}
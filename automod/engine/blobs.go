@@ -41,6 +41,9 @@ func (c *RecordContext) Blobs() ([]lexutil.LexBlob, error) {
 	return out, nil
 }
 
+// default for EngineConfig.MaxBlobSize
+const defaultMaxBlobSize = 32 << 20
+
 func (c *RecordContext) fetchBlob(blob lexutil.LexBlob) ([]byte, error) {
 
 	start := time.Now()
@@ -49,7 +52,13 @@ func (c *RecordContext) fetchBlob(blob lexutil.LexBlob) ([]byte, error) {
 		blobDownloadDuration.Observe(duration.Seconds())
 	}()
 
-	var blobBytes []byte
+	maxSize := c.engine.Config.MaxBlobSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxBlobSize
+	}
+	if blob.Size > maxSize {
+		return nil, fmt.Errorf("blob too large to fetch. did=%s cid=%s size=%d", c.Account.Identity.DID, blob.Ref, blob.Size)
+	}
 
 	// TODO: potential security issue here with malformed or "localhost" PDS endpoint
 	pdsEndpoint := c.Account.Identity.PDSEndpoint()
@@ -85,10 +94,14 @@ func (c *RecordContext) fetchBlob(blob lexutil.LexBlob) ([]byte, error) {
 		return nil, fmt.Errorf("failed to fetch blob from PDS. did=%s cid=%s statusCode=%d", c.Account.Identity.DID, blob.Ref, resp.StatusCode)
 	}
 
-	blobBytes, err = io.ReadAll(resp.Body)
+	// the declared size in the record is not trusted; read one byte past the limit to detect larger bodies
+	blobBytes, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
 	if err != nil {
 		return nil, err
 	}
+	if int64(len(blobBytes)) > maxSize {
+		return nil, fmt.Errorf("blob too large to fetch. did=%s cid=%s", c.Account.Identity.DID, blob.Ref)
+	}
 
 	return blobBytes, nil
 }
@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/bluesky-social/indigo/atproto/data"
+	"github.com/bluesky-social/indigo/atproto/lexicon"
+
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+// A rule which only runs for records in a single collection (by NSID), with the record decoded for the rule. Construct with [TypedRecordRule] (for Go types generated from Lexicons, like those in the api packages) or [UntypedRecordRule] (for any collection, as generic data).
+type CollectionRule struct {
+	Collection string
+	// rule name, for policies and outcome reports. Defaults to the name of the rule function
+	Name string
+	call func(c *RecordContext, rec *parsedRecord) error
+}
+
+// Returns a rule for records in the given collection, decoded as type T (eg, appbsky.GraphList). Rules registered this way are not run for records which fail to decode as T.
+func TypedRecordRule[T any, PT interface {
+	*T
+	cbg.CBORUnmarshaler
+}](collection string, f func(c *RecordContext, rec PT) error) CollectionRule {
+	typ := reflect.TypeFor[T]()
+	return CollectionRule{
+		Collection: collection,
+		Name:       RuleName(f),
+		call: func(c *RecordContext, rec *parsedRecord) error {
+			v, err := rec.typed(typ, func() (any, error) {
+				var out PT = new(T)
+				if err := out.UnmarshalCBOR(bytes.NewReader(rec.cbor)); err != nil {
+					return nil, err
+				}
+				return out, nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to parse %s record as %s: %w", collection, typ, err)
+			}
+			return f(c, v.(PT))
+		},
+	}
+}
+
+// Returns a rule for records in the given collection, decoded as generic data (see the atproto/data package). Useful for collections with no Go types, like third-party Lexicons.
+func UntypedRecordRule(collection string, f func(c *RecordContext, rec map[string]any) error) CollectionRule {
+	return CollectionRule{
+		Collection: collection,
+		Name:       RuleName(f),
+		call: func(c *RecordContext, rec *parsedRecord) error {
+			obj, err := rec.generic()
+			if err != nil {
+				return fmt.Errorf("failed to parse %s record: %w", collection, err)
+			}
+			return f(c, obj)
+		},
+	}
+}
+
+// A single record's CBOR, and lazily decoded versions of it, shared between all collection rules run for the record.
+type parsedRecord struct {
+	cbor []byte
+
+	lk        sync.Mutex
+	obj       map[string]any
+	objErr    error
+	objParsed bool
+	byType    map[reflect.Type]any
+}
+
+func (p *parsedRecord) generic() (map[string]any, error) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	if !p.objParsed {
+		p.obj, p.objErr = data.UnmarshalCBOR(p.cbor)
+		p.objParsed = true
+	}
+	return p.obj, p.objErr
+}
+
+func (p *parsedRecord) typed(typ reflect.Type, decode func() (any, error)) (any, error) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	if v, ok := p.byType[typ]; ok {
+		return v, nil
+	}
+	v, err := decode()
+	if err != nil {
+		return nil, err
+	}
+	if p.byType == nil {
+		p.byType = make(map[reflect.Type]any)
+	}
+	p.byType[typ] = v
+	return v, nil
+}
+
+// Executes any collection rules for the record's collection. Decoding errors are logged per rule, and don't prevent other rules from running.
+func (r *RuleSet) callCollectionRules(c *RecordContext, rec *parsedRecord) {
+	if len(r.CollectionRules) == 0 {
+		return
+	}
+	collection := c.RecordOp.Collection.String()
+	for _, cr := range r.CollectionRules {
+		if cr.Collection != collection {
+			continue
+		}
+		err := c.RunRule(cr.Name, RulePolicy{}, func() error { return cr.call(c, rec) })
+		if err != nil {
+			c.Logger.Error("collection rule execution failed", "rule", cr.Name, "err", err)
+		}
+	}
+}
+
+// Record tag applied to records which fail Lexicon validation.
+const InvalidLexiconTag = "invalid-lexicon"
+
+// If the engine has a Lexicon catalog, validates the record against the schema for its collection, and tags the record if it is invalid. Collections with no schema in the catalog are not validated.
+func (c *RecordContext) validateLexicon(rec *parsedRecord) {
+	cat := c.engine.Lexicons
+	if cat == nil || c.RecordOp.Action == DeleteOp {
+		return
+	}
+	nsid := c.RecordOp.Collection.String()
+	if _, err := cat.Resolve(nsid); err != nil {
+		c.Logger.Debug("no lexicon for collection, skipping validation", "err", err)
+		return
+	}
+	obj, err := rec.generic()
+	if err == nil {
+		err = lexicon.ValidateRecord(cat, obj, nsid, lexicon.LenientMode)
+	}
+	if err != nil {
+		c.Logger.Info("record failed lexicon validation", "err", err)
+		lexiconInvalidCount.WithLabelValues(nsid).Inc()
+		c.AddRecordTag(InvalidLexiconTag)
+	}
+}
@@ -0,0 +1,122 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	appbsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/data"
+	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/lexicon"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func listRule(c *RecordContext, list *appbsky.GraphList) error {
+	if list.Name == "bad list" {
+		c.AddRecordFlag("bad-list")
+	}
+	return nil
+}
+
+func thingRule(c *RecordContext, rec map[string]any) error {
+	if txt, ok := rec["text"].(string); ok && txt == "bad thing" {
+		c.AddRecordFlag("bad-thing")
+	}
+	return nil
+}
+
+var thingLexicon = `{
+  "lexicon": 1,
+  "id": "com.example.thing",
+  "defs": {
+    "main": {
+      "type": "record",
+      "key": "tid",
+      "record": {
+        "type": "object",
+        "required": ["text"],
+        "properties": {
+          "text": {"type": "string", "maxLength": 20}
+        }
+      }
+    }
+  }
+}`
+
+func testRecordOp(collection string, cbor []byte) RecordOp {
+	cid1 := syntax.CID("cid123")
+	return RecordOp{
+		Action:     CreateOp,
+		DID:        syntax.DID("did:plc:abc111"),
+		Collection: syntax.NSID(collection),
+		RecordKey:  syntax.RecordKey("abc123"),
+		CID:        &cid1,
+		RecordCBOR: cbor,
+	}
+}
+
+func TestCollectionRules(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	eng := EngineTestFixture()
+	eng.Rules.CollectionRules = []CollectionRule{
+		TypedRecordRule("app.bsky.graph.list", listRule),
+		UntypedRecordRule("com.example.thing", thingRule),
+	}
+	assert.Equal("listRule", eng.Rules.CollectionRules[0].Name)
+	am1 := AccountMeta{Identity: &identity.Identity{DID: syntax.DID("did:plc:abc111")}}
+
+	purpose := "app.bsky.graph.defs#curatelist"
+	list := appbsky.GraphList{Name: "bad list", Purpose: &purpose, CreatedAt: "2024-01-01T00:00:00Z"}
+	buf := new(bytes.Buffer)
+	assert.NoError(list.MarshalCBOR(buf))
+	c1 := NewRecordContext(ctx, &eng, am1, testRecordOp("app.bsky.graph.list", buf.Bytes()))
+	assert.NoError(eng.Rules.CallRecordRules(&c1))
+	assert.Equal([]string{"bad-list"}, ExtractEffects(&c1.BaseContext).RecordFlags)
+
+	thing, err := data.MarshalCBOR(map[string]any{"$type": "com.example.thing", "text": "bad thing"})
+	assert.NoError(err)
+	c2 := NewRecordContext(ctx, &eng, am1, testRecordOp("com.example.thing", thing))
+	assert.NoError(eng.Rules.CallRecordRules(&c2))
+	assert.Equal([]string{"bad-thing"}, ExtractEffects(&c2.BaseContext).RecordFlags)
+
+	// rules only run for their own collection
+	c3 := NewRecordContext(ctx, &eng, am1, testRecordOp("com.example.other", thing))
+	assert.NoError(eng.Rules.CallRecordRules(&c3))
+	assert.Empty(ExtractEffects(&c3.BaseContext).RecordFlags)
+}
+
+func TestLexiconValidation(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	var sf lexicon.SchemaFile
+	assert.NoError(json.Unmarshal([]byte(thingLexicon), &sf))
+	cat := lexicon.NewBaseCatalog()
+	assert.NoError(cat.AddSchemaFile(sf))
+	eng := EngineTestFixture()
+	eng.Lexicons = &cat
+	am1 := AccountMeta{Identity: &identity.Identity{DID: syntax.DID("did:plc:abc111")}}
+
+	valid, err := data.MarshalCBOR(map[string]any{"$type": "com.example.thing", "text": "hello"})
+	assert.NoError(err)
+	c1 := NewRecordContext(ctx, &eng, am1, testRecordOp("com.example.thing", valid))
+	assert.NoError(eng.Rules.CallRecordRules(&c1))
+	assert.Empty(ExtractEffects(&c1.BaseContext).RecordTags)
+
+	invalid, err := data.MarshalCBOR(map[string]any{"$type": "com.example.thing", "text": "this text is much too long for the schema"})
+	assert.NoError(err)
+	c2 := NewRecordContext(ctx, &eng, am1, testRecordOp("com.example.thing", invalid))
+	assert.NoError(eng.Rules.CallRecordRules(&c2))
+	assert.Equal([]string{InvalidLexiconTag}, ExtractEffects(&c2.BaseContext).RecordTags)
+
+	// collections with no schema in the catalog are not validated
+	c3 := NewRecordContext(ctx, &eng, am1, testRecordOp("com.example.other", invalid))
+	assert.NoError(eng.Rules.CallRecordRules(&c3))
+	assert.Empty(ExtractEffects(&c3.BaseContext).RecordTags)
+}
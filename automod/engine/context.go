@@ -4,10 +4,13 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"time"
 
 	toolsozone "github.com/bluesky-social/indigo/api/ozone"
 	"github.com/bluesky-social/indigo/atproto/identity"
 	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/automod/hashstore"
+	"github.com/bluesky-social/indigo/automod/textsim"
 )
 
 // The primary interface exposed to rules. All other contexts derive from this "base" struct.
@@ -21,6 +24,9 @@ type BaseContext struct {
 
 	engine  *Engine // NOTE: pointer, but expected never to be nil
 	effects *Effects
+	// state for RunRule; frame is nil outside of rule execution, and outcomes is nil unless the engine records outcomes
+	frame    *ruleFrame
+	outcomes *outcomeTracker
 }
 
 // Both a useful context on it's own (eg, for identity events), and extended by other context types.
@@ -158,6 +164,25 @@ func (c *BaseContext) GetCountDistinct(name, bucket, period string) int {
 	return out
 }
 
+// Looks up hashes within maxDistance of the given hash in the engine's hash index, added within the window (zero means any time). Returns nil if the engine has no hash index.
+func (c *BaseContext) SimilarHashes(namespace string, hash uint64, maxDistance int, window time.Duration) []hashstore.Match {
+	if c.engine.Hashes == nil {
+		return nil
+	}
+	var since time.Time
+	if window > 0 {
+		since = time.Now().Add(-window)
+	}
+	out, err := c.engine.Hashes.Lookup(c.Ctx, namespace, hash, maxDistance, since)
+	if err != nil {
+		if nil == c.Err {
+			c.Err = err
+		}
+		return nil
+	}
+	return out
+}
+
 func (c *BaseContext) InSet(name, val string) bool {
 	out, err := c.engine.Sets.InSet(c.Ctx, name, val)
 	if err != nil {
@@ -179,11 +204,12 @@ func (c *BaseContext) InternalEngine() *Engine {
 func NewAccountContext(ctx context.Context, eng *Engine, meta AccountMeta) AccountContext {
 	return AccountContext{
 		BaseContext: BaseContext{
-			Ctx:     ctx,
-			Err:     nil,
-			Logger:  eng.Logger.With("did", meta.Identity.DID),
-			engine:  eng,
-			effects: &Effects{},
+			Ctx:      ctx,
+			Err:      nil,
+			Logger:   eng.Logger.With("did", meta.Identity.DID),
+			engine:   eng,
+			effects:  &Effects{},
+			outcomes: eng.newOutcomeTracker(),
 		},
 		Account: meta,
 	}
@@ -213,6 +239,9 @@ func NewNotificationContext(ctx context.Context, eng *Engine, sender, recipient
 
 // fetch relationship metadata between this account and another account
 func (c *AccountContext) GetAccountRelationship(other syntax.DID) AccountRelationship {
+	if c.engine.Graph != nil && c.engine.Config.GraphRelationships {
+		return c.graphRelationship(c.Account.Identity.DID, other)
+	}
 	rel, err := c.engine.GetAccountRelationship(c.Ctx, c.Account.Identity.DID, other)
 	if err != nil {
 		if nil == c.Err {
@@ -223,6 +252,34 @@ func (c *AccountContext) GetAccountRelationship(other syntax.DID) AccountRelatio
 	return *rel
 }
 
+// Looks up recent texts similar to the given text in the engine's text index (see textsim.MinHash), with estimated similarity at or above minSimilarity, added within the window (zero means any time). Returns nil if the engine has no text index, or the text has no tokens.
+func (c *AccountContext) SimilarTexts(namespace, text string, minSimilarity float64, window time.Duration) []textsim.Match {
+	if c.engine.Texts == nil {
+		return nil
+	}
+	sig := textsim.MinHash(text)
+	if sig == nil {
+		return nil
+	}
+	var since time.Time
+	if window > 0 {
+		since = time.Now().Add(-window)
+	}
+	out, err := c.engine.Texts.Lookup(c.Ctx, namespace, sig, minSimilarity, since)
+	if err != nil {
+		if nil == c.Err {
+			c.Err = err
+		}
+		return nil
+	}
+	return out
+}
+
+// Counts distinct accounts, other than this one, which posted text similar to the given text within the window (see SimilarTexts).
+func (c *AccountContext) SimilarTextAccountCount(namespace, text string, minSimilarity float64, window time.Duration) int {
+	return textsim.DistinctAccounts(c.SimilarTexts(namespace, text, minSimilarity, window), c.Account.Identity.DID.String())
+}
+
 // fetch account metadata for the given DID. if there is any problem with lookup, returns nil.
 //
 // TODO: should this take an AtIdentifier instead?
@@ -259,6 +316,24 @@ func (c *BaseContext) IncrementPeriod(name, val string, period string) {
 	c.effects.IncrementPeriod(name, val, period)
 }
 
+func (c *BaseContext) IncrementDistinctPeriod(name, bucket, val string, period string) {
+	c.effects.IncrementDistinctPeriod(name, bucket, val, period)
+}
+
+func (c *BaseContext) AddHash(namespace string, hash uint64, id string) {
+	c.effects.AddHash(namespace, hash, id)
+}
+
+// Adds a text, posted by this account, to the engine's text index (if there is one), with an ID (like a record AT-URI). Texts with no tokens are skipped.
+func (c *AccountContext) AddText(namespace, id, text string) {
+	if c.engine.Texts == nil {
+		return
+	}
+	if sig := textsim.MinHash(text); sig != nil {
+		c.effects.AddTextSignature(namespace, id, c.Account.Identity.DID.String(), sig)
+	}
+}
+
 func (c *BaseContext) Notify(srv string) {
 	c.effects.Notify(srv)
 }
@@ -1,7 +1,10 @@
 package engine
 
 import (
+	"slices"
 	"sync"
+
+	"github.com/bluesky-social/indigo/automod/textsim"
 )
 
 type CounterRef struct {
@@ -14,6 +17,20 @@ type CounterDistinctRef struct {
 	Name   string
 	Bucket string
 	Val    string
+	Period *string
+}
+
+type HashRef struct {
+	Namespace string
+	Hash      uint64
+	ID        string
+}
+
+type TextRef struct {
+	Namespace string
+	ID        string
+	Account   string
+	Signature textsim.Signature
 }
 
 // Mutable container for all the possible side-effects from rule execution.
@@ -26,6 +43,10 @@ type Effects struct {
 	CounterIncrements []CounterRef
 	// Similar to "CounterIncrements", but for "distinct" style counters
 	CounterDistinctIncrements []CounterDistinctRef // TODO: better variable names
+	// Similarity hashes (eg, of images) to add to the engine's hash index
+	HashAdds []HashRef
+	// Text signatures to add to the engine's text index
+	TextAdds []TextRef
 	// Label values which should be applied to the overall account, as a result of rule execution.
 	AccountLabels []string
 	// Moderation tags (similar to labels, but private) which should be applied to the overall account, as a result of rule execution.
@@ -60,6 +81,8 @@ type Effects struct {
 	RejectEvent bool
 	// Services, if any, which should blast out a notification about this even (eg, Slack)
 	NotifyServices []string
+	// Names of rules which resulted in (live) moderation actions. Only tracked if the engine has action sinks or records rule outcomes
+	Rules []string
 }
 
 // Enqueues the named counter to be incremented at the end of all rule processing. Will automatically increment for all time periods.
@@ -86,6 +109,27 @@ func (e *Effects) IncrementDistinct(name, bucket, val string) {
 	e.CounterDistinctIncrements = append(e.CounterDistinctIncrements, CounterDistinctRef{Name: name, Bucket: bucket, Val: val})
 }
 
+// Enqueues the named "distinct value" counter to be incremented at the end of all rule processing. Will only increment the indicated time period bucket (eg, a sliding window).
+func (e *Effects) IncrementDistinctPeriod(name, bucket, val string, period string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.CounterDistinctIncrements = append(e.CounterDistinctIncrements, CounterDistinctRef{Name: name, Bucket: bucket, Val: val, Period: &period})
+}
+
+// Enqueues a similarity hash to be added to the engine's hash index at the end of all rule processing.
+func (e *Effects) AddHash(namespace string, hash uint64, id string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.HashAdds = append(e.HashAdds, HashRef{Namespace: namespace, Hash: hash, ID: id})
+}
+
+// Enqueues a text signature (posted by the given account) to be added to the engine's text index at the end of all rule processing.
+func (e *Effects) AddTextSignature(namespace, id, account string, sig textsim.Signature) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.TextAdds = append(e.TextAdds, TextRef{Namespace: namespace, ID: id, Account: account, Signature: sig})
+}
+
 // Enqueues the provided label (string value) to be added to the account at the end of rule processing.
 func (e *Effects) AddAccountLabel(val string) {
 	e.mu.Lock()
@@ -245,3 +289,167 @@ func (e *Effects) Notify(srv string) {
 func (e *Effects) Reject() {
 	e.RejectEvent = true
 }
+
+// Returns the number of moderation actions of each effect type (Effect* constants). Counter increments are not included.
+func (e *Effects) actionCounts() map[string]int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	counts := make(map[string]int64)
+	add := func(effect string, n int) {
+		if n > 0 {
+			counts[effect] += int64(n)
+		}
+	}
+	add(EffectLabel, len(e.AccountLabels)+len(e.RecordLabels))
+	add(EffectTag, len(e.AccountTags)+len(e.RecordTags))
+	add(EffectFlag, len(e.AccountFlags)+len(e.RecordFlags))
+	add(EffectReport, len(e.AccountReports)+len(e.RecordReports))
+	add(EffectTakedown, boolCount(e.AccountTakedown, e.RecordTakedown))
+	add(EffectEscalate, boolCount(e.AccountEscalate, e.RecordEscalate))
+	add(EffectAcknowledge, boolCount(e.AccountAcknowledge, e.RecordAcknowledge))
+	add(EffectNotify, len(e.NotifyServices))
+	add(EffectReject, boolCount(e.RejectEvent))
+	return counts
+}
+
+func boolCount(vals ...bool) int {
+	n := 0
+	for _, v := range vals {
+		if v {
+			n++
+		}
+	}
+	return n
+}
+
+// Splits effects in to those whose effect type is allowed, and the rest. Counter increments and hash and text index additions are always allowed.
+func (e *Effects) split(allow func(effect string) bool) (allowed, rest *Effects) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	allowed, rest = &Effects{}, &Effects{}
+	pick := func(effect string) *Effects {
+		if allow(effect) {
+			return allowed
+		}
+		return rest
+	}
+	allowed.CounterIncrements = e.CounterIncrements
+	allowed.CounterDistinctIncrements = e.CounterDistinctIncrements
+	allowed.HashAdds = e.HashAdds
+	allowed.TextAdds = e.TextAdds
+	allowed.Rules = e.Rules
+
+	dst := pick(EffectLabel)
+	dst.AccountLabels, dst.RecordLabels = e.AccountLabels, e.RecordLabels
+	dst = pick(EffectTag)
+	dst.AccountTags, dst.RecordTags = e.AccountTags, e.RecordTags
+	dst = pick(EffectFlag)
+	dst.AccountFlags, dst.RecordFlags = e.AccountFlags, e.RecordFlags
+	dst = pick(EffectReport)
+	dst.AccountReports, dst.RecordReports = e.AccountReports, e.RecordReports
+	// blob takedowns only happen as part of a record takedown
+	dst = pick(EffectTakedown)
+	dst.AccountTakedown, dst.RecordTakedown, dst.BlobTakedowns = e.AccountTakedown, e.RecordTakedown, e.BlobTakedowns
+	dst = pick(EffectEscalate)
+	dst.AccountEscalate, dst.RecordEscalate = e.AccountEscalate, e.RecordEscalate
+	dst = pick(EffectAcknowledge)
+	dst.AccountAcknowledge, dst.RecordAcknowledge = e.AccountAcknowledge, e.RecordAcknowledge
+	pick(EffectNotify).NotifyServices = e.NotifyServices
+	pick(EffectReject).RejectEvent = e.RejectEvent
+	return allowed, rest
+}
+
+// Merges effects from another container (eg, from a single rule) in to this one, with the same de-duplication as the individual enqueue methods.
+func (e *Effects) merge(other *Effects) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.CounterIncrements = append(e.CounterIncrements, other.CounterIncrements...)
+	e.CounterDistinctIncrements = append(e.CounterDistinctIncrements, other.CounterDistinctIncrements...)
+	e.HashAdds = append(e.HashAdds, other.HashAdds...)
+	e.TextAdds = append(e.TextAdds, other.TextAdds...)
+	e.AccountLabels = appendUnique(e.AccountLabels, other.AccountLabels)
+	e.AccountTags = appendUnique(e.AccountTags, other.AccountTags)
+	e.AccountFlags = appendUnique(e.AccountFlags, other.AccountFlags)
+	e.AccountReports = appendReports(e.AccountReports, other.AccountReports)
+	e.AccountTakedown = e.AccountTakedown || other.AccountTakedown
+	e.AccountEscalate = e.AccountEscalate || other.AccountEscalate
+	e.AccountAcknowledge = e.AccountAcknowledge || other.AccountAcknowledge
+	e.RecordLabels = appendUnique(e.RecordLabels, other.RecordLabels)
+	e.RecordTags = appendUnique(e.RecordTags, other.RecordTags)
+	e.RecordFlags = appendUnique(e.RecordFlags, other.RecordFlags)
+	e.RecordReports = appendReports(e.RecordReports, other.RecordReports)
+	e.RecordTakedown = e.RecordTakedown || other.RecordTakedown
+	e.RecordEscalate = e.RecordEscalate || other.RecordEscalate
+	e.RecordAcknowledge = e.RecordAcknowledge || other.RecordAcknowledge
+	e.BlobTakedowns = appendUnique(e.BlobTakedowns, other.BlobTakedowns)
+	e.NotifyServices = appendUnique(e.NotifyServices, other.NotifyServices)
+	e.RejectEvent = e.RejectEvent || other.RejectEvent
+	e.Rules = appendUnique(e.Rules, other.Rules)
+}
+
+// Records the name of a rule which resulted in moderation actions.
+func (e *Effects) addRule(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.Rules = appendUnique(e.Rules, []string{name})
+}
+
+func appendUnique(dst, vals []string) []string {
+	for _, v := range vals {
+		if !slices.Contains(dst, v) {
+			dst = append(dst, v)
+		}
+	}
+	return dst
+}
+
+func appendReports(dst, reports []ModReport) []ModReport {
+	for _, r := range reports {
+		if !slices.ContainsFunc(dst, func(d ModReport) bool { return d.ReasonType == r.ReasonType }) {
+			dst = append(dst, r)
+		}
+	}
+	return dst
+}
+
+// Structured logging key/value pairs for all non-empty moderation actions.
+func (e *Effects) logAttrs() []any {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var attrs []any
+	addList := func(key string, vals []string) {
+		if len(vals) > 0 {
+			attrs = append(attrs, key, vals)
+		}
+	}
+	addBool := func(key string, val bool) {
+		if val {
+			attrs = append(attrs, key, val)
+		}
+	}
+	reasons := func(reports []ModReport) []string {
+		var out []string
+		for _, r := range reports {
+			out = append(out, r.ReasonType)
+		}
+		return out
+	}
+	addList("accountLabels", e.AccountLabels)
+	addList("accountTags", e.AccountTags)
+	addList("accountFlags", e.AccountFlags)
+	addList("accountReports", reasons(e.AccountReports))
+	addBool("accountTakedown", e.AccountTakedown)
+	addBool("accountEscalate", e.AccountEscalate)
+	addBool("accountAcknowledge", e.AccountAcknowledge)
+	addList("recordLabels", e.RecordLabels)
+	addList("recordTags", e.RecordTags)
+	addList("recordFlags", e.RecordFlags)
+	addList("recordReports", reasons(e.RecordReports))
+	addBool("recordTakedown", e.RecordTakedown)
+	addBool("recordEscalate", e.RecordEscalate)
+	addBool("recordAcknowledge", e.RecordAcknowledge)
+	addList("blobTakedowns", e.BlobTakedowns)
+	addList("notify", e.NotifyServices)
+	addBool("reject", e.RejectEvent)
+	return attrs
+}
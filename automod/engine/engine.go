@@ -9,11 +9,15 @@ import (
 
 	comatproto "github.com/bluesky-social/indigo/api/atproto"
 	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/lexicon"
 	"github.com/bluesky-social/indigo/atproto/syntax"
 	"github.com/bluesky-social/indigo/automod/cachestore"
 	"github.com/bluesky-social/indigo/automod/countstore"
 	"github.com/bluesky-social/indigo/automod/flagstore"
+	"github.com/bluesky-social/indigo/automod/graphstore"
+	"github.com/bluesky-social/indigo/automod/hashstore"
 	"github.com/bluesky-social/indigo/automod/setstore"
+	"github.com/bluesky-social/indigo/automod/textsim"
 	"github.com/bluesky-social/indigo/xrpc"
 )
 
@@ -28,8 +32,22 @@ type Engine struct {
 	Sets      setstore.SetStore
 	Cache     cachestore.CacheStore
 	Flags     flagstore.FlagStore
+	// index of similarity hashes (eg, perceptual image hashes); optional
+	Hashes hashstore.HashStore
+	// index of recent text signatures, for near-duplicate text detection; optional
+	Texts textsim.TextStore
+	// local cache of the follow and block graph, built from firehose records; optional
+	Graph graphstore.GraphStore
+	// Lexicon schemas; if set, records in collections with a known schema are validated, and tagged if invalid. optional
+	Lexicons lexicon.Catalog
 	// unlike the other sub-modules, this field (Notifier) may be nil
 	Notifier Notifier
+	// additional destinations for moderation actions (eg, webhooks or an audit log); optional
+	Sinks []ActionSink
+	// used to emit labels directly, instead of via the mod service; optional. if set, labels are not sent to OzoneClient
+	Labeler LabelEmitter
+	// aggregates per-rule live and shadow outcomes, for reporting; optional
+	Outcomes *OutcomeRecorder
 	// use to fetch public account metadata from AppView; no auth
 	BskyClient *xrpc.Client
 	// used to persist moderation actions in ozone moderation service; optional, admin auth
@@ -41,6 +59,9 @@ type Engine struct {
 
 	// internal configuration
 	Config EngineConfig
+
+	// background delivery to Sinks; see StartSinks
+	sinkQueue *sinkQueue
 }
 
 type EngineConfig struct {
@@ -54,6 +75,22 @@ type EngineConfig struct {
 	QuotaModTakedownDay int
 	// number of misc actions automod can do per day, for all subjects combined (circuit breaker)
 	QuotaModActionDay int
+	// if enabled, all rules run in shadow mode: moderation actions are logged and reported, but never persisted. counters are still persisted
+	ShadowMode bool
+	// per-rule effect policies (shadow mode, allowed effect types), keyed by rule name. see RuleName for the names of Go rule functions
+	RulePolicies map[string]RulePolicy
+	// if enabled (and the engine has a graph cache), account relationships are answered from the local graph instead of AppView requests. the graph only includes records seen since it was started, so some relationships will be missed
+	GraphRelationships bool
+	// number of delivery attempts for each action sink, per event (default 3)
+	SinkAttempts int
+	// number of failed deliveries to a single action sink per hour, after which the sink is skipped for the rest of the hour (circuit breaker)
+	QuotaSinkFailureHour int
+	// timeout for each delivery attempt to an action sink (default 10s)
+	SinkTimeout time.Duration
+	// number of action events queued for each action sink, when delivering in the background (default 1000). events are dropped when a sink's queue is full
+	SinkQueueSize int
+	// maximum size of a blob fetched for blob rules, in bytes (default 32 MB). larger blobs fail to fetch
+	MaxBlobSize int64
 
 	// timeout for record event processing (total, including all setup, rules, and teardown)
 	RecordEventTimeout time.Duration
@@ -126,6 +163,7 @@ func (eng *Engine) ProcessIdentityEvent(ctx context.Context, evt comatproto.Sync
 		return fmt.Errorf("rule execution failed: %w", err)
 	}
 	eng.CanonicalLogLineAccount(&ac)
+	eng.recordRuleOutcomes(&ac.BaseContext)
 	if err := eng.persistAccountModActions(&ac); err != nil {
 		eventErrorCount.WithLabelValues("identity").Inc()
 		return fmt.Errorf("failed to persist actions for identity event: %w", err)
@@ -200,6 +238,7 @@ func (eng *Engine) ProcessAccountEvent(ctx context.Context, evt comatproto.SyncS
 		return fmt.Errorf("rule execution failed: %w", err)
 	}
 	eng.CanonicalLogLineAccount(&ac)
+	eng.recordRuleOutcomes(&ac.BaseContext)
 	if err := eng.persistAccountModActions(&ac); err != nil {
 		eventErrorCount.WithLabelValues("account").Inc()
 		return fmt.Errorf("failed to persist actions for account event: %w", err)
@@ -279,6 +318,7 @@ func (eng *Engine) ProcessRecordOp(ctx context.Context, op RecordOp) error {
 		return fmt.Errorf("unexpected op action: %s", op.Action)
 	}
 	eng.CanonicalLogLineRecord(&rc)
+	eng.recordRuleOutcomes(&rc.BaseContext)
 	// purge the account meta cache when profile is updated
 	if rc.RecordOp.Collection == "app.bsky.actor.profile" {
 		if err := eng.PurgeAccountCaches(ctx, op.DID); err != nil {
@@ -293,6 +333,10 @@ func (eng *Engine) ProcessRecordOp(ctx context.Context, op RecordOp) error {
 		eventErrorCount.WithLabelValues("record").Inc()
 		return fmt.Errorf("failed to persist counts for record event: %w", err)
 	}
+	if err := eng.updateGraph(&rc); err != nil {
+		eventErrorCount.WithLabelValues("record").Inc()
+		return fmt.Errorf("failed to update graph for record event: %w", err)
+	}
 	return nil
 }
 
@@ -132,11 +132,12 @@ func NewOzoneEventContext(ctx context.Context, eng *Engine, eventView *toolsozon
 	return &OzoneEventContext{
 		AccountContext: AccountContext{
 			BaseContext: BaseContext{
-				Ctx:     ctx,
-				Err:     nil,
-				Logger:  eng.Logger.With("eventID", evt.EventID, "ozoneEventType", evt.EventType, "creatorDID", evt.CreatedBy, "subjectDID", evt.SubjectDID),
-				engine:  eng,
-				effects: &Effects{},
+				Ctx:      ctx,
+				Err:      nil,
+				Logger:   eng.Logger.With("eventID", evt.EventID, "ozoneEventType", evt.EventType, "creatorDID", evt.CreatedBy, "subjectDID", evt.SubjectDID),
+				engine:   eng,
+				effects:  &Effects{},
+				outcomes: eng.newOutcomeTracker(),
 			},
 			Account: *accountMeta,
 		},
@@ -196,6 +197,7 @@ func (eng *Engine) ProcessOzoneEvent(ctx context.Context, eventView *toolsozone.
 			eng.Logger.Error("failed to purge identity cache", "err", err, "did", ec.Event.SubjectDID)
 		}
 	}
+	eng.recordRuleOutcomes(&ec.BaseContext)
 	if err := eng.persistAccountModActions(&ec.AccountContext); err != nil {
 		eventErrorCount.WithLabelValues("ozoneEvent").Inc()
 		return fmt.Errorf("failed to persist actions for ozone event: %w", err)
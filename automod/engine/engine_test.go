@@ -3,6 +3,7 @@ package engine
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"testing"
 
 	appbsky "github.com/bluesky-social/indigo/api/bsky"
@@ -49,3 +50,48 @@ func TestEngineBasics(t *testing.T) {
 	op.RecordCBOR = p2cbor
 	assert.NoError(eng.ProcessRecordOp(ctx, op))
 }
+
+type testLabelEmitter struct {
+	emitted map[string][]string
+	err     error
+}
+
+func (e *testLabelEmitter) CreateLabels(ctx context.Context, uri string, cid *string, vals []string) (int64, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	e.emitted[uri] = append(e.emitted[uri], vals...)
+	return int64(len(e.emitted)), nil
+}
+
+func TestEngineLabeler(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	eng := EngineTestFixture()
+	lbl := &testLabelEmitter{emitted: map[string][]string{}}
+	eng.Labeler = lbl
+
+	cid1 := syntax.CID("cid123")
+	p1 := appbsky.FeedPost{
+		Text: "some post blah",
+		Tags: []string{"one", "slur"},
+	}
+	p1buf := new(bytes.Buffer)
+	assert.NoError(p1.MarshalCBOR(p1buf))
+	op := RecordOp{
+		Action:     CreateOp,
+		DID:        syntax.DID("did:plc:abc111"),
+		Collection: syntax.NSID("app.bsky.feed.post"),
+		RecordKey:  syntax.RecordKey("abc123"),
+		CID:        &cid1,
+		RecordCBOR: p1buf.Bytes(),
+	}
+	assert.NoError(eng.ProcessRecordOp(ctx, op))
+	assert.Equal([]string{"bad-hashtag"}, lbl.emitted["at://did:plc:abc111/app.bsky.feed.post/abc123"])
+
+	// failures to emit are not swallowed
+	lbl.err = fmt.Errorf("labeler unavailable")
+	op.RecordKey = syntax.RecordKey("abc456")
+	assert.ErrorIs(eng.ProcessRecordOp(ctx, op), lbl.err)
+}
@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	appbsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/automod/graphstore"
+)
+
+// Updates the engine's graph cache (if any) from a follow or block record operation.
+func (eng *Engine) updateGraph(c *RecordContext) error {
+	if eng.Graph == nil {
+		return nil
+	}
+	op := c.RecordOp
+	kind := graphstore.KindForCollection(op.Collection.String())
+	if kind == "" {
+		return nil
+	}
+	if op.Action == DeleteOp {
+		return eng.Graph.RemoveEdge(c.Ctx, kind, op.DID, op.RecordKey.String())
+	}
+
+	var subject string
+	switch kind {
+	case graphstore.KindFollow:
+		var follow appbsky.GraphFollow
+		if err := follow.UnmarshalCBOR(bytes.NewReader(op.RecordCBOR)); err != nil {
+			return fmt.Errorf("failed to parse app.bsky.graph.follow record: %v", err)
+		}
+		subject = follow.Subject
+	case graphstore.KindBlock:
+		var block appbsky.GraphBlock
+		if err := block.UnmarshalCBOR(bytes.NewReader(op.RecordCBOR)); err != nil {
+			return fmt.Errorf("failed to parse app.bsky.graph.block record: %v", err)
+		}
+		subject = block.Subject
+	}
+	did, err := syntax.ParseDID(subject)
+	if err != nil {
+		c.Logger.Warn("invalid graph record subject", "subject", subject)
+		return nil
+	}
+	edge := graphstore.Edge{
+		Kind:      kind,
+		Actor:     op.DID,
+		Subject:   did,
+		RecordKey: op.RecordKey.String(),
+	}
+	if c.Account.CreatedAt != nil {
+		edge.ActorCreatedAt = *c.Account.CreatedAt
+	} else if c.Account.Private != nil && c.Account.Private.IndexedAt != nil {
+		edge.ActorCreatedAt = *c.Account.Private.IndexedAt
+	}
+	return eng.Graph.AddEdge(c.Ctx, edge)
+}
+
+// answers an account relationship from the graph cache
+func (c *BaseContext) graphRelationship(primary, other syntax.DID) AccountRelationship {
+	return AccountRelationship{
+		DID:        other,
+		FollowedBy: c.GraphFollows(other, primary),
+		Following:  c.GraphFollows(primary, other),
+	}
+}
+
+// records an error from the graph cache in the context
+func (c *BaseContext) graphErr(err error) {
+	if err != nil && nil == c.Err {
+		c.Err = err
+	}
+}
+
+// Checks if actor follows subject, in the engine's local graph cache (no network requests). Like all the Graph methods, returns a zero value if the engine has no graph cache.
+func (c *BaseContext) GraphFollows(actor, subject syntax.DID) bool {
+	if c.engine.Graph == nil {
+		return false
+	}
+	ok, err := c.engine.Graph.HasEdge(c.Ctx, graphstore.KindFollow, actor, subject)
+	c.graphErr(err)
+	return ok
+}
+
+// Checks if two accounts follow each other, in the local graph cache.
+func (c *BaseContext) GraphMutuals(a, b syntax.DID) bool {
+	return c.GraphFollows(a, b) && c.GraphFollows(b, a)
+}
+
+// Checks if actor blocks subject, in the local graph cache.
+func (c *BaseContext) GraphBlocks(actor, subject syntax.DID) bool {
+	if c.engine.Graph == nil {
+		return false
+	}
+	ok, err := c.engine.Graph.HasEdge(c.Ctx, graphstore.KindBlock, actor, subject)
+	c.graphErr(err)
+	return ok
+}
+
+// Number of followers of the account, in the local graph cache.
+func (c *BaseContext) GraphFollowerCount(did syntax.DID) int {
+	if c.engine.Graph == nil {
+		return 0
+	}
+	n, err := c.engine.Graph.CountIncoming(c.Ctx, graphstore.KindFollow, did)
+	c.graphErr(err)
+	return n
+}
+
+// Number of accounts the account follows, in the local graph cache.
+func (c *BaseContext) GraphFollowingCount(did syntax.DID) int {
+	if c.engine.Graph == nil {
+		return 0
+	}
+	n, err := c.engine.Graph.CountOutgoing(c.Ctx, graphstore.KindFollow, did)
+	c.graphErr(err)
+	return n
+}
+
+// Number of followers of the account whose own accounts are less than maxAge old (eg, a day), in the local graph cache. Followers with unknown account age are not counted.
+func (c *BaseContext) GraphNewAccountFollowerCount(did syntax.DID, maxAge time.Duration) int {
+	if c.engine.Graph == nil {
+		return 0
+	}
+	n, err := c.engine.Graph.CountIncomingFromNewAccounts(c.Ctx, graphstore.KindFollow, did, time.Now().Add(-maxAge))
+	c.graphErr(err)
+	return n
+}
@@ -0,0 +1,64 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	appbsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/automod/graphstore"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraphUpdates(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	eng := EngineTestFixture()
+	eng.Graph = graphstore.NewMemGraphStore()
+	eng.Config.GraphRelationships = true
+	did1 := syntax.DID("did:plc:abc111")
+	did2 := syntax.DID("did:plc:abc222")
+
+	follow := appbsky.GraphFollow{Subject: did2.String(), CreatedAt: syntax.DatetimeNow().String()}
+	buf := new(bytes.Buffer)
+	assert.NoError(follow.MarshalCBOR(buf))
+	cid1 := syntax.CID("cid123")
+	op := RecordOp{
+		Action:     CreateOp,
+		DID:        did1,
+		Collection: syntax.NSID("app.bsky.graph.follow"),
+		RecordKey:  syntax.RecordKey("follow1"),
+		CID:        &cid1,
+		RecordCBOR: buf.Bytes(),
+	}
+	assert.NoError(eng.ProcessRecordOp(ctx, op))
+
+	am1 := AccountMeta{Identity: &identity.Identity{DID: did1}}
+	c := NewAccountContext(ctx, &eng, am1)
+	assert.True(c.GraphFollows(did1, did2))
+	assert.False(c.GraphFollows(did2, did1))
+	assert.False(c.GraphMutuals(did1, did2))
+	assert.Equal(1, c.GraphFollowerCount(did2))
+	assert.Equal(1, c.GraphFollowingCount(did1))
+	// account creation time isn't known in the test fixture
+	assert.Equal(0, c.GraphNewAccountFollowerCount(did2, 24*time.Hour))
+	rel := c.GetAccountRelationship(did2)
+	assert.True(rel.Following)
+	assert.False(rel.FollowedBy)
+	assert.NoError(c.Err)
+
+	op = RecordOp{
+		Action:     DeleteOp,
+		DID:        did1,
+		Collection: syntax.NSID("app.bsky.graph.follow"),
+		RecordKey:  syntax.RecordKey("follow1"),
+	}
+	assert.NoError(eng.ProcessRecordOp(ctx, op))
+	assert.False(c.GraphFollows(did1, did2))
+	assert.Equal(0, c.GraphFollowerCount(did2))
+}
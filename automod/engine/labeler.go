@@ -0,0 +1,11 @@
+package engine
+
+import (
+	"context"
+)
+
+// Interface for a type that can issue labels directly (eg, a local labeling service), instead of via the Ozone mod service. Satisfied by [github.com/bluesky-social/indigo/labeler.Labeler].
+type LabelEmitter interface {
+	// Subject URI is an AT-URI for records, or a DID for accounts. CID is optional. Returns the sequence number of the emitted event (zero if nothing changed).
+	CreateLabels(ctx context.Context, uri string, cid *string, vals []string) (int64, error)
+}
@@ -74,3 +74,18 @@ var blobDownloadDuration = promauto.NewHistogram(prometheus.HistogramOpts{
 	Name: "automod_blob_download_duration_sec",
 	Help: "Duration of blob download attempts",
 })
+
+var ruleEffectCount = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "automod_rule_effects",
+	Help: "Number of moderation actions from rules, by rule name, effect type, and mode (live or shadow). Only tracked when shadow mode, rule policies, outcome recording, or action sinks are in use",
+}, []string{"rule", "effect", "mode"})
+
+var lexiconInvalidCount = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "automod_lexicon_invalid_records",
+	Help: "Number of records which failed Lexicon validation",
+}, []string{"collection"})
+
+var actionSinkDeliveryCount = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "automod_action_sink_deliveries",
+	Help: "Number of action events delivered to action sinks, by sink and status (ok, failed, circuit-break, dropped)",
+}, []string{"sink", "status"})
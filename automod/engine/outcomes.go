@@ -0,0 +1,202 @@
+package engine
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Outcome of a single rule execution: counts of live (persisted) and shadow (not persisted) effects, by effect type.
+type ruleOutcome struct {
+	rule   string
+	live   map[string]int64
+	shadow map[string]int64
+}
+
+// Collects rule outcomes over the course of processing a single event. Shared by all copies of a context, so safe for concurrent use (eg, by blob rules).
+type outcomeTracker struct {
+	mu       sync.Mutex
+	outcomes []ruleOutcome
+}
+
+func (t *outcomeTracker) add(o ruleOutcome) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.outcomes = append(t.outcomes, o)
+}
+
+// returns nil (no tracking) unless the engine has an outcome recorder
+func (eng *Engine) newOutcomeTracker() *outcomeTracker {
+	if eng.Outcomes == nil {
+		return nil
+	}
+	return &outcomeTracker{}
+}
+
+// Passes the rule outcomes for an event to the engine's outcome recorder, if any. Called once all rules have run for the event.
+func (eng *Engine) recordRuleOutcomes(c *BaseContext) {
+	if c.outcomes == nil || eng.Outcomes == nil {
+		return
+	}
+	c.outcomes.mu.Lock()
+	outcomes := c.outcomes.outcomes
+	c.outcomes.outcomes = nil
+	c.outcomes.mu.Unlock()
+	eng.Outcomes.record(eng.Outcomes.now(), outcomes)
+}
+
+// Summary of a rule's outcomes over a time window.
+type RuleOutcomeSummary struct {
+	Rule string `json:"rule"`
+	// Number of events the rule ran on
+	Runs int64 `json:"runs"`
+	// Number of runs which resulted in at least one persisted effect
+	LiveMatches int64 `json:"liveMatches"`
+	// Number of runs which resulted in at least one shadow (computed, but not persisted) effect
+	ShadowMatches int64 `json:"shadowMatches"`
+	// Number of shadow matches on events where other rules' effects were persisted. A shadow rule with a high overlap mostly agrees with the live rules; the remaining shadow matches are what it would add.
+	ShadowOverlap int64 `json:"shadowOverlap"`
+	// Persisted effects, by effect type (Effect* constants)
+	LiveEffects map[string]int64 `json:"liveEffects,omitempty"`
+	// Shadow effects, by effect type (Effect* constants)
+	ShadowEffects map[string]int64 `json:"shadowEffects,omitempty"`
+}
+
+func (s *RuleOutcomeSummary) add(other *RuleOutcomeSummary) {
+	s.Runs += other.Runs
+	s.LiveMatches += other.LiveMatches
+	s.ShadowMatches += other.ShadowMatches
+	s.ShadowOverlap += other.ShadowOverlap
+	s.LiveEffects = addCounts(s.LiveEffects, other.LiveEffects)
+	s.ShadowEffects = addCounts(s.ShadowEffects, other.ShadowEffects)
+}
+
+func addCounts(dst, src map[string]int64) map[string]int64 {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]int64, len(src))
+	}
+	for k, v := range src {
+		dst[k] += v
+	}
+	return dst
+}
+
+// Aggregates per-rule outcomes, for comparing shadow and live rules side by side over a time window. Outcomes are kept in one-minute buckets, and discarded after the retention period. Safe for concurrent use.
+//
+// When an engine has an OutcomeRecorder, every rule is run with its effects tracked separately (see [BaseContext.RunRule]), which has some overhead.
+type OutcomeRecorder struct {
+	// How long outcomes are kept. Zero means forever
+	Retention time.Duration
+	// Optional source of the current time (eg, a simulated clock when replaying events). Defaults to time.Now
+	Clock func() time.Time
+
+	lk sync.Mutex
+	// unix minute, to rule name, to summary
+	buckets map[int64]map[string]*RuleOutcomeSummary
+}
+
+func NewOutcomeRecorder(retention time.Duration) *OutcomeRecorder {
+	return &OutcomeRecorder{
+		Retention: retention,
+		buckets:   make(map[int64]map[string]*RuleOutcomeSummary),
+	}
+}
+
+func (r *OutcomeRecorder) now() time.Time {
+	if r.Clock != nil {
+		return r.Clock()
+	}
+	return time.Now()
+}
+
+func (r *OutcomeRecorder) record(at time.Time, outcomes []ruleOutcome) {
+	if len(outcomes) == 0 {
+		return
+	}
+	// rules with persisted effects for this event, to compute overlap
+	liveRules := make(map[string]bool)
+	for _, o := range outcomes {
+		if len(o.live) > 0 {
+			liveRules[o.rule] = true
+		}
+	}
+
+	minute := at.Unix() / 60
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	bucket, ok := r.buckets[minute]
+	if !ok {
+		bucket = make(map[string]*RuleOutcomeSummary)
+		r.buckets[minute] = bucket
+		r.prune(at)
+	}
+	for _, o := range outcomes {
+		s, ok := bucket[o.rule]
+		if !ok {
+			s = &RuleOutcomeSummary{Rule: o.rule}
+			bucket[o.rule] = s
+		}
+		s.Runs++
+		if len(o.live) > 0 {
+			s.LiveMatches++
+			s.LiveEffects = addCounts(s.LiveEffects, o.live)
+		}
+		if len(o.shadow) > 0 {
+			s.ShadowMatches++
+			s.ShadowEffects = addCounts(s.ShadowEffects, o.shadow)
+			overlap := false
+			for rule := range liveRules {
+				if rule != o.rule {
+					overlap = true
+					break
+				}
+			}
+			if overlap {
+				s.ShadowOverlap++
+			}
+		}
+	}
+}
+
+// drops expired buckets. lock must be held
+func (r *OutcomeRecorder) prune(now time.Time) {
+	if r.Retention <= 0 {
+		return
+	}
+	oldest := now.Add(-r.Retention).Unix() / 60
+	for minute := range r.buckets {
+		if minute < oldest {
+			delete(r.buckets, minute)
+		}
+	}
+}
+
+// Returns per-rule summaries of outcomes since the given time (at one-minute granularity, and limited by the retention period), sorted by rule name.
+func (r *OutcomeRecorder) Report(since time.Time) []RuleOutcomeSummary {
+	start := since.Unix() / 60
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	totals := make(map[string]*RuleOutcomeSummary)
+	for minute, bucket := range r.buckets {
+		if minute < start {
+			continue
+		}
+		for rule, s := range bucket {
+			t, ok := totals[rule]
+			if !ok {
+				t = &RuleOutcomeSummary{Rule: rule}
+				totals[rule] = t
+			}
+			t.add(s)
+		}
+	}
+	out := make([]RuleOutcomeSummary, 0, len(totals))
+	for _, t := range totals {
+		out = append(out, *t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Rule < out[j].Rule })
+	return out
+}
@@ -3,28 +3,66 @@ package engine
 import (
 	"context"
 	"fmt"
+	"time"
 
 	comatproto "github.com/bluesky-social/indigo/api/atproto"
 	toolsozone "github.com/bluesky-social/indigo/api/ozone"
+	"github.com/bluesky-social/indigo/automod/countstore"
+	"github.com/bluesky-social/indigo/automod/hashstore"
+	"github.com/bluesky-social/indigo/automod/textsim"
 )
 
+// Persists state updates: all counter increments (as a single batch), and hash and text index additions.
 func (eng *Engine) persistCounters(ctx context.Context, eff *Effects) error {
+	if err := eng.persistHashes(ctx, eff); err != nil {
+		return err
+	}
+	if err := eng.persistTexts(ctx, eff); err != nil {
+		return err
+	}
 	// TODO: dedupe this array
+	incs := make([]countstore.CountIncrement, 0, len(eff.CounterIncrements)+len(eff.CounterDistinctIncrements))
 	for _, ref := range eff.CounterIncrements {
+		inc := countstore.CountIncrement{Name: ref.Name, Val: ref.Val}
 		if ref.Period != nil {
-			err := eng.Counters.IncrementPeriod(ctx, ref.Name, ref.Val, *ref.Period)
-			if err != nil {
-				return err
-			}
-		} else {
-			err := eng.Counters.Increment(ctx, ref.Name, ref.Val)
-			if err != nil {
-				return err
-			}
+			inc.Period = *ref.Period
 		}
+		incs = append(incs, inc)
 	}
 	for _, ref := range eff.CounterDistinctIncrements {
-		err := eng.Counters.IncrementDistinct(ctx, ref.Name, ref.Bucket, ref.Val)
+		inc := countstore.CountIncrement{Name: ref.Name, Val: ref.Bucket, Distinct: true, DistinctVal: ref.Val}
+		if ref.Period != nil {
+			inc.Period = *ref.Period
+		}
+		incs = append(incs, inc)
+	}
+	if len(incs) == 0 {
+		return nil
+	}
+	return eng.Counters.IncrementBatch(ctx, incs)
+}
+
+func (eng *Engine) persistHashes(ctx context.Context, eff *Effects) error {
+	if eng.Hashes == nil || len(eff.HashAdds) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for _, ref := range eff.HashAdds {
+		err := eng.Hashes.Add(ctx, ref.Namespace, hashstore.Entry{Hash: ref.Hash, ID: ref.ID, Time: now})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (eng *Engine) persistTexts(ctx context.Context, eff *Effects) error {
+	if eng.Texts == nil || len(eff.TextAdds) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for _, ref := range eff.TextAdds {
+		err := eng.Texts.Add(ctx, ref.Namespace, textsim.Entry{ID: ref.ID, Account: ref.Account, Signature: ref.Signature, Time: now})
 		if err != nil {
 			return err
 		}
@@ -91,6 +129,22 @@ func (eng *Engine) persistAccountModActions(c *AccountContext) error {
 			}
 		}
 	}
+	if anyModActions && len(eng.Sinks) > 0 {
+		eng.sendActionEvent(ctx, c.Logger, &ActionEvent{
+			Time:        time.Now(),
+			SubjectType: ActionSubjectAccount,
+			DID:         c.Account.Identity.DID,
+			Labels:      newLabels,
+			Tags:        newTags,
+			Flags:       newFlags,
+			Reports:     newReports,
+			Takedown:    newTakedown,
+			// there is no escalation if there is a takedown
+			Escalate:    newEscalation && !newTakedown,
+			Acknowledge: newAcknowledge,
+			Rules:       c.effects.Rules,
+		})
+	}
 
 	// flags don't require admin auth
 	if len(newFlags) > 0 {
@@ -101,19 +155,37 @@ func (eng *Engine) persistAccountModActions(c *AccountContext) error {
 		eng.Flags.Add(ctx, c.Account.Identity.DID.String(), newFlags)
 	}
 
+	// labels can be emitted directly by a labeler, instead of via the mod service
+	ozoneLabels := newLabels
+	if len(newLabels) > 0 && eng.Labeler != nil {
+		c.Logger.Info("labeling account", "newLabels", newLabels)
+		for _, val := range newLabels {
+			// note: WithLabelValues is a prometheus label, not an atproto label
+			actionNewLabelCount.WithLabelValues("account", val).Inc()
+		}
+		if _, err := eng.Labeler.CreateLabels(ctx, c.Account.Identity.DID.String(), nil, newLabels); err != nil {
+			return fmt.Errorf("emitting account labels: %w", err)
+		}
+		ozoneLabels = nil
+	}
+
 	// if we can't actually talk to service, bail out early
 	if eng.OzoneClient == nil {
-		if anyModActions {
+		if newTakedown || newEscalation || newAcknowledge || len(ozoneLabels) > 0 || len(newTags) > 0 || len(newReports) > 0 {
 			c.Logger.Warn("not persisting actions, mod service client not configured")
 		}
+		if len(ozoneLabels) < len(newLabels) {
+			// labels were emitted directly
+			return eng.PurgeAccountCaches(ctx, c.Account.Identity.DID)
+		}
 		return nil
 	}
 
 	xrpcc := eng.OzoneClient
 
-	if len(newLabels) > 0 {
-		c.Logger.Info("labeling account", "newLabels", newLabels)
-		for _, val := range newLabels {
+	if len(ozoneLabels) > 0 {
+		c.Logger.Info("labeling account", "newLabels", ozoneLabels)
+		for _, val := range ozoneLabels {
 			// note: WithLabelValues is a prometheus label, not an atproto label
 			actionNewLabelCount.WithLabelValues("account", val).Inc()
 		}
@@ -122,7 +194,7 @@ func (eng *Engine) persistAccountModActions(c *AccountContext) error {
 			CreatedBy: xrpcc.Auth.Did,
 			Event: &toolsozone.ModerationEmitEvent_Input_Event{
 				ModerationDefs_ModEventLabel: &toolsozone.ModerationDefs_ModEventLabel{
-					CreateLabelVals: newLabels,
+					CreateLabelVals: ozoneLabels,
 					NegateLabelVals: []string{},
 					Comment:         &comment,
 				},
@@ -339,6 +411,29 @@ func (eng *Engine) persistRecordModActions(c *RecordContext) error {
 				}
 			}
 		}
+		if len(eng.Sinks) > 0 {
+			evt := ActionEvent{
+				Time:        time.Now(),
+				SubjectType: ActionSubjectRecord,
+				DID:         c.Account.Identity.DID,
+				URI:         atURI,
+				Labels:      newLabels,
+				Tags:        newTags,
+				Flags:       newFlags,
+				Reports:     newReports,
+				Takedown:    newTakedown,
+				Escalate:    newEscalation && !newTakedown,
+				Acknowledge: newAcknowledge,
+				Rules:       c.effects.Rules,
+			}
+			if c.RecordOp.CID != nil {
+				evt.CID = c.RecordOp.CID.String()
+			}
+			if newTakedown {
+				evt.BlobTakedowns = dedupeStrings(c.effects.BlobTakedowns)
+			}
+			eng.sendActionEvent(ctx, c.Logger, &evt)
+		}
 	}
 
 	// flags don't require admin auth
@@ -350,6 +445,24 @@ func (eng *Engine) persistRecordModActions(c *RecordContext) error {
 		eng.Flags.Add(ctx, atURI, newFlags)
 	}
 
+	// labels can be emitted directly by a labeler, instead of via the mod service
+	if len(newLabels) > 0 && eng.Labeler != nil {
+		c.Logger.Info("labeling record", "newLabels", newLabels)
+		for _, val := range newLabels {
+			// note: WithLabelValues is a prometheus label, not an atproto label
+			actionNewLabelCount.WithLabelValues("record", val).Inc()
+		}
+		var cid *string
+		if c.RecordOp.CID != nil {
+			s := c.RecordOp.CID.String()
+			cid = &s
+		}
+		if _, err := eng.Labeler.CreateLabels(ctx, atURI, cid, newLabels); err != nil {
+			return fmt.Errorf("emitting record labels: %w", err)
+		}
+		newLabels = nil
+	}
+
 	// exit early
 	if !newAcknowledge && !newEscalation && !newTakedown && len(newLabels) == 0 && len(newTags) == 0 && len(newReports) == 0 {
 		return nil
@@ -2,8 +2,8 @@ package engine
 
 // Simplified variant of input parameters for com.atproto.moderation.createReport, for internal tracking
 type ModReport struct {
-	ReasonType string
-	Comment    string
+	ReasonType string `json:"reasonType"`
+	Comment    string `json:"comment"`
 }
 
 var (
@@ -11,9 +11,11 @@ import (
 
 // Holds configuration of which rules of various types should be run, and helps dispatch events to those rules.
 type RuleSet struct {
-	PostRules         []PostRuleFunc
-	ProfileRules      []ProfileRuleFunc
-	RecordRules       []RecordRuleFunc
+	PostRules    []PostRuleFunc
+	ProfileRules []ProfileRuleFunc
+	RecordRules  []RecordRuleFunc
+	// rules for specific collections, with the record decoded (typed or generic)
+	CollectionRules   []CollectionRule
 	RecordDeleteRules []RecordRuleFunc
 	IdentityRules     []IdentityRuleFunc
 	AccountRules      []AccountRuleFunc
@@ -24,9 +26,12 @@ type RuleSet struct {
 
 // Executes all the various record-related rules. Only dispatches execution, does no other de-dupe or pre/post processing.
 func (r *RuleSet) CallRecordRules(c *RecordContext) error {
+	// decoded record, shared by lexicon validation and collection rules
+	rec := &parsedRecord{cbor: c.RecordOp.RecordCBOR}
+	c.validateLexicon(rec)
 	// first the generic rules
 	for _, f := range r.RecordRules {
-		err := f(c)
+		err := c.RunRule(RuleName(f), RulePolicy{}, func() error { return f(c) })
 		if err != nil {
 			c.Logger.Error("record rule execution failed", "err", err)
 		}
@@ -39,7 +44,7 @@ func (r *RuleSet) CallRecordRules(c *RecordContext) error {
 			return fmt.Errorf("failed to parse app.bsky.feed.post record: %v", err)
 		}
 		for _, f := range r.PostRules {
-			err := f(c, &post)
+			err := c.RunRule(RuleName(f), RulePolicy{}, func() error { return f(c, &post) })
 			if err != nil {
 				c.Logger.Error("post rule execution failed", "err", err)
 			}
@@ -50,12 +55,14 @@ func (r *RuleSet) CallRecordRules(c *RecordContext) error {
 			return fmt.Errorf("failed to parse app.bsky.actor.profile record: %v", err)
 		}
 		for _, f := range r.ProfileRules {
-			err := f(c, &profile)
+			err := c.RunRule(RuleName(f), RulePolicy{}, func() error { return f(c, &profile) })
 			if err != nil {
 				c.Logger.Error("profile rule execution failed", "err", err)
 			}
 		}
 	}
+	// then any collection rules, which are keyed by NSID and cover any collection
+	r.callCollectionRules(c, rec)
 	// then blob rules, if any
 	if len(r.BlobRules) == 0 {
 		return nil
@@ -71,7 +78,7 @@ func (r *RuleSet) CallRecordRules(c *RecordContext) error {
 // NOTE: this will probably be removed and merged in to `CallRecordRules`
 func (r *RuleSet) CallRecordDeleteRules(c *RecordContext) error {
 	for _, f := range r.RecordDeleteRules {
-		err := f(c)
+		err := c.RunRule(RuleName(f), RulePolicy{}, func() error { return f(c) })
 		if err != nil {
 			c.Logger.Error("record delete rule execution failed", "err", err)
 		}
@@ -82,7 +89,7 @@ func (r *RuleSet) CallRecordDeleteRules(c *RecordContext) error {
 // Executes rules for identity update events.
 func (r *RuleSet) CallIdentityRules(c *AccountContext) error {
 	for _, f := range r.IdentityRules {
-		err := f(c)
+		err := c.RunRule(RuleName(f), RulePolicy{}, func() error { return f(c) })
 		if err != nil {
 			c.Logger.Error("identity rule execution failed", "err", err)
 		}
@@ -93,7 +100,7 @@ func (r *RuleSet) CallIdentityRules(c *AccountContext) error {
 // Executes rules for account update events.
 func (r *RuleSet) CallAccountRules(c *AccountContext) error {
 	for _, f := range r.AccountRules {
-		err := f(c)
+		err := c.RunRule(RuleName(f), RulePolicy{}, func() error { return f(c) })
 		if err != nil {
 			c.Logger.Error("account rule execution failed", "err", err)
 		}
@@ -103,7 +110,7 @@ func (r *RuleSet) CallAccountRules(c *AccountContext) error {
 
 func (r *RuleSet) CallNotificationRules(c *NotificationContext) error {
 	for _, f := range r.NotificationRules {
-		err := f(c)
+		err := c.RunRule(RuleName(f), RulePolicy{}, func() error { return f(c) })
 		if err != nil {
 			c.Logger.Error("notification rule execution failed", "err", err)
 		}
@@ -113,7 +120,7 @@ func (r *RuleSet) CallNotificationRules(c *NotificationContext) error {
 
 func (r *RuleSet) CallOzoneEventRules(c *OzoneEventContext) error {
 	for _, f := range r.OzoneEventRules {
-		err := f(c)
+		err := c.RunRule(RuleName(f), RulePolicy{}, func() error { return f(c) })
 		if err != nil {
 			c.Logger.Error("ozone event rule execution failed", "err", err)
 		}
@@ -170,7 +177,11 @@ func (r *RuleSet) processBlob(c *RecordContext, blob lexutil.LexBlob, data []byt
 		wg.Add(1)
 		go func(brf BlobRuleFunc) {
 			defer wg.Done()
-			err := brf(c, blob, data)
+			// blob rules run concurrently, so each gets a shallow copy of the context with separate effects, which are merged back once the rule is done
+			bc := *c
+			bc.effects = &Effects{}
+			err := bc.RunRule(RuleName(brf), RulePolicy{}, func() error { return brf(&bc, blob, data) })
+			c.effects.merge(bc.effects)
 			if err != nil {
 				errChan <- err
 				return
@@ -0,0 +1,157 @@
+package engine
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Effect types, as used in [RulePolicy] allowlists and rule outcome reports.
+//
+// Counter increments are not moderation actions, and are always persisted, even for rules running in shadow mode.
+const (
+	EffectLabel       = "label"
+	EffectTag         = "tag"
+	EffectFlag        = "flag"
+	EffectReport      = "report"
+	EffectTakedown    = "takedown"
+	EffectEscalate    = "escalate"
+	EffectAcknowledge = "acknowledge"
+	EffectNotify      = "notify"
+	EffectReject      = "reject"
+)
+
+var knownEffects = []string{
+	EffectLabel,
+	EffectTag,
+	EffectFlag,
+	EffectReport,
+	EffectTakedown,
+	EffectEscalate,
+	EffectAcknowledge,
+	EffectNotify,
+	EffectReject,
+}
+
+// Controls which effects (moderation actions) of a rule get persisted.
+//
+// The zero value is a regular "live" rule: all effects are persisted.
+type RulePolicy struct {
+	// If true, effects from the rule are computed, logged, and included in outcome reports, but never persisted.
+	Shadow bool `json:"shadow,omitempty" yaml:"shadow,omitempty"`
+	// If non-empty, only these effect types (Effect* constants) are persisted for the rule. Any other effects are handled as if the rule was in shadow mode.
+	AllowedEffects []string `json:"allowedEffects,omitempty" yaml:"allowed_effects,omitempty"`
+}
+
+// Checks that all allowed effect types are known.
+func (p RulePolicy) Validate() error {
+	for _, e := range p.AllowedEffects {
+		if !slices.Contains(knownEffects, e) {
+			return fmt.Errorf("unknown effect type: %s", e)
+		}
+	}
+	return nil
+}
+
+func (p RulePolicy) allows(effect string) bool {
+	if p.Shadow {
+		return false
+	}
+	return len(p.AllowedEffects) == 0 || slices.Contains(p.AllowedEffects, effect)
+}
+
+func (p RulePolicy) isLive() bool {
+	return !p.Shadow && len(p.AllowedEffects) == 0
+}
+
+// Resolves the policy for a named rule: a policy configured in [EngineConfig.RulePolicies] takes precedence over the rule's own default, and global shadow mode overrides both.
+func (eng *Engine) rulePolicy(name string, def RulePolicy) RulePolicy {
+	p := def
+	if rp, ok := eng.Config.RulePolicies[name]; ok {
+		p = rp
+	}
+	if eng.Config.ShadowMode {
+		p.Shadow = true
+	}
+	return p
+}
+
+// cache of function pointer to rule name
+var ruleNames sync.Map
+
+// Returns the name of a Go rule function, as used in [EngineConfig.RulePolicies] and outcome reports: the function name without package (eg, "BadHashtagsPostRule"). Methods include the receiver type (eg, "(*HiveAIClient).HiveLabelBlobRule").
+func RuleName(f any) string {
+	pc := reflect.ValueOf(f).Pointer()
+	if v, ok := ruleNames.Load(pc); ok {
+		return v.(string)
+	}
+	name := "unknown"
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		name = fn.Name()
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			name = name[i+1:]
+		}
+		if i := strings.Index(name, "."); i >= 0 {
+			name = name[i+1:]
+		}
+		// method values have a "-fm" suffix
+		name = strings.TrimSuffix(name, "-fm")
+	}
+	ruleNames.Store(pc, name)
+	return name
+}
+
+// tracks a single (possibly nested) execution of RunRule
+type ruleFrame struct {
+	// set if the rule itself called RunRule: it is a dispatcher, and only the nested rules are reported
+	delegated bool
+}
+
+// Runs a single named rule, applying the effect policy for that rule name. The supplied policy is the rule's default, which is overridden by any policy configured in [EngineConfig.RulePolicies], and by [EngineConfig.ShadowMode].
+//
+// The engine calls this for every Go rule function. It can also be called by rule functions which dispatch to several independently named rules (such as declarative rules), so that each of them is gated and reported separately.
+func (c *BaseContext) RunRule(name string, policy RulePolicy, f func() error) error {
+	if c.frame != nil {
+		c.frame.delegated = true
+	}
+	policy = c.engine.rulePolicy(name, policy)
+	// fast path: nothing to gate or record
+	if policy.isLive() && c.outcomes == nil && len(c.engine.Sinks) == 0 {
+		return f()
+	}
+
+	parentEffects, parentFrame := c.effects, c.frame
+	frame := &ruleFrame{}
+	c.effects, c.frame = &Effects{}, frame
+	err := f()
+	eff := c.effects
+	c.effects, c.frame = parentEffects, parentFrame
+
+	live, shadow := eff.split(policy.allows)
+	parentEffects.merge(live)
+	if frame.delegated {
+		return err
+	}
+
+	liveCounts := live.actionCounts()
+	shadowCounts := shadow.actionCounts()
+	if len(liveCounts) > 0 {
+		parentEffects.addRule(name)
+	}
+	for effect, n := range liveCounts {
+		ruleEffectCount.WithLabelValues(name, effect, "live").Add(float64(n))
+	}
+	for effect, n := range shadowCounts {
+		ruleEffectCount.WithLabelValues(name, effect, "shadow").Add(float64(n))
+	}
+	if len(shadowCounts) > 0 {
+		c.Logger.Info("shadow rule effects", append([]any{"rule", name}, shadow.logAttrs()...)...)
+	}
+	if c.outcomes != nil {
+		c.outcomes.add(ruleOutcome{rule: name, live: liveCounts, shadow: shadowCounts})
+	}
+	return err
+}
@@ -0,0 +1,159 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	appbsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func flagAccountRule(c *RecordContext, post *appbsky.FeedPost) error {
+	c.AddAccountFlag("flagged")
+	c.Increment("posts", c.Account.Identity.DID.String())
+	return nil
+}
+
+func takedownRule(c *RecordContext, post *appbsky.FeedPost) error {
+	c.AddRecordTag("gated")
+	c.TakedownRecord()
+	return nil
+}
+
+func testPostOp(t *testing.T, tags []string) RecordOp {
+	cid1 := syntax.CID("cid123")
+	p1 := appbsky.FeedPost{
+		Text: "some post blah",
+		Tags: tags,
+	}
+	p1buf := new(bytes.Buffer)
+	if err := p1.MarshalCBOR(p1buf); err != nil {
+		t.Fatal(err)
+	}
+	return RecordOp{
+		Action:     CreateOp,
+		DID:        syntax.DID("did:plc:abc111"),
+		Collection: syntax.NSID("app.bsky.feed.post"),
+		RecordKey:  syntax.RecordKey("abc123"),
+		CID:        &cid1,
+		RecordCBOR: p1buf.Bytes(),
+	}
+}
+
+func TestRuleName(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("simpleRule", RuleName(simpleRule))
+	assert.Equal("simpleRule", RuleName(PostRuleFunc(simpleRule)))
+	lbl := &testLabelEmitter{}
+	assert.Equal("(*testLabelEmitter).CreateLabels", RuleName(lbl.CreateLabels))
+}
+
+func TestShadowRules(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	eng := EngineTestFixture()
+	lbl := &testLabelEmitter{emitted: map[string][]string{}}
+	eng.Labeler = lbl
+	eng.Outcomes = NewOutcomeRecorder(time.Hour)
+	eng.Rules.PostRules = append(eng.Rules.PostRules, flagAccountRule, takedownRule)
+	eng.Config.RulePolicies = map[string]RulePolicy{
+		"simpleRule":   {Shadow: true},
+		"takedownRule": {AllowedEffects: []string{EffectTag}},
+	}
+
+	assert.NoError(eng.ProcessRecordOp(ctx, testPostOp(t, []string{"one", "slur"})))
+	// shadow label was not emitted, but the live flag was persisted
+	assert.Empty(lbl.emitted)
+	flags, err := eng.Flags.Get(ctx, "did:plc:abc111")
+	assert.NoError(err)
+	assert.Equal([]string{"flagged"}, flags)
+	count, err := eng.Counters.GetCount(ctx, "posts", "did:plc:abc111", "total")
+	assert.NoError(err)
+	assert.Equal(1, count)
+
+	// rule does not match, so no shadow effects
+	assert.NoError(eng.ProcessRecordOp(ctx, testPostOp(t, []string{"one"})))
+
+	report := eng.Outcomes.Report(time.Now().Add(-time.Minute))
+	assert.Equal(3, len(report))
+	assert.Equal(RuleOutcomeSummary{
+		Rule:          "flagAccountRule",
+		Runs:          2,
+		LiveMatches:   2,
+		LiveEffects:   map[string]int64{EffectFlag: 2},
+		ShadowEffects: nil,
+	}, report[0])
+	assert.Equal(RuleOutcomeSummary{
+		Rule:          "simpleRule",
+		Runs:          2,
+		ShadowMatches: 1,
+		ShadowOverlap: 1,
+		ShadowEffects: map[string]int64{EffectLabel: 1},
+	}, report[1])
+	assert.Equal(RuleOutcomeSummary{
+		Rule:          "takedownRule",
+		Runs:          2,
+		LiveMatches:   2,
+		ShadowMatches: 2,
+		ShadowOverlap: 2,
+		LiveEffects:   map[string]int64{EffectTag: 2},
+		ShadowEffects: map[string]int64{EffectTakedown: 2},
+	}, report[2])
+
+	// outside of the window
+	assert.Empty(eng.Outcomes.Report(time.Now().Add(2 * time.Minute)))
+}
+
+func TestShadowMode(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	eng := EngineTestFixture()
+	eng.Config.ShadowMode = true
+	eng.Rules.PostRules = append(eng.Rules.PostRules, flagAccountRule)
+
+	assert.NoError(eng.ProcessRecordOp(ctx, testPostOp(t, []string{"slur"})))
+	flags, err := eng.Flags.Get(ctx, "did:plc:abc111")
+	assert.NoError(err)
+	assert.Empty(flags)
+	// counters are not moderation actions, and are still persisted
+	count, err := eng.Counters.GetCount(ctx, "posts", "did:plc:abc111", "total")
+	assert.NoError(err)
+	assert.Equal(1, count)
+}
+
+func TestEffectsSplitMerge(t *testing.T) {
+	assert := assert.New(t)
+
+	eff := &Effects{}
+	eff.AddRecordLabel("spam")
+	eff.ReportRecord(ReportReasonSpam, "")
+	eff.TakedownRecord()
+	eff.TakedownBlob("bafkblob")
+	eff.Increment("posts", "did:plc:abc111")
+
+	live, shadow := eff.split(RulePolicy{AllowedEffects: []string{EffectReport}}.allows)
+	assert.Equal(map[string]int64{EffectReport: 1}, live.actionCounts())
+	assert.Equal(1, len(live.CounterIncrements))
+	assert.Equal(map[string]int64{EffectLabel: 1, EffectTakedown: 1}, shadow.actionCounts())
+	assert.Equal([]string{"bafkblob"}, shadow.BlobTakedowns)
+
+	dst := &Effects{}
+	dst.AddRecordLabel("spam")
+	dst.ReportRecord(ReportReasonSpam, "other")
+	dst.merge(eff)
+	assert.Equal([]string{"spam"}, dst.RecordLabels)
+	assert.Equal(1, len(dst.RecordReports))
+	assert.Equal("other", dst.RecordReports[0].Comment)
+	assert.True(dst.RecordTakedown)
+	assert.Equal(1, len(dst.CounterIncrements))
+
+	assert.NoError(RulePolicy{AllowedEffects: []string{EffectLabel, EffectNotify}}.Validate())
+	assert.Error(RulePolicy{AllowedEffects: []string{"labels"}}.Validate())
+}
@@ -0,0 +1,216 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/automod/countstore"
+)
+
+// Interface for a destination which receives moderation actions, in addition to the mod service (eg, a webhook or an audit log).
+//
+// By default, sinks are called synchronously during event processing, with retries and a per-attempt timeout. Long-running services should call [Engine.StartSinks], so that delivery happens in the background and a slow sink does not stall event processing.
+type ActionSink interface {
+	// Short name for the sink, used in logs, metrics, and circuit breaker counters
+	Name() string
+	SendActions(ctx context.Context, evt *ActionEvent) error
+}
+
+// Subject types for [ActionEvent]
+const (
+	ActionSubjectAccount = "account"
+	ActionSubjectRecord  = "record"
+)
+
+// Finalized set of moderation actions for a single subject (account or record), after de-duplication and circuit breakers. This is what gets passed to action sinks.
+//
+// Processing a record event can result in two action events: one for the account, and one for the record.
+type ActionEvent struct {
+	Time time.Time `json:"time"`
+	// ActionSubjectAccount or ActionSubjectRecord
+	SubjectType string     `json:"subjectType"`
+	DID         syntax.DID `json:"did"`
+	// AT-URI and CID of record subjects
+	URI           string      `json:"uri,omitempty"`
+	CID           string      `json:"cid,omitempty"`
+	Labels        []string    `json:"labels,omitempty"`
+	Tags          []string    `json:"tags,omitempty"`
+	Flags         []string    `json:"flags,omitempty"`
+	Reports       []ModReport `json:"reports,omitempty"`
+	Takedown      bool        `json:"takedown,omitempty"`
+	Escalate      bool        `json:"escalate,omitempty"`
+	Acknowledge   bool        `json:"acknowledge,omitempty"`
+	BlobTakedowns []string    `json:"blobTakedowns,omitempty"`
+	// names of rules which resulted in moderation actions while processing the event (for either subject)
+	Rules []string `json:"rules,omitempty"`
+}
+
+// Returns the AT-URI (for records) or DID (for accounts) of the subject.
+func (evt *ActionEvent) Subject() string {
+	if evt.URI != "" {
+		return evt.URI
+	}
+	return evt.DID.String()
+}
+
+// Delivers an action event to all of the engine's action sinks. If background delivery has been started (see [Engine.StartSinks]), the event is queued for each sink, and dropped for any sink whose queue is full. Otherwise, sinks are called synchronously. Errors are logged, not returned.
+func (eng *Engine) sendActionEvent(ctx context.Context, logger *slog.Logger, evt *ActionEvent) {
+	if eng.sinkQueue != nil {
+		eng.sinkQueue.enqueue(logger, evt)
+		return
+	}
+	for _, sink := range eng.Sinks {
+		eng.sendToSink(ctx, logger, sink, evt)
+	}
+}
+
+// Delivers an action event to a single sink. The sink is retried a few times, and skipped entirely if it has failed too often recently.
+func (eng *Engine) sendToSink(ctx context.Context, logger *slog.Logger, sink ActionSink, evt *ActionEvent) {
+	name := sink.Name()
+	ok, err := eng.circuitBreakSink(ctx, name)
+	if err != nil {
+		logger.Error("checking action sink circuit breaker", "sink", name, "err", err)
+		return
+	}
+	if !ok {
+		actionSinkDeliveryCount.WithLabelValues(name, "circuit-break").Inc()
+		return
+	}
+	if err := eng.deliverToSink(ctx, sink, evt); err != nil {
+		logger.Error("failed to deliver actions to sink", "sink", name, "subject", evt.Subject(), "err", err)
+		actionSinkDeliveryCount.WithLabelValues(name, "failed").Inc()
+		if err := eng.Counters.Increment(ctx, "automod-sink-failure", name); err != nil {
+			logger.Error("incrementing action sink failure count", "sink", name, "err", err)
+		}
+		return
+	}
+	actionSinkDeliveryCount.WithLabelValues(name, "ok").Inc()
+}
+
+// Attempts delivery to a single sink, with exponential backoff between attempts.
+func (eng *Engine) deliverToSink(ctx context.Context, sink ActionSink, evt *ActionEvent) error {
+	attempts := eng.Config.SinkAttempts
+	if attempts <= 0 {
+		attempts = 3
+	}
+	timeout := eng.Config.SinkTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	backoff := 200 * time.Millisecond
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("%w (last error: %w)", ctx.Err(), err)
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		err = sink.SendActions(attemptCtx, evt)
+		cancel()
+		if err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("after %d attempts: %w", attempts, err)
+}
+
+// Circuit breaker for each action sink: if a sink has failed too many times in the current hour, it is skipped until the next hour.
+func (eng *Engine) circuitBreakSink(ctx context.Context, name string) (bool, error) {
+	c, err := eng.Counters.GetCount(ctx, "automod-sink-failure", name, countstore.PeriodHour)
+	if err != nil {
+		return false, fmt.Errorf("checking action sink failure quota: %w", err)
+	}
+	quotaSinkFailureHour := eng.Config.QuotaSinkFailureHour
+	if quotaSinkFailureHour == 0 {
+		quotaSinkFailureHour = 20
+	}
+	if c >= quotaSinkFailureHour {
+		eng.Logger.Warn("CIRCUIT BREAKER: automod action sink", "sink", name)
+		return false, nil
+	}
+	return true, nil
+}
+
+type queuedActionEvent struct {
+	logger *slog.Logger
+	evt    *ActionEvent
+}
+
+// Bounded queues of action events, with a background worker for each sink.
+type sinkQueue struct {
+	lk     sync.Mutex
+	closed bool
+	names  []string
+	queues []chan queuedActionEvent
+	wg     sync.WaitGroup
+}
+
+// Starts background delivery to the engine's action sinks: each sink gets a bounded queue (see EngineConfig.SinkQueueSize) and a worker goroutine, so that a slow or failing sink does not stall event processing. When a sink's queue is full, events for that sink are dropped.
+//
+// Should be called once, before processing events. Call [Engine.StopSinks] on shutdown to deliver any queued events.
+func (eng *Engine) StartSinks() {
+	if eng.sinkQueue != nil || len(eng.Sinks) == 0 {
+		return
+	}
+	size := eng.Config.SinkQueueSize
+	if size <= 0 {
+		size = 1000
+	}
+	q := &sinkQueue{}
+	for _, sink := range eng.Sinks {
+		ch := make(chan queuedActionEvent, size)
+		q.names = append(q.names, sink.Name())
+		q.queues = append(q.queues, ch)
+		q.wg.Add(1)
+		go func(sink ActionSink) {
+			defer q.wg.Done()
+			for qe := range ch {
+				eng.sendToSink(context.Background(), qe.logger, sink, qe.evt)
+			}
+		}(sink)
+	}
+	eng.sinkQueue = q
+}
+
+// Stops background delivery to action sinks (if started), waiting for already-queued events to be delivered. Any action events after this are dropped.
+func (eng *Engine) StopSinks() {
+	q := eng.sinkQueue
+	if q == nil {
+		return
+	}
+	q.lk.Lock()
+	if !q.closed {
+		q.closed = true
+		for _, ch := range q.queues {
+			close(ch)
+		}
+	}
+	q.lk.Unlock()
+	q.wg.Wait()
+}
+
+func (q *sinkQueue) enqueue(logger *slog.Logger, evt *ActionEvent) {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+	if q.closed {
+		logger.Warn("action sinks stopped, dropping action event", "subject", evt.Subject())
+		return
+	}
+	for i, ch := range q.queues {
+		select {
+		case ch <- queuedActionEvent{logger: logger, evt: evt}:
+		default:
+			name := q.names[i]
+			logger.Warn("action sink queue full, dropping action event", "sink", name, "subject", evt.Subject())
+			actionSinkDeliveryCount.WithLabelValues(name, "dropped").Inc()
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Discord limits message content to this many characters
+const discordMaxContent = 2000
+
+// Action sink which posts a summary of each action event to a Discord channel, via "webhook".
+type DiscordSink struct {
+	WebhookURL string
+	// optional; defaults to a client with a 10 second timeout
+	Client *http.Client
+}
+
+func (s *DiscordSink) Name() string {
+	return "discord"
+}
+
+type DiscordWebhookBody struct {
+	Content string `json:"content"`
+}
+
+func (s *DiscordSink) SendActions(ctx context.Context, evt *ActionEvent) error {
+	body, err := json.Marshal(DiscordWebhookBody{Content: discordContent(evt)})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doSinkRequest(s.Client, req)
+}
+
+func discordContent(evt *ActionEvent) string {
+	msg := fmt.Sprintf("**Automod %s action**\n`%s`\n", evt.SubjectType, evt.Subject())
+	if len(evt.Labels) > 0 {
+		msg += fmt.Sprintf("Labels: `%s`\n", strings.Join(evt.Labels, ", "))
+	}
+	if len(evt.Tags) > 0 {
+		msg += fmt.Sprintf("Tags: `%s`\n", strings.Join(evt.Tags, ", "))
+	}
+	if len(evt.Flags) > 0 {
+		msg += fmt.Sprintf("Flags: `%s`\n", strings.Join(evt.Flags, ", "))
+	}
+	for _, rep := range evt.Reports {
+		msg += fmt.Sprintf("Report `%s`: %s\n", ReasonShortName(rep.ReasonType), rep.Comment)
+	}
+	if evt.Takedown {
+		msg += "Takedown!\n"
+	}
+	if len(evt.BlobTakedowns) > 0 {
+		msg += fmt.Sprintf("Blob takedowns: `%s`\n", strings.Join(evt.BlobTakedowns, ", "))
+	}
+	if evt.Escalate {
+		msg += "Escalated\n"
+	}
+	if evt.Acknowledge {
+		msg += "Acknowledged\n"
+	}
+	if len(evt.Rules) > 0 {
+		msg += fmt.Sprintf("Rules: `%s`\n", strings.Join(evt.Rules, ", "))
+	}
+	if len(msg) > discordMaxContent {
+		// don't leave a partial UTF-8 character at the cut
+		msg = strings.ToValidUTF8(msg[:discordMaxContent-3], "") + "..."
+	}
+	return msg
+}
@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Row in the action audit table. List fields are comma-separated, and reports are JSON.
+type ActionAuditRecord struct {
+	ID          uint      `gorm:"primaryKey"`
+	CreatedAt   time.Time `gorm:"index"`
+	SubjectType string
+	DID         string `gorm:"column:did;index"`
+	URI         string `gorm:"column:uri"`
+	CID         string `gorm:"column:cid"`
+	Labels      string
+	Tags        string
+	Flags       string
+	Reports     string
+	Takedown    bool
+	Escalate    bool
+	Acknowledge bool
+	// CIDs of blobs taken down
+	BlobTakedowns string
+	Rules         string
+}
+
+func (ActionAuditRecord) TableName() string {
+	return "automod_action_audit"
+}
+
+// Action sink which records every action event as a row in a SQL table (eg, SQLite or PostgreSQL), for auditing and analytics.
+type SQLAuditSink struct {
+	db *gorm.DB
+}
+
+// Creates the sink, and the audit table if it doesn't exist.
+func NewSQLAuditSink(db *gorm.DB) (*SQLAuditSink, error) {
+	if err := db.AutoMigrate(&ActionAuditRecord{}); err != nil {
+		return nil, fmt.Errorf("migrating action audit table: %w", err)
+	}
+	return &SQLAuditSink{db: db}, nil
+}
+
+func (s *SQLAuditSink) Name() string {
+	return "sql-audit"
+}
+
+func (s *SQLAuditSink) SendActions(ctx context.Context, evt *ActionEvent) error {
+	row := ActionAuditRecord{
+		CreatedAt:     evt.Time,
+		SubjectType:   evt.SubjectType,
+		DID:           evt.DID.String(),
+		URI:           evt.URI,
+		CID:           evt.CID,
+		Labels:        strings.Join(evt.Labels, ","),
+		Tags:          strings.Join(evt.Tags, ","),
+		Flags:         strings.Join(evt.Flags, ","),
+		Takedown:      evt.Takedown,
+		Escalate:      evt.Escalate,
+		Acknowledge:   evt.Acknowledge,
+		BlobTakedowns: strings.Join(evt.BlobTakedowns, ","),
+		Rules:         strings.Join(evt.Rules, ","),
+	}
+	if len(evt.Reports) > 0 {
+		b, err := json.Marshal(evt.Reports)
+		if err != nil {
+			return err
+		}
+		row.Reports = string(b)
+	}
+	return s.db.WithContext(ctx).Create(&row).Error
+}
@@ -0,0 +1,255 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	appbsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type testSink struct {
+	name     string
+	failing  bool
+	mu       sync.Mutex
+	attempts int
+	events   []*ActionEvent
+}
+
+func (s *testSink) Name() string {
+	return s.name
+}
+
+func (s *testSink) SendActions(ctx context.Context, evt *ActionEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts++
+	if s.failing {
+		return fmt.Errorf("sink is down")
+	}
+	s.events = append(s.events, evt)
+	return nil
+}
+
+func processTestReports(t *testing.T, eng *Engine, count int) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	dir := identity.NewMockDirectory()
+	eng.Directory = &dir
+	eng.Rules = RuleSet{
+		RecordRules: []RecordRuleFunc{
+			alwaysReportRecordRule,
+		},
+	}
+
+	cid1 := syntax.CID("cid123")
+	p1 := appbsky.FeedPost{Text: "some post blah"}
+	p1buf := new(bytes.Buffer)
+	assert.NoError(p1.MarshalCBOR(p1buf))
+
+	for i := 0; i < count; i++ {
+		ident := identity.Identity{
+			DID:    syntax.DID(fmt.Sprintf("did:plc:abc%d", i)),
+			Handle: syntax.Handle("handle.example.com"),
+		}
+		dir.Insert(ident)
+		op := RecordOp{
+			Action:     CreateOp,
+			DID:        ident.DID,
+			Collection: syntax.NSID("app.bsky.feed.post"),
+			RecordKey:  syntax.RecordKey("abc123"),
+			CID:        &cid1,
+			RecordCBOR: p1buf.Bytes(),
+		}
+		assert.NoError(eng.ProcessRecordOp(ctx, op))
+	}
+}
+
+func TestActionSinks(t *testing.T) {
+	assert := assert.New(t)
+	eng := EngineTestFixture()
+	sink := &testSink{name: "test"}
+	eng.Sinks = []ActionSink{sink}
+
+	processTestReports(t, &eng, 1)
+	assert.Equal(1, len(sink.events))
+	evt := sink.events[0]
+	assert.Equal(ActionSubjectRecord, evt.SubjectType)
+	assert.Equal("at://did:plc:abc0/app.bsky.feed.post/abc123", evt.Subject())
+	assert.Equal("cid123", evt.CID)
+	assert.Equal([]ModReport{{ReasonType: ReportReasonOther, Comment: "test report"}}, evt.Reports)
+	assert.Equal([]string{"alwaysReportRecordRule"}, evt.Rules)
+}
+
+func TestActionSinkCircuitBreaker(t *testing.T) {
+	assert := assert.New(t)
+	eng := EngineTestFixture()
+	eng.Config.SinkAttempts = 2
+	eng.Config.QuotaSinkFailureHour = 3
+	// need a report quota for all the events
+	eng.Config.QuotaModReportDay = 100
+	failing := &testSink{name: "failing", failing: true}
+	healthy := &testSink{name: "healthy"}
+	eng.Sinks = []ActionSink{failing, healthy}
+
+	processTestReports(t, &eng, 5)
+	// the failing sink is retried, until its failure quota runs out
+	assert.Equal(3*2, failing.attempts)
+	// other sinks are not affected
+	assert.Equal(5, healthy.attempts)
+	assert.Equal(5, len(healthy.events))
+}
+
+// signals when a delivery starts, then blocks until released
+type blockingSink struct {
+	testSink
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingSink) SendActions(ctx context.Context, evt *ActionEvent) error {
+	s.started <- struct{}{}
+	<-s.release
+	return s.testSink.SendActions(ctx, evt)
+}
+
+func TestActionSinkQueue(t *testing.T) {
+	assert := assert.New(t)
+	eng := EngineTestFixture()
+	eng.Config.SinkQueueSize = 2
+	eng.Config.QuotaModReportDay = 100
+	blocked := &blockingSink{
+		testSink: testSink{name: "blocked"},
+		started:  make(chan struct{}, 10),
+		release:  make(chan struct{}),
+	}
+	eng.Sinks = []ActionSink{blocked}
+	eng.StartSinks()
+
+	// processing is not stalled by the blocked sink: one event is in flight, two are queued, and the rest are dropped
+	processTestReports(t, &eng, 1)
+	<-blocked.started
+	processTestReports(t, &eng, 4)
+	close(blocked.release)
+	eng.StopSinks()
+	assert.Equal(1+2, len(blocked.events))
+
+	// events after stopping are dropped
+	processTestReports(t, &eng, 1)
+	assert.Equal(1+2, len(blocked.events))
+}
+
+func TestActionSinksBackground(t *testing.T) {
+	assert := assert.New(t)
+	eng := EngineTestFixture()
+	eng.Config.QuotaModReportDay = 100
+	sink := &testSink{name: "test"}
+	eng.Sinks = []ActionSink{sink}
+	eng.StartSinks()
+
+	processTestReports(t, &eng, 5)
+	eng.StopSinks()
+	assert.Equal(5, len(sink.events))
+}
+
+func TestWebhookSink(t *testing.T) {
+	assert := assert.New(t)
+	secret := "shh"
+
+	var got ActionEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		sig := WebhookSignature(secret, r.Header.Get("X-Automod-Timestamp"), body)
+		if r.Header.Get("X-Automod-Signature") != sig {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.Unmarshal(body, &got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	evt := ActionEvent{
+		Time:        time.Now(),
+		SubjectType: ActionSubjectAccount,
+		DID:         syntax.DID("did:plc:abc111"),
+		Flags:       []string{"some-flag"},
+		Rules:       []string{"SomeRule"},
+	}
+	sink := WebhookSink{URL: srv.URL, Secret: secret}
+	assert.NoError(sink.SendActions(context.Background(), &evt))
+	assert.Equal(evt.DID, got.DID)
+	assert.Equal(evt.Flags, got.Flags)
+	assert.Equal(evt.Rules, got.Rules)
+
+	bad := WebhookSink{URL: srv.URL, Secret: "wrong"}
+	assert.Error(bad.SendActions(context.Background(), &evt))
+}
+
+func TestSQLAuditSink(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink, err := NewSQLAuditSink(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evt := ActionEvent{
+		Time:          time.Now(),
+		SubjectType:   ActionSubjectRecord,
+		DID:           syntax.DID("did:plc:abc111"),
+		URI:           "at://did:plc:abc111/app.bsky.feed.post/abc123",
+		Labels:        []string{"spam", "rude"},
+		Reports:       []ModReport{{ReasonType: ReportReasonSpam, Comment: "spam!"}},
+		Takedown:      true,
+		BlobTakedowns: []string{"bafkreiblob1", "bafkreiblob2"},
+		Rules:         []string{"SomeRule", "OtherRule"},
+	}
+	assert.NoError(sink.SendActions(ctx, &evt))
+
+	var rows []ActionAuditRecord
+	assert.NoError(db.Find(&rows).Error)
+	assert.Equal(1, len(rows))
+	assert.Equal("did:plc:abc111", rows[0].DID)
+	assert.Equal("spam,rude", rows[0].Labels)
+	assert.Equal("SomeRule,OtherRule", rows[0].Rules)
+	assert.True(rows[0].Takedown)
+	assert.Equal("bafkreiblob1,bafkreiblob2", rows[0].BlobTakedowns)
+	assert.Contains(rows[0].Reports, "spam!")
+}
+
+func TestDiscordContent(t *testing.T) {
+	assert := assert.New(t)
+
+	evt := ActionEvent{
+		SubjectType:   ActionSubjectRecord,
+		DID:           syntax.DID("did:plc:abc111"),
+		URI:           "at://did:plc:abc111/app.bsky.feed.post/abc123",
+		Labels:        []string{"spam"},
+		BlobTakedowns: []string{"bafkreiblob1", "bafkreiblob2"},
+		Rules:         []string{"SomeRule"},
+	}
+	msg := discordContent(&evt)
+	assert.Contains(msg, "`at://did:plc:abc111/app.bsky.feed.post/abc123`")
+	assert.Contains(msg, "Labels: `spam`")
+	assert.Contains(msg, "Blob takedowns: `bafkreiblob1, bafkreiblob2`")
+	assert.Contains(msg, "Rules: `SomeRule`")
+}
@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Action sink which POSTs each action event as JSON to a generic webhook URL.
+//
+// If a secret is configured, requests are signed: the "X-Automod-Signature" header is "sha256=" followed by the hex-encoded HMAC-SHA256 (keyed with the secret) of the "X-Automod-Timestamp" header value (unix seconds), a period ("."), and the request body. Receivers should verify the signature, and reject stale timestamps.
+type WebhookSink struct {
+	// name of the sink; defaults to "webhook"
+	SinkName string
+	URL      string
+	Secret   string
+	// optional; defaults to a client with a 10 second timeout
+	Client *http.Client
+}
+
+func (s *WebhookSink) Name() string {
+	if s.SinkName != "" {
+		return s.SinkName
+	}
+	return "webhook"
+}
+
+// Computes the signature header value for a webhook request.
+func WebhookSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *WebhookSink) SendActions(ctx context.Context, evt *ActionEvent) error {
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-Automod-Timestamp", ts)
+		req.Header.Set("X-Automod-Signature", WebhookSignature(s.Secret, ts, body))
+	}
+	return doSinkRequest(s.Client, req)
+}
+
+// HTTP client for sinks with no client configured. Unlike http.DefaultClient, requests time out.
+var defaultSinkClient = &http.Client{Timeout: 10 * time.Second}
+
+// Sends an HTTP request for an action sink, treating any non-2xx status as an error.
+func doSinkRequest(client *http.Client, req *http.Request) error {
+	if client == nil {
+		client = defaultSinkClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("action sink request failed. status=%d", resp.StatusCode)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"fmt"
 	"log/slog"
 	"time"
 
@@ -45,13 +46,14 @@ func EngineTestFixture() Engine {
 	cache := cachestore.NewMemCacheStore(10, time.Hour)
 	flags := flagstore.NewMemFlagStore()
 	sets := setstore.NewMemSetStore()
-	sets.Sets["bad-hashtags"] = make(map[string]bool)
-	sets.Sets["bad-hashtags"]["slur"] = true
-	sets.Sets["bad-words"] = make(map[string]bool)
-	sets.Sets["bad-words"]["hardr"] = true
-	sets.Sets["bad-words"]["hardestr"] = true
-	sets.Sets["worst-words"] = make(map[string]bool)
-	sets.Sets["worst-words"]["hardestr"] = true
+	err := sets.Load(map[string]setstore.SetDef{
+		"bad-hashtags": {Values: []string{"slur"}},
+		"bad-words":    {Values: []string{"hardr", "hardestr"}},
+		"worst-words":  {Values: []string{"hardestr"}},
+	})
+	if err != nil {
+		panic(fmt.Sprintf("loading fixture sets: %v", err))
+	}
 	dir := identity.NewMockDirectory()
 	id1 := identity.Identity{
 		DID:    syntax.DID("did:plc:abc111"),
@@ -0,0 +1,4 @@
+// Interface for a local cache of the app.bsky social graph (follow and block records), with separate implementations using redis, an embedded pebble database, and in-process memory.
+//
+// The graph is built by consuming follow and block records from the firehose, so rules can check relationships between accounts without AppView requests. It only includes records seen since the cache was started (or backfilled), so absence of an edge is not proof the relationship doesn't exist.
+package graphstore
@@ -0,0 +1,52 @@
+package graphstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+)
+
+// Types of edge in the graph, each corresponding to a record type.
+const (
+	// app.bsky.graph.follow
+	KindFollow = "follow"
+	// app.bsky.graph.block
+	KindBlock = "block"
+)
+
+// Returns the edge kind for a record collection, or an empty string if records in the collection are not graph edges.
+func KindForCollection(collection string) string {
+	switch collection {
+	case "app.bsky.graph.follow":
+		return KindFollow
+	case "app.bsky.graph.block":
+		return KindBlock
+	}
+	return ""
+}
+
+// A single directed edge: the actor follows (or blocks) the subject.
+type Edge struct {
+	Kind    string
+	Actor   syntax.DID
+	Subject syntax.DID
+	// record key of the follow or block record in the actor's repo, used to remove the edge when the record is deleted
+	RecordKey string
+	// when the actor's account was created, if known (zero otherwise). Used to count followers which are new accounts
+	ActorCreatedAt time.Time
+}
+
+type GraphStore interface {
+	AddEdge(ctx context.Context, edge Edge) error
+	// Removes the edge created by the given record. Records which aren't known are ignored
+	RemoveEdge(ctx context.Context, kind string, actor syntax.DID, rkey string) error
+	// Checks if there is an edge from actor to subject
+	HasEdge(ctx context.Context, kind string, actor, subject syntax.DID) (bool, error)
+	// Counts edges to the subject (eg, followers)
+	CountIncoming(ctx context.Context, kind string, subject syntax.DID) (int, error)
+	// Counts edges from the actor (eg, follows)
+	CountOutgoing(ctx context.Context, kind string, actor syntax.DID) (int, error)
+	// Counts edges to the subject from accounts created at or after the given time (eg, followers which are new accounts). Edges from accounts with unknown creation time are not counted
+	CountIncomingFromNewAccounts(ctx context.Context, kind string, subject syntax.DID, createdSince time.Time) (int, error)
+}
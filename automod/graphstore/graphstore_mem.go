@@ -0,0 +1,137 @@
+package graphstore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+)
+
+// In-process graph cache, mostly for tests and small deployments. Safe for concurrent use.
+type MemGraphStore struct {
+	lk    sync.Mutex
+	kinds map[string]*memGraph
+}
+
+type memGraph struct {
+	// actor to record key to subject, for deletes
+	records map[syntax.DID]map[string]syntax.DID
+	// actor to subject to number of records (duplicate records are possible)
+	outgoing map[syntax.DID]map[syntax.DID]int
+	// subject to actor to actor account creation time
+	incoming map[syntax.DID]map[syntax.DID]time.Time
+}
+
+func NewMemGraphStore() *MemGraphStore {
+	return &MemGraphStore{
+		kinds: make(map[string]*memGraph),
+	}
+}
+
+// lock must be held
+func (s *MemGraphStore) graph(kind string) *memGraph {
+	g, ok := s.kinds[kind]
+	if !ok {
+		g = &memGraph{
+			records:  make(map[syntax.DID]map[string]syntax.DID),
+			outgoing: make(map[syntax.DID]map[syntax.DID]int),
+			incoming: make(map[syntax.DID]map[syntax.DID]time.Time),
+		}
+		s.kinds[kind] = g
+	}
+	return g
+}
+
+func (s *MemGraphStore) AddEdge(ctx context.Context, edge Edge) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	g := s.graph(edge.Kind)
+
+	recs, ok := g.records[edge.Actor]
+	if !ok {
+		recs = make(map[string]syntax.DID)
+		g.records[edge.Actor] = recs
+	}
+	if _, ok := recs[edge.RecordKey]; ok {
+		// already seen this record
+		return nil
+	}
+	recs[edge.RecordKey] = edge.Subject
+
+	out, ok := g.outgoing[edge.Actor]
+	if !ok {
+		out = make(map[syntax.DID]int)
+		g.outgoing[edge.Actor] = out
+	}
+	out[edge.Subject]++
+
+	in, ok := g.incoming[edge.Subject]
+	if !ok {
+		in = make(map[syntax.DID]time.Time)
+		g.incoming[edge.Subject] = in
+	}
+	if _, ok := in[edge.Actor]; !ok || !edge.ActorCreatedAt.IsZero() {
+		in[edge.Actor] = edge.ActorCreatedAt
+	}
+	return nil
+}
+
+func (s *MemGraphStore) RemoveEdge(ctx context.Context, kind string, actor syntax.DID, rkey string) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	g := s.graph(kind)
+
+	subject, ok := g.records[actor][rkey]
+	if !ok {
+		return nil
+	}
+	delete(g.records[actor], rkey)
+	if len(g.records[actor]) == 0 {
+		delete(g.records, actor)
+	}
+	g.outgoing[actor][subject]--
+	if g.outgoing[actor][subject] > 0 {
+		// another record for the same edge remains
+		return nil
+	}
+	delete(g.outgoing[actor], subject)
+	if len(g.outgoing[actor]) == 0 {
+		delete(g.outgoing, actor)
+	}
+	delete(g.incoming[subject], actor)
+	if len(g.incoming[subject]) == 0 {
+		delete(g.incoming, subject)
+	}
+	return nil
+}
+
+func (s *MemGraphStore) HasEdge(ctx context.Context, kind string, actor, subject syntax.DID) (bool, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return s.graph(kind).outgoing[actor][subject] > 0, nil
+}
+
+func (s *MemGraphStore) CountIncoming(ctx context.Context, kind string, subject syntax.DID) (int, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return len(s.graph(kind).incoming[subject]), nil
+}
+
+func (s *MemGraphStore) CountOutgoing(ctx context.Context, kind string, actor syntax.DID) (int, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return len(s.graph(kind).outgoing[actor]), nil
+}
+
+func (s *MemGraphStore) CountIncomingFromNewAccounts(ctx context.Context, kind string, subject syntax.DID, createdSince time.Time) (int, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	count := 0
+	for _, created := range s.graph(kind).incoming[subject] {
+		if !created.IsZero() && !created.Before(createdSince) {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,249 @@
+package graphstore
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+)
+
+// Graph cache in an embedded pebble database, for single-process deployments. Writes are serialized; reads are concurrent.
+//
+// Keys (DIDs and record keys never contain "/"):
+//
+//	rec/<kind>/<actor>/<rkey>: subject
+//	out/<kind>/<actor>/<subject>: number of records for the edge (uint64)
+//	in/<kind>/<subject>/<actor>: actor account creation time (unix millis, uint64; zero if unknown)
+//	new/<kind>/<subject>/<created millis, big-endian><actor>: empty, only for edges with known actor creation time
+//	count-in/<kind>/<subject> and count-out/<kind>/<actor>: edge counts (uint64)
+type PebbleGraphStore struct {
+	db *pebble.DB
+	// serializes writes, which read-modify-write counts
+	lk sync.Mutex
+}
+
+func OpenPebbleGraphStore(path string) (*PebbleGraphStore, error) {
+	db, err := pebble.Open(path, &pebble.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("opening graph store: %w", err)
+	}
+	return &PebbleGraphStore{db: db}, nil
+}
+
+// Opens a store which is held entirely in memory, for tests.
+func OpenPebbleMemGraphStore() (*PebbleGraphStore, error) {
+	db, err := pebble.Open("", &pebble.Options{FS: vfs.NewMem()})
+	if err != nil {
+		return nil, fmt.Errorf("opening graph store: %w", err)
+	}
+	return &PebbleGraphStore{db: db}, nil
+}
+
+func (s *PebbleGraphStore) Close() error {
+	return s.db.Close()
+}
+
+func recKey(kind string, actor syntax.DID, rkey string) []byte {
+	return []byte(fmt.Sprintf("rec/%s/%s/%s", kind, actor, rkey))
+}
+
+func outKey(kind string, actor, subject syntax.DID) []byte {
+	return []byte(fmt.Sprintf("out/%s/%s/%s", kind, actor, subject))
+}
+
+func inKey(kind string, subject, actor syntax.DID) []byte {
+	return []byte(fmt.Sprintf("in/%s/%s/%s", kind, subject, actor))
+}
+
+func newPrefix(kind string, subject syntax.DID) []byte {
+	return []byte(fmt.Sprintf("new/%s/%s/", kind, subject))
+}
+
+func newKey(kind string, subject, actor syntax.DID, created time.Time) []byte {
+	k := newPrefix(kind, subject)
+	k = binary.BigEndian.AppendUint64(k, uint64(created.UnixMilli()))
+	return append(k, actor...)
+}
+
+func countInKey(kind string, subject syntax.DID) []byte {
+	return []byte(fmt.Sprintf("count-in/%s/%s", kind, subject))
+}
+
+func countOutKey(kind string, actor syntax.DID) []byte {
+	return []byte(fmt.Sprintf("count-out/%s/%s", kind, actor))
+}
+
+// returns nil (and no error) if the key doesn't exist
+func (s *PebbleGraphStore) get(key []byte) ([]byte, error) {
+	val, closer, err := s.db.Get(key)
+	if errors.Is(err, pebble.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+	return append([]byte{}, val...), nil
+}
+
+func (s *PebbleGraphStore) getUint(key []byte) (uint64, error) {
+	val, err := s.get(key)
+	if err != nil || val == nil {
+		return 0, err
+	}
+	if len(val) != 8 {
+		return 0, fmt.Errorf("corrupt graph store value: %s", key)
+	}
+	return binary.BigEndian.Uint64(val), nil
+}
+
+// adds delta to a uint64 value in the batch, deleting it at zero. write lock must be held
+func (s *PebbleGraphStore) addUint(b *pebble.Batch, key []byte, delta int) error {
+	v, err := s.getUint(key)
+	if err != nil {
+		return err
+	}
+	n := int64(v) + int64(delta)
+	if n <= 0 {
+		return b.Delete(key, nil)
+	}
+	return b.Set(key, binary.BigEndian.AppendUint64(nil, uint64(n)), nil)
+}
+
+func (s *PebbleGraphStore) AddEdge(ctx context.Context, edge Edge) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	rk := recKey(edge.Kind, edge.Actor, edge.RecordKey)
+	existing, err := s.get(rk)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		// already seen this record
+		return nil
+	}
+	b := s.db.NewBatch()
+	defer b.Close()
+	if err := b.Set(rk, []byte(edge.Subject), nil); err != nil {
+		return err
+	}
+	ok := outKey(edge.Kind, edge.Actor, edge.Subject)
+	records, err := s.getUint(ok)
+	if err != nil {
+		return err
+	}
+	if err := b.Set(ok, binary.BigEndian.AppendUint64(nil, records+1), nil); err != nil {
+		return err
+	}
+	if records > 0 {
+		// another record for the same edge already exists
+		return b.Commit(pebble.NoSync)
+	}
+	var created int64
+	if !edge.ActorCreatedAt.IsZero() {
+		created = edge.ActorCreatedAt.UnixMilli()
+		if err := b.Set(newKey(edge.Kind, edge.Subject, edge.Actor, edge.ActorCreatedAt), nil, nil); err != nil {
+			return err
+		}
+	}
+	if err := b.Set(inKey(edge.Kind, edge.Subject, edge.Actor), binary.BigEndian.AppendUint64(nil, uint64(created)), nil); err != nil {
+		return err
+	}
+	if err := s.addUint(b, countInKey(edge.Kind, edge.Subject), 1); err != nil {
+		return err
+	}
+	if err := s.addUint(b, countOutKey(edge.Kind, edge.Actor), 1); err != nil {
+		return err
+	}
+	return b.Commit(pebble.NoSync)
+}
+
+func (s *PebbleGraphStore) RemoveEdge(ctx context.Context, kind string, actor syntax.DID, rkey string) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	rk := recKey(kind, actor, rkey)
+	val, err := s.get(rk)
+	if err != nil || val == nil {
+		return err
+	}
+	subject := syntax.DID(val)
+	b := s.db.NewBatch()
+	defer b.Close()
+	if err := b.Delete(rk, nil); err != nil {
+		return err
+	}
+	ok := outKey(kind, actor, subject)
+	records, err := s.getUint(ok)
+	if err != nil {
+		return err
+	}
+	if err := s.addUint(b, ok, -1); err != nil {
+		return err
+	}
+	if records > 1 {
+		// another record for the same edge remains
+		return b.Commit(pebble.NoSync)
+	}
+	ik := inKey(kind, subject, actor)
+	created, err := s.getUint(ik)
+	if err != nil {
+		return err
+	}
+	if created != 0 {
+		if err := b.Delete(newKey(kind, subject, actor, time.UnixMilli(int64(created))), nil); err != nil {
+			return err
+		}
+	}
+	if err := b.Delete(ik, nil); err != nil {
+		return err
+	}
+	if err := s.addUint(b, countInKey(kind, subject), -1); err != nil {
+		return err
+	}
+	if err := s.addUint(b, countOutKey(kind, actor), -1); err != nil {
+		return err
+	}
+	return b.Commit(pebble.NoSync)
+}
+
+func (s *PebbleGraphStore) HasEdge(ctx context.Context, kind string, actor, subject syntax.DID) (bool, error) {
+	val, err := s.get(outKey(kind, actor, subject))
+	return val != nil, err
+}
+
+func (s *PebbleGraphStore) CountIncoming(ctx context.Context, kind string, subject syntax.DID) (int, error) {
+	n, err := s.getUint(countInKey(kind, subject))
+	return int(n), err
+}
+
+func (s *PebbleGraphStore) CountOutgoing(ctx context.Context, kind string, actor syntax.DID) (int, error) {
+	n, err := s.getUint(countOutKey(kind, actor))
+	return int(n), err
+}
+
+func (s *PebbleGraphStore) CountIncomingFromNewAccounts(ctx context.Context, kind string, subject syntax.DID, createdSince time.Time) (int, error) {
+	prefix := newPrefix(kind, subject)
+	lower := binary.BigEndian.AppendUint64(append([]byte{}, prefix...), uint64(max(createdSince.UnixMilli(), 0)))
+	// prefix with the last byte incremented ("/" to "0")
+	upper := append([]byte{}, prefix...)
+	upper[len(upper)-1]++
+	iter, err := s.db.NewIterWithContext(ctx, &pebble.IterOptions{LowerBound: lower, UpperBound: upper})
+	if err != nil {
+		return 0, err
+	}
+	defer iter.Close()
+	count := 0
+	for iter.First(); iter.Valid(); iter.Next() {
+		count++
+	}
+	return count, iter.Error()
+}
@@ -0,0 +1,126 @@
+package graphstore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var redisGraphPrefix string = "graph/"
+
+// Graph cache backed by redis, shared between processes. Per edge kind:
+//
+//	graph/<kind>/rec/<actor>: hash of record key to subject
+//	graph/<kind>/out/<actor>: hash of subject to number of records for the edge
+//	graph/<kind>/in/<subject>: sorted set of actors, scored by actor account creation time (unix millis; zero if unknown)
+type RedisGraphStore struct {
+	Client *redis.Client
+}
+
+func NewRedisGraphStore(redisURL string) (*RedisGraphStore, error) {
+	ctx := context.Background()
+	opt, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	rdb := redis.NewClient(opt)
+	// check redis connection
+	_, err = rdb.Ping(ctx).Result()
+	if err != nil {
+		return nil, err
+	}
+	rgs := RedisGraphStore{
+		Client: rdb,
+	}
+	return &rgs, nil
+}
+
+func redisRecKey(kind string, actor syntax.DID) string {
+	return fmt.Sprintf("%s%s/rec/%s", redisGraphPrefix, kind, actor)
+}
+
+func redisOutKey(kind string, actor syntax.DID) string {
+	return fmt.Sprintf("%s%s/out/%s", redisGraphPrefix, kind, actor)
+}
+
+func redisInKey(kind string, subject syntax.DID) string {
+	return fmt.Sprintf("%s%s/in/%s", redisGraphPrefix, kind, subject)
+}
+
+func (s *RedisGraphStore) AddEdge(ctx context.Context, edge Edge) error {
+	added, err := s.Client.HSetNX(ctx, redisRecKey(edge.Kind, edge.Actor), edge.RecordKey, edge.Subject.String()).Result()
+	if err != nil {
+		return err
+	}
+	if !added {
+		// already seen this record
+		return nil
+	}
+	records, err := s.Client.HIncrBy(ctx, redisOutKey(edge.Kind, edge.Actor), edge.Subject.String(), 1).Result()
+	if err != nil {
+		return err
+	}
+	if records > 1 {
+		// another record for the same edge already exists
+		return nil
+	}
+	var created int64
+	if !edge.ActorCreatedAt.IsZero() {
+		created = edge.ActorCreatedAt.UnixMilli()
+	}
+	return s.Client.ZAdd(ctx, redisInKey(edge.Kind, edge.Subject), redis.Z{Score: float64(created), Member: edge.Actor.String()}).Err()
+}
+
+func (s *RedisGraphStore) RemoveEdge(ctx context.Context, kind string, actor syntax.DID, rkey string) error {
+	rk := redisRecKey(kind, actor)
+	subject, err := s.Client.HGet(ctx, rk, rkey).Result()
+	if err == redis.Nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.Client.HDel(ctx, rk, rkey).Err(); err != nil {
+		return err
+	}
+	ok := redisOutKey(kind, actor)
+	records, err := s.Client.HIncrBy(ctx, ok, subject, -1).Result()
+	if err != nil {
+		return err
+	}
+	if records > 0 {
+		// another record for the same edge remains
+		return nil
+	}
+	multi := s.Client.TxPipeline()
+	multi.HDel(ctx, ok, subject)
+	multi.ZRem(ctx, redisInKey(kind, syntax.DID(subject)), actor.String())
+	_, err = multi.Exec(ctx)
+	return err
+}
+
+func (s *RedisGraphStore) HasEdge(ctx context.Context, kind string, actor, subject syntax.DID) (bool, error) {
+	return s.Client.HExists(ctx, redisOutKey(kind, actor), subject.String()).Result()
+}
+
+func (s *RedisGraphStore) CountIncoming(ctx context.Context, kind string, subject syntax.DID) (int, error) {
+	n, err := s.Client.ZCard(ctx, redisInKey(kind, subject)).Result()
+	return int(n), err
+}
+
+func (s *RedisGraphStore) CountOutgoing(ctx context.Context, kind string, actor syntax.DID) (int, error) {
+	n, err := s.Client.HLen(ctx, redisOutKey(kind, actor)).Result()
+	return int(n), err
+}
+
+func (s *RedisGraphStore) CountIncomingFromNewAccounts(ctx context.Context, kind string, subject syntax.DID, createdSince time.Time) (int, error) {
+	// unknown creation times are stored as zero, and never counted
+	since := max(createdSince.UnixMilli(), 1)
+	n, err := s.Client.ZCount(ctx, redisInKey(kind, subject), strconv.FormatInt(since, 10), "+inf").Result()
+	return int(n), err
+}
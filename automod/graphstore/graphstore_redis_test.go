@@ -0,0 +1,15 @@
+package graphstore
+
+import (
+	"testing"
+)
+
+func TestRedisGraphStore(t *testing.T) {
+	t.Skip("live test, need redis running locally")
+
+	gs, err := NewRedisGraphStore("redis://localhost:6379/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testGraphStore(t, gs)
+}
@@ -0,0 +1,107 @@
+package graphstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testGraphStore(t *testing.T, gs GraphStore) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	now := time.Now()
+	alice := syntax.DID("did:plc:alice")
+	bob := syntax.DID("did:plc:bob")
+	carol := syntax.DID("did:plc:carol")
+	newbie := syntax.DID("did:plc:newbie")
+
+	assert.NoError(gs.AddEdge(ctx, Edge{Kind: KindFollow, Actor: alice, Subject: bob, RecordKey: "a1", ActorCreatedAt: now.Add(-365 * 24 * time.Hour)}))
+	assert.NoError(gs.AddEdge(ctx, Edge{Kind: KindFollow, Actor: bob, Subject: alice, RecordKey: "b1"}))
+	assert.NoError(gs.AddEdge(ctx, Edge{Kind: KindFollow, Actor: carol, Subject: bob, RecordKey: "c1"}))
+	assert.NoError(gs.AddEdge(ctx, Edge{Kind: KindFollow, Actor: newbie, Subject: bob, RecordKey: "n1", ActorCreatedAt: now.Add(-time.Hour)}))
+	assert.NoError(gs.AddEdge(ctx, Edge{Kind: KindBlock, Actor: bob, Subject: newbie, RecordKey: "b2"}))
+	// duplicate record is ignored
+	assert.NoError(gs.AddEdge(ctx, Edge{Kind: KindFollow, Actor: carol, Subject: bob, RecordKey: "c1"}))
+
+	ok, err := gs.HasEdge(ctx, KindFollow, alice, bob)
+	assert.NoError(err)
+	assert.True(ok)
+	ok, err = gs.HasEdge(ctx, KindFollow, bob, carol)
+	assert.NoError(err)
+	assert.False(ok)
+	ok, err = gs.HasEdge(ctx, KindBlock, bob, newbie)
+	assert.NoError(err)
+	assert.True(ok)
+	ok, err = gs.HasEdge(ctx, KindFollow, bob, newbie)
+	assert.NoError(err)
+	assert.False(ok)
+
+	n, err := gs.CountIncoming(ctx, KindFollow, bob)
+	assert.NoError(err)
+	assert.Equal(3, n)
+	n, err = gs.CountOutgoing(ctx, KindFollow, bob)
+	assert.NoError(err)
+	assert.Equal(1, n)
+	n, err = gs.CountIncomingFromNewAccounts(ctx, KindFollow, bob, now.Add(-24*time.Hour))
+	assert.NoError(err)
+	assert.Equal(1, n)
+	n, err = gs.CountIncomingFromNewAccounts(ctx, KindFollow, bob, time.Time{})
+	assert.NoError(err)
+	assert.Equal(2, n)
+
+	// a second record for the same edge; the edge remains until both are deleted
+	assert.NoError(gs.AddEdge(ctx, Edge{Kind: KindFollow, Actor: newbie, Subject: bob, RecordKey: "n2", ActorCreatedAt: now.Add(-time.Hour)}))
+	n, err = gs.CountIncoming(ctx, KindFollow, bob)
+	assert.NoError(err)
+	assert.Equal(3, n)
+	assert.NoError(gs.RemoveEdge(ctx, KindFollow, newbie, "n1"))
+	ok, err = gs.HasEdge(ctx, KindFollow, newbie, bob)
+	assert.NoError(err)
+	assert.True(ok)
+	assert.NoError(gs.RemoveEdge(ctx, KindFollow, newbie, "n2"))
+	ok, err = gs.HasEdge(ctx, KindFollow, newbie, bob)
+	assert.NoError(err)
+	assert.False(ok)
+	n, err = gs.CountIncomingFromNewAccounts(ctx, KindFollow, bob, now.Add(-24*time.Hour))
+	assert.NoError(err)
+	assert.Equal(0, n)
+	n, err = gs.CountIncoming(ctx, KindFollow, bob)
+	assert.NoError(err)
+	assert.Equal(2, n)
+	n, err = gs.CountOutgoing(ctx, KindFollow, newbie)
+	assert.NoError(err)
+	assert.Equal(0, n)
+
+	// unknown records are ignored
+	assert.NoError(gs.RemoveEdge(ctx, KindFollow, alice, "unknown"))
+	assert.NoError(gs.RemoveEdge(ctx, KindFollow, newbie, "n1"))
+	n, err = gs.CountIncoming(ctx, KindFollow, bob)
+	assert.NoError(err)
+	assert.Equal(2, n)
+}
+
+func TestMemGraphStore(t *testing.T) {
+	testGraphStore(t, NewMemGraphStore())
+}
+
+func TestPebbleGraphStore(t *testing.T) {
+	gs, err := OpenPebbleMemGraphStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gs.Close()
+	testGraphStore(t, gs)
+}
+
+func TestKindForCollection(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(KindFollow, KindForCollection("app.bsky.graph.follow"))
+	assert.Equal(KindBlock, KindForCollection("app.bsky.graph.block"))
+	assert.Equal("", KindForCollection("app.bsky.feed.post"))
+}
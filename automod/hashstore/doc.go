@@ -0,0 +1,4 @@
+// Interface for indexing 64-bit similarity hashes (eg, perceptual image hashes), with lookups by Hamming distance, and separate implementations using redis and in-process memory.
+//
+// Hashes are indexed by splitting them in to bands: by the pigeonhole principle, two hashes within a Hamming distance less than the number of bands must be identical in at least one band. Lookups for larger distances are supported, but may miss some matches.
+package hashstore
@@ -0,0 +1,55 @@
+package hashstore
+
+import (
+	"context"
+	"math/bits"
+	"time"
+)
+
+// Default number of bands hashes are split in to. Lookups with a max distance below this are exhaustive.
+const DefaultBands = 6
+
+// A single indexed hash.
+type Entry struct {
+	Hash uint64 `json:"hash"`
+	// Identifier of the hashed content (eg, a blob CID, or a description for known-bad hashes). An entry with the same hash and ID replaces an existing one
+	ID string `json:"id"`
+	// When the entry was added
+	Time time.Time `json:"time"`
+}
+
+type Match struct {
+	Entry
+	// Hamming distance from the looked-up hash
+	Distance int `json:"distance"`
+}
+
+// HashStore is an index of 64-bit hashes, in separate namespaces (eg, for different hash algorithms, or a set of known-bad hashes).
+//
+// Entries are kept for a retention period (configured per implementation), after which they may expire.
+type HashStore interface {
+	Add(ctx context.Context, namespace string, entry Entry) error
+	// Returns entries within maxDistance of the hash, added at or after "since" (the zero time means any), sorted by distance
+	Lookup(ctx context.Context, namespace string, hash uint64, maxDistance int, since time.Time) ([]Match, error)
+}
+
+// Hamming distance between two hashes (the number of bits which differ).
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Splits a hash in to the given number of bands, as near to equal width as possible. Each band value is returned with its index in the high bits, so values from different bands never collide.
+func bandValues(hash uint64, bands int) []uint64 {
+	out := make([]uint64, bands)
+	offset := 0
+	for i := 0; i < bands; i++ {
+		width := 64 / bands
+		if i < 64%bands {
+			width++
+		}
+		mask := uint64(1)<<width - 1
+		out[i] = uint64(i)<<56 | (hash>>offset)&mask
+		offset += width
+	}
+	return out
+}
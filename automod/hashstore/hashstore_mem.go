@@ -0,0 +1,127 @@
+package hashstore
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// In-process hash index. Safe for concurrent use.
+type MemHashStore struct {
+	Bands int
+	// How long entries are kept (relative to the newest entry in the namespace). Zero means forever
+	Retention time.Duration
+
+	lk         sync.Mutex
+	namespaces map[string]*memIndex
+}
+
+type entryKey struct {
+	hash uint64
+	id   string
+}
+
+type memIndex struct {
+	entries map[entryKey]Entry
+	// band value to entries
+	bands map[uint64]map[entryKey]bool
+	// newest entry time, and when expired entries were last dropped
+	latest    time.Time
+	lastPrune time.Time
+}
+
+func NewMemHashStore(retention time.Duration) *MemHashStore {
+	return &MemHashStore{
+		Bands:      DefaultBands,
+		Retention:  retention,
+		namespaces: make(map[string]*memIndex),
+	}
+}
+
+func (s *MemHashStore) Add(ctx context.Context, namespace string, entry Entry) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	idx, ok := s.namespaces[namespace]
+	if !ok {
+		idx = &memIndex{
+			entries: make(map[entryKey]Entry),
+			bands:   make(map[uint64]map[entryKey]bool),
+		}
+		s.namespaces[namespace] = idx
+	}
+	k := entryKey{hash: entry.Hash, id: entry.ID}
+	idx.entries[k] = entry
+	for _, bv := range bandValues(entry.Hash, s.Bands) {
+		m, ok := idx.bands[bv]
+		if !ok {
+			m = make(map[entryKey]bool)
+			idx.bands[bv] = m
+		}
+		m[k] = true
+	}
+	if entry.Time.After(idx.latest) {
+		idx.latest = entry.Time
+	}
+	// scanning every entry is expensive, so only prune occasionally
+	if s.Retention > 0 && idx.latest.Sub(idx.lastPrune) > s.Retention/10 {
+		s.prune(idx)
+		idx.lastPrune = idx.latest
+	}
+	return nil
+}
+
+// drops expired entries. lock must be held
+func (s *MemHashStore) prune(idx *memIndex) {
+	oldest := idx.latest.Add(-s.Retention)
+	for k, e := range idx.entries {
+		if !e.Time.Before(oldest) {
+			continue
+		}
+		delete(idx.entries, k)
+		for _, bv := range bandValues(k.hash, s.Bands) {
+			delete(idx.bands[bv], k)
+			if len(idx.bands[bv]) == 0 {
+				delete(idx.bands, bv)
+			}
+		}
+	}
+}
+
+func (s *MemHashStore) Lookup(ctx context.Context, namespace string, hash uint64, maxDistance int, since time.Time) ([]Match, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	out := []Match{}
+	idx, ok := s.namespaces[namespace]
+	if !ok {
+		return out, nil
+	}
+	seen := make(map[entryKey]bool)
+	for _, bv := range bandValues(hash, s.Bands) {
+		for k := range idx.bands[bv] {
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			e := idx.entries[k]
+			if e.Time.Before(since) {
+				continue
+			}
+			if d := Distance(hash, k.hash); d <= maxDistance {
+				out = append(out, Match{Entry: e, Distance: d})
+			}
+		}
+	}
+	sortMatches(out)
+	return out, nil
+}
+
+// sorts by distance, then newest first
+func sortMatches(l []Match) {
+	sort.Slice(l, func(i, j int) bool {
+		if l[i].Distance != l[j].Distance {
+			return l[i].Distance < l[j].Distance
+		}
+		return l[i].Time.After(l[j].Time)
+	})
+}
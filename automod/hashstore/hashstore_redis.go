@@ -0,0 +1,107 @@
+package hashstore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var redisHashesPrefix string = "hashes/"
+
+// Hash index backed by redis. Each band value is a sorted set of entries (as "<hash>/<id>"), scored by entry time.
+type RedisHashStore struct {
+	Client *redis.Client
+	Bands  int
+	// How long entries are kept. Zero means forever
+	Retention time.Duration
+}
+
+func NewRedisHashStore(redisURL string, retention time.Duration) (*RedisHashStore, error) {
+	ctx := context.Background()
+	opt, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	rdb := redis.NewClient(opt)
+	// check redis connection
+	_, err = rdb.Ping(ctx).Result()
+	if err != nil {
+		return nil, err
+	}
+	rhs := RedisHashStore{
+		Client:    rdb,
+		Bands:     DefaultBands,
+		Retention: retention,
+	}
+	return &rhs, nil
+}
+
+func bandKey(namespace string, bv uint64) string {
+	return fmt.Sprintf("%s%s/%x", redisHashesPrefix, namespace, bv)
+}
+
+func (s *RedisHashStore) Add(ctx context.Context, namespace string, entry Entry) error {
+	member := fmt.Sprintf("%016x/%s", entry.Hash, entry.ID)
+	score := float64(entry.Time.UnixMilli()) / 1000
+
+	// all bands in a single redis round-trip
+	multi := s.Client.Pipeline()
+	for _, bv := range bandValues(entry.Hash, s.Bands) {
+		key := bandKey(namespace, bv)
+		multi.ZAdd(ctx, key, redis.Z{Score: score, Member: member})
+		if s.Retention > 0 {
+			oldest := float64(entry.Time.Add(-s.Retention).UnixMilli()) / 1000
+			multi.ZRemRangeByScore(ctx, key, "-inf", fmt.Sprintf("(%f", oldest))
+			multi.Expire(ctx, key, s.Retention)
+		}
+	}
+	_, err := multi.Exec(ctx)
+	return err
+}
+
+func (s *RedisHashStore) Lookup(ctx context.Context, namespace string, hash uint64, maxDistance int, since time.Time) ([]Match, error) {
+	minScore := "-inf"
+	if !since.IsZero() {
+		minScore = fmt.Sprintf("%f", float64(since.UnixMilli())/1000)
+	}
+	multi := s.Client.Pipeline()
+	var cmds []*redis.ZSliceCmd
+	for _, bv := range bandValues(hash, s.Bands) {
+		cmds = append(cmds, multi.ZRangeByScoreWithScores(ctx, bandKey(namespace, bv), &redis.ZRangeBy{Min: minScore, Max: "+inf"}))
+	}
+	if _, err := multi.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	out := []Match{}
+	seen := make(map[string]bool)
+	for _, cmd := range cmds {
+		for _, z := range cmd.Val() {
+			member, ok := z.Member.(string)
+			if !ok || seen[member] {
+				continue
+			}
+			seen[member] = true
+			hexHash, id, ok := strings.Cut(member, "/")
+			if !ok {
+				continue
+			}
+			h, err := strconv.ParseUint(hexHash, 16, 64)
+			if err != nil {
+				continue
+			}
+			d := Distance(hash, h)
+			if d > maxDistance {
+				continue
+			}
+			t := time.UnixMilli(int64(z.Score * 1000))
+			out = append(out, Match{Entry: Entry{Hash: h, ID: id, Time: t}, Distance: d})
+		}
+	}
+	sortMatches(out)
+	return out, nil
+}
@@ -0,0 +1,31 @@
+package hashstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisHashStore(t *testing.T) {
+	t.Skip("live test, need redis running locally")
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	hs, err := NewRedisHashStore("redis://localhost:6379/0", time.Hour)
+	if err != nil {
+		t.Fail()
+	}
+
+	base := uint64(0x0123456789abcdef)
+	now := time.Now()
+	assert.NoError(hs.Add(ctx, "test-dhash", Entry{Hash: base, ID: "cid1", Time: now}))
+	assert.NoError(hs.Add(ctx, "test-dhash", Entry{Hash: base ^ 0x11, ID: "cid2", Time: now}))
+
+	matches, err := hs.Lookup(ctx, "test-dhash", base, 3, now.Add(-time.Minute))
+	assert.NoError(err)
+	assert.Equal(2, len(matches))
+	assert.Equal("cid1", matches[0].ID)
+	assert.Equal(2, matches[1].Distance)
+}
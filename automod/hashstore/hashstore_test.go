@@ -0,0 +1,68 @@
+package hashstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBandValues(t *testing.T) {
+	assert := assert.New(t)
+
+	bv := bandValues(0xffffffffffffffff, 6)
+	assert.Equal(6, len(bv))
+	total := 0
+	for i, v := range bv {
+		assert.Equal(uint64(i), v>>56)
+		for w := v & (1<<56 - 1); w > 0; w >>= 1 {
+			total++
+		}
+	}
+	assert.Equal(64, total)
+}
+
+func TestMemHashStore(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	hs := NewMemHashStore(24 * time.Hour)
+	base := uint64(0x0123456789abcdef)
+
+	assert.NoError(hs.Add(ctx, "dhash", Entry{Hash: base, ID: "cid1", Time: now}))
+	// five bits different, spread across bands
+	assert.NoError(hs.Add(ctx, "dhash", Entry{Hash: base ^ 0x8000100020004001, ID: "cid2", Time: now.Add(time.Hour)}))
+	// very different
+	assert.NoError(hs.Add(ctx, "dhash", Entry{Hash: ^base, ID: "cid3", Time: now.Add(time.Hour)}))
+	// same hash and ID replaces the entry
+	assert.NoError(hs.Add(ctx, "dhash", Entry{Hash: base, ID: "cid1", Time: now.Add(2 * time.Hour)}))
+
+	matches, err := hs.Lookup(ctx, "dhash", base, 5, time.Time{})
+	assert.NoError(err)
+	assert.Equal(2, len(matches))
+	assert.Equal("cid1", matches[0].ID)
+	assert.Equal(0, matches[0].Distance)
+	assert.Equal("cid2", matches[1].ID)
+	assert.Equal(5, matches[1].Distance)
+
+	matches, err = hs.Lookup(ctx, "dhash", base, 4, time.Time{})
+	assert.NoError(err)
+	assert.Equal(1, len(matches))
+
+	matches, err = hs.Lookup(ctx, "dhash", base, 5, now.Add(90*time.Minute))
+	assert.NoError(err)
+	assert.Equal(1, len(matches))
+
+	matches, err = hs.Lookup(ctx, "other", base, 5, time.Time{})
+	assert.NoError(err)
+	assert.Empty(matches)
+
+	// entries expire after the retention period
+	assert.NoError(hs.Add(ctx, "dhash", Entry{Hash: ^base, ID: "cid4", Time: now.Add(26 * time.Hour)}))
+	matches, err = hs.Lookup(ctx, "dhash", base, 5, time.Time{})
+	assert.NoError(err)
+	assert.Equal(1, len(matches))
+	assert.Equal("cid1", matches[0].ID)
+}
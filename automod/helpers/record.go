@@ -0,0 +1,185 @@
+package helpers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bluesky-social/indigo/atproto/data"
+	"github.com/bluesky-social/indigo/automod/keyword"
+)
+
+// Field names which hold human-readable text in records, across Lexicons (eg, post text, profile and list descriptions, image alt text).
+var recordTextFields = map[string]bool{
+	"text":        true,
+	"description": true,
+	"displayName": true,
+	"name":        true,
+	"title":       true,
+	"alt":         true,
+	"summary":     true,
+}
+
+// calls f for every object in a generic record (including the record itself, and nested objects in arrays), in a deterministic order
+func walkRecordObjects(obj map[string]any, f func(obj map[string]any)) {
+	f(obj)
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		walkRecordValue(obj[k], f)
+	}
+}
+
+func walkRecordValue(v any, f func(obj map[string]any)) {
+	switch val := v.(type) {
+	case map[string]any:
+		walkRecordObjects(val, f)
+	case []any:
+		for _, elem := range val {
+			walkRecordValue(elem, f)
+		}
+	}
+}
+
+// Returns all human-readable text in a generic record (as parsed by the atproto/data package), from well-known field names (like "text", "description", and "alt") at any depth. Works for any Lexicon, including those with no Go types.
+func ExtractTextRecord(rec map[string]any) []string {
+	var out []string
+	walkRecordObjects(rec, func(obj map[string]any) {
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			if recordTextFields[k] {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if s, ok := obj[k].(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+	})
+	return out
+}
+
+func ExtractTextTokensRecord(rec map[string]any) []string {
+	return keyword.TokenizeText(strings.Join(ExtractTextRecord(rec), " "))
+}
+
+// Returns rich-text facets from a generic record. Facets are found in arrays named "facets" (applying to a sibling "text" field) or "<field>Facets" (applying to "<field>", like "descriptionFacets"), at any depth.
+func ExtractFacetsRecord(rec map[string]any) ([]PostFacet, error) {
+	var out []PostFacet
+	var outErr error
+	walkRecordObjects(rec, func(obj map[string]any) {
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			facets, ok := obj[k].([]any)
+			if !ok {
+				continue
+			}
+			var field string
+			if k == "facets" {
+				field = "text"
+			} else if strings.HasSuffix(k, "Facets") {
+				field = strings.TrimSuffix(k, "Facets")
+			} else {
+				continue
+			}
+			txt, _ := obj[field].(string)
+			for _, raw := range facets {
+				f, err := parseGenericFacet(txt, raw)
+				if err != nil {
+					if outErr == nil {
+						outErr = err
+					}
+					continue
+				}
+				out = append(out, f...)
+			}
+		}
+	})
+	if outErr != nil {
+		return nil, outErr
+	}
+	return out, nil
+}
+
+func parseGenericFacet(txt string, raw any) ([]PostFacet, error) {
+	facet, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("facet is not an object")
+	}
+	idx, ok := facet["index"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("facet missing index")
+	}
+	start, ok1 := idx["byteStart"].(int64)
+	end, ok2 := idx["byteEnd"].(int64)
+	if !ok1 || !ok2 || start < 0 || end > int64(len(txt)) || start > end {
+		return nil, fmt.Errorf("invalid facet byte range")
+	}
+	span := txt[start:end]
+	if span == "" {
+		return nil, fmt.Errorf("empty facet text")
+	}
+	features, _ := facet["features"].([]any)
+	var out []PostFacet
+	for _, rf := range features {
+		feat, ok := rf.(map[string]any)
+		if !ok {
+			continue
+		}
+		typ, _ := feat["$type"].(string)
+		switch typ {
+		case "app.bsky.richtext.facet#link":
+			if uri, ok := feat["uri"].(string); ok {
+				out = append(out, PostFacet{Text: span, URL: &uri})
+			}
+		case "app.bsky.richtext.facet#tag":
+			if tag, ok := feat["tag"].(string); ok {
+				out = append(out, PostFacet{Text: span, Tag: &tag})
+			}
+		case "app.bsky.richtext.facet#mention":
+			if did, ok := feat["did"].(string); ok {
+				out = append(out, PostFacet{Text: span, DID: &did})
+			}
+		}
+	}
+	return out, nil
+}
+
+// Returns links from a generic record: link facets, "uri" fields with an HTTP(S) URL (eg, external embeds), and URLs in text. De-duplicated.
+func ExtractLinksRecord(rec map[string]any) []string {
+	var out []string
+	// invalid facets are ignored; links in them are usually also in the text
+	facets, _ := ExtractFacetsRecord(rec)
+	for _, f := range facets {
+		if f.URL != nil {
+			out = append(out, *f.URL)
+		}
+	}
+	walkRecordObjects(rec, func(obj map[string]any) {
+		if uri, ok := obj["uri"].(string); ok && (strings.HasPrefix(uri, "https://") || strings.HasPrefix(uri, "http://")) {
+			out = append(out, uri)
+		}
+	})
+	for _, txt := range ExtractTextRecord(rec) {
+		out = append(out, ExtractTextURLs(txt)...)
+	}
+	return DedupeStrings(out)
+}
+
+// Returns the CIDs of all blobs in a generic record, at any depth. Generalizes ExtractBlobCIDsProfile and ExtractPostBlobCIDsPost to any record type.
+func ExtractBlobCIDsRecord(rec map[string]any) []string {
+	var out []string
+	for _, b := range data.ExtractBlobs(rec) {
+		out = append(out, b.Ref.String())
+	}
+	return DedupeStrings(out)
+}
@@ -0,0 +1,75 @@
+package helpers
+
+import (
+	"bytes"
+	"testing"
+
+	appbsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/data"
+	lexutil "github.com/bluesky-social/indigo/lex/util"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractRecord(t *testing.T) {
+	assert := assert.New(t)
+
+	c, err := cid.Decode("bafkreiccldh766hwcnuxnf2wh6jgzepf2nlu2lvcllt63eww5p6chi4ity")
+	assert.NoError(err)
+	desc := "best accounts #cats, see https://example.com"
+	purpose := "app.bsky.graph.defs#curatelist"
+	list := appbsky.GraphList{
+		Name:        "Cat List",
+		Purpose:     &purpose,
+		Description: &desc,
+		DescriptionFacets: []*appbsky.RichtextFacet{
+			{
+				Index: &appbsky.RichtextFacet_ByteSlice{ByteStart: 14, ByteEnd: 19},
+				Features: []*appbsky.RichtextFacet_Features_Elem{
+					{RichtextFacet_Tag: &appbsky.RichtextFacet_Tag{Tag: "cats"}},
+				},
+			},
+			{
+				Index: &appbsky.RichtextFacet_ByteSlice{ByteStart: 25, ByteEnd: 44},
+				Features: []*appbsky.RichtextFacet_Features_Elem{
+					{RichtextFacet_Link: &appbsky.RichtextFacet_Link{Uri: "https://example.com/full"}},
+				},
+			},
+		},
+		Avatar:    &lexutil.LexBlob{Ref: lexutil.LexLink(c), MimeType: "image/png", Size: 123},
+		CreatedAt: "2024-01-01T00:00:00Z",
+	}
+	buf := new(bytes.Buffer)
+	assert.NoError(list.MarshalCBOR(buf))
+	rec, err := data.UnmarshalCBOR(buf.Bytes())
+	assert.NoError(err)
+
+	assert.Equal([]string{desc, "Cat List"}, ExtractTextRecord(rec))
+	assert.Equal([]string{"best", "accounts", "cats", "see", "https", "example", "com", "cat", "list"}, ExtractTextTokensRecord(rec))
+
+	facets, err := ExtractFacetsRecord(rec)
+	assert.NoError(err)
+	assert.Equal(2, len(facets))
+	assert.Equal("#cats", facets[0].Text)
+	assert.Equal("cats", *facets[0].Tag)
+	assert.Equal("https://example.com/full", *facets[1].URL)
+
+	assert.Equal([]string{"https://example.com/full", "https://example.com"}, ExtractLinksRecord(rec))
+	assert.Equal([]string{c.String()}, ExtractBlobCIDsRecord(rec))
+
+	// third-party lexicons, with invalid facets
+	rec = map[string]any{
+		"$type": "com.example.thing",
+		"title": "hello",
+		"embed": map[string]any{"uri": "http://example.net/page"},
+		"facets": []any{
+			map[string]any{"index": map[string]any{"byteStart": int64(0), "byteEnd": int64(100)}},
+		},
+	}
+	assert.Equal([]string{"hello"}, ExtractTextRecord(rec))
+	_, err = ExtractFacetsRecord(rec)
+	assert.Error(err)
+	assert.Equal([]string{"http://example.net/page"}, ExtractLinksRecord(rec))
+	assert.Empty(ExtractBlobCIDsRecord(rec))
+}
@@ -0,0 +1,4 @@
+// In-process perceptual image hashing (dHash and pHash), for detecting near-duplicate images (eg, the same spam image re-uploaded with different encoding or size, and thus a different CID).
+//
+// Hashes are 64 bits, and compared by Hamming distance; they can be indexed for similarity lookups with the automod/hashstore package.
+package imagehash
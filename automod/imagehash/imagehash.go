@@ -0,0 +1,221 @@
+package imagehash
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"sort"
+	"strconv"
+
+	_ "golang.org/x/image/webp"
+)
+
+// A 64-bit perceptual hash. Similar images have hashes with a small Hamming distance (see hashstore.Distance).
+type Hash uint64
+
+func (h Hash) String() string {
+	return fmt.Sprintf("%016x", uint64(h))
+}
+
+// Parses a hash from its hex string form.
+func ParseHash(s string) (Hash, error) {
+	v, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid image hash: %w", err)
+	}
+	return Hash(v), nil
+}
+
+// Perceptual hashes of a single image.
+type Hashes struct {
+	// Difference hash: fast, and robust to re-encoding and resizing
+	DHash Hash
+	// DCT-based hash: slower, and more robust to small edits (eg, brightness and contrast changes)
+	PHash Hash
+}
+
+// Largest image (by pixel count) which will be decoded. Image headers can declare dimensions far larger than the encoded data, so this bounds memory use when decoding untrusted images.
+const MaxPixels = 40_000_000
+
+// Decodes an image (JPEG, PNG, GIF, or WebP; other formats if a decoder has been registered with the image package). Images larger than [MaxPixels] are rejected before decoding.
+func DecodeImage(data []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decoding image: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, fmt.Errorf("empty image")
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > MaxPixels {
+		return nil, fmt.Errorf("image too large: %dx%d", cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decoding image: %w", err)
+	}
+	b := img.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 {
+		return nil, fmt.Errorf("empty image")
+	}
+	return img, nil
+}
+
+// Decodes an image (see [DecodeImage]) and computes its hashes.
+func HashImage(data []byte) (*Hashes, error) {
+	img, err := DecodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Hashes{
+		DHash: DHash(img),
+		PHash: PHash(img),
+	}, nil
+}
+
+// Computes the difference hash of an image: the image is reduced to 9x8 grayscale, and each bit is whether a pixel is brighter than its right-hand neighbor.
+func DHash(img image.Image) Hash {
+	px := grayscale(img, 9, 8)
+	var h uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			h <<= 1
+			if px[y*9+x] > px[y*9+x+1] {
+				h |= 1
+			}
+		}
+	}
+	return Hash(h)
+}
+
+// size of the reduced image for pHash
+const phashSize = 32
+
+// Computes the DCT-based perceptual hash of an image: the image is reduced to 32x32 grayscale, and each bit is whether one of the 8x8 lowest-frequency DCT coefficients is above their median.
+func PHash(img image.Image) Hash {
+	px := grayscale(img, phashSize, phashSize)
+	coeffs := dct2D(px, phashSize)
+
+	low := make([]float64, 0, 64)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			low = append(low, coeffs[y*phashSize+x])
+		}
+	}
+	// the DC term (overall brightness) is excluded from the median
+	sorted := append([]float64{}, low[1:]...)
+	sort.Float64s(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+
+	var h uint64
+	for _, c := range low {
+		h <<= 1
+		if c > median {
+			h |= 1
+		}
+	}
+	return Hash(h)
+}
+
+// Reduces an image to w by h grayscale (luma) values, by averaging the source pixels in each target cell. Fast paths avoid per-pixel color conversion for common decoded image types.
+func grayscale(img image.Image, w, h int) []float64 {
+	b := img.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	sums := make([]float64, w*h)
+	counts := make([]float64, w*h)
+	add := func(x, y int, lum float64) {
+		i := (y*h/sh)*w + x*w/sw
+		sums[i] += lum
+		counts[i]++
+	}
+
+	switch src := img.(type) {
+	case *image.YCbCr:
+		for y := 0; y < sh; y++ {
+			for x := 0; x < sw; x++ {
+				add(x, y, float64(src.Y[src.YOffset(b.Min.X+x, b.Min.Y+y)]))
+			}
+		}
+	case *image.Gray:
+		for y := 0; y < sh; y++ {
+			row := src.Pix[y*src.Stride:]
+			for x := 0; x < sw; x++ {
+				add(x, y, float64(row[x]))
+			}
+		}
+	case *image.NRGBA:
+		for y := 0; y < sh; y++ {
+			row := src.Pix[y*src.Stride:]
+			for x := 0; x < sw; x++ {
+				p := row[x*4 : x*4+3]
+				add(x, y, luma(float64(p[0]), float64(p[1]), float64(p[2])))
+			}
+		}
+	case *image.RGBA:
+		for y := 0; y < sh; y++ {
+			row := src.Pix[y*src.Stride:]
+			for x := 0; x < sw; x++ {
+				p := row[x*4 : x*4+3]
+				add(x, y, luma(float64(p[0]), float64(p[1]), float64(p[2])))
+			}
+		}
+	default:
+		for y := 0; y < sh; y++ {
+			for x := 0; x < sw; x++ {
+				r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+				add(x, y, luma(float64(r>>8), float64(g>>8), float64(bl>>8)))
+			}
+		}
+	}
+
+	for i := range sums {
+		if counts[i] > 0 {
+			sums[i] /= counts[i]
+		} else {
+			// source image smaller than the target: nearest source pixel
+			x, y := i%w, i/w
+			r, g, bl, _ := img.At(b.Min.X+x*sw/w, b.Min.Y+y*sh/h).RGBA()
+			sums[i] = luma(float64(r>>8), float64(g>>8), float64(bl>>8))
+		}
+	}
+	return sums
+}
+
+// ITU-R BT.601 luma, from 8-bit RGB
+func luma(r, g, b float64) float64 {
+	return 0.299*r + 0.587*g + 0.114*b
+}
+
+// 2D DCT-II of an n by n matrix (row-major), computed separably
+func dct2D(px []float64, n int) []float64 {
+	cos := make([]float64, n*n)
+	for k := 0; k < n; k++ {
+		for i := 0; i < n; i++ {
+			cos[k*n+i] = math.Cos(math.Pi / float64(n) * (float64(i) + 0.5) * float64(k))
+		}
+	}
+	rows := make([]float64, n*n)
+	for y := 0; y < n; y++ {
+		for k := 0; k < n; k++ {
+			var sum float64
+			for i := 0; i < n; i++ {
+				sum += px[y*n+i] * cos[k*n+i]
+			}
+			rows[y*n+k] = sum
+		}
+	}
+	out := make([]float64, n*n)
+	for x := 0; x < n; x++ {
+		for k := 0; k < n; k++ {
+			var sum float64
+			for i := 0; i < n; i++ {
+				sum += rows[i*n+x] * cos[k*n+i]
+			}
+			out[k*n+x] = sum
+		}
+	}
+	return out
+}
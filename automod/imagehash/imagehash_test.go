@@ -0,0 +1,111 @@
+package imagehash
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/bits"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// draws a synthetic test image: a gradient background with some shapes, which vary by seed
+func testImage(w, h, seed int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8((x*255/w + y*128/h) % 256)
+			c := color.NRGBA{R: v, G: 255 - v, B: uint8(y * 255 / h), A: 255}
+			// a few bright and dark blocks
+			bx, by := x*8/w, y*8/h
+			switch (bx*7 + by*3 + seed) % 5 {
+			case 0:
+				c = color.NRGBA{R: 250, G: 250, B: 250, A: 255}
+			case 1:
+				c = color.NRGBA{R: 10, G: 10, B: 40, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image, quality int) []byte {
+	var buf bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}))
+	return buf.Bytes()
+}
+
+func distance(a, b Hash) int {
+	return bits.OnesCount64(uint64(a ^ b))
+}
+
+func TestHashImage(t *testing.T) {
+	assert := assert.New(t)
+
+	orig, err := HashImage(encodePNG(t, testImage(400, 300, 0)))
+	assert.NoError(err)
+	// same image, re-encoded as low-quality JPEG
+	reenc, err := HashImage(encodeJPEG(t, testImage(400, 300, 0), 40))
+	assert.NoError(err)
+	// same image, resized
+	resized, err := HashImage(encodeJPEG(t, testImage(200, 150, 0), 80))
+	assert.NoError(err)
+	// different image
+	other, err := HashImage(encodePNG(t, testImage(400, 300, 2)))
+	assert.NoError(err)
+
+	assert.LessOrEqual(distance(orig.DHash, reenc.DHash), 4)
+	assert.LessOrEqual(distance(orig.PHash, reenc.PHash), 4)
+	assert.LessOrEqual(distance(orig.DHash, resized.DHash), 6)
+	assert.LessOrEqual(distance(orig.PHash, resized.PHash), 6)
+	assert.Greater(distance(orig.DHash, other.DHash), 10)
+	assert.Greater(distance(orig.PHash, other.PHash), 10)
+
+	_, err = HashImage([]byte("not an image"))
+	assert.Error(err)
+}
+
+func TestDecodeImage(t *testing.T) {
+	assert := assert.New(t)
+
+	// 1x1 lossless WebP
+	webp, err := base64.StdEncoding.DecodeString("UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA==")
+	assert.NoError(err)
+	img, err := DecodeImage(webp)
+	assert.NoError(err)
+	assert.Equal(1, img.Bounds().Dx())
+
+	// a small PNG which declares huge dimensions is rejected before the pixel data is decoded
+	data := encodePNG(t, testImage(10, 10, 0))
+	ihdr := data[8+8 : 8+8+13]
+	binary.BigEndian.PutUint32(ihdr[0:4], 100_000)
+	binary.BigEndian.PutUint32(ihdr[4:8], 100_000)
+	binary.BigEndian.PutUint32(data[8+8+13:], crc32.ChecksumIEEE(data[8+4:8+8+13]))
+	_, err = DecodeImage(data)
+	assert.ErrorContains(err, "too large")
+}
+
+func TestParseHash(t *testing.T) {
+	assert := assert.New(t)
+
+	h, err := ParseHash("00ff00ff00ff00ff")
+	assert.NoError(err)
+	assert.Equal(Hash(0x00ff00ff00ff00ff), h)
+	assert.Equal("00ff00ff00ff00ff", h.String())
+
+	_, err = ParseHash("xyz")
+	assert.Error(err)
+}
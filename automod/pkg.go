@@ -3,6 +3,8 @@ package automod
 import (
 	"github.com/bluesky-social/indigo/automod/countstore"
 	"github.com/bluesky-social/indigo/automod/engine"
+
+	cbg "github.com/whyrusleeping/cbor-gen"
 )
 
 type Engine = engine.Engine
@@ -15,6 +17,11 @@ type RuleSet = engine.RuleSet
 type Notifier = engine.Notifier
 type SlackNotifier = engine.SlackNotifier
 
+type ActionSink = engine.ActionSink
+type ActionEvent = engine.ActionEvent
+type WebhookSink = engine.WebhookSink
+type DiscordSink = engine.DiscordSink
+
 type AccountContext = engine.AccountContext
 type RecordContext = engine.RecordContext
 type OzoneEventContext = engine.OzoneEventContext
@@ -28,6 +35,7 @@ type ProfileRuleFunc = engine.ProfileRuleFunc
 type BlobRuleFunc = engine.BlobRuleFunc
 type NotificationRuleFunc = engine.NotificationRuleFunc
 type OzoneEventRuleFunc = engine.OzoneEventRuleFunc
+type CollectionRule = engine.CollectionRule
 
 var (
 	ReportReasonSpam       = engine.ReportReasonSpam
@@ -40,8 +48,21 @@ var (
 	PeriodTotal = countstore.PeriodTotal
 	PeriodDay   = countstore.PeriodDay
 	PeriodHour  = countstore.PeriodHour
+	// Returns the period string for a sliding window counter (eg, "10m0s")
+	PeriodWindow = countstore.PeriodWindow
+
+	// Returns a rule for records in a collection, decoded as generic data
+	UntypedRecordRule = engine.UntypedRecordRule
 
 	CreateOp = engine.CreateOp
 	UpdateOp = engine.UpdateOp
 	DeleteOp = engine.DeleteOp
 )
+
+// Returns a rule for records in a collection, decoded as a Go type (see [engine.TypedRecordRule]).
+func TypedRecordRule[T any, PT interface {
+	*T
+	cbg.CBORUnmarshaler
+}](collection string, f func(c *RecordContext, rec PT) error) CollectionRule {
+	return engine.TypedRecordRule(collection, f)
+}
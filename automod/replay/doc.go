@@ -0,0 +1,6 @@
+// Harness for running automod rules over captured firehose events, to evaluate a candidate ruleset against a large historical sample before deploying it.
+//
+// Captures can be JSON lines (as output by "goat firehose"), binary firehose frames, or an [events.DiskPersistence] directory (see [ReadPath]). Each [Replayer] processes events in order with its own engine, using in-memory stores and a simulated clock which follows event timestamps, so that counters and time periods behave deterministically. Nothing is persisted outside of memory, and nothing is fetched over the network: account metadata is not hydrated, and blob rules are skipped.
+//
+// Results are aggregated per rule (see [engine.RuleOutcomeSummary]), and can be compared against a baseline ruleset run over the same events with [Diff].
+package replay
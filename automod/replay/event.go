@@ -0,0 +1,140 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	comatproto "github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/automod"
+	"github.com/bluesky-social/indigo/events"
+	lexutil "github.com/bluesky-social/indigo/lex/util"
+	"github.com/bluesky-social/indigo/repo"
+	"github.com/bluesky-social/indigo/repomgr"
+)
+
+// A single event to replay. Exactly one of Identity, Account, or RecordOp is set; commits are split in to one event per record operation.
+type Event struct {
+	Seq int64
+	// Timestamp of the original event, which the simulated clock is advanced to. May be zero, if unknown.
+	Time     time.Time
+	Identity *comatproto.SyncSubscribeRepos_Identity
+	Account  *comatproto.SyncSubscribeRepos_Account
+	RecordOp *automod.RecordOp
+}
+
+// Simulated clock, which only moves forward, as events are replayed.
+type Clock struct {
+	lk  sync.Mutex
+	now time.Time
+}
+
+// Sets the clock to the given time, unless it is zero or earlier than the current time (events are not strictly ordered by timestamp).
+func (c *Clock) Advance(t time.Time) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	if t.After(c.now) {
+		c.now = t
+	}
+}
+
+// Current simulated time. Has the signature expected by the Clock fields of in-memory stores.
+func (c *Clock) Now() time.Time {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	return c.now
+}
+
+// parses a firehose timestamp, returning zero time if missing or invalid
+func parseTime(s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+	dt, err := syntax.ParseDatetimeLenient(s)
+	if err != nil {
+		return time.Time{}
+	}
+	return dt.Time()
+}
+
+// Converts a firehose stream event to replay events. Event types which automod doesn't process are ignored.
+func streamEvents(ctx context.Context, evt *events.XRPCStreamEvent) ([]Event, error) {
+	switch {
+	case evt.RepoCommit != nil:
+		return commitEventsLenient(ctx, evt.RepoCommit), nil
+	case evt.RepoIdentity != nil:
+		return []Event{{Seq: evt.RepoIdentity.Seq, Time: parseTime(evt.RepoIdentity.Time), Identity: evt.RepoIdentity}}, nil
+	case evt.RepoAccount != nil:
+		return []Event{{Seq: evt.RepoAccount.Seq, Time: parseTime(evt.RepoAccount.Time), Account: evt.RepoAccount}}, nil
+	}
+	return nil, nil
+}
+
+// Same as commitEvents, but logs and skips invalid commits, instead of stopping the replay (like the live firehose consumer does).
+func commitEventsLenient(ctx context.Context, evt *comatproto.SyncSubscribeRepos_Commit) []Event {
+	out, err := commitEvents(ctx, evt)
+	if err != nil {
+		slog.Warn("skipping invalid commit event", "did", evt.Repo, "seq", evt.Seq, "err", err)
+		return nil
+	}
+	return out
+}
+
+// Splits a commit in to record operations, reading record data from the commit's CAR blocks. This mirrors the firehose consumer used by hepa.
+func commitEvents(ctx context.Context, evt *comatproto.SyncSubscribeRepos_Commit) ([]Event, error) {
+	if evt.TooBig {
+		return nil, nil
+	}
+	did, err := syntax.ParseDID(evt.Repo)
+	if err != nil {
+		return nil, fmt.Errorf("bad DID syntax in commit event (seq %d): %w", evt.Seq, err)
+	}
+	t := parseTime(evt.Time)
+
+	var rr *repo.Repo
+	out := make([]Event, 0, len(evt.Ops))
+	for _, op := range evt.Ops {
+		collection, rkey, err := syntax.ParseRepoPath(op.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path in repo op (seq %d): %w", evt.Seq, err)
+		}
+		rop := automod.RecordOp{
+			DID:        did,
+			Collection: collection,
+			RecordKey:  rkey,
+		}
+		switch repomgr.EventKind(op.Action) {
+		case repomgr.EvtKindCreateRecord, repomgr.EvtKindUpdateRecord:
+			if rr == nil {
+				rr, err = repo.ReadRepoFromCar(ctx, bytes.NewReader(evt.Blocks))
+				if err != nil {
+					return nil, fmt.Errorf("failed to read repo from car (seq %d): %w", evt.Seq, err)
+				}
+			}
+			rc, recCBOR, err := rr.GetRecordBytes(ctx, op.Path)
+			if err != nil {
+				return nil, fmt.Errorf("reading record from event blocks (seq %d): %w", evt.Seq, err)
+			}
+			if op.Cid == nil || lexutil.LexLink(rc) != *op.Cid {
+				return nil, fmt.Errorf("mismatch between commit op CID and record block (seq %d)", evt.Seq)
+			}
+			rop.Action = automod.CreateOp
+			if repomgr.EventKind(op.Action) == repomgr.EvtKindUpdateRecord {
+				rop.Action = automod.UpdateOp
+			}
+			recCID := syntax.CID(op.Cid.String())
+			rop.CID = &recCID
+			rop.RecordCBOR = *recCBOR
+		case repomgr.EvtKindDeleteRecord:
+			rop.Action = automod.DeleteOp
+		default:
+			continue
+		}
+		out = append(out, Event{Seq: evt.Seq, Time: t, RecordOp: &rop})
+	}
+	return out, nil
+}
@@ -0,0 +1,264 @@
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	comatproto "github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/atproto/data"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/automod"
+	"github.com/bluesky-social/indigo/events"
+
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+// maximum length of a single JSONL line
+const maxLineSize = 16 << 20
+
+// Reads events from a capture, detecting the format from the path: a directory is read as [events.DiskPersistence] log files, ".json" and ".jsonl" files as JSON lines, and anything else as binary firehose frames.
+func ReadPath(ctx context.Context, path string, cb func(Event) error) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return ReadDiskPersistDir(ctx, path, cb)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if ext := filepath.Ext(path); ext == ".jsonl" || ext == ".json" {
+		return ReadJSONL(ctx, f, cb)
+	}
+	return ReadFrames(ctx, f, cb)
+}
+
+// a single line of JSONL capture: either a full event ("type" and "payload"), or a single record operation
+type jsonLine struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+
+	Seq        int64           `json:"seq"`
+	Time       string          `json:"time"`
+	DID        string          `json:"did"`
+	Collection string          `json:"collection"`
+	RecordKey  string          `json:"rkey"`
+	Action     string          `json:"action"`
+	CID        string          `json:"cid"`
+	Record     json.RawMessage `json:"record"`
+}
+
+// Reads events from JSON lines, in the formats output by "goat firehose". Each line is either a full event, with "type" ("commit", "identity", or "account") and "payload" fields, or a single record operation (from "goat firehose --ops"), with "did", "collection", "rkey", "action", and (for creates and updates) "cid" and "record" fields.
+//
+// Commit events are only useful if they include CAR blocks.
+func ReadJSONL(ctx context.Context, r io.Reader, cb func(Event) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		evts, err := parseJSONLine(ctx, line)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		for _, evt := range evts {
+			if err := cb(evt); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+func parseJSONLine(ctx context.Context, line []byte) ([]Event, error) {
+	var jl jsonLine
+	if err := json.Unmarshal(line, &jl); err != nil {
+		return nil, err
+	}
+	switch jl.Type {
+	case "commit":
+		var evt comatproto.SyncSubscribeRepos_Commit
+		if err := json.Unmarshal(jl.Payload, &evt); err != nil {
+			return nil, err
+		}
+		return commitEventsLenient(ctx, &evt), nil
+	case "identity":
+		var evt comatproto.SyncSubscribeRepos_Identity
+		if err := json.Unmarshal(jl.Payload, &evt); err != nil {
+			return nil, err
+		}
+		return []Event{{Seq: evt.Seq, Time: parseTime(evt.Time), Identity: &evt}}, nil
+	case "account":
+		var evt comatproto.SyncSubscribeRepos_Account
+		if err := json.Unmarshal(jl.Payload, &evt); err != nil {
+			return nil, err
+		}
+		return []Event{{Seq: evt.Seq, Time: parseTime(evt.Time), Account: &evt}}, nil
+	case "":
+		// record operation
+	default:
+		// other event types are not processed by automod
+		return nil, nil
+	}
+
+	did, err := syntax.ParseDID(jl.DID)
+	if err != nil {
+		return nil, fmt.Errorf("record op: %w", err)
+	}
+	collection, err := syntax.ParseNSID(jl.Collection)
+	if err != nil {
+		return nil, fmt.Errorf("record op: %w", err)
+	}
+	rkey, err := syntax.ParseRecordKey(jl.RecordKey)
+	if err != nil {
+		return nil, fmt.Errorf("record op: %w", err)
+	}
+	op := automod.RecordOp{
+		Action:     jl.Action,
+		DID:        did,
+		Collection: collection,
+		RecordKey:  rkey,
+	}
+	switch jl.Action {
+	case automod.CreateOp, automod.UpdateOp:
+		recCID, err := syntax.ParseCID(jl.CID)
+		if err != nil {
+			return nil, fmt.Errorf("record op: %w", err)
+		}
+		rec, err := data.UnmarshalJSON(jl.Record)
+		if err != nil {
+			return nil, fmt.Errorf("record op: %w", err)
+		}
+		recCBOR, err := data.MarshalCBOR(rec)
+		if err != nil {
+			return nil, fmt.Errorf("record op: %w", err)
+		}
+		op.CID = &recCID
+		op.RecordCBOR = recCBOR
+	case automod.DeleteOp:
+	default:
+		return nil, fmt.Errorf("record op: unexpected action: %s", jl.Action)
+	}
+	return []Event{{Seq: jl.Seq, Time: parseTime(jl.Time), RecordOp: &op}}, nil
+}
+
+// Reads events from a binary capture of firehose frames: concatenated messages, each a header and body CBOR object, in the same encoding as the WebSocket stream (see [events.XRPCStreamEvent.Serialize]). Commit messages include their CAR blocks.
+func ReadFrames(ctx context.Context, r io.Reader, cb func(Event) error) error {
+	bufr := bufio.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := bufr.Peek(1); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		var header events.EventHeader
+		if err := header.UnmarshalCBOR(bufr); err != nil {
+			return fmt.Errorf("reading frame header: %w", err)
+		}
+		var xe events.XRPCStreamEvent
+		var err error
+		switch {
+		case header.Op == events.EvtKindMessage && header.MsgType == "#commit":
+			var evt comatproto.SyncSubscribeRepos_Commit
+			err = evt.UnmarshalCBOR(bufr)
+			xe.RepoCommit = &evt
+		case header.Op == events.EvtKindMessage && header.MsgType == "#identity":
+			var evt comatproto.SyncSubscribeRepos_Identity
+			err = evt.UnmarshalCBOR(bufr)
+			xe.RepoIdentity = &evt
+		case header.Op == events.EvtKindMessage && header.MsgType == "#account":
+			var evt comatproto.SyncSubscribeRepos_Account
+			err = evt.UnmarshalCBOR(bufr)
+			xe.RepoAccount = &evt
+		default:
+			// skip over other message types, and error frames
+			var d cbg.Deferred
+			err = d.UnmarshalCBOR(bufr)
+		}
+		if err != nil {
+			return fmt.Errorf("reading %s frame: %w", header.MsgType, err)
+		}
+		evts, err := streamEvents(ctx, &xe)
+		if err != nil {
+			return err
+		}
+		for _, evt := range evts {
+			if err := cb(evt); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Reads events from an [events.DiskPersistence] primary directory, by reading the "evts-<seq>" log files in order. The metadata database is not needed.
+func ReadDiskPersistDir(ctx context.Context, dir string, cb func(Event) error) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	type logFile struct {
+		path     string
+		seqStart int64
+	}
+	var logs []logFile
+	for _, e := range entries {
+		suffix, ok := strings.CutPrefix(e.Name(), "evts-")
+		if !ok || e.IsDir() {
+			continue
+		}
+		seq, err := strconv.ParseInt(suffix, 10, 64)
+		if err != nil {
+			continue
+		}
+		logs = append(logs, logFile{path: filepath.Join(dir, e.Name()), seqStart: seq})
+	}
+	if len(logs) == 0 {
+		return fmt.Errorf("no event log files found in directory: %s", dir)
+	}
+	sort.Slice(logs, func(i, j int) bool { return logs[i].seqStart < logs[j].seqStart })
+
+	for _, lf := range logs {
+		_, err := events.ReadEventLogFile(ctx, 0, lf.path, func(xe *events.XRPCStreamEvent) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			evts, err := streamEvents(ctx, xe)
+			if err != nil {
+				return err
+			}
+			for _, evt := range evts {
+				if err := cb(evt); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", lf.path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/automod"
+	"github.com/bluesky-social/indigo/automod/cachestore"
+	"github.com/bluesky-social/indigo/automod/countstore"
+	"github.com/bluesky-social/indigo/automod/engine"
+	"github.com/bluesky-social/indigo/automod/flagstore"
+	"github.com/bluesky-social/indigo/automod/setstore"
+)
+
+// Runs a ruleset over captured events, with in-memory state and a simulated clock.
+//
+// Each Replayer has its own engine and stores, so several can process the same events side by side (eg, a candidate and a baseline ruleset). Events must be processed in order, from a single goroutine.
+type Replayer struct {
+	Engine   *automod.Engine
+	Clock    *Clock
+	Outcomes *engine.OutcomeRecorder
+
+	dir    *identity.MockDirectory
+	result Result
+}
+
+// Creates a replayer for the given ruleset. Blob rules are dropped, because blobs can't be fetched from a capture. The set store may be shared between replayers; if nil, an empty one is used.
+//
+// Accounts are not hydrated (there is no network access), so rules only see the DID and (from identity events) handle of accounts.
+func NewReplayer(rules automod.RuleSet, sets setstore.SetStore, logger *slog.Logger) *Replayer {
+	if logger == nil {
+		// there is no mod service, so every moderation action logs a warning about not being persisted
+		logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	}
+	if sets == nil {
+		sets = setstore.NewMemSetStore()
+	}
+	rules.BlobRules = nil
+
+	clock := &Clock{}
+	counters := countstore.NewMemCountStore()
+	counters.Clock = clock.Now
+	outcomes := engine.NewOutcomeRecorder(0)
+	outcomes.Clock = clock.Now
+	dir := identity.NewMockDirectory()
+	eng := automod.Engine{
+		Logger:    logger,
+		Directory: &dir,
+		Rules:     rules,
+		Counters:  counters,
+		Sets:      sets,
+		Flags:     flagstore.NewMemFlagStore(),
+		Cache:     cachestore.NewMemCacheStore(100_000, 24*time.Hour),
+		Outcomes:  outcomes,
+		Config: engine.EngineConfig{
+			SkipAccountMeta: true,
+		},
+	}
+	return &Replayer{
+		Engine:   &eng,
+		Clock:    clock,
+		Outcomes: outcomes,
+		dir:      &dir,
+		result:   Result{Events: make(map[string]int64)},
+	}
+}
+
+// Aggregate results of a replay.
+type Result struct {
+	// Number of events processed, by type ("record", "delete", "identity", "account")
+	Events map[string]int64 `json:"events"`
+	// Number of events which failed processing
+	Errors int64 `json:"errors"`
+	// Timestamps of the first and last event
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Per-rule outcomes, sorted by rule name
+	Rules []engine.RuleOutcomeSummary `json:"rules"`
+}
+
+// makes sure the directory knows about an account, so that events can be processed
+func (r *Replayer) ensureIdentity(did syntax.DID, handle *string) {
+	ident, err := r.dir.LookupDID(context.Background(), did)
+	if err == nil && handle == nil {
+		return
+	}
+	if err != nil {
+		ident = &identity.Identity{DID: did, Handle: syntax.HandleInvalid}
+	}
+	if handle != nil {
+		if h, err := syntax.ParseHandle(*handle); err == nil {
+			ident.Handle = h.Normalize()
+		}
+	}
+	r.dir.Insert(*ident)
+}
+
+// Processes a single event, after advancing the simulated clock to the event's timestamp. Processing errors are counted (and logged at debug level), and don't stop the replay.
+func (r *Replayer) Process(ctx context.Context, evt Event) {
+	r.Clock.Advance(evt.Time)
+	if !evt.Time.IsZero() {
+		if r.result.Start.IsZero() || evt.Time.Before(r.result.Start) {
+			r.result.Start = evt.Time
+		}
+		if evt.Time.After(r.result.End) {
+			r.result.End = evt.Time
+		}
+	}
+
+	var typ string
+	var err error
+	switch {
+	case evt.Identity != nil:
+		typ = "identity"
+		did, perr := syntax.ParseDID(evt.Identity.Did)
+		if perr != nil {
+			err = perr
+			break
+		}
+		r.ensureIdentity(did, evt.Identity.Handle)
+		err = r.Engine.ProcessIdentityEvent(ctx, *evt.Identity)
+	case evt.Account != nil:
+		typ = "account"
+		did, perr := syntax.ParseDID(evt.Account.Did)
+		if perr != nil {
+			err = perr
+			break
+		}
+		r.ensureIdentity(did, nil)
+		err = r.Engine.ProcessAccountEvent(ctx, *evt.Account)
+	case evt.RecordOp != nil:
+		typ = "record"
+		if evt.RecordOp.Action == automod.DeleteOp {
+			typ = "delete"
+		}
+		r.ensureIdentity(evt.RecordOp.DID, nil)
+		err = r.Engine.ProcessRecordOp(ctx, *evt.RecordOp)
+	default:
+		return
+	}
+	r.result.Events[typ]++
+	if err != nil {
+		r.result.Errors++
+		r.Engine.Logger.Debug("replay event processing failed", "seq", evt.Seq, "type", typ, "err", err)
+	}
+}
+
+// Returns aggregate results of all events processed so far.
+func (r *Replayer) Result() *Result {
+	res := r.result
+	res.Events = make(map[string]int64, len(r.result.Events))
+	for k, v := range r.result.Events {
+		res.Events[k] = v
+	}
+	res.Rules = r.Outcomes.Report(time.Time{})
+	return &res
+}
+
+// Reads events from a capture (see [ReadPath]), and processes each of them with all of the replayers.
+func Run(ctx context.Context, path string, replayers ...*Replayer) error {
+	return ReadPath(ctx, path, func(evt Event) error {
+		for _, r := range replayers {
+			r.Process(ctx, evt)
+		}
+		return nil
+	})
+}
+
+// Comparison of one rule's outcomes between a baseline and a candidate replay. Either side is nil if the rule did not run in that replay.
+type RuleDiff struct {
+	Rule      string                     `json:"rule"`
+	Baseline  *engine.RuleOutcomeSummary `json:"baseline,omitempty"`
+	Candidate *engine.RuleOutcomeSummary `json:"candidate,omitempty"`
+}
+
+// True if the rule was added or removed, or its matches or effects changed.
+func (d *RuleDiff) Changed() bool {
+	if d.Baseline == nil || d.Candidate == nil {
+		return true
+	}
+	b, c := d.Baseline, d.Candidate
+	return b.LiveMatches != c.LiveMatches ||
+		b.ShadowMatches != c.ShadowMatches ||
+		formatCounts(b.LiveEffects) != formatCounts(c.LiveEffects) ||
+		formatCounts(b.ShadowEffects) != formatCounts(c.ShadowEffects)
+}
+
+// Compares per-rule outcomes of two replays over the same events. Rules are matched by name; the result covers every rule from either replay, sorted by name.
+func Diff(baseline, candidate *Result) []RuleDiff {
+	byRule := make(map[string]*RuleDiff)
+	get := func(rule string) *RuleDiff {
+		d, ok := byRule[rule]
+		if !ok {
+			d = &RuleDiff{Rule: rule}
+			byRule[rule] = d
+		}
+		return d
+	}
+	for i := range baseline.Rules {
+		get(baseline.Rules[i].Rule).Baseline = &baseline.Rules[i]
+	}
+	for i := range candidate.Rules {
+		get(candidate.Rules[i].Rule).Candidate = &candidate.Rules[i]
+	}
+	out := make([]RuleDiff, 0, len(byRule))
+	for _, d := range byRule {
+		out = append(out, *d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Rule < out[j].Rule })
+	return out
+}
+
+// formats effect counts as "label=2 report=1", sorted by effect type
+func formatCounts(counts map[string]int64) string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%d", k, counts[k]))
+	}
+	return strings.Join(parts, " ")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// Writes a human-readable summary of a replay, as a table of per-rule outcomes. If a baseline is supplied, the table instead compares each rule's (live) matches and effects against the baseline, and marks rules which changed.
+func WriteReport(w io.Writer, candidate, baseline *Result) error {
+	types := make([]string, 0, len(candidate.Events))
+	for k := range candidate.Events {
+		types = append(types, k)
+	}
+	sort.Strings(types)
+	var total int64
+	counts := make([]string, 0, len(types))
+	for _, k := range types {
+		total += candidate.Events[k]
+		counts = append(counts, fmt.Sprintf("%s=%d", k, candidate.Events[k]))
+	}
+	fmt.Fprintf(w, "events: %d (%s), errors: %d\n", total, strings.Join(counts, " "), candidate.Errors)
+	if !candidate.Start.IsZero() {
+		fmt.Fprintf(w, "time range: %s to %s\n", candidate.Start.UTC().Format(time.RFC3339), candidate.End.UTC().Format(time.RFC3339))
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if baseline == nil {
+		fmt.Fprintln(tw, "RULE\tRUNS\tLIVE\tSHADOW\tLIVE EFFECTS\tSHADOW EFFECTS")
+		for _, s := range candidate.Rules {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\t%s\n", s.Rule, s.Runs, s.LiveMatches, s.ShadowMatches, orDash(formatCounts(s.LiveEffects)), orDash(formatCounts(s.ShadowEffects)))
+		}
+		return tw.Flush()
+	}
+
+	fmt.Fprintln(tw, "RULE\tMATCHES (BASELINE)\tMATCHES (CANDIDATE)\tEFFECTS (BASELINE)\tEFFECTS (CANDIDATE)\t")
+	for _, d := range Diff(baseline, candidate) {
+		bm, cm, be, ce := "-", "-", "-", "-"
+		if d.Baseline != nil {
+			bm = fmt.Sprintf("%d", d.Baseline.LiveMatches)
+			be = orDash(formatCounts(d.Baseline.LiveEffects))
+		}
+		if d.Candidate != nil {
+			cm = fmt.Sprintf("%d", d.Candidate.LiveMatches)
+			ce = orDash(formatCounts(d.Candidate.LiveEffects))
+		}
+		mark := ""
+		if d.Changed() {
+			mark = "*"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", d.Rule, bm, cm, be, ce, mark)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,105 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	comatproto "github.com/bluesky-social/indigo/api/atproto"
+	appbsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/automod"
+	"github.com/bluesky-social/indigo/automod/countstore"
+	"github.com/bluesky-social/indigo/events"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hashtagRule(c *automod.RecordContext, post *appbsky.FeedPost) error {
+	for _, tag := range post.Tags {
+		if tag == "slur" {
+			c.AddRecordLabel("bad-hashtag")
+			c.IncrementPeriod("bad-hashtag", c.Account.Identity.DID.String(), countstore.PeriodHour)
+			c.Increment("bad-hashtag-total", c.Account.Identity.DID.String())
+		}
+	}
+	return nil
+}
+
+var testCapture = `{"type":"identity","payload":{"did":"did:plc:abc111","handle":"handle.example.com","seq":1,"time":"2024-01-01T00:00:00Z"}}
+{"seq":2,"time":"2024-01-01T00:10:00Z","did":"did:plc:abc111","collection":"app.bsky.feed.post","rkey":"3k1","action":"create","cid":"bafyreie5737gdxlw5i64vzichcalba3z2v5n6icifvx5xytvske7mr3hpm","record":{"$type":"app.bsky.feed.post","text":"hello","tags":["slur"],"createdAt":"2024-01-01T00:10:00Z"}}
+{"seq":3,"time":"2024-01-01T02:10:00Z","did":"did:plc:abc111","collection":"app.bsky.feed.post","rkey":"3k2","action":"create","cid":"bafyreie5737gdxlw5i64vzichcalba3z2v5n6icifvx5xytvske7mr3hpm","record":{"$type":"app.bsky.feed.post","text":"hello again","tags":["slur"],"createdAt":"2024-01-01T02:10:00Z"}}
+
+{"seq":4,"time":"2024-01-01T02:11:00Z","did":"did:plc:abc222","collection":"app.bsky.feed.post","rkey":"3k3","action":"create","cid":"bafyreie5737gdxlw5i64vzichcalba3z2v5n6icifvx5xytvske7mr3hpm","record":{"$type":"app.bsky.feed.post","text":"fine","createdAt":"2024-01-01T02:11:00Z"}}
+{"seq":5,"time":"2024-01-01T02:12:00Z","did":"did:plc:abc111","collection":"app.bsky.feed.post","rkey":"3k1","action":"delete"}
+`
+
+func TestReplayJSONL(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+	assert.NoError(os.WriteFile(path, []byte(testCapture), 0644))
+
+	candidate := NewReplayer(automod.RuleSet{PostRules: []automod.PostRuleFunc{hashtagRule}}, nil, nil)
+	baseline := NewReplayer(automod.RuleSet{}, nil, nil)
+	assert.NoError(Run(ctx, path, candidate, baseline))
+
+	res := candidate.Result()
+	assert.Equal(map[string]int64{"identity": 1, "record": 3, "delete": 1}, res.Events)
+	assert.Equal(int64(0), res.Errors)
+	assert.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), res.Start)
+	assert.Equal(time.Date(2024, 1, 1, 2, 12, 0, 0, time.UTC), res.End)
+	assert.Equal(1, len(res.Rules))
+	assert.Equal("hashtagRule", res.Rules[0].Rule)
+	assert.Equal(int64(3), res.Rules[0].Runs)
+	assert.Equal(int64(2), res.Rules[0].LiveMatches)
+	assert.Equal(map[string]int64{"label": 2}, res.Rules[0].LiveEffects)
+
+	// counter periods follow the simulated clock: the two posts were in different hours
+	hour, err := candidate.Engine.Counters.GetCount(ctx, "bad-hashtag", "did:plc:abc111", countstore.PeriodHour)
+	assert.NoError(err)
+	assert.Equal(1, hour)
+	total, err := candidate.Engine.Counters.GetCount(ctx, "bad-hashtag-total", "did:plc:abc111", countstore.PeriodTotal)
+	assert.NoError(err)
+	assert.Equal(2, total)
+
+	diff := Diff(baseline.Result(), res)
+	assert.Equal(1, len(diff))
+	assert.Nil(diff[0].Baseline)
+	assert.True(diff[0].Changed())
+
+	var buf bytes.Buffer
+	assert.NoError(WriteReport(&buf, res, baseline.Result()))
+	assert.Contains(buf.String(), "events: 5")
+	assert.Contains(buf.String(), "hashtagRule")
+	assert.Contains(buf.String(), "label=2")
+}
+
+func TestReadFrames(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	handle := "handle.example.com"
+	var buf bytes.Buffer
+	for _, evt := range []events.XRPCStreamEvent{
+		{RepoIdentity: &comatproto.SyncSubscribeRepos_Identity{Did: "did:plc:abc111", Handle: &handle, Seq: 1, Time: "2024-01-01T00:00:00Z"}},
+		{RepoHandle: &comatproto.SyncSubscribeRepos_Handle{Did: "did:plc:abc111", Handle: handle, Seq: 2, Time: "2024-01-01T00:00:00Z"}},
+		{RepoAccount: &comatproto.SyncSubscribeRepos_Account{Did: "did:plc:abc111", Active: true, Seq: 3, Time: "2024-01-01T00:01:00Z"}},
+	} {
+		assert.NoError(evt.Serialize(&buf))
+	}
+
+	var got []Event
+	assert.NoError(ReadFrames(ctx, &buf, func(evt Event) error {
+		got = append(got, evt)
+		return nil
+	}))
+	assert.Equal(2, len(got))
+	assert.NotNil(got[0].Identity)
+	assert.Equal(int64(1), got[0].Seq)
+	assert.NotNil(got[1].Account)
+	assert.Equal(time.Date(2024, 1, 1, 0, 1, 0, 0, time.UTC), got[1].Time)
+}
@@ -30,6 +30,7 @@ func DefaultRules() automod.RuleSet {
 			HarassmentTrivialPostRule,
 			NostrSpamPostRule,
 			TrivialSpamPostRule,
+			CopyPasteTextPostRule,
 		},
 		ProfileRules: []automod.ProfileRuleFunc{
 			GtubeProfileRule,
@@ -43,6 +44,10 @@ func DefaultRules() automod.RuleSet {
 			BadWordOtherRecordRule,
 			TooManyRepostRule,
 		},
+		CollectionRules: []automod.CollectionRule{
+			//automod.TypedRecordRule("app.bsky.graph.starterpack", BadWordStarterPackRule),
+			automod.TypedRecordRule("app.bsky.graph.follow", NewAccountFollowBurstRule),
+		},
 		RecordDeleteRules: []automod.RecordRuleFunc{
 			DeleteInteractionRule,
 		},
@@ -0,0 +1,40 @@
+package rules
+
+import (
+	"fmt"
+	"time"
+
+	appbsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/automod"
+	"github.com/bluesky-social/indigo/automod/helpers"
+	"github.com/bluesky-social/indigo/automod/keyword"
+)
+
+// Detects near-identical post text ("copy-paste" spam) from many distinct accounts. Requires the engine to have a text index; otherwise does nothing.
+var copyPasteTextNamespace = "post-text"
+var copyPasteMinTokens = 8
+var copyPasteMinSimilarity = 0.7
+var copyPasteWindow = time.Hour
+var copyPasteAccountLimit = 5
+var _ automod.PostRuleFunc = CopyPasteTextPostRule
+
+func CopyPasteTextPostRule(c *automod.RecordContext, post *appbsky.FeedPost) error {
+	// short posts are too likely to be similar by chance
+	if len(keyword.TokenizeText(post.Text)) < copyPasteMinTokens {
+		return nil
+	}
+
+	c.AddText(copyPasteTextNamespace, c.RecordOp.ATURI().String(), post.Text)
+
+	count := c.SimilarTextAccountCount(copyPasteTextNamespace, post.Text, copyPasteMinSimilarity, copyPasteWindow)
+	if count < copyPasteAccountLimit {
+		return nil
+	}
+	c.AddRecordFlag("copy-paste-text")
+	if !helpers.AccountIsOlderThan(&c.AccountContext, 7*24*time.Hour) {
+		c.AddAccountFlag("copy-paste-text")
+		c.ReportAccount(automod.ReportReasonSpam, fmt.Sprintf("possible spam (new account, post text similar to posts from %d other accounts in the past hour)", count))
+		c.Notify("slack")
+	}
+	return nil
+}
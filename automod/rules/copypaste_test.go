@@ -0,0 +1,78 @@
+package rules
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	appbsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/automod"
+	"github.com/bluesky-social/indigo/automod/engine"
+	"github.com/bluesky-social/indigo/automod/textsim"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopyPasteTextPostRule(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	eng := engine.EngineTestFixture()
+	eng.Texts = textsim.NewMemTextStore(24 * time.Hour)
+	am1 := automod.AccountMeta{
+		Identity: &identity.Identity{
+			DID:    syntax.DID("did:plc:abc111"),
+			Handle: syntax.Handle("handle.example.com"),
+		},
+	}
+	cid1 := syntax.CID("cid123")
+	spam := "Congratulations! You have been selected to receive a free crypto airdrop, claim your tokens now at the link in my profile"
+	p1 := appbsky.FeedPost{Text: spam}
+	p1buf := new(bytes.Buffer)
+	assert.NoError(p1.MarshalCBOR(p1buf))
+	op := engine.RecordOp{
+		Action:     engine.CreateOp,
+		DID:        am1.Identity.DID,
+		Collection: syntax.NSID("app.bsky.feed.post"),
+		RecordKey:  syntax.RecordKey("abc123"),
+		CID:        &cid1,
+		RecordCBOR: p1buf.Bytes(),
+	}
+
+	// short posts are skipped entirely
+	c0 := engine.NewRecordContext(ctx, &eng, am1, op)
+	assert.NoError(CopyPasteTextPostRule(&c0, &appbsky.FeedPost{Text: "gm everybody"}))
+	assert.Empty(engine.ExtractEffects(&c0.BaseContext).TextAdds)
+
+	c1 := engine.NewRecordContext(ctx, &eng, am1, op)
+	assert.NoError(CopyPasteTextPostRule(&c1, &p1))
+	eff1 := engine.ExtractEffects(&c1.BaseContext)
+	assert.Equal(1, len(eff1.TextAdds))
+	assert.Equal("did:plc:abc111", eff1.TextAdds[0].Account)
+	assert.Empty(eff1.RecordFlags)
+
+	// the same text from many other accounts (and more from this one, which doesn't count)
+	sig := textsim.MinHash(spam + "!!")
+	for i := 0; i < copyPasteAccountLimit; i++ {
+		entry := textsim.Entry{ID: fmt.Sprintf("post%d", i), Account: fmt.Sprintf("did:plc:other%d", i), Signature: sig, Time: time.Now()}
+		if i == 0 {
+			entry.Account = "did:plc:abc111"
+		}
+		assert.NoError(eng.Texts.Add(ctx, copyPasteTextNamespace, entry))
+	}
+	c2 := engine.NewRecordContext(ctx, &eng, am1, op)
+	assert.NoError(CopyPasteTextPostRule(&c2, &p1))
+	assert.Empty(engine.ExtractEffects(&c2.BaseContext).RecordFlags)
+
+	assert.NoError(eng.Texts.Add(ctx, copyPasteTextNamespace, textsim.Entry{ID: "post-last", Account: "did:plc:otherlast", Signature: sig, Time: time.Now()}))
+	c3 := engine.NewRecordContext(ctx, &eng, am1, op)
+	assert.NoError(CopyPasteTextPostRule(&c3, &p1))
+	eff3 := engine.ExtractEffects(&c3.BaseContext)
+	assert.Equal([]string{"copy-paste-text"}, eff3.RecordFlags)
+	assert.Equal([]string{"copy-paste-text"}, eff3.AccountFlags)
+	assert.Equal(1, len(eff3.AccountReports))
+}
@@ -2,9 +2,13 @@ package rules
 
 import (
 	"fmt"
+	"time"
 
+	appbsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/syntax"
 	"github.com/bluesky-social/indigo/automod"
 	"github.com/bluesky-social/indigo/automod/countstore"
+	"github.com/bluesky-social/indigo/automod/helpers"
 )
 
 var interactionDailyThreshold = 800
@@ -67,3 +71,22 @@ func DeleteInteractionRule(c *automod.RecordContext) error {
 	}
 	return nil
 }
+
+var newAccountFollowerThreshold = 50
+
+// looks for new accounts joining a burst of new accounts following the same account (eg, follower farming with fresh accounts). uses the local graph cache, if the engine has one.
+func NewAccountFollowBurstRule(c *automod.RecordContext, follow *appbsky.GraphFollow) error {
+	if !helpers.AccountIsYoungerThan(&c.AccountContext, 24*time.Hour) {
+		return nil
+	}
+	subject, err := syntax.ParseDID(follow.Subject)
+	if err != nil {
+		return nil
+	}
+	count := c.GraphNewAccountFollowerCount(subject, 24*time.Hour)
+	if count >= newAccountFollowerThreshold {
+		c.Logger.Info("new-account-follow-burst", "subject", subject, "newAccountFollowers", count)
+		c.AddAccountFlag("new-account-follow-burst")
+	}
+	return nil
+}
@@ -233,3 +233,29 @@ func BadWordDIDRule(c *automod.AccountContext) error {
 }
 
 var _ automod.IdentityRuleFunc = BadWordDIDRule
+
+// Checks starter pack names and descriptions for explicit slurs and bad words. An example of a typed collection rule; not enabled in DefaultRules.
+func BadWordStarterPackRule(c *automod.RecordContext, sp *appbsky.GraphStarterpack) error {
+	if word := keyword.SlugContainsExplicitSlur(keyword.Slugify(sp.Name)); word != "" {
+		c.AddRecordFlag("bad-word-name")
+		c.ReportRecord(automod.ReportReasonRude, fmt.Sprintf("possible bad word in starter pack name: %s", word))
+		c.Notify("slack")
+		return nil
+	}
+	for _, tok := range keyword.TokenizeText(sp.Name) {
+		if c.InSet("bad-words", tok) {
+			c.AddRecordFlag("bad-word-name")
+			c.ReportRecord(automod.ReportReasonRude, fmt.Sprintf("possible bad word in starter pack name: %s", tok))
+			c.Notify("slack")
+			return nil
+		}
+	}
+	if sp.Description != nil {
+		if word := keyword.SlugContainsExplicitSlur(keyword.Slugify(*sp.Description)); word != "" {
+			c.AddRecordFlag("bad-word-text")
+			c.ReportRecord(automod.ReportReasonRude, fmt.Sprintf("possible bad word in starter pack description: %s", word))
+			c.Notify("slack")
+		}
+	}
+	return nil
+}
@@ -107,3 +107,33 @@ func TestBadWordPostRule(t *testing.T) {
 	eff2 := engine.ExtractEffects(&c2.BaseContext)
 	assert.Equal([]string{"bad-word-text"}, eff2.RecordFlags)
 }
+
+func TestBadWordStarterPackRule(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	eng := engine.EngineTestFixture()
+	am1 := automod.AccountMeta{
+		Identity: &identity.Identity{
+			DID:    syntax.DID("did:plc:abc111"),
+			Handle: syntax.Handle("handle.example.com"),
+		},
+	}
+	cid1 := syntax.CID("cid123")
+	op := engine.RecordOp{
+		Action:     engine.CreateOp,
+		DID:        am1.Identity.DID,
+		Collection: syntax.NSID("app.bsky.graph.starterpack"),
+		RecordKey:  syntax.RecordKey("abc123"),
+		CID:        &cid1,
+		RecordCBOR: []byte("dummy"),
+	}
+
+	c1 := engine.NewRecordContext(ctx, &eng, am1, op)
+	assert.NoError(BadWordStarterPackRule(&c1, &appbsky.GraphStarterpack{Name: "my friends"}))
+	assert.Empty(engine.ExtractEffects(&c1.BaseContext).RecordFlags)
+
+	c2 := engine.NewRecordContext(ctx, &eng, am1, op)
+	assert.NoError(BadWordStarterPackRule(&c2, &appbsky.GraphStarterpack{Name: "hardr pack"}))
+	assert.Equal([]string{"bad-word-name"}, engine.ExtractEffects(&c2.BaseContext).RecordFlags)
+}
@@ -0,0 +1,163 @@
+package setstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Audit log entry for a single modification of a set.
+type SetChange struct {
+	Time time.Time `json:"time"`
+	// Who made the change (eg, a moderator's handle or email)
+	Actor string `json:"actor"`
+	// One of "add", "remove", "replace", "delete", or "reload"
+	Action string   `json:"action"`
+	Set    string   `json:"set"`
+	Type   string   `json:"type,omitempty"`
+	Values []string `json:"values,omitempty"`
+}
+
+// Record of who changed what in a set store.
+type AuditLog interface {
+	Record(ctx context.Context, change SetChange) error
+	// Returns the most recent changes, newest first
+	Recent(ctx context.Context, limit int) ([]SetChange, error)
+}
+
+// In-memory audit log, keeping a fixed number of recent changes.
+type MemAuditLog struct {
+	MaxEntries int
+
+	lk      sync.Mutex
+	entries []SetChange
+}
+
+func NewMemAuditLog(maxEntries int) *MemAuditLog {
+	return &MemAuditLog{MaxEntries: maxEntries}
+}
+
+func (l *MemAuditLog) Record(ctx context.Context, change SetChange) error {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	l.entries = append(l.entries, change)
+	if l.MaxEntries > 0 && len(l.entries) > l.MaxEntries {
+		l.entries = l.entries[len(l.entries)-l.MaxEntries:]
+	}
+	return nil
+}
+
+func (l *MemAuditLog) Recent(ctx context.Context, limit int) ([]SetChange, error) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	out := []SetChange{}
+	for i := len(l.entries) - 1; i >= 0 && (limit <= 0 || len(out) < limit); i-- {
+		out = append(out, l.entries[i])
+	}
+	return out, nil
+}
+
+var redisSetAuditKey string = "sets-audit"
+
+// Audit log stored as a capped Redis list of JSON entries, shared across processes.
+type RedisAuditLog struct {
+	Client     *redis.Client
+	MaxEntries int64
+}
+
+func NewRedisAuditLog(client *redis.Client, maxEntries int64) *RedisAuditLog {
+	return &RedisAuditLog{Client: client, MaxEntries: maxEntries}
+}
+
+func (l *RedisAuditLog) Record(ctx context.Context, change SetChange) error {
+	b, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	multi := l.Client.TxPipeline()
+	multi.LPush(ctx, redisSetAuditKey, b)
+	if l.MaxEntries > 0 {
+		multi.LTrim(ctx, redisSetAuditKey, 0, l.MaxEntries-1)
+	}
+	_, err = multi.Exec(ctx)
+	return err
+}
+
+func (l *RedisAuditLog) Recent(ctx context.Context, limit int) ([]SetChange, error) {
+	stop := int64(-1)
+	if limit > 0 {
+		stop = int64(limit) - 1
+	}
+	raw, err := l.Client.LRange(ctx, redisSetAuditKey, 0, stop).Result()
+	if err == redis.Nil {
+		return []SetChange{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	out := make([]SetChange, 0, len(raw))
+	for _, r := range raw {
+		var c SetChange
+		if err := json.Unmarshal([]byte(r), &c); err != nil {
+			return nil, fmt.Errorf("parsing set audit log entry: %w", err)
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// Wraps a mutable set store, so that every modification is attributed to an actor, logged, and recorded in an audit log.
+type Admin struct {
+	Store  MutableSetStore
+	Audit  AuditLog
+	Logger *slog.Logger
+}
+
+// Logs a change, and records it in the audit log. Called by the other methods; can also be used for changes made outside of Admin (eg, reloading sets from a file).
+func (a *Admin) Record(ctx context.Context, change SetChange) error {
+	change.Time = time.Now()
+	logger := a.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.Info("set modified", "actor", change.Actor, "action", change.Action, "set", change.Set, "type", change.Type, "count", len(change.Values))
+	if a.Audit == nil {
+		return nil
+	}
+	if err := a.Audit.Record(ctx, change); err != nil {
+		return fmt.Errorf("recording set change in audit log: %w", err)
+	}
+	return nil
+}
+
+func (a *Admin) Add(ctx context.Context, actor, name, typ string, vals []string) error {
+	if err := a.Store.Add(ctx, name, typ, vals); err != nil {
+		return err
+	}
+	return a.Record(ctx, SetChange{Actor: actor, Action: "add", Set: name, Type: typ, Values: vals})
+}
+
+func (a *Admin) Remove(ctx context.Context, actor, name string, vals []string) error {
+	if err := a.Store.Remove(ctx, name, vals); err != nil {
+		return err
+	}
+	return a.Record(ctx, SetChange{Actor: actor, Action: "remove", Set: name, Values: vals})
+}
+
+func (a *Admin) Replace(ctx context.Context, actor, name, typ string, vals []string) error {
+	if err := a.Store.Replace(ctx, name, typ, vals); err != nil {
+		return err
+	}
+	return a.Record(ctx, SetChange{Actor: actor, Action: "replace", Set: name, Type: typ, Values: vals})
+}
+
+func (a *Admin) DeleteSet(ctx context.Context, actor, name string) error {
+	if err := a.Store.DeleteSet(ctx, name); err != nil {
+		return err
+	}
+	return a.Record(ctx, SetChange{Actor: actor, Action: "delete", Set: name})
+}
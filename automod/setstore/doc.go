@@ -1,2 +1,6 @@
 // Interface for simple sets of strings, with fast inclusion checks.
+//
+// Sets have a type, which determines how values are matched against members: exact match (the default), domain-style suffix match, CIDR prefixes, or regular expressions. Sets can be loaded from a JSON file, and stores implementing [MutableSetStore] (in-memory and Redis) can be modified at runtime; [Admin] attributes such modifications to an actor, and records them in an [AuditLog].
+//
+// Compatibility note: [MemSetStore] changed incompatibly when sets became typed and modifiable at runtime. [NewMemSetStore] now returns a pointer; the exported Sets map was removed (use [MemSetStore.Load], or the [MutableSetStore] methods, instead); and [MemSetStore.LoadFromFileJSON] now replaces all sets in the store, instead of merging the file into the existing sets.
 package setstore
@@ -0,0 +1,153 @@
+package setstore
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+)
+
+// Set types, which determine how values are matched against set members.
+const (
+	// Value is exactly equal to a member. This is the default
+	TypeExact = "exact"
+	// Value is equal to a member, or is a dot-separated "sub-name" of a member (eg, member "example.com" matches "example.com" and "www.example.com", but not "badexample.com"). Members with a leading dot (".example.com") only match sub-names
+	TypeSuffix = "suffix"
+	// Value is an IP address contained in a member CIDR prefix (eg, "192.0.2.0/24"). Members may also be single IP addresses
+	TypeCIDR = "cidr"
+	// Value matches a member regular expression (RE2 syntax, unanchored)
+	TypeRegex = "regex"
+)
+
+// Normalizes a set type name: empty means [TypeExact]. Returns an error for unknown types.
+func ParseType(typ string) (string, error) {
+	switch typ {
+	case "", TypeExact:
+		return TypeExact, nil
+	case TypeSuffix, TypeCIDR, TypeRegex:
+		return typ, nil
+	}
+	return "", fmt.Errorf("unknown set type: %s", typ)
+}
+
+// Checks that a value is a valid member of a set of the given type (eg, that a regex compiles).
+func ValidateMember(typ, val string) error {
+	if val == "" {
+		return fmt.Errorf("empty set member")
+	}
+	switch typ {
+	case TypeCIDR:
+		_, err := parsePrefix(val)
+		return err
+	case TypeRegex:
+		_, err := regexp.Compile(val)
+		return err
+	}
+	return nil
+}
+
+// parses a CIDR prefix, or a single IP address as a full-length prefix
+func parsePrefix(val string) (netip.Prefix, error) {
+	if strings.Contains(val, "/") {
+		p, err := netip.ParsePrefix(val)
+		if err != nil {
+			return p, err
+		}
+		return p.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(val)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Returns the strings which a value could be equal to, to be a member of a suffix set: the value itself, and every sub-name with and without the leading dot.
+func suffixCandidates(val string) []string {
+	out := []string{val}
+	for i := 0; i < len(val); i++ {
+		if val[i] != '.' {
+			continue
+		}
+		out = append(out, val[i:])
+		if i+1 < len(val) {
+			out = append(out, val[i+1:])
+		}
+	}
+	return out
+}
+
+// Compiled form of a set, for inclusion checks. Immutable once built.
+type matcher struct {
+	typ      string
+	members  map[string]bool
+	prefixes []netip.Prefix
+	regexes  []*regexp.Regexp
+}
+
+func newMatcher(typ string, members []string) (*matcher, error) {
+	typ, err := ParseType(typ)
+	if err != nil {
+		return nil, err
+	}
+	m := &matcher{
+		typ:     typ,
+		members: make(map[string]bool, len(members)),
+	}
+	for _, val := range members {
+		if err := ValidateMember(typ, val); err != nil {
+			return nil, fmt.Errorf("invalid %s set member %q: %w", typ, val, err)
+		}
+		m.members[val] = true
+		switch typ {
+		case TypeCIDR:
+			p, _ := parsePrefix(val)
+			m.prefixes = append(m.prefixes, p)
+		case TypeRegex:
+			m.regexes = append(m.regexes, regexp.MustCompile(val))
+		}
+	}
+	return m, nil
+}
+
+func (m *matcher) match(val string) bool {
+	switch m.typ {
+	case TypeSuffix:
+		for _, c := range suffixCandidates(val) {
+			if m.members[c] {
+				return true
+			}
+		}
+		return false
+	case TypeCIDR:
+		addr, err := netip.ParseAddr(val)
+		if err != nil {
+			return false
+		}
+		addr = addr.Unmap()
+		for _, p := range m.prefixes {
+			if p.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	case TypeRegex:
+		for _, re := range m.regexes {
+			if re.MatchString(val) {
+				return true
+			}
+		}
+		return false
+	default:
+		return m.members[val]
+	}
+}
+
+func (m *matcher) memberList() []string {
+	out := make([]string, 0, len(m.members))
+	for val := range m.members {
+		out = append(out, val)
+	}
+	return out
+}
@@ -3,6 +3,7 @@ package setstore
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
 )
@@ -11,51 +12,86 @@ type SetStore interface {
 	InSet(ctx context.Context, name, val string) (bool, error)
 }
 
-// TODO: this implementation isn't race-safe (yet)!
-type MemSetStore struct {
-	Sets map[string]map[string]bool
+// A SetStore which can be modified at runtime (eg, by moderators adding a domain to a set), without a restart.
+type MutableSetStore interface {
+	SetStore
+	// Returns the name, type, and size of every set, sorted by name
+	ListSets(ctx context.Context) ([]SetInfo, error)
+	// Returns the members of a set, sorted. Returns an empty list if the set doesn't exist
+	Members(ctx context.Context, name string) ([]string, error)
+	// Adds members to a set, creating it (with the given type) if it doesn't exist. If the type is empty, an existing set's type is kept (or exact match is used for new sets); otherwise it must match the type of an existing set
+	Add(ctx context.Context, name, typ string, vals []string) error
+	// Removes members from a set. Removing values which aren't members is not an error
+	Remove(ctx context.Context, name string, vals []string) error
+	// Atomically replaces the type and members of a set, creating it if needed
+	Replace(ctx context.Context, name, typ string, vals []string) error
+	// Removes a set entirely
+	DeleteSet(ctx context.Context, name string) error
 }
 
-func NewMemSetStore() MemSetStore {
-	return MemSetStore{
-		Sets: make(map[string]map[string]bool),
-	}
+type SetInfo struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Size int    `json:"size"`
+}
+
+// Definition of a single set, as loaded from a file.
+//
+// In JSON, a set is either a plain array of members (an exact-match set), or an object with "type" and "values" fields.
+type SetDef struct {
+	Type   string   `json:"type,omitempty"`
+	Values []string `json:"values"`
 }
 
-func (s MemSetStore) InSet(ctx context.Context, name, val string) (bool, error) {
-	set, ok := s.Sets[name]
-	if !ok {
-		// NOTE: currently returns false when entire set isn't found
-		return false, nil
+func (d *SetDef) UnmarshalJSON(raw []byte) error {
+	var vals []string
+	if err := json.Unmarshal(raw, &vals); err == nil {
+		d.Type = TypeExact
+		d.Values = vals
+		return nil
+	}
+	type setDef SetDef
+	var out setDef
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return err
 	}
-	_, ok = set[val]
-	return ok, nil
+	*d = SetDef(out)
+	return nil
 }
 
-func (s *MemSetStore) LoadFromFileJSON(p string) error {
+// Parses a JSON object mapping set names to definitions (see [SetDef]), and validates the types and members of every set.
+func ParseSetsJSON(raw []byte) (map[string]SetDef, error) {
+	var sets map[string]SetDef
+	if err := json.Unmarshal(raw, &sets); err != nil {
+		return nil, err
+	}
+	for name, def := range sets {
+		typ, err := ParseType(def.Type)
+		if err != nil {
+			return nil, fmt.Errorf("set %s: %w", name, err)
+		}
+		for _, val := range def.Values {
+			if err := ValidateMember(typ, val); err != nil {
+				return nil, fmt.Errorf("set %s: invalid member %q: %w", name, val, err)
+			}
+		}
+		def.Type = typ
+		sets[name] = def
+	}
+	return sets, nil
+}
 
+// Reads and parses a sets JSON file (see [ParseSetsJSON]).
+func ReadSetsFileJSON(p string) (map[string]SetDef, error) {
 	f, err := os.Open(p)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer func() { _ = f.Close() }()
 
 	raw, err := io.ReadAll(f)
 	if err != nil {
-		return err
-	}
-
-	var rules map[string][]string
-	if err := json.Unmarshal(raw, &rules); err != nil {
-		return err
+		return nil, err
 	}
-
-	for name, l := range rules {
-		m := make(map[string]bool, len(l))
-		for _, val := range l {
-			m[val] = true
-		}
-		s.Sets[name] = m
-	}
-	return nil
+	return ParseSetsJSON(raw)
 }
@@ -0,0 +1,147 @@
+package setstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// In-memory set store. Safe for concurrent use: inclusion checks read an immutable snapshot of all sets without locking, and every modification (or reload) atomically swaps in a new snapshot.
+type MemSetStore struct {
+	// serializes modifications
+	lk   sync.Mutex
+	sets atomic.Pointer[map[string]*matcher]
+}
+
+func NewMemSetStore() *MemSetStore {
+	s := &MemSetStore{}
+	s.sets.Store(&map[string]*matcher{})
+	return s
+}
+
+func (s *MemSetStore) snapshot() map[string]*matcher {
+	return *s.sets.Load()
+}
+
+func (s *MemSetStore) InSet(ctx context.Context, name, val string) (bool, error) {
+	set, ok := s.snapshot()[name]
+	if !ok {
+		// NOTE: currently returns false when entire set isn't found
+		return false, nil
+	}
+	return set.match(val), nil
+}
+
+// Atomically replaces the entire contents of the store with the given sets. Sets which are not included are dropped.
+func (s *MemSetStore) Load(sets map[string]SetDef) error {
+	next := make(map[string]*matcher, len(sets))
+	for name, def := range sets {
+		m, err := newMatcher(def.Type, def.Values)
+		if err != nil {
+			return fmt.Errorf("set %s: %w", name, err)
+		}
+		next[name] = m
+	}
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.sets.Store(&next)
+	return nil
+}
+
+// Atomically replaces the entire contents of the store with sets from a JSON file (see [ParseSetsJSON]). Can be called again to reload the file; if the file is invalid, the current sets are kept.
+func (s *MemSetStore) LoadFromFileJSON(p string) error {
+	sets, err := ReadSetsFileJSON(p)
+	if err != nil {
+		return err
+	}
+	return s.Load(sets)
+}
+
+// copy-on-write update of a single set. if the update function returns a nil matcher, the set is removed
+func (s *MemSetStore) update(name string, fn func(cur *matcher) (*matcher, error)) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	cur := s.snapshot()
+	m, err := fn(cur[name])
+	if err != nil {
+		return err
+	}
+	next := make(map[string]*matcher, len(cur)+1)
+	for k, v := range cur {
+		next[k] = v
+	}
+	if m == nil {
+		delete(next, name)
+	} else {
+		next[name] = m
+	}
+	s.sets.Store(&next)
+	return nil
+}
+
+func (s *MemSetStore) ListSets(ctx context.Context) ([]SetInfo, error) {
+	sets := s.snapshot()
+	out := make([]SetInfo, 0, len(sets))
+	for name, m := range sets {
+		out = append(out, SetInfo{Name: name, Type: m.typ, Size: len(m.members)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (s *MemSetStore) Members(ctx context.Context, name string) ([]string, error) {
+	m, ok := s.snapshot()[name]
+	if !ok {
+		return []string{}, nil
+	}
+	out := m.memberList()
+	sort.Strings(out)
+	return out, nil
+}
+
+func (s *MemSetStore) Add(ctx context.Context, name, typ string, vals []string) error {
+	return s.update(name, func(cur *matcher) (*matcher, error) {
+		members := []string{}
+		if cur != nil {
+			if typ != "" && typ != cur.typ {
+				return nil, fmt.Errorf("set %s has type %s, not %s", name, cur.typ, typ)
+			}
+			typ = cur.typ
+			members = cur.memberList()
+		}
+		return newMatcher(typ, append(members, vals...))
+	})
+}
+
+func (s *MemSetStore) Remove(ctx context.Context, name string, vals []string) error {
+	return s.update(name, func(cur *matcher) (*matcher, error) {
+		if cur == nil {
+			return nil, nil
+		}
+		drop := make(map[string]bool, len(vals))
+		for _, v := range vals {
+			drop[v] = true
+		}
+		members := []string{}
+		for v := range cur.members {
+			if !drop[v] {
+				members = append(members, v)
+			}
+		}
+		return newMatcher(cur.typ, members)
+	})
+}
+
+func (s *MemSetStore) Replace(ctx context.Context, name, typ string, vals []string) error {
+	return s.update(name, func(cur *matcher) (*matcher, error) {
+		return newMatcher(typ, vals)
+	})
+}
+
+func (s *MemSetStore) DeleteSet(ctx context.Context, name string) error {
+	return s.update(name, func(cur *matcher) (*matcher, error) {
+		return nil, nil
+	})
+}
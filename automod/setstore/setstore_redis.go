@@ -0,0 +1,259 @@
+package setstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var redisSetsPrefix string = "sets/"
+var redisSetTypesKey string = "sets-types"
+
+// Set store backed by Redis, so sets can be shared and updated across processes. Each set is a Redis set, and set types are stored in a separate hash.
+//
+// Exact and suffix sets are checked in Redis directly. CIDR and regex sets are fetched and compiled in-process, and cached (as are set types) for CacheTTL, so changes made by other processes can take that long to be seen.
+type RedisSetStore struct {
+	Client   *redis.Client
+	CacheTTL time.Duration
+
+	lk       sync.Mutex
+	types    map[string]string
+	typesAt  time.Time
+	matchers map[string]cachedMatcher
+}
+
+type cachedMatcher struct {
+	m  *matcher
+	at time.Time
+}
+
+func NewRedisSetStore(redisURL string) (*RedisSetStore, error) {
+	ctx := context.Background()
+	opt, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	rdb := redis.NewClient(opt)
+	// check redis connection
+	_, err = rdb.Ping(ctx).Result()
+	if err != nil {
+		return nil, err
+	}
+	rss := RedisSetStore{
+		Client:   rdb,
+		CacheTTL: 30 * time.Second,
+		matchers: make(map[string]cachedMatcher),
+	}
+	return &rss, nil
+}
+
+// drops cached set types and compiled sets, after a local modification
+func (s *RedisSetStore) invalidate() {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.types = nil
+	s.matchers = make(map[string]cachedMatcher)
+}
+
+// returns the type of a set, or empty string if the set doesn't exist
+func (s *RedisSetStore) setType(ctx context.Context, name string) (string, error) {
+	s.lk.Lock()
+	if s.types != nil && time.Since(s.typesAt) < s.CacheTTL {
+		typ := s.types[name]
+		s.lk.Unlock()
+		return typ, nil
+	}
+	s.lk.Unlock()
+
+	types, err := s.Client.HGetAll(ctx, redisSetTypesKey).Result()
+	if err != nil && err != redis.Nil {
+		return "", err
+	}
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.types = types
+	s.typesAt = time.Now()
+	return types[name], nil
+}
+
+// returns a compiled (CIDR or regex) set, from cache if fresh
+func (s *RedisSetStore) matcher(ctx context.Context, name, typ string) (*matcher, error) {
+	s.lk.Lock()
+	cm, ok := s.matchers[name]
+	s.lk.Unlock()
+	if ok && cm.m.typ == typ && time.Since(cm.at) < s.CacheTTL {
+		return cm.m, nil
+	}
+
+	members, err := s.Client.SMembers(ctx, redisSetsPrefix+name).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	m, err := newMatcher(typ, members)
+	if err != nil {
+		return nil, fmt.Errorf("set %s: %w", name, err)
+	}
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if s.matchers == nil {
+		s.matchers = make(map[string]cachedMatcher)
+	}
+	s.matchers[name] = cachedMatcher{m: m, at: time.Now()}
+	return m, nil
+}
+
+func (s *RedisSetStore) InSet(ctx context.Context, name, val string) (bool, error) {
+	typ, err := s.setType(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	rkey := redisSetsPrefix + name
+	switch typ {
+	case "":
+		// NOTE: currently returns false when entire set isn't found
+		return false, nil
+	case TypeExact:
+		return s.Client.SIsMember(ctx, rkey, val).Result()
+	case TypeSuffix:
+		candidates := suffixCandidates(val)
+		l := make([]interface{}, len(candidates))
+		for i, c := range candidates {
+			l[i] = c
+		}
+		found, err := s.Client.SMIsMember(ctx, rkey, l...).Result()
+		if err != nil {
+			return false, err
+		}
+		for _, ok := range found {
+			if ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		m, err := s.matcher(ctx, name, typ)
+		if err != nil {
+			return false, err
+		}
+		return m.match(val), nil
+	}
+}
+
+func (s *RedisSetStore) ListSets(ctx context.Context) ([]SetInfo, error) {
+	types, err := s.Client.HGetAll(ctx, redisSetTypesKey).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	out := make([]SetInfo, 0, len(types))
+	for name, typ := range types {
+		out = append(out, SetInfo{Name: name, Type: typ})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+
+	pipe := s.Client.Pipeline()
+	sizes := make([]*redis.IntCmd, len(out))
+	for i, info := range out {
+		sizes[i] = pipe.SCard(ctx, redisSetsPrefix+info.Name)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	for i := range out {
+		out[i].Size = int(sizes[i].Val())
+	}
+	return out, nil
+}
+
+func (s *RedisSetStore) Members(ctx context.Context, name string) ([]string, error) {
+	l, err := s.Client.SMembers(ctx, redisSetsPrefix+name).Result()
+	if err == redis.Nil {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	sort.Strings(l)
+	return l, nil
+}
+
+func (s *RedisSetStore) Add(ctx context.Context, name, typ string, vals []string) error {
+	cur, err := s.Client.HGet(ctx, redisSetTypesKey, name).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	if cur != "" {
+		if typ != "" && typ != cur {
+			return fmt.Errorf("set %s has type %s, not %s", name, cur, typ)
+		}
+		typ = cur
+	}
+	typ, err = ParseType(typ)
+	if err != nil {
+		return err
+	}
+	l := []interface{}{}
+	for _, v := range vals {
+		if err := ValidateMember(typ, v); err != nil {
+			return fmt.Errorf("invalid %s set member %q: %w", typ, v, err)
+		}
+		l = append(l, v)
+	}
+
+	defer s.invalidate()
+	multi := s.Client.TxPipeline()
+	multi.HSetNX(ctx, redisSetTypesKey, name, typ)
+	if len(l) > 0 {
+		multi.SAdd(ctx, redisSetsPrefix+name, l...)
+	}
+	_, err = multi.Exec(ctx)
+	return err
+}
+
+func (s *RedisSetStore) Remove(ctx context.Context, name string, vals []string) error {
+	if len(vals) == 0 {
+		return nil
+	}
+	l := []interface{}{}
+	for _, v := range vals {
+		l = append(l, v)
+	}
+	defer s.invalidate()
+	return s.Client.SRem(ctx, redisSetsPrefix+name, l...).Err()
+}
+
+func (s *RedisSetStore) Replace(ctx context.Context, name, typ string, vals []string) error {
+	typ, err := ParseType(typ)
+	if err != nil {
+		return err
+	}
+	l := []interface{}{}
+	for _, v := range vals {
+		if err := ValidateMember(typ, v); err != nil {
+			return fmt.Errorf("invalid %s set member %q: %w", typ, v, err)
+		}
+		l = append(l, v)
+	}
+
+	defer s.invalidate()
+	rkey := redisSetsPrefix + name
+	multi := s.Client.TxPipeline()
+	multi.Del(ctx, rkey)
+	if len(l) > 0 {
+		multi.SAdd(ctx, rkey, l...)
+	}
+	multi.HSet(ctx, redisSetTypesKey, name, typ)
+	_, err = multi.Exec(ctx)
+	return err
+}
+
+func (s *RedisSetStore) DeleteSet(ctx context.Context, name string) error {
+	defer s.invalidate()
+	multi := s.Client.TxPipeline()
+	multi.Del(ctx, redisSetsPrefix+name)
+	multi.HDel(ctx, redisSetTypesKey, name)
+	_, err := multi.Exec(ctx)
+	return err
+}
@@ -0,0 +1,46 @@
+package setstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisSetStoreBasics(t *testing.T) {
+	t.Skip("live test, need redis running locally")
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	s, err := NewRedisSetStore("redis://localhost:6379/0")
+	if err != nil {
+		t.Fail()
+	}
+
+	assert.NoError(s.Replace(ctx, "test-words", "", []string{"red", "green"}))
+	ok, err := s.InSet(ctx, "test-words", "red")
+	assert.NoError(err)
+	assert.True(ok)
+	assert.NoError(s.Remove(ctx, "test-words", []string{"red"}))
+	ok, err = s.InSet(ctx, "test-words", "red")
+	assert.NoError(err)
+	assert.False(ok)
+
+	assert.NoError(s.Replace(ctx, "test-domains", TypeSuffix, []string{"example.com"}))
+	ok, err = s.InSet(ctx, "test-domains", "www.example.com")
+	assert.NoError(err)
+	assert.True(ok)
+
+	assert.NoError(s.Add(ctx, "test-nets", TypeCIDR, []string{"192.0.2.0/24"}))
+	ok, err = s.InSet(ctx, "test-nets", "192.0.2.1")
+	assert.NoError(err)
+	assert.True(ok)
+
+	l, err := s.Members(ctx, "test-words")
+	assert.NoError(err)
+	assert.Equal([]string{"green"}, l)
+
+	for _, name := range []string{"test-words", "test-domains", "test-nets"} {
+		assert.NoError(s.DeleteSet(ctx, name))
+	}
+}
@@ -0,0 +1,132 @@
+package setstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemSetStoreBasics(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	s := NewMemSetStore()
+	ok, err := s.InSet(ctx, "bad-words", "hardr")
+	assert.NoError(err)
+	assert.False(ok)
+
+	assert.NoError(s.Add(ctx, "bad-words", "", []string{"hardr", "hardestr"}))
+	ok, err = s.InSet(ctx, "bad-words", "hardr")
+	assert.NoError(err)
+	assert.True(ok)
+
+	assert.Error(s.Add(ctx, "bad-words", TypeRegex, []string{"bad.*"}))
+	assert.NoError(s.Remove(ctx, "bad-words", []string{"hardr", "other"}))
+	ok, err = s.InSet(ctx, "bad-words", "hardr")
+	assert.NoError(err)
+	assert.False(ok)
+
+	l, err := s.Members(ctx, "bad-words")
+	assert.NoError(err)
+	assert.Equal([]string{"hardestr"}, l)
+
+	infos, err := s.ListSets(ctx)
+	assert.NoError(err)
+	assert.Equal([]SetInfo{{Name: "bad-words", Type: TypeExact, Size: 1}}, infos)
+
+	assert.NoError(s.DeleteSet(ctx, "bad-words"))
+	infos, err = s.ListSets(ctx)
+	assert.NoError(err)
+	assert.Empty(infos)
+}
+
+func TestSetTypes(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	s := NewMemSetStore()
+	assert.NoError(s.Replace(ctx, "domains", TypeSuffix, []string{"example.com", ".sub.example.org"}))
+	assert.NoError(s.Replace(ctx, "networks", TypeCIDR, []string{"192.0.2.0/24", "2001:db8::/32", "198.51.100.7"}))
+	assert.NoError(s.Replace(ctx, "patterns", TypeRegex, []string{"^spam[0-9]+$"}))
+	assert.Error(s.Replace(ctx, "networks", TypeCIDR, []string{"192.0.2.0/33"}))
+	assert.Error(s.Replace(ctx, "patterns", TypeRegex, []string{"(unclosed"}))
+	assert.Error(s.Replace(ctx, "other", "glob", []string{"*"}))
+
+	testCases := []struct {
+		set      string
+		val      string
+		expected bool
+	}{
+		{"domains", "example.com", true},
+		{"domains", "www.example.com", true},
+		{"domains", "badexample.com", false},
+		{"domains", "sub.example.org", false},
+		{"domains", "a.sub.example.org", true},
+		{"networks", "192.0.2.99", true},
+		{"networks", "192.0.3.1", false},
+		{"networks", "::ffff:192.0.2.1", true},
+		{"networks", "2001:db8::1", true},
+		{"networks", "198.51.100.7", true},
+		{"networks", "not-an-ip", false},
+		{"patterns", "spam123", true},
+		{"patterns", "nospam123", false},
+	}
+	for _, tc := range testCases {
+		ok, err := s.InSet(ctx, tc.set, tc.val)
+		assert.NoError(err)
+		assert.Equal(tc.expected, ok, "%s: %s", tc.set, tc.val)
+	}
+}
+
+func TestLoadFromFileJSON(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	p := filepath.Join(t.TempDir(), "sets.json")
+	assert.NoError(os.WriteFile(p, []byte(`{"bad-words": ["hardr"], "promo-domain": {"type": "suffix", "values": ["example.com"]}}`), 0644))
+
+	s := NewMemSetStore()
+	assert.NoError(s.Add(ctx, "stale", "", []string{"val"}))
+	assert.NoError(s.LoadFromFileJSON(p))
+
+	ok, err := s.InSet(ctx, "promo-domain", "shop.example.com")
+	assert.NoError(err)
+	assert.True(ok)
+	ok, err = s.InSet(ctx, "bad-words", "hardr")
+	assert.NoError(err)
+	assert.True(ok)
+	// reloading replaces all sets
+	ok, err = s.InSet(ctx, "stale", "val")
+	assert.NoError(err)
+	assert.False(ok)
+
+	// an invalid file keeps the current sets
+	assert.NoError(os.WriteFile(p, []byte(`{"nets": {"type": "cidr", "values": ["nope"]}}`), 0644))
+	assert.Error(s.LoadFromFileJSON(p))
+	ok, err = s.InSet(ctx, "bad-words", "hardr")
+	assert.NoError(err)
+	assert.True(ok)
+}
+
+func TestAdminAudit(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	audit := NewMemAuditLog(2)
+	admin := Admin{Store: NewMemSetStore(), Audit: audit}
+	assert.NoError(admin.Add(ctx, "alice", "bad-words", "", []string{"one"}))
+	assert.NoError(admin.Add(ctx, "bob", "bad-words", "", []string{"two"}))
+	assert.NoError(admin.Remove(ctx, "carol", "bad-words", []string{"one"}))
+	assert.Error(admin.Add(ctx, "dave", "bad-words", TypeCIDR, []string{"10.0.0.0/8"}))
+
+	l, err := audit.Recent(ctx, 10)
+	assert.NoError(err)
+	assert.Equal(2, len(l))
+	assert.Equal("carol", l[0].Actor)
+	assert.Equal("remove", l[0].Action)
+	assert.Equal([]string{"one"}, l[0].Values)
+	assert.Equal("bob", l[1].Actor)
+}
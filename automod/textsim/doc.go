@@ -0,0 +1,6 @@
+// Near-duplicate text detection (eg, copy-paste spam posted from many accounts), using MinHash signatures over word shingles, and an index of recent signatures with separate implementations using redis and in-process memory.
+//
+// Text is tokenized and normalized with the keyword package, so trivial variations (case, punctuation, accents, emoji) don't affect similarity. Signatures are indexed with locality-sensitive hashing (LSH): each signature is split in to bands, and texts sharing any band are compared. With the default parameters, texts with a Jaccard similarity above about 0.5 are very likely to be found.
+//
+// SimHash is also provided, as a compact 64-bit alternative which can be indexed with the hashstore package.
+package textsim
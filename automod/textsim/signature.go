@@ -0,0 +1,132 @@
+package textsim
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+
+	"github.com/bluesky-social/indigo/automod/keyword"
+)
+
+const (
+	// Number of hash functions (values) in a MinHash signature
+	SignatureSize = 64
+	// Number of words in each shingle
+	ShingleSize = 3
+)
+
+// A MinHash signature: for each of a fixed set of hash functions, the minimum hash value over all shingles of a text. The fraction of values two signatures share estimates the Jaccard similarity of their shingle sets.
+type Signature []uint32
+
+// per-hash-function seeds, derived deterministically so signatures are comparable between processes
+var signatureSeeds = func() [SignatureSize]uint64 {
+	var out [SignatureSize]uint64
+	for i := range out {
+		out[i] = splitmix64(uint64(i) + 0x9e3779b97f4a7c15)
+	}
+	return out
+}()
+
+// splitmix64 finalizer: a fast, well-distributed 64-bit mixing function
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// Splits text in to normalized tokens (see keyword.TokenizeText), and returns the distinct word shingles (runs of ShingleSize tokens). Texts with fewer tokens than that have the whole text as a single shingle. Returns nil if there are no tokens.
+func Shingles(text string) []string {
+	return shingleTokens(keyword.TokenizeText(text))
+}
+
+func shingleTokens(tokens []string) []string {
+	if len(tokens) == 0 {
+		return nil
+	}
+	if len(tokens) <= ShingleSize {
+		return []string{strings.Join(tokens, " ")}
+	}
+	seen := make(map[string]bool, len(tokens))
+	out := make([]string, 0, len(tokens)-ShingleSize+1)
+	for i := 0; i+ShingleSize <= len(tokens); i++ {
+		s := strings.Join(tokens[i:i+ShingleSize], " ")
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Computes the MinHash signature of text. Returns nil if the text has no tokens.
+func MinHash(text string) Signature {
+	return minHashShingles(Shingles(text))
+}
+
+func minHashShingles(shingles []string) Signature {
+	if len(shingles) == 0 {
+		return nil
+	}
+	sig := make(Signature, SignatureSize)
+	for i := range sig {
+		sig[i] = ^uint32(0)
+	}
+	for _, s := range shingles {
+		x := hashString(s)
+		for i, seed := range signatureSeeds {
+			if v := uint32(splitmix64(x ^ seed)); v < sig[i] {
+				sig[i] = v
+			}
+		}
+	}
+	return sig
+}
+
+// Estimated Jaccard similarity (0.0 to 1.0) of the texts two signatures were computed from. Signatures of different sizes (or empty signatures) have zero similarity.
+func Similarity(a, b Signature) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a))
+}
+
+// Computes the 64-bit SimHash of text, over the same shingles as MinHash. Similar texts have hashes with a small Hamming distance, so these can be indexed with hashstore. Returns zero if the text has no tokens.
+func SimHash(text string) uint64 {
+	var weights [64]int
+	shingles := Shingles(text)
+	for _, s := range shingles {
+		x := hashString(s)
+		for i := 0; i < 64; i++ {
+			if x&(1<<i) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+	var out uint64
+	for i, w := range weights {
+		if w > 0 {
+			out |= 1 << i
+		}
+	}
+	return out
+}
+
+// Hamming distance between two SimHash values.
+func SimHashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
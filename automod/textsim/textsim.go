@@ -0,0 +1,77 @@
+package textsim
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Default LSH banding: SignatureSize values split in to 16 bands of 4. Texts with Jaccard similarity s share at least one band with probability 1-(1-s^4)^16: about 0.65 at s=0.4, 0.96 at s=0.6, and over 0.999 at s=0.8.
+const DefaultBands = 16
+
+// A single indexed text signature.
+type Entry struct {
+	// Identifier of the text (eg, a record AT-URI). An entry with the same ID replaces an existing one
+	ID string `json:"id"`
+	// Account which posted the text
+	Account   string    `json:"account"`
+	Signature Signature `json:"sig"`
+	// When the entry was added
+	Time time.Time `json:"time"`
+}
+
+type Match struct {
+	Entry
+	// Estimated Jaccard similarity to the looked-up signature
+	Similarity float64 `json:"similarity"`
+}
+
+// TextStore is an index of recent text signatures, in separate namespaces (eg, for posts and profile descriptions).
+//
+// Entries are kept for a retention period (configured per implementation), after which they may expire.
+type TextStore interface {
+	Add(ctx context.Context, namespace string, entry Entry) error
+	// Returns entries with estimated similarity at or above minSimilarity, added at or after "since" (the zero time means any), sorted by similarity
+	Lookup(ctx context.Context, namespace string, sig Signature, minSimilarity float64, since time.Time) ([]Match, error)
+}
+
+// Counts the distinct accounts in a set of matches, not including the given account (which may be empty).
+func DistinctAccounts(matches []Match, exclude string) int {
+	seen := make(map[string]bool, len(matches))
+	for _, m := range matches {
+		if m.Account != exclude {
+			seen[m.Account] = true
+		}
+	}
+	return len(seen)
+}
+
+// Splits a signature in to the given number of bands, and hashes each band. Each value includes the band index, so values from different bands never collide.
+func bandValues(sig Signature, bands int) []uint64 {
+	if len(sig) == 0 || bands <= 0 {
+		return nil
+	}
+	rows := len(sig) / bands
+	if rows == 0 {
+		rows, bands = 1, len(sig)
+	}
+	out := make([]uint64, bands)
+	for i := 0; i < bands; i++ {
+		x := uint64(i)
+		for _, v := range sig[i*rows : (i+1)*rows] {
+			x = splitmix64(x ^ uint64(v))
+		}
+		out[i] = x
+	}
+	return out
+}
+
+// sorts by similarity, then newest first
+func sortMatches(l []Match) {
+	sort.Slice(l, func(i, j int) bool {
+		if l[i].Similarity != l[j].Similarity {
+			return l[i].Similarity > l[j].Similarity
+		}
+		return l[i].Time.After(l[j].Time)
+	})
+}
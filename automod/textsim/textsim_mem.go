@@ -0,0 +1,112 @@
+package textsim
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// In-process text signature index. Safe for concurrent use.
+type MemTextStore struct {
+	Bands int
+	// How long entries are kept (relative to the newest entry in the namespace). Zero means forever
+	Retention time.Duration
+
+	lk         sync.Mutex
+	namespaces map[string]*memIndex
+}
+
+type memIndex struct {
+	entries map[string]Entry
+	// band value to entry IDs
+	bands map[uint64]map[string]bool
+	// newest entry time, and when expired entries were last dropped
+	latest    time.Time
+	lastPrune time.Time
+}
+
+func NewMemTextStore(retention time.Duration) *MemTextStore {
+	return &MemTextStore{
+		Bands:      DefaultBands,
+		Retention:  retention,
+		namespaces: make(map[string]*memIndex),
+	}
+}
+
+func (s *MemTextStore) Add(ctx context.Context, namespace string, entry Entry) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	idx, ok := s.namespaces[namespace]
+	if !ok {
+		idx = &memIndex{
+			entries: make(map[string]Entry),
+			bands:   make(map[uint64]map[string]bool),
+		}
+		s.namespaces[namespace] = idx
+	}
+	if prev, ok := idx.entries[entry.ID]; ok {
+		s.unindex(idx, prev)
+	}
+	idx.entries[entry.ID] = entry
+	for _, bv := range bandValues(entry.Signature, s.Bands) {
+		m, ok := idx.bands[bv]
+		if !ok {
+			m = make(map[string]bool)
+			idx.bands[bv] = m
+		}
+		m[entry.ID] = true
+	}
+	if entry.Time.After(idx.latest) {
+		idx.latest = entry.Time
+	}
+	// scanning every entry is expensive, so only prune occasionally
+	if s.Retention > 0 && idx.latest.Sub(idx.lastPrune) > s.Retention/10 {
+		oldest := idx.latest.Add(-s.Retention)
+		for _, e := range idx.entries {
+			if e.Time.Before(oldest) {
+				s.unindex(idx, e)
+			}
+		}
+		idx.lastPrune = idx.latest
+	}
+	return nil
+}
+
+// removes an entry from the index. lock must be held
+func (s *MemTextStore) unindex(idx *memIndex, entry Entry) {
+	delete(idx.entries, entry.ID)
+	for _, bv := range bandValues(entry.Signature, s.Bands) {
+		delete(idx.bands[bv], entry.ID)
+		if len(idx.bands[bv]) == 0 {
+			delete(idx.bands, bv)
+		}
+	}
+}
+
+func (s *MemTextStore) Lookup(ctx context.Context, namespace string, sig Signature, minSimilarity float64, since time.Time) ([]Match, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	out := []Match{}
+	idx, ok := s.namespaces[namespace]
+	if !ok {
+		return out, nil
+	}
+	seen := make(map[string]bool)
+	for _, bv := range bandValues(sig, s.Bands) {
+		for id := range idx.bands[bv] {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			e := idx.entries[id]
+			if e.Time.Before(since) {
+				continue
+			}
+			if sim := Similarity(sig, e.Signature); sim >= minSimilarity {
+				out = append(out, Match{Entry: e, Similarity: sim})
+			}
+		}
+	}
+	sortMatches(out)
+	return out, nil
+}
@@ -0,0 +1,131 @@
+package textsim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var redisTextsimPrefix string = "textsim/"
+
+// Text signature index backed by redis. Each entry is stored as a JSON string, and each band value is a sorted set of entry IDs, scored by entry time.
+type RedisTextStore struct {
+	Client *redis.Client
+	Bands  int
+	// How long entries are kept. Zero means forever
+	Retention time.Duration
+	// Max number of entries (newest first) compared from each band on lookup, to bound the cost of very common texts. Zero means no limit
+	MaxBandCandidates int64
+}
+
+func NewRedisTextStore(redisURL string, retention time.Duration) (*RedisTextStore, error) {
+	ctx := context.Background()
+	opt, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	rdb := redis.NewClient(opt)
+	// check redis connection
+	_, err = rdb.Ping(ctx).Result()
+	if err != nil {
+		return nil, err
+	}
+	rts := RedisTextStore{
+		Client:            rdb,
+		Bands:             DefaultBands,
+		Retention:         retention,
+		MaxBandCandidates: 500,
+	}
+	return &rts, nil
+}
+
+func entryKey(namespace, id string) string {
+	return fmt.Sprintf("%s%s/entry/%s", redisTextsimPrefix, namespace, id)
+}
+
+func bandKey(namespace string, bv uint64) string {
+	return fmt.Sprintf("%s%s/band/%016x", redisTextsimPrefix, namespace, bv)
+}
+
+func (s *RedisTextStore) Add(ctx context.Context, namespace string, entry Entry) error {
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	score := float64(entry.Time.UnixMilli()) / 1000
+
+	// entry and all bands in a single redis round-trip. stale band members (from a replaced entry) are harmless: candidates are re-checked against the stored signature
+	multi := s.Client.Pipeline()
+	multi.Set(ctx, entryKey(namespace, entry.ID), raw, s.Retention)
+	for _, bv := range bandValues(entry.Signature, s.Bands) {
+		key := bandKey(namespace, bv)
+		multi.ZAdd(ctx, key, redis.Z{Score: score, Member: entry.ID})
+		if s.Retention > 0 {
+			oldest := float64(entry.Time.Add(-s.Retention).UnixMilli()) / 1000
+			multi.ZRemRangeByScore(ctx, key, "-inf", fmt.Sprintf("(%f", oldest))
+			multi.Expire(ctx, key, s.Retention)
+		}
+	}
+	_, err = multi.Exec(ctx)
+	return err
+}
+
+func (s *RedisTextStore) Lookup(ctx context.Context, namespace string, sig Signature, minSimilarity float64, since time.Time) ([]Match, error) {
+	out := []Match{}
+	bvs := bandValues(sig, s.Bands)
+	if len(bvs) == 0 {
+		return out, nil
+	}
+	minScore := "-inf"
+	if !since.IsZero() {
+		minScore = fmt.Sprintf("%f", float64(since.UnixMilli())/1000)
+	}
+	multi := s.Client.Pipeline()
+	var cmds []*redis.StringSliceCmd
+	for _, bv := range bvs {
+		cmds = append(cmds, multi.ZRevRangeByScore(ctx, bandKey(namespace, bv), &redis.ZRangeBy{Min: minScore, Max: "+inf", Count: s.MaxBandCandidates}))
+	}
+	if _, err := multi.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var keys []string
+	for _, cmd := range cmds {
+		for _, id := range cmd.Val() {
+			if !seen[id] {
+				seen[id] = true
+				keys = append(keys, entryKey(namespace, id))
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return out, nil
+	}
+	vals, err := s.Client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vals {
+		raw, ok := v.(string)
+		if !ok {
+			// expired
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal([]byte(raw), &e); err != nil {
+			continue
+		}
+		if e.Time.Before(since) {
+			continue
+		}
+		if sim := Similarity(sig, e.Signature); sim >= minSimilarity {
+			out = append(out, Match{Entry: e, Similarity: sim})
+		}
+	}
+	sortMatches(out)
+	return out, nil
+}
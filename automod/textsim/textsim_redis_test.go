@@ -0,0 +1,31 @@
+package textsim
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisTextStore(t *testing.T) {
+	t.Skip("live test, need redis running locally")
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	ts, err := NewRedisTextStore("redis://localhost:6379/0", time.Hour)
+	if err != nil {
+		t.Fail()
+	}
+
+	now := time.Now()
+	assert.NoError(ts.Add(ctx, "test-post", Entry{ID: "post1", Account: "did:plc:aaa", Signature: MinHash(spamText), Time: now}))
+	assert.NoError(ts.Add(ctx, "test-post", Entry{ID: "post2", Account: "did:plc:bbb", Signature: MinHash(spamVariant), Time: now}))
+	assert.NoError(ts.Add(ctx, "test-post", Entry{ID: "post3", Account: "did:plc:ccc", Signature: MinHash(otherText), Time: now}))
+
+	matches, err := ts.Lookup(ctx, "test-post", MinHash(spamText), 0.5, now.Add(-time.Minute))
+	assert.NoError(err)
+	assert.Equal(2, len(matches))
+	assert.Equal("post1", matches[0].ID)
+	assert.Equal(2, DistinctAccounts(matches, ""))
+}
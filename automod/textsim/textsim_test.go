@@ -0,0 +1,108 @@
+package textsim
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	spamText    = "Congratulations! You have been selected to receive a free crypto airdrop, claim your tokens now at the link in my profile before it expires"
+	spamVariant = "CONGRATULATIONS!!! you have been selected to receive a FREE crypto airdrop 🚀 claim your tokens now at the link in my bio before it expires"
+	otherText   = "Had a lovely walk along the river this morning, the herons were out and the water was calm and clear"
+)
+
+func TestShingles(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(Shingles(""))
+	assert.Nil(Shingles("!!! 🚀"))
+	assert.Equal([]string{"hello world"}, Shingles("Hello, World!"))
+	assert.Equal([]string{"one two three", "two three four"}, Shingles("one two three four"))
+	// repeated shingles are de-duplicated
+	assert.Equal([]string{"a b c", "b c a", "c a b"}, Shingles("a b c a b c"))
+}
+
+func TestMinHash(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(MinHash(""))
+	sig := MinHash(spamText)
+	assert.Equal(SignatureSize, len(sig))
+	// deterministic, and insensitive to normalization
+	assert.Equal(sig, MinHash(spamText))
+	assert.Equal(sig, MinHash("congratulations you have been selected to receive a free crypto airdrop claim your tokens now at the link in my profile before it expires"))
+	assert.Equal(1.0, Similarity(sig, MinHash(spamText)))
+
+	assert.Greater(Similarity(sig, MinHash(spamVariant)), 0.6)
+	assert.Less(Similarity(sig, MinHash(otherText)), 0.2)
+	assert.Equal(0.0, Similarity(sig, nil))
+}
+
+func TestSimHash(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(uint64(0), SimHash(""))
+	h := SimHash(spamText)
+	assert.Equal(h, SimHash(spamText))
+	assert.Less(SimHashDistance(h, SimHash(spamVariant)), SimHashDistance(h, SimHash(otherText)))
+}
+
+func TestDistinctAccounts(t *testing.T) {
+	assert := assert.New(t)
+
+	matches := []Match{
+		{Entry: Entry{ID: "a1", Account: "did:plc:aaa"}},
+		{Entry: Entry{ID: "a2", Account: "did:plc:aaa"}},
+		{Entry: Entry{ID: "b1", Account: "did:plc:bbb"}},
+		{Entry: Entry{ID: "c1", Account: "did:plc:ccc"}},
+	}
+	assert.Equal(3, DistinctAccounts(matches, ""))
+	assert.Equal(2, DistinctAccounts(matches, "did:plc:aaa"))
+	assert.Equal(0, DistinctAccounts(nil, ""))
+}
+
+func TestMemTextStore(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ts := NewMemTextStore(24 * time.Hour)
+
+	assert.NoError(ts.Add(ctx, "post", Entry{ID: "post1", Account: "did:plc:aaa", Signature: MinHash(spamText), Time: now}))
+	assert.NoError(ts.Add(ctx, "post", Entry{ID: "post2", Account: "did:plc:bbb", Signature: MinHash(spamVariant), Time: now.Add(time.Hour)}))
+	assert.NoError(ts.Add(ctx, "post", Entry{ID: "post3", Account: "did:plc:ccc", Signature: MinHash(otherText), Time: now.Add(time.Hour)}))
+
+	matches, err := ts.Lookup(ctx, "post", MinHash(spamText), 0.5, time.Time{})
+	assert.NoError(err)
+	assert.Equal(2, len(matches))
+	assert.Equal("post1", matches[0].ID)
+	assert.Equal(1.0, matches[0].Similarity)
+	assert.Equal("post2", matches[1].ID)
+
+	// time window
+	matches, err = ts.Lookup(ctx, "post", MinHash(spamText), 0.5, now.Add(30*time.Minute))
+	assert.NoError(err)
+	assert.Equal(1, len(matches))
+	assert.Equal("post2", matches[0].ID)
+
+	// namespaces are separate
+	matches, err = ts.Lookup(ctx, "profile", MinHash(spamText), 0.5, time.Time{})
+	assert.NoError(err)
+	assert.Empty(matches)
+
+	// replacing an entry with the same ID
+	assert.NoError(ts.Add(ctx, "post", Entry{ID: "post1", Account: "did:plc:aaa", Signature: MinHash(otherText), Time: now.Add(2 * time.Hour)}))
+	matches, err = ts.Lookup(ctx, "post", MinHash(spamText), 0.5, time.Time{})
+	assert.NoError(err)
+	assert.Equal(1, len(matches))
+	assert.Equal("post2", matches[0].ID)
+
+	// entries expire after the retention period
+	assert.NoError(ts.Add(ctx, "post", Entry{ID: "post4", Account: "did:plc:ddd", Signature: MinHash(otherText), Time: now.Add(26 * time.Hour)}))
+	matches, err = ts.Lookup(ctx, "post", MinHash(spamText), 0.5, time.Time{})
+	assert.NoError(err)
+	assert.Empty(matches)
+}
@@ -0,0 +1,88 @@
+package visual
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bluesky-social/indigo/automod"
+	"github.com/bluesky-social/indigo/automod/hashstore"
+	"github.com/bluesky-social/indigo/automod/imagehash"
+	lexutil "github.com/bluesky-social/indigo/lex/util"
+)
+
+const (
+	// hash index namespace for dHashes of all image blobs processed
+	ImageDHashNamespace = "image-dhash"
+	// hash index namespace for dHashes of known-bad images, added out of band (eg, with "hepa image-hash --add-known-bad"). Entry IDs are descriptions
+	KnownBadImageNamespace = "image-dhash-known-bad"
+	// max Hamming distance between dHashes for images to be considered the same
+	SimilarImageDistance = 4
+	// number of other blobs with the same image, within a day, for an image to be flagged as repeated
+	RepeatedImageThreshold = 10
+)
+
+// Computes the dHash of an image blob. Returns nil (and no error) for non-image blobs.
+//
+// Only the dHash is computed (not the pHash), because that is what the hash index is keyed on.
+func BlobImageDHash(blob lexutil.LexBlob, data []byte) (*imagehash.Hash, error) {
+	if !strings.HasPrefix(blob.MimeType, "image/") {
+		return nil, nil
+	}
+	start := time.Now()
+	defer func() {
+		imageHashDuration.Observe(time.Since(start).Seconds())
+	}()
+	img, err := imagehash.DecodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+	h := imagehash.DHash(img)
+	return &h, nil
+}
+
+// Counts distinct blobs, other than the one with the given CID, with a similar image (by dHash) seen within the window.
+func SimilarImageCount(c *automod.RecordContext, cid string, hash imagehash.Hash, window time.Duration) int {
+	count := 0
+	for _, m := range c.SimilarHashes(ImageDHashNamespace, uint64(hash), SimilarImageDistance, window) {
+		if m.ID != cid {
+			count++
+		}
+	}
+	return count
+}
+
+// Returns the closest known-bad image hash similar to the given dHash, or nil if there is none.
+func KnownBadImageMatch(c *automod.RecordContext, hash imagehash.Hash) *hashstore.Match {
+	matches := c.SimilarHashes(KnownBadImageNamespace, uint64(hash), SimilarImageDistance, 0)
+	if len(matches) == 0 {
+		return nil
+	}
+	return &matches[0]
+}
+
+// Hashes image blobs in-process, and adds them to the hash index. Flags (and reports) images similar to known-bad images, and flags images which have been posted as many different blobs (eg, the same spam image re-encoded to get a new CID).
+func ImageHashBlobRule(c *automod.RecordContext, blob lexutil.LexBlob, data []byte) error {
+	dhash, err := BlobImageDHash(blob, data)
+	if err != nil {
+		// undecodable (or unsupported format) images are not an error for the event
+		c.Logger.Warn("failed to hash image blob", "cid", blob.Ref.String(), "mimeType", blob.MimeType, "err", err)
+		return nil
+	}
+	if dhash == nil {
+		return nil
+	}
+	cid := blob.Ref.String()
+	c.AddHash(ImageDHashNamespace, uint64(*dhash), cid)
+
+	if m := KnownBadImageMatch(c, *dhash); m != nil {
+		c.Logger.Warn("known-bad image match", "cid", cid, "match", m.ID, "distance", m.Distance)
+		c.AddRecordFlag("known-bad-image")
+		c.ReportRecord(automod.ReportReasonViolation, fmt.Sprintf("image similar to known-bad image: %s (distance %d)", m.ID, m.Distance))
+	}
+
+	if SimilarImageCount(c, cid, *dhash, 24*time.Hour) >= RepeatedImageThreshold {
+		c.AddRecordFlag("repeated-image")
+	}
+	return nil
+}
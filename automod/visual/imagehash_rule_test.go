@@ -0,0 +1,103 @@
+package visual
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/automod"
+	"github.com/bluesky-social/indigo/automod/engine"
+	"github.com/bluesky-social/indigo/automod/hashstore"
+	"github.com/bluesky-social/indigo/automod/imagehash"
+	lexutil "github.com/bluesky-social/indigo/lex/util"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+)
+
+func testImagePNG(t *testing.T, shift int) []byte {
+	img := image.NewGray(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			img.SetGray(x, y, color.Gray{Y: uint8((x*x + y*3 + shift) % 256)})
+		}
+	}
+	buf := new(bytes.Buffer)
+	assert.NoError(t, png.Encode(buf, img))
+	return buf.Bytes()
+}
+
+func testBlob(t *testing.T, data []byte) lexutil.LexBlob {
+	c, err := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: 0x12, MhLength: -1}.Sum(data)
+	assert.NoError(t, err)
+	return lexutil.LexBlob{Ref: lexutil.LexLink(c), MimeType: "image/png", Size: int64(len(data))}
+}
+
+func TestImageHashBlobRule(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	eng := engine.EngineTestFixture()
+	eng.Hashes = hashstore.NewMemHashStore(48 * time.Hour)
+	am1 := automod.AccountMeta{
+		Identity: &identity.Identity{
+			DID:    syntax.DID("did:plc:abc111"),
+			Handle: syntax.Handle("handle.example.com"),
+		},
+	}
+	cid1 := syntax.CID("cid123")
+	op := engine.RecordOp{
+		Action:     engine.CreateOp,
+		DID:        am1.Identity.DID,
+		Collection: syntax.NSID("app.bsky.feed.post"),
+		RecordKey:  syntax.RecordKey("abc123"),
+		CID:        &cid1,
+		RecordCBOR: []byte("dummy"),
+	}
+	data := testImagePNG(t, 0)
+	blob := testBlob(t, data)
+
+	// non-image blobs are skipped
+	c0 := engine.NewRecordContext(ctx, &eng, am1, op)
+	assert.NoError(ImageHashBlobRule(&c0, lexutil.LexBlob{Ref: blob.Ref, MimeType: "video/mp4"}, data))
+	assert.Empty(engine.ExtractEffects(&c0.BaseContext).HashAdds)
+
+	// undecodable images are skipped, without an error
+	c0 = engine.NewRecordContext(ctx, &eng, am1, op)
+	assert.NoError(ImageHashBlobRule(&c0, blob, []byte("not an image")))
+	assert.Empty(engine.ExtractEffects(&c0.BaseContext).HashAdds)
+
+	c1 := engine.NewRecordContext(ctx, &eng, am1, op)
+	assert.NoError(ImageHashBlobRule(&c1, blob, data))
+	eff1 := engine.ExtractEffects(&c1.BaseContext)
+	assert.Len(eff1.HashAdds, 1)
+	assert.Equal(ImageDHashNamespace, eff1.HashAdds[0].Namespace)
+	assert.Equal(blob.Ref.String(), eff1.HashAdds[0].ID)
+	assert.Empty(eff1.RecordFlags)
+
+	// known-bad image
+	hashes, err := imagehash.HashImage(data)
+	assert.NoError(err)
+	assert.NoError(eng.Hashes.Add(ctx, KnownBadImageNamespace, hashstore.Entry{Hash: uint64(hashes.DHash), ID: "test-bad-image", Time: time.Now()}))
+	c2 := engine.NewRecordContext(ctx, &eng, am1, op)
+	assert.NoError(ImageHashBlobRule(&c2, blob, data))
+	eff2 := engine.ExtractEffects(&c2.BaseContext)
+	assert.Equal([]string{"known-bad-image"}, eff2.RecordFlags)
+	assert.Len(eff2.RecordReports, 1)
+
+	// the same image, posted as many different blobs
+	for i := 0; i < RepeatedImageThreshold; i++ {
+		assert.NoError(eng.Hashes.Add(ctx, ImageDHashNamespace, hashstore.Entry{Hash: uint64(hashes.DHash), ID: fmt.Sprintf("other-cid-%d", i), Time: time.Now()}))
+	}
+	c3 := engine.NewRecordContext(ctx, &eng, am1, op)
+	assert.NoError(ImageHashBlobRule(&c3, blob, data))
+	eff3 := engine.ExtractEffects(&c3.BaseContext)
+	assert.Contains(eff3.RecordFlags, "repeated-image")
+}
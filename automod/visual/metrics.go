@@ -24,3 +24,8 @@ var abyssAPICount = promauto.NewCounterVec(prometheus.CounterOpts{
 	Name: "automod_abyss_api_count",
 	Help: "Number of abyss image scanning API calls, by HTTP status code",
 }, []string{"status"})
+
+var imageHashDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+	Name: "automod_image_hash_duration_sec",
+	Help: "Duration of in-process perceptual image hashing",
+})
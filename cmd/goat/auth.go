@@ -93,6 +93,15 @@ func loadAuthClient(ctx context.Context) (*xrpc.Client, error) {
 	client.Auth.AccessJwt = resp.AccessJwt
 	client.Auth.RefreshJwt = resp.RefreshJwt
 
+	// long-running commands (eg, repo imports) may outlive the access token
+	client.AutoRefresh = true
+	client.RefreshCallback = func(ctx context.Context, auth *xrpc.AuthInfo) {
+		sess.RefreshToken = auth.RefreshJwt
+		if err := persistAuthSession(&sess); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to persist refreshed auth session: %v\n", err)
+		}
+	}
+
 	return &client, nil
 }
 
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/bluesky-social/indigo/atproto/crypto"
+	"github.com/bluesky-social/indigo/util/cliutil"
 
 	"github.com/urfave/cli/v2"
 )
@@ -29,6 +33,64 @@ var cmdCrypto = &cli.Command{
 			Usage:  "parses and outputs metadata about a public or secret key",
 			Action: runCryptoInspect,
 		},
+		&cli.Command{
+			Name:      "encrypt",
+			Usage:     "encrypts a secret key with a passphrase, as a key file",
+			ArgsUsage: `[<secret-key>]`,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "kdf",
+					Usage: "passphrase key derivation function (scrypt or argon2id)",
+					Value: crypto.KDFScrypt,
+				},
+				&cli.StringFlag{
+					Name:    "output",
+					Aliases: []string{"o"},
+					Usage:   "path to write key file (instead of stdout)",
+				},
+				&cli.StringFlag{
+					Name:  "passphrase-env",
+					Usage: "environment variable to read passphrase from (otherwise prompts)",
+					Value: cliutil.KeyPassphraseEnv,
+				},
+			},
+			Action: runCryptoEncrypt,
+		},
+		&cli.Command{
+			Name:      "decrypt",
+			Usage:     "decrypts a key file, and outputs the secret key",
+			ArgsUsage: `<key-file>`,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "passphrase-env",
+					Usage: "environment variable to read passphrase from (otherwise prompts)",
+					Value: cliutil.KeyPassphraseEnv,
+				},
+			},
+			Action: runCryptoDecrypt,
+		},
+		&cli.Command{
+			Name:      "rotate",
+			Usage:     "re-encrypts a key file in place, with a new passphrase (and fresh salt)",
+			ArgsUsage: `<key-file>`,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "kdf",
+					Usage: "passphrase key derivation function for the new file (scrypt or argon2id); defaults to the current one",
+				},
+				&cli.StringFlag{
+					Name:  "passphrase-env",
+					Usage: "environment variable to read current passphrase from (otherwise prompts)",
+					Value: cliutil.KeyPassphraseEnv,
+				},
+				&cli.StringFlag{
+					Name:  "new-passphrase-env",
+					Usage: "environment variable to read new passphrase from (otherwise prompts)",
+					Value: "ATP_KEY_NEW_PASSPHRASE",
+				},
+			},
+			Action: runCryptoRotate,
+		},
 	},
 }
 
@@ -102,3 +164,86 @@ func runCryptoInspect(cctx *cli.Context) error {
 	}
 	return fmt.Errorf("unknown key encoding or type")
 }
+
+func runCryptoEncrypt(cctx *cli.Context) error {
+	s := cctx.Args().First()
+	if s == "" {
+		// read from stdin, so the secret key doesn't end up in shell history
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("need to provide secret key as an argument or on stdin")
+		}
+		s = strings.TrimSpace(line)
+	}
+	priv, err := crypto.ParsePrivateMultibase(s)
+	if err != nil {
+		return err
+	}
+
+	pass, err := cliutil.ReadPassphrase(cctx.String("passphrase-env"), "New passphrase: ", true)
+	if err != nil {
+		return err
+	}
+	if cctx.String("output") != "" {
+		return crypto.SaveEncryptedKeyFile(cctx.String("output"), priv, pass, cctx.String("kdf"))
+	}
+	out, err := crypto.EncryptPrivateKey(priv, pass, cctx.String("kdf"))
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+func runCryptoDecrypt(cctx *cli.Context) error {
+	path := cctx.Args().First()
+	if path == "" {
+		return fmt.Errorf("need to provide key file path as an argument")
+	}
+	priv, err := cliutil.LoadPrivateKeyFile(path, cctx.String("passphrase-env"))
+	if err != nil {
+		return err
+	}
+	mb, ok := priv.(interface{ Multibase() string })
+	if !ok {
+		return fmt.Errorf("unsupported key type: %T", priv)
+	}
+	fmt.Println(mb.Multibase())
+	return nil
+}
+
+func runCryptoRotate(cctx *cli.Context) error {
+	path := cctx.Args().First()
+	if path == "" {
+		return fmt.Errorf("need to provide key file path as an argument")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	kf, err := crypto.ParseKeyFile(data)
+	if err != nil {
+		return err
+	}
+	pass, err := cliutil.ReadPassphrase(cctx.String("passphrase-env"), "Current passphrase: ", false)
+	if err != nil {
+		return err
+	}
+	priv, err := crypto.DecryptPrivateKey(data, pass)
+	if err != nil {
+		return err
+	}
+	newPass, err := cliutil.ReadPassphrase(cctx.String("new-passphrase-env"), "New passphrase: ", true)
+	if err != nil {
+		return err
+	}
+	kdf := cctx.String("kdf")
+	if kdf == "" {
+		kdf = kf.KDF.Name
+	}
+	if err := crypto.SaveEncryptedKeyFile(path, priv, newPass, kdf); err != nil {
+		return err
+	}
+	fmt.Printf("re-encrypted key file: %s (%s)\n", path, kf.PublicKey)
+	return nil
+}
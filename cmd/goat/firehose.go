@@ -234,6 +234,7 @@ func (gfc *GoatFirehoseConsumer) handleCommitEventOps(ctx context.Context, evt *
 
 		out := make(map[string]interface{})
 		out["seq"] = evt.Seq
+		out["did"] = evt.Repo
 		out["rev"] = evt.Rev
 		out["time"] = evt.Time
 		out["collection"] = collection
@@ -4,7 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"strings"
 
+	"github.com/bluesky-social/indigo/atproto/crypto"
 	"github.com/bluesky-social/indigo/atproto/identity"
 	"github.com/bluesky-social/indigo/atproto/syntax"
 
@@ -93,3 +96,294 @@ func runResolve(cctx *cli.Context) error {
 	fmt.Println(string(b))
 	return nil
 }
+
+var cmdIdentity = &cli.Command{
+	Name:  "identity",
+	Usage: "sub-commands for atproto identities (DIDs and handles)",
+	Subcommands: []*cli.Command{
+		&cli.Command{
+			Name:      "diagnose",
+			Usage:     "runs all handle resolution methods and reports on bi-directional verification",
+			ArgsUsage: `<handle-or-did>`,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "json",
+					Usage: "output the full report as JSON",
+				},
+				&cli.StringSliceFlag{
+					Name:  "fallback-dns",
+					Usage: "fallback DNS servers to query (host:port)",
+				},
+			},
+			Action: runIdentityDiagnose,
+		},
+		&cli.Command{
+			Name:  "did-web",
+			Usage: "sub-commands for did:web documents",
+			Subcommands: []*cli.Command{
+				&cli.Command{
+					Name:      "generate",
+					Usage:     "outputs a did:web DID document for a hostname",
+					ArgsUsage: `<hostname>`,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:     "atproto-key",
+							Usage:    "public key for atproto repo signing (did:key or multibase); a private key is also accepted",
+							Required: true,
+						},
+						&cli.StringFlag{
+							Name:  "pds",
+							Usage: "PDS service endpoint URL",
+						},
+						&cli.StringFlag{
+							Name:  "handle",
+							Usage: "atproto handle to declare (defaults to the hostname)",
+						},
+						&cli.StringSliceFlag{
+							Name:  "also-known-as",
+							Usage: "additional alsoKnownAs URIs",
+						},
+					},
+					Action: runIdentityDIDWebGenerate,
+				},
+				&cli.Command{
+					Name:      "validate",
+					Usage:     "fetches (or reads from file) and checks a did:web DID document",
+					ArgsUsage: `<hostname-or-did>`,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "file",
+							Usage: "path to local DID document JSON file, instead of fetching from the hostname",
+						},
+					},
+					Action: runIdentityDIDWebValidate,
+				},
+			},
+		},
+	},
+}
+
+func runIdentityDiagnose(cctx *cli.Context) error {
+	ctx := context.Background()
+	s := cctx.Args().First()
+	if s == "" {
+		return fmt.Errorf("need to provide handle or DID as an argument")
+	}
+	atid, err := syntax.ParseAtIdentifier(s)
+	if err != nil {
+		return err
+	}
+
+	// same resolution behavior as identity.DefaultDirectory
+	dir := identity.BaseDirectory{
+		TryAuthoritativeDNS:   true,
+		SkipDNSDomainSuffixes: []string{".bsky.social"},
+		FallbackDNSServers:    cctx.StringSlice("fallback-dns"),
+	}
+	var hd *identity.HandleDiagnosis
+	if atid.IsDID() {
+		did, _ := atid.AsDID()
+		hd = dir.DiagnoseDID(ctx, did)
+	} else {
+		handle, _ := atid.AsHandle()
+		hd = dir.DiagnoseHandle(ctx, handle)
+	}
+
+	if cctx.Bool("json") {
+		b, err := json.MarshalIndent(hd, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+
+	fmt.Printf("handle: %s\n", hd.Handle)
+	fmt.Printf("did: %s\n", hd.DID)
+	fmt.Println()
+	for _, res := range hd.Methods {
+		status := "ok"
+		switch {
+		case res.Skipped:
+			fmt.Printf("%-18s skipped\n", res.Method)
+			continue
+		case res.DID == "" && res.NotFound:
+			status = "not found"
+		case res.DID == "":
+			status = "error"
+		}
+		fmt.Printf("%-18s %-9s %5dms  %s\n", res.Method, status, res.DurationMs, res.DID)
+		if res.Nameserver != "" {
+			fmt.Printf("    nameserver: %s\n", res.Nameserver)
+		}
+		for _, r := range res.Records {
+			fmt.Printf("    TXT: %q\n", r)
+		}
+		if res.HTTPStatus != 0 {
+			fmt.Printf("    HTTP %d: %q\n", res.HTTPStatus, res.Body)
+		}
+		if res.Error != "" {
+			fmt.Printf("    error: %s\n", res.Error)
+		}
+	}
+	if hd.DIDDocument != nil || hd.DIDError != "" {
+		fmt.Println()
+		if hd.DIDError != "" {
+			fmt.Printf("DID document: error (%dms): %s\n", hd.DIDDurationMs, hd.DIDError)
+		} else {
+			fmt.Printf("DID document: resolved (%dms), declared handle: %s\n", hd.DIDDurationMs, hd.DeclaredHandle)
+		}
+	}
+	fmt.Println()
+	fmt.Printf("handle -> DID: %s\n", okStr(hd.HandleToDID))
+	fmt.Printf("DID -> handle: %s\n", okStr(hd.DIDToHandle))
+	fmt.Printf("verified: %s\n", okStr(hd.Verified))
+	if len(hd.Problems) > 0 {
+		fmt.Println()
+		fmt.Println("problems:")
+		for _, p := range hd.Problems {
+			fmt.Printf("  - %s\n", p)
+		}
+	}
+	return nil
+}
+
+func okStr(ok bool) string {
+	if ok {
+		return "ok"
+	}
+	return "FAIL"
+}
+
+// parses a public key from did:key or multibase syntax, or the public part of a multibase private key
+func parsePublicKeyArg(s string) (crypto.PublicKey, error) {
+	if strings.HasPrefix(s, "did:key:") {
+		return crypto.ParsePublicDIDKey(s)
+	}
+	if pub, err := crypto.ParsePublicMultibase(s); err == nil {
+		return pub, nil
+	}
+	priv, err := crypto.ParsePrivateMultibase(s)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse key (expected did:key or multibase): %w", err)
+	}
+	return priv.PublicKey()
+}
+
+func didWebArg(s string) (syntax.DID, error) {
+	if s == "" {
+		return "", fmt.Errorf("need to provide hostname as an argument")
+	}
+	if !strings.HasPrefix(s, "did:") {
+		s = "did:web:" + s
+	}
+	did, err := syntax.ParseDID(s)
+	if err != nil {
+		return "", err
+	}
+	if did.Method() != "web" {
+		return "", fmt.Errorf("not a did:web: %s", did)
+	}
+	// only hostname-level did:web are supported by atproto
+	if _, err := syntax.ParseHandle(did.Identifier()); err != nil {
+		return "", fmt.Errorf("did:web identifier not a simple hostname: %s", did.Identifier())
+	}
+	return did, nil
+}
+
+func runIdentityDIDWebGenerate(cctx *cli.Context) error {
+	did, err := didWebArg(cctx.Args().First())
+	if err != nil {
+		return err
+	}
+	pub, err := parsePublicKeyArg(cctx.String("atproto-key"))
+	if err != nil {
+		return err
+	}
+	handle := syntax.Handle(did.Identifier())
+	if cctx.String("handle") != "" {
+		handle, err = syntax.ParseHandle(cctx.String("handle"))
+		if err != nil {
+			return err
+		}
+	}
+
+	b := identity.NewDIDDocumentBuilder(did).Handle(handle).AtprotoKey(pub)
+	for _, aka := range cctx.StringSlice("also-known-as") {
+		b.AlsoKnownAs(aka)
+	}
+	if cctx.String("pds") != "" {
+		b.PDS(cctx.String("pds"))
+	}
+	doc, err := b.Build()
+	if err != nil {
+		return err
+	}
+
+	out, err := json.MarshalIndent(struct {
+		Context []string `json:"@context"`
+		*identity.DIDDocument
+	}{identity.DIDDocumentContext, doc}, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+func runIdentityDIDWebValidate(cctx *cli.Context) error {
+	ctx := context.Background()
+	did, err := didWebArg(cctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	var doc *identity.DIDDocument
+	if cctx.String("file") != "" {
+		b, err := os.ReadFile(cctx.String("file"))
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(b, &doc); err != nil {
+			return fmt.Errorf("parsing DID document JSON: %w", err)
+		}
+		if doc == nil {
+			return fmt.Errorf("no DID document in file: %s", cctx.String("file"))
+		}
+	} else {
+		dir := identity.BaseDirectory{}
+		doc, err = dir.ResolveDIDWeb(ctx, did)
+		if err != nil {
+			return err
+		}
+	}
+
+	if doc.DID != did {
+		return fmt.Errorf("DID document id does not match: %s", doc.DID)
+	}
+	if err := doc.Validate(); err != nil {
+		return err
+	}
+
+	// atproto-specific checks are reported, but not fatal: a did:web may be used for non-atproto purposes
+	ident := identity.ParseIdentity(doc)
+	if _, err := ident.PublicKey(); err != nil {
+		fmt.Printf("warning: no atproto signing key: %s\n", err)
+	}
+	if ident.PDSEndpoint() == "" {
+		fmt.Println("warning: no atproto PDS service endpoint")
+	}
+	if handle, err := ident.DeclaredHandle(); err != nil {
+		fmt.Printf("warning: no atproto handle declared: %s\n", err)
+	} else if cctx.String("file") == "" {
+		dir := identity.BaseDirectory{}
+		resolved, err := dir.ResolveHandle(ctx, handle)
+		if err != nil {
+			fmt.Printf("warning: declared handle %s did not resolve: %s\n", handle, err)
+		} else if resolved != did {
+			fmt.Printf("warning: declared handle %s resolves to a different DID: %s\n", handle, resolved)
+		}
+	}
+	fmt.Printf("valid DID document: %s\n", did)
+	return nil
+}
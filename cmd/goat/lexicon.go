@@ -73,6 +73,34 @@ var cmdLex = &cli.Command{
 			},
 			Action: runLexValidate,
 		},
+		&cli.Command{
+			Name:      "export",
+			Usage:     "convert a directory of Lexicon files to JSON Schema or OpenAPI",
+			ArgsUsage: `<dir>`,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "format",
+					Usage: "output format: 'jsonschema' or 'openapi'",
+					Value: "jsonschema",
+				},
+				&cli.StringFlag{
+					Name:    "output",
+					Aliases: []string{"o"},
+					Usage:   "file path to write to (default is stdout)",
+				},
+				&cli.StringFlag{
+					Name:  "title",
+					Usage: "API title (OpenAPI only)",
+					Value: "atproto XRPC API",
+				},
+				&cli.StringFlag{
+					Name:  "api-version",
+					Usage: "API version string (OpenAPI only)",
+					Value: "0.0.0",
+				},
+			},
+			Action: runLexExport,
+		},
 	},
 }
 
@@ -339,3 +367,41 @@ func runLexValidate(cctx *cli.Context) error {
 	fmt.Printf("valid %s record\n", nsid)
 	return nil
 }
+
+func runLexExport(cctx *cli.Context) error {
+	dirPath := cctx.Args().First()
+	if dirPath == "" {
+		return fmt.Errorf("need to provide directory path as an argument")
+	}
+
+	cat := lexicon.NewBaseCatalog()
+	if err := cat.LoadDirectory(dirPath); err != nil {
+		return err
+	}
+
+	var doc map[string]any
+	var err error
+	switch cctx.String("format") {
+	case "jsonschema":
+		doc, err = cat.ExportJSONSchema()
+	case "openapi":
+		doc, err = cat.ExportOpenAPI(cctx.String("title"), cctx.String("api-version"))
+	default:
+		return fmt.Errorf("unknown export format: %s", cctx.String("format"))
+	}
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	outPath := cctx.String("output")
+	if outPath == "" {
+		fmt.Println(string(b))
+		return nil
+	}
+	return os.WriteFile(outPath, append(b, '\n'), 0666)
+}
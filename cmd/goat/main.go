@@ -29,6 +29,7 @@ func run(args []string) error {
 		cmdRecordList,
 		cmdFirehose,
 		cmdResolve,
+		cmdIdentity,
 		cmdRepo,
 		cmdBlob,
 		cmdLex,
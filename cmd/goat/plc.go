@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/plc"
 	"github.com/bluesky-social/indigo/atproto/syntax"
 
 	"github.com/urfave/cli/v2"
@@ -34,8 +36,13 @@ var cmdPLCHistory = &cli.Command{
 	Name:      "history",
 	Usage:     "fetch operation log for individual DID",
 	ArgsUsage: `<at-identifier>`,
-	Flags:     []cli.Flag{},
-	Action:    runPLCHistory,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "verify",
+			Usage: "fetch full audit log, and verify signatures and operation chain",
+		},
+	},
+	Action: runPLCHistory,
 }
 
 func runPLCHistory(cctx *cli.Context) error {
@@ -75,6 +82,10 @@ func runPLCHistory(cctx *cli.Context) error {
 		return fmt.Errorf("non-PLC DID method: %s", did.Method())
 	}
 
+	if cctx.Bool("verify") {
+		return verifyPLCHistory(ctx, plcURL, did)
+	}
+
 	url := fmt.Sprintf("%s/%s/log", plcURL, did)
 	resp, err := http.Get(url)
 	if err != nil {
@@ -106,6 +117,49 @@ func runPLCHistory(cctx *cli.Context) error {
 	return nil
 }
 
+func verifyPLCHistory(ctx context.Context, plcURL string, did syntax.DID) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/%s/log/audit", plcURL, did), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("PLC HTTP request failed status=%d", resp.StatusCode)
+	}
+	var entries []plc.LogEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		status := "ok"
+		if e.Nullified {
+			status = "nullified"
+		}
+		fmt.Printf("%s\t%s\t%s\n", e.CreatedAt, e.CID, status)
+	}
+
+	state, err := plc.VerifyAuditLog(did, entries)
+	if err != nil {
+		return fmt.Errorf("PLC operation log verification failed: %w", err)
+	}
+	fmt.Printf("verified %d operations (%d nullified)\n", len(entries), len(state.Nullified))
+	if state.Tombstoned {
+		fmt.Println("DID is tombstoned")
+		return nil
+	}
+	b, err := json.MarshalIndent(state.Data.DIDDocument(), "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
 var cmdPLCDump = &cli.Command{
 	Name:  "dump",
 	Usage: "output full operation log, as JSON lines",
@@ -116,6 +170,10 @@ var cmdPLCDump = &cli.Command{
 		&cli.BoolFlag{
 			Name: "tail",
 		},
+		&cli.BoolFlag{
+			Name:  "audit",
+			Usage: "verify every operation (instead of printing them), and output failures as JSON lines. Keeps per-DID state in memory",
+		},
 	},
 	Action: runPLCDump,
 }
@@ -125,6 +183,9 @@ func runPLCDump(cctx *cli.Context) error {
 	plcURL := cctx.String("plc-directory")
 	client := http.DefaultClient
 	tailMode := cctx.Bool("tail")
+	auditMode := cctx.Bool("audit")
+	verifiers := map[syntax.DID]*plc.Verifier{}
+	var auditCount, auditFailures int
 
 	cursor := cctx.String("cursor")
 	if cursor == "now" {
@@ -185,6 +246,19 @@ func runPLCDump(cctx *cli.Context) error {
 				continue
 			}
 
+			if auditMode {
+				auditCount++
+				if err := auditPLCEntry(verifiers, []byte(l)); err != nil {
+					auditFailures++
+					b, err := json.Marshal(map[string]any{"did": op["did"], "cid": op["cid"], "createdAt": op["createdAt"], "error": err.Error()})
+					if err != nil {
+						return err
+					}
+					fmt.Println(string(b))
+				}
+				continue
+			}
+
 			b, err := json.Marshal(op)
 			if err != nil {
 				return err
@@ -201,5 +275,43 @@ func runPLCDump(cctx *cli.Context) error {
 		lastCursor = cursor
 	}
 
+	if auditMode {
+		fmt.Fprintf(os.Stderr, "audited %d operations for %d DIDs: %d failures\n", auditCount, len(verifiers), auditFailures)
+	}
+	return nil
+}
+
+// Verifies a single export stream entry against the history of the DID seen so far. Operations for DIDs whose genesis was not seen (eg, when starting from a cursor) can not be verified.
+func auditPLCEntry(verifiers map[syntax.DID]*plc.Verifier, line []byte) error {
+	var entry plc.LogEntry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return err
+	}
+	op, err := plc.ParseOperation(entry.Operation)
+	if err != nil {
+		return err
+	}
+	c, err := plc.OpCID(op)
+	if err != nil {
+		return err
+	}
+	if c != entry.CID {
+		return fmt.Errorf("CID mismatch (computed %s)", c)
+	}
+	createdAt, err := syntax.ParseDatetimeLenient(entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+	v, ok := verifiers[entry.DID]
+	if !ok {
+		if op.PrevCID() != "" {
+			return fmt.Errorf("genesis operation not seen")
+		}
+		v = plc.NewVerifier(entry.DID)
+	}
+	if _, err := v.Apply(op, createdAt.Time()); err != nil {
+		return err
+	}
+	verifiers[entry.DID] = v
 	return nil
 }
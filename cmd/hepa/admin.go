@@ -0,0 +1,160 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bluesky-social/indigo/automod/setstore"
+)
+
+// Request body for modifying a set. Action is one of "add", "remove", or "replace".
+type setChangeRequest struct {
+	Actor  string   `json:"actor"`
+	Action string   `json:"action"`
+	Type   string   `json:"type,omitempty"`
+	Values []string `json:"values"`
+}
+
+// Registers set admin endpoints on the default mux. All of them require the admin token as a bearer token, and modifications require an "actor" (who is making the change) for the audit log.
+//
+//   - GET /admin/sets: list sets, with type and size
+//   - GET /admin/sets/{name}: list members of a set
+//   - POST /admin/sets/{name}: modify a set (JSON body, see setChangeRequest)
+//   - DELETE /admin/sets/{name}?actor=: delete a set
+//   - GET /admin/sets-audit?limit=: recent set changes, newest first
+//   - POST /admin/sets-reload?actor=: reload sets from the JSON file (in-memory set store only)
+func (s *Server) registerAdminHandlers() {
+	http.HandleFunc("GET /admin/sets", s.adminAuth(s.handleListSets))
+	http.HandleFunc("GET /admin/sets/{name}", s.adminAuth(s.handleGetSet))
+	http.HandleFunc("POST /admin/sets/{name}", s.adminAuth(s.handleChangeSet))
+	http.HandleFunc("DELETE /admin/sets/{name}", s.adminAuth(s.handleDeleteSet))
+	http.HandleFunc("GET /admin/sets-audit", s.adminAuth(s.handleSetsAudit))
+	http.HandleFunc("POST /admin/sets-reload", s.adminAuth(s.handleReloadSets))
+}
+
+func (s *Server) adminAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.logger.Error("failed to encode admin response", "err", err)
+	}
+}
+
+func (s *Server) handleListSets(w http.ResponseWriter, r *http.Request) {
+	infos, err := s.SetsAdmin.Store.ListSets(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.writeJSON(w, map[string]any{"sets": infos})
+}
+
+func (s *Server) handleGetSet(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	members, err := s.SetsAdmin.Store.Members(r.Context(), name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.writeJSON(w, map[string]any{"name": name, "members": members})
+}
+
+func (s *Server) handleChangeSet(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	name := r.PathValue("name")
+	var req setChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Actor == "" {
+		http.Error(w, "actor is required", http.StatusBadRequest)
+		return
+	}
+	var err error
+	switch req.Action {
+	case "add":
+		err = s.SetsAdmin.Add(ctx, req.Actor, name, req.Type, req.Values)
+	case "remove":
+		err = s.SetsAdmin.Remove(ctx, req.Actor, name, req.Values)
+	case "replace":
+		err = s.SetsAdmin.Replace(ctx, req.Actor, name, req.Type, req.Values)
+	default:
+		http.Error(w, "unknown action: "+req.Action, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		// most failures are invalid types or members
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.writeJSON(w, map[string]any{"success": true})
+}
+
+func (s *Server) handleDeleteSet(w http.ResponseWriter, r *http.Request) {
+	actor := r.URL.Query().Get("actor")
+	if actor == "" {
+		http.Error(w, "actor is required", http.StatusBadRequest)
+		return
+	}
+	if err := s.SetsAdmin.DeleteSet(r.Context(), actor, r.PathValue("name")); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.writeJSON(w, map[string]any{"success": true})
+}
+
+func (s *Server) handleSetsAudit(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	changes, err := s.SetsAdmin.Audit.Recent(r.Context(), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.writeJSON(w, map[string]any{"changes": changes})
+}
+
+// NOTE: reloading replaces all in-memory sets, including any changes made through the admin endpoints since the file was last loaded
+func (s *Server) handleReloadSets(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	actor := r.URL.Query().Get("actor")
+	if actor == "" {
+		http.Error(w, "actor is required", http.StatusBadRequest)
+		return
+	}
+	mss, ok := s.SetsAdmin.Store.(*setstore.MemSetStore)
+	if !ok || s.setsFileJSON == "" {
+		http.Error(w, "reload requires the in-memory set store and a sets JSON file", http.StatusBadRequest)
+		return
+	}
+	if err := mss.LoadFromFileJSON(s.setsFileJSON); err != nil {
+		http.Error(w, "reloading sets: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	change := setstore.SetChange{Actor: actor, Action: "reload", Set: "*", Values: []string{s.setsFileJSON}}
+	if err := s.SetsAdmin.Record(ctx, change); err != nil {
+		s.logger.Error("failed to record set reload", "err", err)
+	}
+	s.writeJSON(w, map[string]any{"success": true})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bluesky-social/indigo/automod/hashstore"
+	"github.com/bluesky-social/indigo/automod/imagehash"
+	"github.com/bluesky-social/indigo/automod/visual"
+
+	cli "github.com/urfave/cli/v2"
+)
+
+// how long hashes of processed image blobs are kept in the hash index
+const imageHashRetention = 48 * time.Hour
+
+var imageHashCmd = &cli.Command{
+	Name:      "image-hash",
+	Usage:     "compute perceptual hashes of local image files (JPEG, PNG, GIF, WebP), optionally adding them to the known-bad image index in redis",
+	ArgsUsage: `<file>...`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "add-known-bad",
+			Usage: "add the image hashes to the known-bad index, with this description (requires redis-url)",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		ctx := context.Background()
+		if cctx.Args().Len() == 0 {
+			return fmt.Errorf("expected at least one image file argument")
+		}
+
+		var hashes hashstore.HashStore
+		desc := cctx.String("add-known-bad")
+		if desc != "" {
+			redisURL := cctx.String("redis-url")
+			if redisURL == "" {
+				return fmt.Errorf("adding known-bad images requires a redis URL")
+			}
+			// known-bad entries don't expire
+			hs, err := hashstore.NewRedisHashStore(redisURL, 0)
+			if err != nil {
+				return err
+			}
+			hashes = hs
+		}
+
+		for _, p := range cctx.Args().Slice() {
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			h, err := imagehash.HashImage(data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", p, err)
+			}
+			fmt.Printf("%s\tdhash=%s\tphash=%s\n", p, h.DHash, h.PHash)
+			if hashes != nil {
+				entry := hashstore.Entry{Hash: uint64(h.DHash), ID: desc, Time: time.Now()}
+				if err := hashes.Add(ctx, visual.KnownBadImageNamespace, entry); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	},
+}
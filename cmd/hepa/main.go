@@ -16,8 +16,13 @@ import (
 	"github.com/bluesky-social/indigo/atproto/identity"
 	"github.com/bluesky-social/indigo/atproto/identity/redisdir"
 	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/automod"
 	"github.com/bluesky-social/indigo/automod/capture"
 	"github.com/bluesky-social/indigo/automod/consumer"
+	"github.com/bluesky-social/indigo/automod/declarative"
+	"github.com/bluesky-social/indigo/automod/engine"
+	"github.com/bluesky-social/indigo/automod/replay"
+	"github.com/bluesky-social/indigo/automod/setstore"
 
 	"github.com/carlmjohnson/versioninfo"
 	_ "github.com/joho/godotenv/autoload"
@@ -104,6 +109,12 @@ func run(args []string) error {
 			Usage:   "file path of JSON file containing static sets",
 			EnvVars: []string{"HEPA_SETS_JSON_PATH"},
 		},
+		&cli.StringFlag{
+			Name:    "set-store",
+			Usage:   "where sets are stored: 'memory' (loaded from sets-json-path), or 'redis' (shared, and editable with 'hepa sets'; requires redis-url)",
+			Value:   "memory",
+			EnvVars: []string{"HEPA_SET_STORE"},
+		},
 		&cli.StringFlag{
 			Name:    "hiveai-api-token",
 			Usage:   "API token for Hive AI image auto-labeling",
@@ -119,6 +130,37 @@ func run(args []string) error {
 			Usage:   "admin auth password for abyss API",
 			EnvVars: []string{"ABYSS_PASSWORD"},
 		},
+		&cli.BoolFlag{
+			Name:    "image-hashing",
+			Usage:   "compute perceptual hashes of image blobs in-process, to detect re-posted and known-bad images (hash index is stored in redis, if redis-url is set)",
+			EnvVars: []string{"HEPA_IMAGE_HASHING"},
+		},
+		&cli.BoolFlag{
+			Name:    "text-similarity",
+			Usage:   "index recent post text signatures, for rules detecting near-identical text from many accounts (index is stored in redis, if redis-url is set)",
+			EnvVars: []string{"HEPA_TEXT_SIMILARITY"},
+		},
+		&cli.StringFlag{
+			Name:    "lexicons-dir",
+			Usage:   "directory of Lexicon schema JSON files; if set, records in those collections are validated, and invalid records are tagged",
+			EnvVars: []string{"HEPA_LEXICONS_DIR"},
+		},
+		&cli.StringFlag{
+			Name:    "graph-store",
+			Usage:   "local cache of the follow/block graph, built from the firehose: 'none', 'memory', 'redis' (requires redis-url), or 'pebble' (requires graph-pebble-path)",
+			Value:   "none",
+			EnvVars: []string{"HEPA_GRAPH_STORE"},
+		},
+		&cli.StringFlag{
+			Name:    "graph-pebble-path",
+			Usage:   "directory for the pebble graph store database",
+			EnvVars: []string{"HEPA_GRAPH_PEBBLE_PATH"},
+		},
+		&cli.BoolFlag{
+			Name:    "graph-relationships",
+			Usage:   "answer account relationship lookups from the local graph store, instead of the network",
+			EnvVars: []string{"HEPA_GRAPH_RELATIONSHIPS"},
+		},
 		&cli.StringFlag{
 			Name:    "ruleset",
 			Usage:   "which ruleset config to use: default, no-blobs, only-blobs",
@@ -191,6 +233,32 @@ func run(args []string) error {
 			EnvVars: []string{"HEPA_OZONE_EVENT_TIMEOUT"},
 			Value:   30 * time.Second,
 		},
+		&cli.StringFlag{
+			Name:    "rules-file",
+			Usage:   "path or URL of a declarative (YAML) rule file, run in addition to the compiled-in ruleset",
+			EnvVars: []string{"HEPA_RULES_FILE"},
+		},
+		&cli.DurationFlag{
+			Name:    "rules-reload-interval",
+			Usage:   "how often to check the declarative rule file for changes",
+			EnvVars: []string{"HEPA_RULES_RELOAD_INTERVAL"},
+			Value:   1 * time.Minute,
+		},
+		&cli.BoolFlag{
+			Name:    "shadow-mode",
+			Usage:   "run all rules in shadow mode: moderation actions are logged, but never persisted (counters still are)",
+			EnvVars: []string{"HEPA_SHADOW_MODE"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "shadow-rules",
+			Usage:   "names of individual rules to run in shadow mode (comma-separated)",
+			EnvVars: []string{"HEPA_SHADOW_RULES"},
+		},
+		&cli.DurationFlag{
+			Name:    "rule-outcomes-retention",
+			Usage:   "if set, per-rule live and shadow outcomes are kept for this long, and served on the metrics port at /rule-outcomes",
+			EnvVars: []string{"HEPA_RULE_OUTCOMES_RETENTION"},
+		},
 	}
 
 	app.Commands = []*cli.Command{
@@ -198,6 +266,10 @@ func run(args []string) error {
 		processRecordCmd,
 		processRecentCmd,
 		captureRecentCmd,
+		checkRulesCmd,
+		replayCmd,
+		setsCmd,
+		imageHashCmd,
 	}
 
 	return app.Run(args)
@@ -219,6 +291,12 @@ func configDirectory(cctx *cli.Context) (identity.Directory, error) {
 		if err != nil {
 			return nil, err
 		}
+		// drop in-process cache entries when any hepa process purges an identity
+		go func() {
+			if err := rdir.SubscribePurges(context.Background()); err != nil {
+				slog.Error("identity cache purge subscription failed", "err", err)
+			}
+		}()
 		dir = rdir
 	} else {
 		cdir := identity.NewCacheDirectory(&baseDir, 1_500_000, time.Hour*24, time.Minute*2, time.Minute*5)
@@ -264,6 +342,32 @@ var runCmd = &cli.Command{
 			Usage:   "full URL of slack webhook",
 			EnvVars: []string{"SLACK_WEBHOOK_URL"},
 		},
+		&cli.StringFlag{
+			Name:    "action-webhook-url",
+			Usage:   "URL to POST every moderation action to, as JSON",
+			EnvVars: []string{"HEPA_ACTION_WEBHOOK_URL"},
+		},
+		&cli.StringFlag{
+			Name:    "action-webhook-secret",
+			Usage:   "secret for HMAC-SHA256 signatures of action webhook requests",
+			EnvVars: []string{"HEPA_ACTION_WEBHOOK_SECRET"},
+		},
+		&cli.StringFlag{
+			Name: "discord-webhook-url",
+			// eg: https://discord.com/api/webhooks/1234/abcd
+			Usage:   "full URL of discord webhook, to post every moderation action to",
+			EnvVars: []string{"DISCORD_WEBHOOK_URL"},
+		},
+		&cli.StringFlag{
+			Name:    "action-audit-db-url",
+			Usage:   "database to record every moderation action in, for auditing (eg, 'sqlite://audit.sqlite' or a postgres URL)",
+			EnvVars: []string{"HEPA_ACTION_AUDIT_DB_URL"},
+		},
+		&cli.StringFlag{
+			Name:    "admin-token",
+			Usage:   "if set, set admin endpoints (/admin/sets) are served on the metrics port, with this bearer token required",
+			EnvVars: []string{"HEPA_ADMIN_TOKEN"},
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		ctx := context.Background()
@@ -278,36 +382,56 @@ var runCmd = &cli.Command{
 		srv, err := NewServer(
 			dir,
 			Config{
-				Logger:               logger,
-				BskyHost:             cctx.String("atp-bsky-host"),
-				OzoneHost:            cctx.String("atp-ozone-host"),
-				OzoneDID:             cctx.String("ozone-did"),
-				OzoneAdminToken:      cctx.String("ozone-admin-token"),
-				PDSHost:              cctx.String("atp-pds-host"),
-				PDSAdminToken:        cctx.String("pds-admin-token"),
-				SetsFileJSON:         cctx.String("sets-json-path"),
-				RedisURL:             cctx.String("redis-url"),
-				SlackWebhookURL:      cctx.String("slack-webhook-url"),
-				HiveAPIToken:         cctx.String("hiveai-api-token"),
-				AbyssHost:            cctx.String("abyss-host"),
-				AbyssPassword:        cctx.String("abyss-password"),
-				RatelimitBypass:      cctx.String("ratelimit-bypass"),
-				RulesetName:          cctx.String("ruleset"),
-				PreScreenHost:        cctx.String("prescreen-host"),
-				PreScreenToken:       cctx.String("prescreen-token"),
-				ReportDupePeriod:     cctx.Duration("report-dupe-period"),
-				QuotaModReportDay:    cctx.Int("quota-mod-report-day"),
-				QuotaModTakedownDay:  cctx.Int("quota-mod-takedown-day"),
-				QuotaModActionDay:    cctx.Int("quota-mod-action-day"),
-				RecordEventTimeout:   cctx.Duration("record-event-timeout"),
-				IdentityEventTimeout: cctx.Duration("identity-event-timeout"),
-				OzoneEventTimeout:    cctx.Duration("ozone-event-timeout"),
+				Logger:                logger,
+				BskyHost:              cctx.String("atp-bsky-host"),
+				OzoneHost:             cctx.String("atp-ozone-host"),
+				OzoneDID:              cctx.String("ozone-did"),
+				OzoneAdminToken:       cctx.String("ozone-admin-token"),
+				PDSHost:               cctx.String("atp-pds-host"),
+				PDSAdminToken:         cctx.String("pds-admin-token"),
+				SetsFileJSON:          cctx.String("sets-json-path"),
+				SetStore:              cctx.String("set-store"),
+				AdminToken:            cctx.String("admin-token"),
+				RedisURL:              cctx.String("redis-url"),
+				SlackWebhookURL:       cctx.String("slack-webhook-url"),
+				ActionWebhookURL:      cctx.String("action-webhook-url"),
+				ActionWebhookSecret:   cctx.String("action-webhook-secret"),
+				DiscordWebhookURL:     cctx.String("discord-webhook-url"),
+				ActionAuditDBURL:      cctx.String("action-audit-db-url"),
+				HiveAPIToken:          cctx.String("hiveai-api-token"),
+				AbyssHost:             cctx.String("abyss-host"),
+				AbyssPassword:         cctx.String("abyss-password"),
+				ImageHashing:          cctx.Bool("image-hashing"),
+				TextSimilarity:        cctx.Bool("text-similarity"),
+				LexiconsDir:           cctx.String("lexicons-dir"),
+				GraphStore:            cctx.String("graph-store"),
+				GraphPebblePath:       cctx.String("graph-pebble-path"),
+				GraphRelationships:    cctx.Bool("graph-relationships"),
+				RatelimitBypass:       cctx.String("ratelimit-bypass"),
+				RulesetName:           cctx.String("ruleset"),
+				RulesFile:             cctx.String("rules-file"),
+				ShadowMode:            cctx.Bool("shadow-mode"),
+				ShadowRules:           cctx.StringSlice("shadow-rules"),
+				RuleOutcomesRetention: cctx.Duration("rule-outcomes-retention"),
+				PreScreenHost:         cctx.String("prescreen-host"),
+				PreScreenToken:        cctx.String("prescreen-token"),
+				ReportDupePeriod:      cctx.Duration("report-dupe-period"),
+				QuotaModReportDay:     cctx.Int("quota-mod-report-day"),
+				QuotaModTakedownDay:   cctx.Int("quota-mod-takedown-day"),
+				QuotaModActionDay:     cctx.Int("quota-mod-action-day"),
+				RecordEventTimeout:    cctx.Duration("record-event-timeout"),
+				IdentityEventTimeout:  cctx.Duration("identity-event-timeout"),
+				OzoneEventTimeout:     cctx.Duration("ozone-event-timeout"),
 			},
 		)
 		if err != nil {
 			return fmt.Errorf("failed to construct server: %v", err)
 		}
 
+		// deliver to action sinks in the background, so a slow sink doesn't stall event processing
+		srv.Engine.StartSinks()
+		defer srv.Engine.StopSinks()
+
 		// ozone event consumer (if configured)
 		if srv.Engine.OzoneClient != nil {
 			oc := consumer.OzoneConsumer{
@@ -330,6 +454,15 @@ var runCmd = &cli.Command{
 			}()
 		}
 
+		// hot-reload of declarative rules (if configured)
+		if srv.RulesLoader != nil {
+			go func() {
+				if err := srv.RulesLoader.Run(ctx, cctx.Duration("rules-reload-interval")); err != nil {
+					slog.Error("declarative rule reloader failed", "err", err)
+				}
+			}()
+		}
+
 		// prometheus HTTP endpoint: /metrics
 		go func() {
 			runtime.SetBlockProfileRate(10)
@@ -387,12 +520,21 @@ func configEphemeralServer(cctx *cli.Context) (*Server, error) {
 			PDSHost:         cctx.String("atp-pds-host"),
 			PDSAdminToken:   cctx.String("pds-admin-token"),
 			SetsFileJSON:    cctx.String("sets-json-path"),
+			SetStore:        cctx.String("set-store"),
 			RedisURL:        cctx.String("redis-url"),
 			HiveAPIToken:    cctx.String("hiveai-api-token"),
 			AbyssHost:       cctx.String("abyss-host"),
 			AbyssPassword:   cctx.String("abyss-password"),
+			ImageHashing:    cctx.Bool("image-hashing"),
+			TextSimilarity:  cctx.Bool("text-similarity"),
+			LexiconsDir:     cctx.String("lexicons-dir"),
+			GraphStore:      cctx.String("graph-store"),
+			GraphPebblePath: cctx.String("graph-pebble-path"),
 			RatelimitBypass: cctx.String("ratelimit-bypass"),
 			RulesetName:     cctx.String("ruleset"),
+			RulesFile:       cctx.String("rules-file"),
+			ShadowMode:      cctx.Bool("shadow-mode"),
+			ShadowRules:     cctx.StringSlice("shadow-rules"),
 			PreScreenHost:   cctx.String("prescreen-host"),
 			PreScreenToken:  cctx.String("prescreen-token"),
 		},
@@ -496,3 +638,131 @@ var captureRecentCmd = &cli.Command{
 		return nil
 	},
 }
+
+var checkRulesCmd = &cli.Command{
+	Name:      "check-rules",
+	Usage:     "parse and validate a declarative rule file, without running it",
+	ArgsUsage: `<path>`,
+	Flags:     []cli.Flag{},
+	Action: func(cctx *cli.Context) error {
+		path := cctx.Args().First()
+		if path == "" {
+			return fmt.Errorf("expected a rule file path argument")
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		cr, err := declarative.Compile(src)
+		if err != nil {
+			return err
+		}
+		for _, r := range cr.Rules {
+			status := "enabled"
+			if r.Disabled {
+				status = "disabled"
+			}
+			fmt.Printf("%s\t%s\t%s\n", r.Name, r.Event, status)
+		}
+		return nil
+	},
+}
+
+var replayCmd = &cli.Command{
+	Name:      "replay",
+	Usage:     "run rules over captured firehose events (JSONL, binary frames, or event log directory), using in-memory state",
+	ArgsUsage: `<path>`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "baseline-ruleset",
+			Usage: "compiled-in ruleset to compare against (eg, 'default'). a baseline is only run if this or --baseline-rules-file is set",
+		},
+		&cli.StringFlag{
+			Name:  "baseline-rules-file",
+			Usage: "declarative rule file to include in the baseline",
+		},
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "output results as JSON, instead of a table",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		ctx := context.Background()
+		path := cctx.Args().First()
+		if path == "" {
+			return fmt.Errorf("expected a capture path argument")
+		}
+
+		sets := setstore.NewMemSetStore()
+		if cctx.String("sets-json-path") != "" {
+			if err := sets.LoadFromFileJSON(cctx.String("sets-json-path")); err != nil {
+				return fmt.Errorf("loading sets: %w", err)
+			}
+		}
+
+		candidateRules, err := replayRuleset(ctx, cctx.String("ruleset"), cctx.String("rules-file"))
+		if err != nil {
+			return err
+		}
+		candidate := replay.NewReplayer(candidateRules, sets, nil)
+		candidate.Engine.Config.ShadowMode = cctx.Bool("shadow-mode")
+		if names := cctx.StringSlice("shadow-rules"); len(names) > 0 {
+			candidate.Engine.Config.RulePolicies = make(map[string]engine.RulePolicy, len(names))
+			for _, name := range names {
+				candidate.Engine.Config.RulePolicies[name] = engine.RulePolicy{Shadow: true}
+			}
+		}
+		replayers := []*replay.Replayer{candidate}
+
+		var baseline *replay.Replayer
+		if cctx.IsSet("baseline-ruleset") || cctx.IsSet("baseline-rules-file") {
+			baselineRules, err := replayRuleset(ctx, cctx.String("baseline-ruleset"), cctx.String("baseline-rules-file"))
+			if err != nil {
+				return fmt.Errorf("baseline: %w", err)
+			}
+			baseline = replay.NewReplayer(baselineRules, sets, nil)
+			replayers = append(replayers, baseline)
+		}
+
+		if err := replay.Run(ctx, path, replayers...); err != nil {
+			return err
+		}
+
+		var baselineResult *replay.Result
+		if baseline != nil {
+			baselineResult = baseline.Result()
+		}
+		if !cctx.Bool("json") {
+			return replay.WriteReport(os.Stdout, candidate.Result(), baselineResult)
+		}
+		out := map[string]any{
+			"candidate": candidate.Result(),
+		}
+		if baselineResult != nil {
+			out["baseline"] = baselineResult
+			out["diff"] = replay.Diff(baselineResult, candidate.Result())
+		}
+		b, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	},
+}
+
+// compiled-in ruleset by name, plus declarative rules from a file (if any)
+func replayRuleset(ctx context.Context, name, rulesFile string) (automod.RuleSet, error) {
+	ruleset, err := configRuleset(name, nil)
+	if err != nil {
+		return ruleset, err
+	}
+	if rulesFile != "" {
+		loader := declarative.NewLoader(rulesFile)
+		if _, err := loader.Load(ctx); err != nil {
+			return ruleset, fmt.Errorf("loading declarative rules: %w", err)
+		}
+		loader.Extend(&ruleset)
+	}
+	return ruleset, nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -9,16 +10,22 @@ import (
 	"time"
 
 	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/lexicon"
 	"github.com/bluesky-social/indigo/atproto/syntax"
 	"github.com/bluesky-social/indigo/automod"
 	"github.com/bluesky-social/indigo/automod/cachestore"
 	"github.com/bluesky-social/indigo/automod/countstore"
+	"github.com/bluesky-social/indigo/automod/declarative"
 	"github.com/bluesky-social/indigo/automod/engine"
 	"github.com/bluesky-social/indigo/automod/flagstore"
+	"github.com/bluesky-social/indigo/automod/graphstore"
+	"github.com/bluesky-social/indigo/automod/hashstore"
 	"github.com/bluesky-social/indigo/automod/rules"
 	"github.com/bluesky-social/indigo/automod/setstore"
+	"github.com/bluesky-social/indigo/automod/textsim"
 	"github.com/bluesky-social/indigo/automod/visual"
 	"github.com/bluesky-social/indigo/util"
+	"github.com/bluesky-social/indigo/util/cliutil"
 	"github.com/bluesky-social/indigo/xrpc"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -28,35 +35,67 @@ import (
 type Server struct {
 	Engine      *automod.Engine
 	RedisClient *redis.Client
+	// only set if a declarative rule file is configured
+	RulesLoader *declarative.Loader
+	// for modifying sets at runtime, with an audit log
+	SetsAdmin *setstore.Admin
 
-	logger *slog.Logger
+	logger       *slog.Logger
+	setsFileJSON string
+	adminToken   string
 }
 
 type Config struct {
-	Logger               *slog.Logger
-	BskyHost             string
-	OzoneHost            string
-	OzoneDID             string
-	OzoneAdminToken      string
-	PDSHost              string
-	PDSAdminToken        string
-	SetsFileJSON         string
-	RedisURL             string
-	SlackWebhookURL      string
-	HiveAPIToken         string
-	AbyssHost            string
-	AbyssPassword        string
-	RulesetName          string
-	RatelimitBypass      string
-	PreScreenHost        string
-	PreScreenToken       string
-	ReportDupePeriod     time.Duration
-	QuotaModReportDay    int
-	QuotaModTakedownDay  int
-	QuotaModActionDay    int
-	RecordEventTimeout   time.Duration
-	IdentityEventTimeout time.Duration
-	OzoneEventTimeout    time.Duration
+	Logger          *slog.Logger
+	BskyHost        string
+	OzoneHost       string
+	OzoneDID        string
+	OzoneAdminToken string
+	PDSHost         string
+	PDSAdminToken   string
+	SetsFileJSON    string
+	// "memory" (default) or "redis"
+	SetStore string
+	// bearer token for admin endpoints; if empty, they are not served
+	AdminToken      string
+	RedisURL        string
+	SlackWebhookURL string
+	// additional destinations for moderation actions
+	ActionWebhookURL    string
+	ActionWebhookSecret string
+	DiscordWebhookURL   string
+	ActionAuditDBURL    string
+	HiveAPIToken        string
+	AbyssHost           string
+	AbyssPassword       string
+	// hash image blobs in-process, for near-duplicate detection
+	ImageHashing bool
+	// index post text signatures, for near-duplicate text detection
+	TextSimilarity bool
+	// directory of Lexicon schemas to validate records against
+	LexiconsDir string
+	// "none" (default), "memory", "redis", or "pebble"
+	GraphStore      string
+	GraphPebblePath string
+	// answer relationship lookups from the graph store
+	GraphRelationships bool
+	RulesetName        string
+	RulesFile          string
+	ShadowMode         bool
+	// names of rules to run in shadow mode
+	ShadowRules []string
+	// if non-zero, rule outcomes are recorded for reporting
+	RuleOutcomesRetention time.Duration
+	RatelimitBypass       string
+	PreScreenHost         string
+	PreScreenToken        string
+	ReportDupePeriod      time.Duration
+	QuotaModReportDay     int
+	QuotaModTakedownDay   int
+	QuotaModActionDay     int
+	RecordEventTimeout    time.Duration
+	IdentityEventTimeout  time.Duration
+	OzoneEventTimeout     time.Duration
 }
 
 func NewServer(dir identity.Directory, config Config) (*Server, error) {
@@ -106,15 +145,6 @@ func NewServer(dir identity.Directory, config Config) (*Server, error) {
 		logger.Info("did not configure PDS admin client")
 	}
 
-	sets := setstore.NewMemSetStore()
-	if config.SetsFileJSON != "" {
-		if err := sets.LoadFromFileJSON(config.SetsFileJSON); err != nil {
-			return nil, fmt.Errorf("initializing in-process setstore: %v", err)
-		} else {
-			logger.Info("loaded set config from JSON", "path", config.SetsFileJSON)
-		}
-	}
-
 	var counters countstore.CountStore
 	var cache cachestore.CacheStore
 	var flags flagstore.FlagStore
@@ -150,11 +180,42 @@ func NewServer(dir identity.Directory, config Config) (*Server, error) {
 		}
 		flags = flg
 	} else {
+		if config.SetStore == "redis" {
+			return nil, fmt.Errorf("redis set store requires a redis URL")
+		}
 		counters = countstore.NewMemCountStore()
 		cache = cachestore.NewMemCacheStore(5_000, 1*time.Hour)
 		flags = flagstore.NewMemFlagStore()
 	}
 
+	var sets setstore.MutableSetStore
+	var setsAudit setstore.AuditLog
+	switch config.SetStore {
+	case "", "memory":
+		mss := setstore.NewMemSetStore()
+		if config.SetsFileJSON != "" {
+			if err := mss.LoadFromFileJSON(config.SetsFileJSON); err != nil {
+				return nil, fmt.Errorf("initializing in-process setstore: %v", err)
+			} else {
+				logger.Info("loaded set config from JSON", "path", config.SetsFileJSON)
+			}
+		}
+		sets = mss
+		setsAudit = setstore.NewMemAuditLog(1_000)
+	case "redis":
+		rss, err := setstore.NewRedisSetStore(config.RedisURL)
+		if err != nil {
+			return nil, fmt.Errorf("initializing redis setstore: %v", err)
+		}
+		if config.SetsFileJSON != "" {
+			logger.Warn("ignoring sets JSON file with redis setstore; use 'hepa sets import' to load it", "path", config.SetsFileJSON)
+		}
+		sets = rss
+		setsAudit = setstore.NewRedisAuditLog(rdb, 10_000)
+	default:
+		return nil, fmt.Errorf("unknown set store: %s", config.SetStore)
+	}
+
 	// IMPORTANT: reminder that these are the indigo-edition rules, not production rules
 	extraBlobRules := []automod.BlobRuleFunc{}
 	if config.HiveAPIToken != "" && config.RulesetName != "no-hive" {
@@ -174,18 +235,91 @@ func NewServer(dir identity.Directory, config Config) (*Server, error) {
 		extraBlobRules = append(extraBlobRules, ac.AbyssScanBlobRule)
 	}
 
-	var ruleset automod.RuleSet
-	switch config.RulesetName {
-	case "", "default", "no-hive":
-		ruleset = rules.DefaultRules()
-		ruleset.BlobRules = append(ruleset.BlobRules, extraBlobRules...)
-	case "no-blobs":
-		ruleset = rules.DefaultRules()
-		ruleset.BlobRules = []automod.BlobRuleFunc{}
-	case "only-blobs":
-		ruleset.BlobRules = extraBlobRules
+	var hashes hashstore.HashStore
+	if config.ImageHashing {
+		logger.Info("configuring perceptual image hashing")
+		if config.RedisURL != "" {
+			hs, err := hashstore.NewRedisHashStore(config.RedisURL, imageHashRetention)
+			if err != nil {
+				return nil, fmt.Errorf("initializing redis hashstore: %v", err)
+			}
+			hashes = hs
+		} else {
+			hashes = hashstore.NewMemHashStore(imageHashRetention)
+		}
+		extraBlobRules = append(extraBlobRules, visual.ImageHashBlobRule)
+	}
+
+	var texts textsim.TextStore
+	if config.TextSimilarity {
+		logger.Info("configuring text similarity index")
+		if config.RedisURL != "" {
+			ts, err := textsim.NewRedisTextStore(config.RedisURL, textSimilarityRetention)
+			if err != nil {
+				return nil, fmt.Errorf("initializing redis textstore: %v", err)
+			}
+			texts = ts
+		} else {
+			texts = textsim.NewMemTextStore(textSimilarityRetention)
+		}
+	}
+
+	var lexicons lexicon.Catalog
+	if config.LexiconsDir != "" {
+		cat := lexicon.NewBaseCatalog()
+		if err := cat.LoadDirectory(config.LexiconsDir); err != nil {
+			return nil, fmt.Errorf("loading lexicons: %v", err)
+		}
+		logger.Info("loaded lexicon schemas for record validation", "path", config.LexiconsDir)
+		lexicons = &cat
+	}
+
+	var graph graphstore.GraphStore
+	switch config.GraphStore {
+	case "", "none":
+		if config.GraphRelationships {
+			return nil, fmt.Errorf("graph relationships require a graph store")
+		}
+	case "memory":
+		graph = graphstore.NewMemGraphStore()
+	case "redis":
+		if config.RedisURL == "" {
+			return nil, fmt.Errorf("redis graph store requires a redis URL")
+		}
+		gs, err := graphstore.NewRedisGraphStore(config.RedisURL)
+		if err != nil {
+			return nil, fmt.Errorf("initializing redis graphstore: %v", err)
+		}
+		graph = gs
+	case "pebble":
+		if config.GraphPebblePath == "" {
+			return nil, fmt.Errorf("pebble graph store requires a database path")
+		}
+		gs, err := graphstore.OpenPebbleGraphStore(config.GraphPebblePath)
+		if err != nil {
+			return nil, fmt.Errorf("initializing pebble graphstore: %v", err)
+		}
+		graph = gs
 	default:
-		return nil, fmt.Errorf("unknown ruleset config: %s", config.RulesetName)
+		return nil, fmt.Errorf("unknown graph store: %s", config.GraphStore)
+	}
+	if graph != nil {
+		logger.Info("configuring local account graph", "store", config.GraphStore)
+	}
+
+	ruleset, err := configRuleset(config.RulesetName, extraBlobRules)
+	if err != nil {
+		return nil, err
+	}
+
+	var rulesLoader *declarative.Loader
+	if config.RulesFile != "" {
+		rulesLoader = declarative.NewLoader(config.RulesFile)
+		rulesLoader.Logger = logger.With("subsystem", "declarative-rules")
+		if _, err := rulesLoader.Load(context.TODO()); err != nil {
+			return nil, fmt.Errorf("loading declarative rules: %w", err)
+		}
+		rulesLoader.Extend(&ruleset)
 	}
 
 	var notifier automod.Notifier
@@ -195,6 +329,36 @@ func NewServer(dir identity.Directory, config Config) (*Server, error) {
 		}
 	}
 
+	var sinks []automod.ActionSink
+	sinkClient := util.RobustHTTPClient()
+	if config.ActionWebhookURL != "" {
+		if config.ActionWebhookSecret == "" {
+			logger.Warn("action webhook requests will not be signed; set a webhook secret")
+		}
+		sinks = append(sinks, &automod.WebhookSink{
+			URL:    config.ActionWebhookURL,
+			Secret: config.ActionWebhookSecret,
+			Client: sinkClient,
+		})
+	}
+	if config.DiscordWebhookURL != "" {
+		sinks = append(sinks, &automod.DiscordSink{
+			WebhookURL: config.DiscordWebhookURL,
+			Client:     sinkClient,
+		})
+	}
+	if config.ActionAuditDBURL != "" {
+		db, err := cliutil.SetupDatabase(config.ActionAuditDBURL, 4)
+		if err != nil {
+			return nil, fmt.Errorf("connecting to action audit database: %w", err)
+		}
+		auditSink, err := engine.NewSQLAuditSink(db)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, auditSink)
+	}
+
 	bskyClient := xrpc.Client{
 		Client: util.RobustHTTPClient(),
 		Host:   config.BskyHost,
@@ -203,20 +367,38 @@ func NewServer(dir identity.Directory, config Config) (*Server, error) {
 		bskyClient.Headers = make(map[string]string)
 		bskyClient.Headers["x-ratelimit-bypass"] = config.RatelimitBypass
 	}
+	var rulePolicies map[string]engine.RulePolicy
+	if len(config.ShadowRules) > 0 {
+		rulePolicies = make(map[string]engine.RulePolicy, len(config.ShadowRules))
+		for _, name := range config.ShadowRules {
+			rulePolicies[name] = engine.RulePolicy{Shadow: true}
+		}
+	}
+	var outcomes *engine.OutcomeRecorder
+	if config.RuleOutcomesRetention > 0 {
+		outcomes = engine.NewOutcomeRecorder(config.RuleOutcomesRetention)
+	}
+
 	blobClient := util.RobustHTTPClient()
 	eng := automod.Engine{
 		Logger:      logger,
 		Directory:   dir,
 		Counters:    counters,
 		Sets:        sets,
+		Hashes:      hashes,
+		Texts:       texts,
+		Lexicons:    lexicons,
+		Graph:       graph,
 		Flags:       flags,
 		Cache:       cache,
 		Rules:       ruleset,
 		Notifier:    notifier,
+		Sinks:       sinks,
 		BskyClient:  &bskyClient,
 		OzoneClient: ozoneClient,
 		AdminClient: adminClient,
 		BlobClient:  blobClient,
+		Outcomes:    outcomes,
 		Config: engine.EngineConfig{
 			ReportDupePeriod:     config.ReportDupePeriod,
 			QuotaModReportDay:    config.QuotaModReportDay,
@@ -225,6 +407,9 @@ func NewServer(dir identity.Directory, config Config) (*Server, error) {
 			RecordEventTimeout:   config.RecordEventTimeout,
 			IdentityEventTimeout: config.IdentityEventTimeout,
 			OzoneEventTimeout:    config.OzoneEventTimeout,
+			ShadowMode:           config.ShadowMode,
+			RulePolicies:         rulePolicies,
+			GraphRelationships:   config.GraphRelationships,
 		},
 	}
 
@@ -232,12 +417,64 @@ func NewServer(dir identity.Directory, config Config) (*Server, error) {
 		logger:      logger,
 		Engine:      &eng,
 		RedisClient: rdb,
+		RulesLoader: rulesLoader,
+		SetsAdmin: &setstore.Admin{
+			Store:  sets,
+			Audit:  setsAudit,
+			Logger: logger.With("subsystem", "sets-admin"),
+		},
+		setsFileJSON: config.SetsFileJSON,
+		adminToken:   config.AdminToken,
 	}
 
 	return s, nil
 }
 
+// how long post text signatures are kept in the text index
+const textSimilarityRetention = 24 * time.Hour
+
+// Returns a compiled-in ruleset by name, with any extra blob rules (eg, image scanning) included
+func configRuleset(name string, extraBlobRules []automod.BlobRuleFunc) (automod.RuleSet, error) {
+	var ruleset automod.RuleSet
+	switch name {
+	case "", "default", "no-hive":
+		ruleset = rules.DefaultRules()
+		ruleset.BlobRules = append(ruleset.BlobRules, extraBlobRules...)
+	case "no-blobs":
+		ruleset = rules.DefaultRules()
+		ruleset.BlobRules = []automod.BlobRuleFunc{}
+	case "only-blobs":
+		ruleset.BlobRules = extraBlobRules
+	default:
+		return ruleset, fmt.Errorf("unknown ruleset config: %s", name)
+	}
+	return ruleset, nil
+}
+
 func (s *Server) RunMetrics(listen string) error {
 	http.Handle("/metrics", promhttp.Handler())
+	if s.Engine.Outcomes != nil {
+		http.HandleFunc("GET /rule-outcomes", s.handleRuleOutcomes)
+	}
+	if s.adminToken != "" {
+		s.registerAdminHandlers()
+	}
 	return http.ListenAndServe(listen, nil)
 }
+
+// Serves per-rule live and shadow outcome summaries as JSON. The optional "window" query parameter is a duration (default one hour).
+func (s *Server) handleRuleOutcomes(w http.ResponseWriter, r *http.Request) {
+	window := time.Hour
+	if v := r.URL.Query().Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "invalid window duration", http.StatusBadRequest)
+			return
+		}
+		window = d
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.Engine.Outcomes.Report(time.Now().Add(-window))); err != nil {
+		s.logger.Error("failed to encode rule outcomes", "err", err)
+	}
+}
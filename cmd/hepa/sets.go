@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/bluesky-social/indigo/automod/setstore"
+
+	cli "github.com/urfave/cli/v2"
+)
+
+var setsCmd = &cli.Command{
+	Name:  "sets",
+	Usage: "view and edit sets in the redis set store (changes are recorded in the audit log, and picked up by running hepa processes)",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "actor",
+			Usage:   "who is making changes, for the audit log (defaults to $USER)",
+			EnvVars: []string{"HEPA_SETS_ACTOR", "USER"},
+		},
+	},
+	Subcommands: []*cli.Command{
+		&cli.Command{
+			Name:   "list",
+			Usage:  "list sets, with type and number of members",
+			Action: runSetsList,
+		},
+		&cli.Command{
+			Name:      "show",
+			Usage:     "list members of a set",
+			ArgsUsage: `<set>`,
+			Action:    runSetsShow,
+		},
+		&cli.Command{
+			Name:      "add",
+			Usage:     "add members to a set, creating it if needed",
+			ArgsUsage: `<set> <value>...`,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "type",
+					Usage: "type of set (for new sets): exact, suffix, cidr, regex",
+				},
+			},
+			Action: runSetsAdd,
+		},
+		&cli.Command{
+			Name:      "remove",
+			Usage:     "remove members from a set",
+			ArgsUsage: `<set> <value>...`,
+			Action:    runSetsRemove,
+		},
+		&cli.Command{
+			Name:      "delete",
+			Usage:     "delete an entire set",
+			ArgsUsage: `<set>`,
+			Action:    runSetsDelete,
+		},
+		&cli.Command{
+			Name:      "import",
+			Usage:     "replace sets with those from a JSON file (same format as sets-json-path). other sets are not changed",
+			ArgsUsage: `<file>`,
+			Action:    runSetsImport,
+		},
+		&cli.Command{
+			Name:  "audit",
+			Usage: "show recent set changes, newest first",
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:  "limit",
+					Usage: "max number of changes to show",
+					Value: 50,
+				},
+			},
+			Action: runSetsAudit,
+		},
+	},
+}
+
+func configSetsAdmin(cctx *cli.Context) (*setstore.Admin, error) {
+	redisURL := cctx.String("redis-url")
+	if redisURL == "" {
+		return nil, fmt.Errorf("editing sets requires a redis URL")
+	}
+	rss, err := setstore.NewRedisSetStore(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	return &setstore.Admin{
+		Store:  rss,
+		Audit:  setstore.NewRedisAuditLog(rss.Client, 10_000),
+		Logger: configLogger(cctx, os.Stderr),
+	}, nil
+}
+
+func setsActor(cctx *cli.Context) (string, error) {
+	actor := cctx.String("actor")
+	if actor == "" {
+		return "", fmt.Errorf("an actor is required for set changes (--actor)")
+	}
+	return actor, nil
+}
+
+func printJSON(v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+func runSetsList(cctx *cli.Context) error {
+	admin, err := configSetsAdmin(cctx)
+	if err != nil {
+		return err
+	}
+	infos, err := admin.Store.ListSets(context.Background())
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		fmt.Printf("%s\t%s\t%d\n", info.Name, info.Type, info.Size)
+	}
+	return nil
+}
+
+func runSetsShow(cctx *cli.Context) error {
+	name := cctx.Args().First()
+	if name == "" {
+		return fmt.Errorf("expected a set name argument")
+	}
+	admin, err := configSetsAdmin(cctx)
+	if err != nil {
+		return err
+	}
+	members, err := admin.Store.Members(context.Background(), name)
+	if err != nil {
+		return err
+	}
+	for _, val := range members {
+		fmt.Println(val)
+	}
+	return nil
+}
+
+func runSetsAdd(cctx *cli.Context) error {
+	if cctx.Args().Len() < 2 {
+		return fmt.Errorf("expected a set name and at least one value")
+	}
+	actor, err := setsActor(cctx)
+	if err != nil {
+		return err
+	}
+	admin, err := configSetsAdmin(cctx)
+	if err != nil {
+		return err
+	}
+	return admin.Add(context.Background(), actor, cctx.Args().First(), cctx.String("type"), cctx.Args().Tail())
+}
+
+func runSetsRemove(cctx *cli.Context) error {
+	if cctx.Args().Len() < 2 {
+		return fmt.Errorf("expected a set name and at least one value")
+	}
+	actor, err := setsActor(cctx)
+	if err != nil {
+		return err
+	}
+	admin, err := configSetsAdmin(cctx)
+	if err != nil {
+		return err
+	}
+	return admin.Remove(context.Background(), actor, cctx.Args().First(), cctx.Args().Tail())
+}
+
+func runSetsDelete(cctx *cli.Context) error {
+	name := cctx.Args().First()
+	if name == "" {
+		return fmt.Errorf("expected a set name argument")
+	}
+	actor, err := setsActor(cctx)
+	if err != nil {
+		return err
+	}
+	admin, err := configSetsAdmin(cctx)
+	if err != nil {
+		return err
+	}
+	return admin.DeleteSet(context.Background(), actor, name)
+}
+
+func runSetsImport(cctx *cli.Context) error {
+	p := cctx.Args().First()
+	if p == "" {
+		return fmt.Errorf("expected a JSON file path argument")
+	}
+	actor, err := setsActor(cctx)
+	if err != nil {
+		return err
+	}
+	sets, err := setstore.ReadSetsFileJSON(p)
+	if err != nil {
+		return err
+	}
+	admin, err := configSetsAdmin(cctx)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	for name, def := range sets {
+		if err := admin.Replace(ctx, actor, name, def.Type, def.Values); err != nil {
+			return fmt.Errorf("importing set %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func runSetsAudit(cctx *cli.Context) error {
+	admin, err := configSetsAdmin(cctx)
+	if err != nil {
+		return err
+	}
+	changes, err := admin.Audit.Recent(context.Background(), cctx.Int("limit"))
+	if err != nil {
+		return err
+	}
+	return printJSON(changes)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	_ "net/http/pprof"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/bluesky-social/indigo/plcmirror"
+
+	"github.com/carlmjohnson/versioninfo"
+	_ "github.com/joho/godotenv/autoload"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/urfave/cli/v2"
+	_ "go.uber.org/automaxprocs"
+)
+
+var log = slog.Default().With("system", "plcmirror")
+
+func main() {
+	if err := run(os.Args); err != nil {
+		log.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	app := cli.App{
+		Name:    "plcmirror",
+		Usage:   "verifying read-only replica of a did:plc directory",
+		Version: versioninfo.Short(),
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "upstream-url",
+				Usage:   "base URL of upstream PLC directory",
+				Value:   "https://plc.directory",
+				EnvVars: []string{"PLCMIRROR_UPSTREAM_URL", "ATP_PLC_HOST"},
+			},
+			&cli.StringFlag{
+				Name:    "db-path",
+				Usage:   "path to directory for embedded (pebble) database",
+				Value:   "./plcmirror.db",
+				EnvVars: []string{"PLCMIRROR_DB_PATH"},
+			},
+			&cli.StringFlag{
+				Name:    "api-listen",
+				Value:   ":2582",
+				EnvVars: []string{"PLCMIRROR_API_LISTEN"},
+			},
+			&cli.StringFlag{
+				Name:    "metrics-listen",
+				Value:   ":2583",
+				EnvVars: []string{"PLCMIRROR_METRICS_LISTEN"},
+			},
+			&cli.DurationFlag{
+				Name:    "poll-interval",
+				Usage:   "how often to poll upstream once caught up",
+				Value:   5 * time.Second,
+				EnvVars: []string{"PLCMIRROR_POLL_INTERVAL"},
+			},
+			&cli.BoolFlag{
+				Name:    "no-sync",
+				Usage:   "only serve existing mirrored data; don't consume from upstream",
+				EnvVars: []string{"PLCMIRROR_NO_SYNC"},
+			},
+		},
+		Action: runMirror,
+	}
+	return app.Run(args)
+}
+
+func runMirror(cctx *cli.Context) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	store, err := plcmirror.OpenStore(cctx.String("db-path"))
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	config := plcmirror.DefaultMirrorConfig()
+	config.UpstreamURL = cctx.String("upstream-url")
+	config.PollInterval = cctx.Duration("poll-interval")
+	config.UserAgent = "indigo-plcmirror/" + versioninfo.Short()
+	mirror := plcmirror.NewMirror(config, store)
+
+	// metrics and pprof
+	go func() {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		mux.Handle("/debug/pprof/", http.DefaultServeMux)
+		if err := http.ListenAndServe(cctx.String("metrics-listen"), mux); err != nil {
+			log.Error("failed to start metrics endpoint", "err", err)
+		}
+	}()
+
+	srv := &http.Server{
+		Addr:              cctx.String("api-listen"),
+		Handler:           plcmirror.NewServer(store, mirror).Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	runErr := make(chan error, 2)
+	go func() {
+		log.Info("starting API server", "bind", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			runErr <- err
+		}
+	}()
+	syncDone := make(chan struct{})
+	if cctx.Bool("no-sync") {
+		close(syncDone)
+	} else {
+		go func() {
+			defer close(syncDone)
+			log.Info("starting upstream sync", "upstream", config.UpstreamURL)
+			if err := mirror.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				runErr <- err
+			}
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+		log.Info("received shutdown signal")
+	case err = <-runErr:
+		log.Error("shutting down after error", "err", err)
+	}
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	if serr := srv.Shutdown(shutdownCtx); serr != nil {
+		log.Error("error during API server shutdown", "err", serr)
+	}
+	// wait for any in-progress writes before closing the store
+	cancel()
+	<-syncDone
+	return err
+}
@@ -21,6 +21,9 @@ import (
 	"github.com/bluesky-social/indigo/api/atproto"
 	comatproto "github.com/bluesky-social/indigo/api/atproto"
 	"github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/crypto"
+	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/syntax"
 	"github.com/bluesky-social/indigo/carstore"
 	"github.com/bluesky-social/indigo/did"
 	"github.com/bluesky-social/indigo/events"
@@ -69,7 +72,7 @@ type Server struct {
 	EnableSSL    bool
 	Logger       *slog.Logger
 	EventControl chan string
-	MultibaseKey string
+	SigningKey   crypto.PublicKey
 	RepoManager  *repomgr.RepoManager
 
 	// Event Loop Parameters
@@ -240,9 +243,9 @@ func Reload(cctx *cli.Context) error {
 		log.Fatalf("failed to init repo manager: %+v\n", err)
 	}
 
-	vMethod, err := godid.VerificationMethodFromKey(privkey.Public())
+	signingKey, err := crypto.ParsePublicDIDKey(privkey.Public().DID())
 	if err != nil {
-		log.Fatalf("failed to generate verification method: %+v\n", err)
+		log.Fatalf("failed to parse public key: %+v\n", err)
 	}
 
 	// Initialize fake account DIDs
@@ -258,9 +261,9 @@ func Reload(cctx *cli.Context) error {
 		EnableSSL: cctx.Bool("use-ssl"),
 		Host:      cctx.String("hostname"),
 
-		RepoManager:  repoman,
-		MultibaseKey: *vMethod.PublicKeyMultibase,
-		Dids:         dids,
+		RepoManager: repoman,
+		SigningKey:  signingKey,
+		Dids:        dids,
 
 		Events:             em,
 		TotalDesiredEvents: cctx.Int("total-events"),
@@ -449,9 +452,9 @@ func Fire(cctx *cli.Context) error {
 		}
 	}
 
-	vMethod, err := godid.VerificationMethodFromKey(privkey.Public())
+	signingKey, err := crypto.ParsePublicDIDKey(privkey.Public().DID())
 	if err != nil {
-		log.Fatalf("failed to generate verification method: %+v\n", err)
+		log.Fatalf("failed to parse public key: %+v\n", err)
 	}
 
 	// Instantiate Server
@@ -459,7 +462,7 @@ func Fire(cctx *cli.Context) error {
 		Logger:             logger,
 		EnableSSL:          cctx.Bool("use-ssl"),
 		Host:               cctx.String("hostname"),
-		MultibaseKey:       *vMethod.PublicKeyMultibase,
+		SigningKey:         signingKey,
 		MaxEventsPerSecond: cctx.Int("events-per-second"),
 		PlaybackFile:       cctx.String("input-file"),
 	}
@@ -663,28 +666,25 @@ func (s *Server) HandleAtprotoDid(c echo.Context) error {
 
 // HandleWellKnownDid handles DID document lookups (DID -> identity)
 func (s *Server) HandleWellKnownDid(c echo.Context) error {
-	return c.JSON(http.StatusOK, map[string]any{
-		"@context": []string{"https://www.w3.org/ns/did/v1"},
-		"id":       "did:web:" + c.Request().Host,
-		"alsoKnownAs": []string{
-			"at://" + c.Request().Host,
-		},
-		"verificationMethod": []map[string]any{
-			{
-				"id":                 "#atproto",
-				"type":               godid.KeyTypeSecp256k1,
-				"controller":         "did:web:" + s.Host,
-				"publicKeyMultibase": s.MultibaseKey,
-			},
-		},
-		"service": []map[string]any{
-			{
-				"id":              "#atproto_pds",
-				"type":            "AtprotoPersonalDataServer",
-				"serviceEndpoint": "http://" + s.Host,
-			},
-		},
-	})
+	// did:web requires the port to be percent-encoded, and handles can't include a port
+	host := c.Request().Host
+	didHost := host
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		host = h
+		didHost = h + "%3A" + port
+	}
+	builder := identity.NewDIDDocumentBuilder(syntax.DID("did:web:" + didHost)).
+		AtprotoKey(s.SigningKey).
+		PDS("http://" + s.Host)
+	// single-label hosts (like "localhost") are not valid handles
+	if handle, err := syntax.ParseHandle(host); err == nil {
+		builder.Handle(handle)
+	}
+	doc, err := builder.Build()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return echo.WrapHandler(identity.DIDDocumentHandler(doc))(c)
 }
 
 // DescribeServerHandler identifies the server as a PDS (even though it isn't)
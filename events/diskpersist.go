@@ -685,10 +685,18 @@ func postDoNotEmit(flags uint32) bool {
 }
 
 func (dp *DiskPersistence) readEventsFrom(ctx context.Context, since int64, fn string, cb func(*XRPCStreamEvent) error) (*int64, error) {
+	return ReadEventLogFile(ctx, since, fn, cb)
+}
+
+// Reads events from a single DiskPersistence log file (eg, "evts-12345" in the primary directory), starting from the "since" sequence number (or the start of the file, if zero), and calls cb for each. Taken-down and rebased events are skipped. Returns the last sequence number read.
+//
+// This works directly on the log file, without the metadata database, so it can be used by offline tools.
+func ReadEventLogFile(ctx context.Context, since int64, fn string, cb func(*XRPCStreamEvent) error) (*int64, error) {
 	fi, err := os.OpenFile(fn, os.O_RDONLY, 0)
 	if err != nil {
 		return nil, err
 	}
+	defer fi.Close()
 
 	if since != 0 {
 		lastSeq, err := scanForLastSeq(fi, since)
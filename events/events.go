@@ -211,6 +211,12 @@ func (evt *XRPCStreamEvent) Serialize(wc io.Writer) error {
 	case evt.RepoTombstone != nil:
 		header.MsgType = "#tombstone"
 		obj = evt.RepoTombstone
+	case evt.LabelLabels != nil:
+		header.MsgType = "#labels"
+		obj = evt.LabelLabels
+	case evt.LabelInfo != nil:
+		header.MsgType = "#info"
+		obj = evt.LabelInfo
 	default:
 		return fmt.Errorf("unrecognized event kind")
 	}
@@ -446,6 +452,8 @@ func (evt *XRPCStreamEvent) Sequence() int64 {
 		return evt.RepoIdentity.Seq
 	case evt.RepoAccount != nil:
 		return evt.RepoAccount.Seq
+	case evt.LabelLabels != nil:
+		return evt.LabelLabels.Seq
 	case evt.RepoInfo != nil:
 		return -1
 	case evt.Error != nil:
@@ -13,6 +13,7 @@ import (
 	"github.com/bluesky-social/indigo/mst"
 	"github.com/bluesky-social/indigo/repo"
 	"github.com/bluesky-social/indigo/util/labels"
+	"github.com/bluesky-social/indigo/xrpc"
 
 	cbg "github.com/whyrusleeping/cbor-gen"
 )
@@ -118,6 +119,10 @@ func main() {
 		panic(err)
 	}
 
+	if err := genCfg.WriteMapEncodersToFile("xrpc/cbor_gen.go", "xrpc", xrpc.StreamHeader{}, xrpc.StreamError{}, xrpc.StreamInfo{}); err != nil {
+		panic(err)
+	}
+
 	if err := genCfg.WriteMapEncodersToFile("atproto/data/cbor_gen.go", "data", data.GenericRecord{}, data.LegacyBlobSchema{}, data.BlobSchema{}); err != nil {
 		panic(err)
 	}
@@ -0,0 +1,8 @@
+/*
+Package invalidator keeps identity directory caches fresh by consuming a relay firehose.
+
+Caching directories ([identity.CacheDirectory], redisdir.RedisDirectory) otherwise rely on TTLs, and may return stale handles or keys for up to a day after an identity change. An [Invalidator] purges the DID (and new handle, if any) from the directory whenever an "#identity" or "#account" event is received. When the directory is Redis-backed, the purge is also broadcast to other processes sharing the cache; see redisdir.RedisDirectory.SubscribePurges.
+
+This package is kept outside of the atproto/ library tree because it depends on the firehose consumer in the events package.
+*/
+package invalidator
@@ -0,0 +1,136 @@
+package invalidator
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	comatproto "github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/events"
+	"github.com/bluesky-social/indigo/events/schedulers/sequential"
+
+	"github.com/carlmjohnson/versioninfo"
+	"github.com/gorilla/websocket"
+)
+
+// Consumes "#identity" and "#account" events from a relay firehose, and purges the affected identities from an [identity.Directory] cache.
+//
+// For a handle change, the DID and the new handle are purged. The previous handle is not known from the event; a cached entry for it will still point at the DID, but will fail bi-directional verification on the next lookup (because the DID is re-resolved).
+type Invalidator struct {
+	Directory identity.Directory
+	// Relay host, eg "wss://bsky.network"
+	RelayHost string
+	Logger    *slog.Logger
+
+	// most recent event sequence number seen, used as the cursor when reconnecting
+	lastSeq atomic.Int64
+}
+
+func NewInvalidator(dir identity.Directory, relayHost string) *Invalidator {
+	return &Invalidator{
+		Directory: dir,
+		RelayHost: relayHost,
+		Logger:    slog.Default().With("system", "identity-invalidator"),
+	}
+}
+
+// Subscribes to the relay until the context is cancelled, reconnecting (with backoff) after errors.
+//
+// The subscription starts at the live tip of the firehose: identities which changed before the invalidator started are not purged. After a reconnect, the stream is resumed from the last seen event.
+func (inv *Invalidator) Run(ctx context.Context) error {
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := inv.subscribe(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		inv.Logger.Warn("identity invalidator firehose connection failed", "err", err, "retry", backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+func (inv *Invalidator) subscribe(ctx context.Context) error {
+	u, err := url.Parse(inv.RelayHost)
+	if err != nil {
+		return fmt.Errorf("invalid relay host URL: %w", err)
+	}
+	u.Path = "xrpc/com.atproto.sync.subscribeRepos"
+	if seq := inv.lastSeq.Load(); seq > 0 {
+		u.RawQuery = fmt.Sprintf("cursor=%d", seq)
+	}
+	inv.Logger.Info("subscribing to repo event stream", "upstream", inv.RelayHost, "cursor", inv.lastSeq.Load())
+	con, _, err := websocket.DefaultDialer.DialContext(ctx, u.String(), http.Header{
+		"User-Agent": []string{fmt.Sprintf("indigo-identity-invalidator/%s", versioninfo.Short())},
+	})
+	if err != nil {
+		return fmt.Errorf("subscribing to firehose failed (dialing): %w", err)
+	}
+	// events are processed in order, so that a purge is never overtaken by an earlier event for the same account
+	sched := sequential.NewScheduler("identity-invalidator", inv.HandleEvent)
+	return events.HandleRepoStream(ctx, con, sched, inv.Logger)
+}
+
+// Processes a single firehose event. This can be used as the handler for an existing [events.Scheduler], instead of calling [Invalidator.Run].
+//
+// Purge failures are logged, not returned, so they do not interrupt the event stream.
+func (inv *Invalidator) HandleEvent(ctx context.Context, xev *events.XRPCStreamEvent) error {
+	if seq := xev.Sequence(); seq > 0 {
+		inv.lastSeq.Store(seq)
+	}
+	switch {
+	case xev.RepoIdentity != nil:
+		inv.handleIdentity(ctx, xev.RepoIdentity)
+	case xev.RepoAccount != nil:
+		inv.handleAccount(ctx, xev.RepoAccount)
+	}
+	return nil
+}
+
+func (inv *Invalidator) handleIdentity(ctx context.Context, evt *comatproto.SyncSubscribeRepos_Identity) {
+	did, err := syntax.ParseDID(evt.Did)
+	if err != nil {
+		inv.Logger.Warn("invalid DID in identity event", "did", evt.Did, "seq", evt.Seq, "err", err)
+		return
+	}
+	inv.purge(ctx, "identity", did.AtIdentifier())
+	if evt.Handle != nil {
+		handle, err := syntax.ParseHandle(*evt.Handle)
+		if err == nil && !handle.IsInvalidHandle() {
+			inv.purge(ctx, "identity", handle.AtIdentifier())
+		}
+	}
+}
+
+// account status changes (eg, deactivation or deletion) can coincide with identity changes, so the DID is purged
+func (inv *Invalidator) handleAccount(ctx context.Context, evt *comatproto.SyncSubscribeRepos_Account) {
+	did, err := syntax.ParseDID(evt.Did)
+	if err != nil {
+		inv.Logger.Warn("invalid DID in account event", "did", evt.Did, "seq", evt.Seq, "err", err)
+		return
+	}
+	inv.purge(ctx, "account", did.AtIdentifier())
+}
+
+func (inv *Invalidator) purge(ctx context.Context, eventType string, id syntax.AtIdentifier) {
+	if err := inv.Directory.Purge(ctx, id); err != nil {
+		purgeErrors.WithLabelValues(eventType).Inc()
+		inv.Logger.Warn("failed to purge identity from directory cache", "id", id.String(), "err", err)
+		return
+	}
+	purges.WithLabelValues(eventType).Inc()
+}
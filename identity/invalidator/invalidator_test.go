@@ -0,0 +1,113 @@
+package invalidator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	comatproto "github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/events"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// records purged identifiers
+type purgeRecorder struct {
+	identity.MockDirectory
+	lk     sync.Mutex
+	purged []string
+}
+
+func (d *purgeRecorder) Purge(ctx context.Context, a syntax.AtIdentifier) error {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+	d.purged = append(d.purged, a.String())
+	return nil
+}
+
+func (d *purgeRecorder) Purged() []string {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+	return append([]string{}, d.purged...)
+}
+
+func TestHandleEvent(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	dir := purgeRecorder{MockDirectory: identity.NewMockDirectory()}
+	inv := NewInvalidator(&dir, "")
+
+	handle := "alice.example.com"
+	assert.NoError(inv.HandleEvent(ctx, &events.XRPCStreamEvent{
+		RepoIdentity: &comatproto.SyncSubscribeRepos_Identity{Did: "did:plc:abc111", Handle: &handle, Seq: 10},
+	}))
+	invalid := "handle.invalid"
+	assert.NoError(inv.HandleEvent(ctx, &events.XRPCStreamEvent{
+		RepoIdentity: &comatproto.SyncSubscribeRepos_Identity{Did: "did:plc:abc222", Handle: &invalid, Seq: 11},
+	}))
+	assert.NoError(inv.HandleEvent(ctx, &events.XRPCStreamEvent{
+		RepoAccount: &comatproto.SyncSubscribeRepos_Account{Did: "did:plc:abc333", Seq: 12},
+	}))
+	assert.NoError(inv.HandleEvent(ctx, &events.XRPCStreamEvent{
+		RepoAccount: &comatproto.SyncSubscribeRepos_Account{Did: "not-a-did", Seq: 13},
+	}))
+
+	assert.Equal([]string{"did:plc:abc111", "alice.example.com", "did:plc:abc222", "did:plc:abc333"}, dir.Purged())
+	assert.Equal(int64(13), inv.lastSeq.Load())
+}
+
+func TestRun(t *testing.T) {
+	assert := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/xrpc/com.atproto.sync.subscribeRepos", r.URL.Path)
+		con, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer con.Close()
+		evt := events.XRPCStreamEvent{
+			RepoIdentity: &comatproto.SyncSubscribeRepos_Identity{Did: "did:plc:abc111", Seq: 1, Time: syntax.DatetimeNow().String()},
+		}
+		wc, err := con.NextWriter(websocket.BinaryMessage)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err := evt.Serialize(wc); err != nil {
+			t.Error(err)
+			return
+		}
+		wc.Close()
+		// hold the connection open until the client goes away
+		con.ReadMessage()
+	}))
+	defer srv.Close()
+
+	dir := purgeRecorder{MockDirectory: identity.NewMockDirectory()}
+	inv := NewInvalidator(&dir, "ws"+strings.TrimPrefix(srv.URL, "http"))
+	done := make(chan error)
+	go func() {
+		done <- inv.Run(ctx)
+	}()
+
+	assert.Eventually(func() bool {
+		return len(dir.Purged()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal([]string{"did:plc:abc111"}, dir.Purged())
+
+	cancel()
+	assert.ErrorIs(<-done, context.Canceled)
+}
@@ -0,0 +1,16 @@
+package invalidator
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var purges = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "atproto_identity_invalidator_purges",
+	Help: "Number of identifiers purged from the identity directory cache, by firehose event type",
+}, []string{"event"})
+
+var purgeErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "atproto_identity_invalidator_purge_errors",
+	Help: "Number of failed identity directory cache purges, by firehose event type",
+}, []string{"event"})
@@ -2,9 +2,12 @@ package indexer
 
 import (
 	"context"
+	"crypto/ecdsa"
 	"fmt"
 	"log/slog"
 
+	"github.com/bluesky-social/indigo/atproto/crypto"
+
 	did "github.com/whyrusleeping/go-did"
 	"go.opentelemetry.io/otel"
 )
@@ -12,7 +15,9 @@ import (
 type KeyManager struct {
 	didr DidResolver
 
-	signingKey *did.PrivKey
+	signingKey crypto.PrivateKey
+	// only used for key types which atproto/crypto doesn't support
+	legacyKey *did.PrivKey
 
 	log *slog.Logger
 }
@@ -22,6 +27,44 @@ type DidResolver interface {
 }
 
 func NewKeyManager(didr DidResolver, k *did.PrivKey) *KeyManager {
+	km := &KeyManager{
+		didr: didr,
+		log:  slog.Default().With("system", "indexer"),
+	}
+	if k == nil {
+		return km
+	}
+	// go-did keys are converted so that signatures get the same "low-S" normalization as other atproto signing keys
+	if priv, err := cryptoKeyFromDID(k); err == nil {
+		km.signingKey = priv
+		return km
+	}
+	km.legacyKey = k
+	return km
+}
+
+// Converts an in-memory go-did key to the equivalent atproto/crypto key. Only P-256 and K-256 keys are supported.
+func cryptoKeyFromDID(k *did.PrivKey) (crypto.PrivateKey, error) {
+	switch k.Type {
+	case did.KeyTypeP256:
+		sk, ok := k.Raw.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("unexpected P-256 key type: %T", k.Raw)
+		}
+		return crypto.ParsePrivateBytesP256(sk.D.FillBytes(make([]byte, 32)))
+	case did.KeyTypeSecp256k1:
+		raw, err := k.RawBytes()
+		if err != nil {
+			return nil, err
+		}
+		return crypto.ParsePrivateBytesK256(raw)
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", k.Type)
+	}
+}
+
+// Creates a KeyManager which signs with an atproto/crypto key, which may be held externally (eg, an HSM or remote signing service; see [crypto.ExternalPrivateKey]).
+func NewCryptoKeyManager(didr DidResolver, k crypto.PrivateKey) *KeyManager {
 	return &KeyManager{
 		didr:       didr,
 		signingKey: k,
@@ -65,9 +108,11 @@ func (km *KeyManager) getKey(ctx context.Context, did string) (*did.PubKey, erro
 }
 
 func (km *KeyManager) SignForUser(ctx context.Context, did string, msg []byte) ([]byte, error) {
-	if km.signingKey == nil {
-		return nil, fmt.Errorf("key manager does not have a signing key, cannot sign")
+	if km.signingKey != nil {
+		return km.signingKey.HashAndSign(msg)
 	}
-
-	return km.signingKey.Sign(msg)
+	if km.legacyKey != nil {
+		return km.legacyKey.Sign(msg)
+	}
+	return nil, fmt.Errorf("key manager does not have a signing key, cannot sign")
 }
@@ -0,0 +1,42 @@
+package indexer
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+
+	"github.com/bluesky-social/indigo/atproto/crypto"
+
+	"github.com/stretchr/testify/assert"
+	did "github.com/whyrusleeping/go-did"
+)
+
+func TestKeyManagerLowS(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	msg := []byte("commit bytes")
+
+	for _, kt := range []string{did.KeyTypeP256, did.KeyTypeSecp256k1} {
+		k, err := did.GeneratePrivKey(rand.Reader, kt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		km := NewKeyManager(nil, k)
+		if !assert.NotNil(km.signingKey) {
+			continue
+		}
+		// in-memory keys are converted, not wrapped as external signers
+		_, external := km.signingKey.(*crypto.ExternalPrivateKey)
+		assert.False(external)
+		pub, err := km.signingKey.PublicKey()
+		assert.NoError(err)
+
+		// HashAndVerify rejects "high-S" signatures; go-did alone produces them about half the time for P-256
+		for range 32 {
+			sig, err := km.SignForUser(ctx, "did:plc:abc111", msg)
+			assert.NoError(err)
+			assert.NoError(pub.HashAndVerify(msg, sig))
+			assert.NoError(k.Public().Verify(msg, sig))
+		}
+	}
+}
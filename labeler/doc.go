@@ -0,0 +1,10 @@
+/*
+Package labeler implements an atproto labeling service.
+
+[Labeler] issues labels signed with the service's atproto signing key, and persists them in an embedded [Store] with monotonically increasing sequence numbers. Negation labels and label expiration ("exp") are supported; re-emitting a label which is already in effect (or negating one which is not) is a no-op.
+
+[Labeler.Handler] serves the "com.atproto.label.subscribeLabels" event stream (with cursor replay from the store, followed by live events) and the "com.atproto.label.queryLabels" endpoint (with exact and prefix URI patterns).
+
+A [Labeler] can be attached to an automod engine, so that rules emit labels directly instead of via an Ozone instance.
+*/
+package labeler
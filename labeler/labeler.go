@@ -0,0 +1,255 @@
+package labeler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	comatproto "github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/atproto/crypto"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/util/labels"
+)
+
+// Number of events buffered for each subscribeLabels consumer. Consumers which fall further behind are disconnected, and can reconnect with a cursor.
+const subscriberBuffer = 1024
+
+// A label to be issued. URI is an AT-URI (for a record) or a DID (for an account).
+type Label struct {
+	URI string
+	// Optional: specific version of the record this label applies to
+	CID *string
+	Val string
+	// If true, removes a previously issued label
+	Neg bool
+	// Optional: time at which the label stops applying
+	Exp *time.Time
+}
+
+// Issues signed labels, persists them, and broadcasts them to subscribers.
+type Labeler struct {
+	DID    syntax.DID
+	Logger *slog.Logger
+
+	key   crypto.PrivateKey
+	store *Store
+
+	// protects seq and store writes, so events are persisted and broadcast in sequence order
+	lk  sync.Mutex
+	seq int64
+
+	subsLk sync.Mutex
+	subs   map[*subscriber]struct{}
+}
+
+type subscriber struct {
+	evts chan *comatproto.LabelSubscribeLabels_Labels
+	// closed when the subscriber is dropped for falling behind
+	dropped chan struct{}
+}
+
+// The key must be the labeler's atproto signing key ("#atproto_label" verification method in the DID document).
+func NewLabeler(did syntax.DID, key crypto.PrivateKey, store *Store) (*Labeler, error) {
+	seq, err := store.LastSeq()
+	if err != nil {
+		return nil, err
+	}
+	return &Labeler{
+		DID:    did,
+		Logger: slog.Default().With("system", "labeler"),
+		key:    key,
+		store:  store,
+		seq:    seq,
+		subs:   make(map[*subscriber]struct{}),
+	}, nil
+}
+
+// Sequence number of the most recently persisted event.
+func (l *Labeler) Seq() int64 {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	return l.seq
+}
+
+// Signs and persists labels as a single event, and broadcasts it to subscribers. Labels which would not change the current state (re-issuing an active label with the same expiration, or negating a label which is not active) are skipped.
+//
+// Returns the sequence number of the event, or zero if every label was skipped.
+func (l *Labeler) Emit(ctx context.Context, lbls ...Label) (int64, error) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	now := time.Now()
+	cts := syntax.DatetimeNow().String()
+	var signed []*comatproto.LabelDefs_Label
+	for _, lbl := range lbls {
+		if lbl.URI == "" || lbl.Val == "" {
+			return 0, fmt.Errorf("label URI and value are required")
+		}
+		var exp *string
+		if lbl.Exp != nil {
+			s := lbl.Exp.UTC().Format(syntax.AtprotoDatetimeLayout)
+			exp = &s
+		}
+		cur, err := l.store.Current(lbl.URI, lbl.CID, lbl.Val)
+		if err != nil {
+			return 0, err
+		}
+		active := cur != nil && isActive(cur, now)
+		if lbl.Neg && !active {
+			continue
+		}
+		if !lbl.Neg && active && equalPtr(cur.Exp, exp) {
+			continue
+		}
+		sl, err := l.sign(labels.UnsignedLabel{
+			Cid: lbl.CID,
+			Cts: cts,
+			Exp: exp,
+			Neg: boolPtr(lbl.Neg),
+			Src: l.DID.String(),
+			Uri: lbl.URI,
+			Val: lbl.Val,
+			Ver: int64Ptr(1),
+		})
+		if err != nil {
+			return 0, err
+		}
+		signed = append(signed, sl)
+	}
+	if len(signed) == 0 {
+		return 0, nil
+	}
+
+	evt := &comatproto.LabelSubscribeLabels_Labels{
+		Seq:    l.seq + 1,
+		Labels: signed,
+	}
+	if err := l.store.Append(evt); err != nil {
+		return 0, fmt.Errorf("persisting labels: %w", err)
+	}
+	l.seq = evt.Seq
+	for _, sl := range signed {
+		labelsEmitted.WithLabelValues(fmt.Sprint(sl.Neg != nil && *sl.Neg)).Inc()
+	}
+	l.broadcast(evt)
+	return evt.Seq, nil
+}
+
+// Issues labels with the given values on a subject (AT-URI or DID).
+func (l *Labeler) CreateLabels(ctx context.Context, uri string, cid *string, vals []string) (int64, error) {
+	lbls := make([]Label, len(vals))
+	for i, val := range vals {
+		lbls[i] = Label{URI: uri, CID: cid, Val: val}
+	}
+	return l.Emit(ctx, lbls...)
+}
+
+// Negates previously issued labels with the given values on a subject (AT-URI or DID).
+func (l *Labeler) NegateLabels(ctx context.Context, uri string, cid *string, vals []string) (int64, error) {
+	lbls := make([]Label, len(vals))
+	for i, val := range vals {
+		lbls[i] = Label{URI: uri, CID: cid, Val: val, Neg: true}
+	}
+	return l.Emit(ctx, lbls...)
+}
+
+func (l *Labeler) sign(ul labels.UnsignedLabel) (*comatproto.LabelDefs_Label, error) {
+	b, err := ul.BytesForSigning()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := l.key.HashAndSign(b)
+	if err != nil {
+		return nil, fmt.Errorf("signing label: %w", err)
+	}
+	return &comatproto.LabelDefs_Label{
+		Cid: ul.Cid,
+		Cts: ul.Cts,
+		Exp: ul.Exp,
+		Neg: ul.Neg,
+		Sig: sig,
+		Src: ul.Src,
+		Uri: ul.Uri,
+		Val: ul.Val,
+		Ver: ul.Ver,
+	}, nil
+}
+
+// Registers a live subscriber. Returns the sequence number of the most recently persisted event: the subscriber receives every event after it, and none before it.
+func (l *Labeler) subscribe() (*subscriber, int64) {
+	sub := &subscriber{
+		evts:    make(chan *comatproto.LabelSubscribeLabels_Labels, subscriberBuffer),
+		dropped: make(chan struct{}),
+	}
+	// holding lk means no event can be persisted and broadcast between registering and reading seq
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	l.subsLk.Lock()
+	l.subs[sub] = struct{}{}
+	l.subsLk.Unlock()
+	subscribersConnected.Inc()
+	return sub, l.seq
+}
+
+func (l *Labeler) unsubscribe(sub *subscriber) {
+	l.subsLk.Lock()
+	defer l.subsLk.Unlock()
+	if _, ok := l.subs[sub]; ok {
+		delete(l.subs, sub)
+		subscribersConnected.Dec()
+	}
+}
+
+func (l *Labeler) broadcast(evt *comatproto.LabelSubscribeLabels_Labels) {
+	l.subsLk.Lock()
+	defer l.subsLk.Unlock()
+	for sub := range l.subs {
+		select {
+		case sub.evts <- evt:
+		default:
+			delete(l.subs, sub)
+			close(sub.dropped)
+			subscribersConnected.Dec()
+			subscribersDropped.Inc()
+		}
+	}
+}
+
+// Verifies the signature on a label against a public key (eg, the labeler's "#atproto_label" key).
+func VerifyLabel(lbl *comatproto.LabelDefs_Label, pub crypto.PublicKey) error {
+	ul := labels.UnsignedLabel{
+		Cid: lbl.Cid,
+		Cts: lbl.Cts,
+		Exp: lbl.Exp,
+		Neg: lbl.Neg,
+		Src: lbl.Src,
+		Uri: lbl.Uri,
+		Val: lbl.Val,
+		Ver: lbl.Ver,
+	}
+	b, err := ul.BytesForSigning()
+	if err != nil {
+		return err
+	}
+	return pub.HashAndVerify(b, lbl.Sig)
+}
+
+func equalPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func boolPtr(v bool) *bool {
+	if !v {
+		return nil
+	}
+	return &v
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
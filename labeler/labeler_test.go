@@ -0,0 +1,289 @@
+package labeler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	comatproto "github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/atproto/crypto"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/events"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func testLabeler(t *testing.T) (*Labeler, crypto.PublicKey) {
+	priv, err := crypto.GeneratePrivateKeyK256()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := priv.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := OpenMemStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	l, err := NewLabeler(syntax.DID("did:plc:labeler111"), priv, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l, pub
+}
+
+func TestEmit(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	l, pub := testLabeler(t)
+	uri := "at://did:plc:abc111/app.bsky.feed.post/abc123"
+
+	seq, err := l.CreateLabels(ctx, uri, nil, []string{"spam", "rude"})
+	assert.NoError(err)
+	assert.Equal(int64(1), seq)
+
+	// already active
+	seq, err = l.CreateLabels(ctx, uri, nil, []string{"spam"})
+	assert.NoError(err)
+	assert.Equal(int64(0), seq)
+
+	// not active
+	seq, err = l.NegateLabels(ctx, "did:plc:abc111", nil, []string{"spam"})
+	assert.NoError(err)
+	assert.Equal(int64(0), seq)
+
+	seq, err = l.NegateLabels(ctx, uri, nil, []string{"spam"})
+	assert.NoError(err)
+	assert.Equal(int64(2), seq)
+
+	// changing expiration re-issues the label
+	exp := time.Now().Add(time.Hour)
+	seq, err = l.Emit(ctx, Label{URI: uri, Val: "rude", Exp: &exp})
+	assert.NoError(err)
+	assert.Equal(int64(3), seq)
+
+	evts, err := l.store.Events(0, 100)
+	assert.NoError(err)
+	assert.Equal(3, len(evts))
+	assert.Equal(2, len(evts[0].Labels))
+	for _, evt := range evts {
+		for _, lbl := range evt.Labels {
+			assert.Equal("did:plc:labeler111", lbl.Src)
+			assert.NoError(VerifyLabel(lbl, pub))
+		}
+	}
+	neg := evts[1].Labels[0]
+	assert.True(*neg.Neg)
+	assert.NotNil(evts[2].Labels[0].Exp)
+
+	// tampered label fails verification
+	tampered := *neg
+	tampered.Val = "other"
+	assert.Error(VerifyLabel(&tampered, pub))
+
+	// sequence resumes after re-opening
+	l2, err := NewLabeler(l.DID, l.key, l.store)
+	assert.NoError(err)
+	assert.Equal(int64(3), l2.Seq())
+}
+
+func TestQueryLabels(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	l, _ := testLabeler(t)
+	srv := httptest.NewServer(l.Handler())
+	defer srv.Close()
+
+	past := time.Now().Add(-time.Hour)
+	for _, lbl := range []Label{
+		{URI: "did:plc:abc111", Val: "a"},
+		{URI: "at://did:plc:abc111/app.bsky.feed.post/1", Val: "b"},
+		{URI: "at://did:plc:abc111/app.bsky.feed.post/2", Val: "c"},
+		{URI: "at://did:plc:abc111/app.bsky.feed.post/3", Val: "d", Exp: &past},
+		{URI: "at://did:plc:abc222/app.bsky.feed.post/1", Val: "e"},
+		{URI: "did:plc:abc222", Val: "f"},
+	} {
+		_, err := l.Emit(ctx, lbl)
+		assert.NoError(err)
+	}
+	_, err := l.NegateLabels(ctx, "at://did:plc:abc111/app.bsky.feed.post/2", nil, []string{"c"})
+	assert.NoError(err)
+
+	query := func(params url.Values) (int, comatproto.LabelQueryLabels_Output) {
+		var out comatproto.LabelQueryLabels_Output
+		resp, err := http.Get(srv.URL + "/xrpc/com.atproto.label.queryLabels?" + params.Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			assert.NoError(json.NewDecoder(resp.Body).Decode(&out))
+		}
+		return resp.StatusCode, out
+	}
+	vals := func(out comatproto.LabelQueryLabels_Output) []string {
+		var v []string
+		for _, lbl := range out.Labels {
+			v = append(v, lbl.Val)
+		}
+		return v
+	}
+
+	status, out := query(url.Values{"uriPatterns": {"at://did:plc:abc111/*"}})
+	assert.Equal(http.StatusOK, status)
+	assert.Equal([]string{"b"}, vals(out))
+	assert.Nil(out.Cursor)
+
+	status, out = query(url.Values{"uriPatterns": {"did:plc:abc111", "at://did:plc:abc222/*", "at://*"}})
+	assert.Equal(http.StatusOK, status)
+	assert.Equal([]string{"b", "e", "a"}, vals(out))
+
+	// pagination
+	var all []string
+	params := url.Values{"uriPatterns": {"*"}, "limit": {"2"}}
+	for {
+		status, out = query(params)
+		assert.Equal(http.StatusOK, status)
+		all = append(all, vals(out)...)
+		if out.Cursor == nil {
+			break
+		}
+		params.Set("cursor", *out.Cursor)
+	}
+	assert.Equal([]string{"b", "e", "a", "f"}, all)
+
+	status, out = query(url.Values{"uriPatterns": {"*"}, "sources": {"did:plc:other"}})
+	assert.Equal(http.StatusOK, status)
+	assert.Empty(out.Labels)
+
+	status, _ = query(url.Values{"uriPatterns": {"at://*/app.bsky.feed.post/*"}})
+	assert.Equal(http.StatusBadRequest, status)
+	status, _ = query(url.Values{})
+	assert.Equal(http.StatusBadRequest, status)
+	status, _ = query(url.Values{"uriPatterns": {"*"}, "cursor": {"!!"}})
+	assert.Equal(http.StatusBadRequest, status)
+}
+
+func TestSubscribeLabels(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	l, _ := testLabeler(t)
+	srv := httptest.NewServer(l.Handler())
+	defer srv.Close()
+
+	for _, val := range []string{"a", "b", "c"} {
+		_, err := l.CreateLabels(ctx, "did:plc:abc111", nil, []string{val})
+		assert.NoError(err)
+	}
+
+	dial := func(cursor string) *websocket.Conn {
+		u := "ws" + strings.TrimPrefix(srv.URL, "http") + "/xrpc/com.atproto.label.subscribeLabels"
+		if cursor != "" {
+			u += "?cursor=" + cursor
+		}
+		conn, _, err := websocket.DefaultDialer.Dial(u, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	read := func(conn *websocket.Conn) *events.XRPCStreamEvent {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var evt events.XRPCStreamEvent
+		assert.NoError(evt.Deserialize(bytes.NewReader(msg)))
+		return &evt
+	}
+
+	// replay from cursor, then live
+	conn := dial("1")
+	for _, seq := range []int64{2, 3} {
+		evt := read(conn)
+		assert.NotNil(evt.LabelLabels)
+		assert.Equal(seq, evt.LabelLabels.Seq)
+	}
+	_, err := l.NegateLabels(ctx, "did:plc:abc111", nil, []string{"a"})
+	assert.NoError(err)
+	evt := read(conn)
+	assert.Equal(int64(4), evt.LabelLabels.Seq)
+	assert.True(*evt.LabelLabels.Labels[0].Neg)
+
+	// no cursor: live events only
+	live := dial("")
+	// wait for the subscription to register, since there is no replay to synchronize on
+	for i := 0; i < 100; i++ {
+		l.subsLk.Lock()
+		n := len(l.subs)
+		l.subsLk.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	_, err = l.CreateLabels(ctx, "did:plc:abc111", nil, []string{"d"})
+	assert.NoError(err)
+	assert.Equal(int64(5), read(live).LabelLabels.Seq)
+	assert.Equal(int64(5), read(conn).LabelLabels.Seq)
+
+	// future cursor
+	evt = read(dial("100"))
+	assert.NotNil(evt.Error)
+	assert.Equal("FutureCursor", evt.Error.Error)
+}
+
+func TestSubscribeLabelsBacklog(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	l, _ := testLabeler(t)
+	srv := httptest.NewServer(l.Handler())
+	defer srv.Close()
+
+	// a backlog longer than the live buffer
+	n := int64(subscriberBuffer + 10)
+	for i := int64(0); i < n; i++ {
+		_, err := l.CreateLabels(ctx, "did:plc:abc111", nil, []string{"val" + strconv.FormatInt(i, 10)})
+		assert.NoError(err)
+	}
+
+	u := "ws" + strings.TrimPrefix(srv.URL, "http") + "/xrpc/com.atproto.label.subscribeLabels?cursor=0"
+	conn, _, err := websocket.DefaultDialer.Dial(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// labels keep flowing while the backlog is replayed
+	go func() {
+		for i := 0; i < 20; i++ {
+			l.CreateLabels(ctx, "did:plc:abc222", nil, []string{"live" + strconv.Itoa(i)})
+		}
+	}()
+
+	for seq := int64(1); seq <= n+20; seq++ {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var evt events.XRPCStreamEvent
+		assert.NoError(evt.Deserialize(bytes.NewReader(msg)))
+		if !assert.NotNil(evt.LabelLabels) {
+			break
+		}
+		assert.Equal(seq, evt.LabelLabels.Seq)
+	}
+}
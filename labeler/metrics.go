@@ -0,0 +1,26 @@
+package labeler
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var labelsEmitted = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "labeler_labels_emitted",
+	Help: "Number of signed labels persisted, by whether they are negations",
+}, []string{"neg"})
+
+var subscribersConnected = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "labeler_subscribers",
+	Help: "Number of connected subscribeLabels consumers",
+})
+
+var subscribersDropped = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "labeler_subscribers_dropped",
+	Help: "Number of subscribeLabels consumers disconnected for falling behind",
+})
+
+var requestsServed = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "labeler_requests",
+	Help: "Number of HTTP requests served, by endpoint and status code",
+}, []string{"endpoint", "status"})
@@ -0,0 +1,238 @@
+package labeler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	comatproto "github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/events"
+
+	"github.com/gorilla/websocket"
+)
+
+// Number of events read from the store at a time when replaying from a cursor.
+const replayPageSize = 500
+
+// Returns an HTTP handler for the "com.atproto.label.subscribeLabels" and "com.atproto.label.queryLabels" endpoints.
+func (l *Labeler) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /xrpc/com.atproto.label.subscribeLabels", l.handleSubscribeLabels)
+	mux.HandleFunc("GET /xrpc/com.atproto.label.queryLabels", l.handleQueryLabels)
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("failed to write HTTP response", "err", err)
+	}
+}
+
+// writes an XRPC error response
+func writeError(w http.ResponseWriter, status int, name, msg string) {
+	writeJSON(w, status, map[string]string{"error": name, "message": msg})
+}
+
+func (l *Labeler) handleQueryLabels(w http.ResponseWriter, r *http.Request) {
+	status := l.serveQueryLabels(w, r)
+	requestsServed.WithLabelValues("queryLabels", strconv.Itoa(status)).Inc()
+}
+
+func (l *Labeler) serveQueryLabels(w http.ResponseWriter, r *http.Request) int {
+	q := r.URL.Query()
+	patterns := q["uriPatterns"]
+	if len(patterns) == 0 {
+		writeError(w, http.StatusBadRequest, "InvalidRequest", "uriPatterns is required")
+		return http.StatusBadRequest
+	}
+	for _, p := range patterns {
+		// only a trailing wildcard is supported
+		if strings.Contains(strings.TrimSuffix(p, "*"), "*") {
+			writeError(w, http.StatusBadRequest, "InvalidRequest", "wildcards are only supported at the end of a URI pattern")
+			return http.StatusBadRequest
+		}
+	}
+	limit := 50
+	if s := q.Get("limit"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 1 || v > 250 {
+			writeError(w, http.StatusBadRequest, "InvalidRequest", "limit must be between 1 and 250")
+			return http.StatusBadRequest
+		}
+		limit = v
+	}
+
+	// this service only holds its own labels
+	if sources := q["sources"]; len(sources) > 0 {
+		found := false
+		for _, src := range sources {
+			if src == l.DID.String() {
+				found = true
+			}
+		}
+		if !found {
+			writeJSON(w, http.StatusOK, comatproto.LabelQueryLabels_Output{Labels: []*comatproto.LabelDefs_Label{}})
+			return http.StatusOK
+		}
+	}
+
+	lbls, cursor, err := l.store.Query(patterns, q.Get("cursor"), limit, time.Now())
+	if err != nil {
+		if errors.Is(err, ErrInvalidCursor) {
+			writeError(w, http.StatusBadRequest, "InvalidRequest", "invalid cursor")
+			return http.StatusBadRequest
+		}
+		l.Logger.Error("querying labels", "err", err)
+		writeError(w, http.StatusInternalServerError, "InternalServerError", "failed to query labels")
+		return http.StatusInternalServerError
+	}
+	out := comatproto.LabelQueryLabels_Output{Labels: lbls}
+	if cursor != "" {
+		out.Cursor = &cursor
+	}
+	writeJSON(w, http.StatusOK, out)
+	return http.StatusOK
+}
+
+func (l *Labeler) handleSubscribeLabels(w http.ResponseWriter, r *http.Request) {
+	var cursor *int64
+	if s := r.URL.Query().Get("cursor"); s != "" {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || v < 0 {
+			writeError(w, http.StatusBadRequest, "InvalidRequest", "invalid cursor")
+			return
+		}
+		cursor = &v
+	}
+
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1 << 10,
+		WriteBufferSize: 10 << 10,
+		// public, read-only stream
+		CheckOrigin: func(r *http.Request) bool { return true },
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		l.Logger.Warn("upgrading websocket", "err", err)
+		return
+	}
+	defer conn.Close()
+
+	logger := l.Logger.With("remote_addr", r.RemoteAddr, "user_agent", r.UserAgent())
+	logger.Info("new subscribeLabels consumer", "cursor", cursor)
+
+	// read (and discard) messages from the client, to process control frames and notice disconnects
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if err := l.streamLabels(conn, cursor, done); err != nil {
+		logger.Info("subscribeLabels consumer disconnected", "err", err)
+	}
+}
+
+func (l *Labeler) streamLabels(conn *websocket.Conn, cursor *int64, done <-chan struct{}) error {
+	var last int64
+	if cursor != nil {
+		head := l.Seq()
+		if *cursor > head {
+			errFrame := events.XRPCStreamEvent{Error: &events.ErrorFrame{
+				Error:   "FutureCursor",
+				Message: fmt.Sprintf("cursor %d is ahead of current sequence %d", *cursor, head),
+			}}
+			return writeEvent(conn, &errFrame)
+		}
+		// replay the backlog before subscribing, so that a long replay does not overflow the live buffer
+		var err error
+		last, err = l.replayEvents(conn, *cursor, math.MaxInt64)
+		if err != nil {
+			return err
+		}
+	}
+
+	sub, head := l.subscribe()
+	defer l.unsubscribe(sub)
+	if cursor != nil {
+		// events persisted since the replay above; anything after 'head' is delivered by the subscription
+		var err error
+		last, err = l.replayEvents(conn, last, head)
+		if err != nil {
+			return err
+		}
+	}
+
+	ping := time.NewTicker(30 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case evt := <-sub.evts:
+			if evt.Seq <= last {
+				continue
+			}
+			if err := writeEvent(conn, &events.XRPCStreamEvent{LabelLabels: evt}); err != nil {
+				return err
+			}
+			last = evt.Seq
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(5*time.Second)); err != nil {
+				return err
+			}
+		case <-sub.dropped:
+			errFrame := events.XRPCStreamEvent{Error: &events.ErrorFrame{
+				Error:   "ConsumerTooSlow",
+				Message: "consumer fell too far behind; reconnect with a cursor",
+			}}
+			return writeEvent(conn, &errFrame)
+		case <-done:
+			return nil
+		}
+	}
+}
+
+// Sends persisted events after 'cursor', up to and including 'until'. Returns the sequence number of the last event sent (or 'cursor', if none were).
+func (l *Labeler) replayEvents(conn *websocket.Conn, cursor, until int64) (int64, error) {
+	last := cursor
+	for {
+		evts, err := l.store.Events(last, replayPageSize)
+		if err != nil {
+			return last, err
+		}
+		if len(evts) == 0 {
+			return last, nil
+		}
+		for _, evt := range evts {
+			if evt.Seq > until {
+				return last, nil
+			}
+			if err := writeEvent(conn, &events.XRPCStreamEvent{LabelLabels: evt}); err != nil {
+				return last, err
+			}
+			last = evt.Seq
+		}
+	}
+}
+
+func writeEvent(conn *websocket.Conn, evt *events.XRPCStreamEvent) error {
+	wc, err := conn.NextWriter(websocket.BinaryMessage)
+	if err != nil {
+		return err
+	}
+	if err := evt.Serialize(wc); err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	return wc.Close()
+}
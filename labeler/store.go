@@ -0,0 +1,261 @@
+package labeler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	comatproto "github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+)
+
+var (
+	seqKey        = []byte("meta/seq")
+	eventPrefix   = []byte("event/")
+	currentPrefix = []byte("label/")
+)
+
+// Returned by [Store.Query] for a malformed pagination cursor.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// 8-byte big-endian, so events iterate in sequence order
+func eventKey(seq int64) []byte {
+	k := make([]byte, len(eventPrefix)+8)
+	copy(k, eventPrefix)
+	binary.BigEndian.PutUint64(k[len(eventPrefix):], uint64(seq))
+	return k
+}
+
+// identifies the current state of a label. URIs and label values can not contain NUL bytes.
+func currentSuffix(uri, cid, val string) string {
+	return uri + "\x00" + cid + "\x00" + val
+}
+
+// Embedded (pebble) storage for a labeler: the sequenced log of label events (for subscribeLabels), and the current state of each label (for queryLabels).
+type Store struct {
+	db *pebble.DB
+}
+
+func OpenStore(path string) (*Store, error) {
+	db, err := pebble.Open(path, &pebble.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("opening labeler store: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Opens a store which is held entirely in memory, for tests.
+func OpenMemStore() (*Store, error) {
+	db, err := pebble.Open("", &pebble.Options{FS: vfs.NewMem()})
+	if err != nil {
+		return nil, fmt.Errorf("opening labeler store: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Returns the sequence number of the most recent event, or zero if there are none.
+func (s *Store) LastSeq() (int64, error) {
+	val, closer, err := s.db.Get(seqKey)
+	if errors.Is(err, pebble.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer closer.Close()
+	if len(val) != 8 {
+		return 0, fmt.Errorf("corrupt labeler sequence number")
+	}
+	return int64(binary.BigEndian.Uint64(val)), nil
+}
+
+// Atomically writes a label event, and updates the current state of each label in it.
+func (s *Store) Append(evt *comatproto.LabelSubscribeLabels_Labels) error {
+	batch := s.db.NewBatch()
+	defer batch.Close()
+
+	buf := new(bytes.Buffer)
+	if err := evt.MarshalCBOR(buf); err != nil {
+		return err
+	}
+	if err := batch.Set(eventKey(evt.Seq), buf.Bytes(), nil); err != nil {
+		return err
+	}
+	for _, lbl := range evt.Labels {
+		buf := new(bytes.Buffer)
+		if err := lbl.MarshalCBOR(buf); err != nil {
+			return err
+		}
+		cid := ""
+		if lbl.Cid != nil {
+			cid = *lbl.Cid
+		}
+		k := append(append([]byte{}, currentPrefix...), currentSuffix(lbl.Uri, cid, lbl.Val)...)
+		if err := batch.Set(k, buf.Bytes(), nil); err != nil {
+			return err
+		}
+	}
+	seq := make([]byte, 8)
+	binary.BigEndian.PutUint64(seq, uint64(evt.Seq))
+	if err := batch.Set(seqKey, seq, nil); err != nil {
+		return err
+	}
+	return batch.Commit(pebble.Sync)
+}
+
+// Returns up to limit events with sequence numbers greater than the cursor, in order.
+func (s *Store) Events(cursor int64, limit int) ([]*comatproto.LabelSubscribeLabels_Labels, error) {
+	iter, err := s.db.NewIter(&pebble.IterOptions{
+		LowerBound: eventKey(cursor + 1),
+		UpperBound: append(append([]byte{}, eventPrefix[:len(eventPrefix)-1]...), '/'+1),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var out []*comatproto.LabelSubscribeLabels_Labels
+	for iter.First(); iter.Valid() && len(out) < limit; iter.Next() {
+		var evt comatproto.LabelSubscribeLabels_Labels
+		if err := evt.UnmarshalCBOR(bytes.NewReader(iter.Value())); err != nil {
+			return nil, fmt.Errorf("corrupt label event: %w", err)
+		}
+		out = append(out, &evt)
+	}
+	return out, iter.Error()
+}
+
+// Returns the current state of a label (which may be a negation, or expired), or nil if it has never been emitted.
+func (s *Store) Current(uri string, cid *string, val string) (*comatproto.LabelDefs_Label, error) {
+	c := ""
+	if cid != nil {
+		c = *cid
+	}
+	k := append(append([]byte{}, currentPrefix...), currentSuffix(uri, c, val)...)
+	b, closer, err := s.db.Get(k)
+	if errors.Is(err, pebble.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+	var lbl comatproto.LabelDefs_Label
+	if err := lbl.UnmarshalCBOR(bytes.NewReader(b)); err != nil {
+		return nil, fmt.Errorf("corrupt label state: %w", err)
+	}
+	return &lbl, nil
+}
+
+// key range covered by a queryLabels URI pattern
+type keyRange struct {
+	start, end string
+}
+
+// Converts URI patterns to sorted, non-overlapping key ranges (relative to the current label prefix). A pattern ending in '*' is a prefix match; anything else is an exact URI match.
+func patternRanges(patterns []string) []keyRange {
+	var ranges []keyRange
+	for _, p := range patterns {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			end := ""
+			if prefix != "" {
+				// smallest string greater than every string with the prefix (URIs are ASCII)
+				end = prefix[:len(prefix)-1] + string(prefix[len(prefix)-1]+1)
+			}
+			ranges = append(ranges, keyRange{start: prefix, end: end})
+		} else {
+			ranges = append(ranges, keyRange{start: p + "\x00", end: p + "\x01"})
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start < ranges[j].start })
+	var merged []keyRange
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && (merged[n-1].end == "" || r.start <= merged[n-1].end) {
+			if merged[n-1].end != "" && (r.end == "" || r.end > merged[n-1].end) {
+				merged[n-1].end = r.end
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// Returns active labels (not negated, and not expired as of now) matching any of the URI patterns, ordered by URI, along with a cursor for the next page (empty if there are no more results).
+func (s *Store) Query(patterns []string, cursor string, limit int, now time.Time) ([]*comatproto.LabelDefs_Label, string, error) {
+	after := ""
+	if cursor != "" {
+		b, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		after = string(b)
+	}
+
+	out := []*comatproto.LabelDefs_Label{}
+	last := ""
+	for _, r := range patternRanges(patterns) {
+		lower := append(append([]byte{}, currentPrefix...), r.start...)
+		if after != "" && after >= r.start {
+			// resume strictly after the cursor position
+			lower = append(append([]byte{}, currentPrefix...), (after + "\x00")...)
+		}
+		upper := append(append([]byte{}, currentPrefix[:len(currentPrefix)-1]...), '/'+1)
+		if r.end != "" {
+			upper = append(append([]byte{}, currentPrefix...), r.end...)
+		}
+		if bytes.Compare(lower, upper) >= 0 {
+			continue
+		}
+		iter, err := s.db.NewIter(&pebble.IterOptions{LowerBound: lower, UpperBound: upper})
+		if err != nil {
+			return nil, "", err
+		}
+		for iter.First(); iter.Valid(); iter.Next() {
+			var lbl comatproto.LabelDefs_Label
+			if err := lbl.UnmarshalCBOR(bytes.NewReader(iter.Value())); err != nil {
+				iter.Close()
+				return nil, "", fmt.Errorf("corrupt label state: %w", err)
+			}
+			if !isActive(&lbl, now) {
+				continue
+			}
+			if len(out) == limit {
+				iter.Close()
+				return out, base64.RawURLEncoding.EncodeToString([]byte(last)), nil
+			}
+			out = append(out, &lbl)
+			last = string(iter.Key()[len(currentPrefix):])
+		}
+		if err := iter.Close(); err != nil {
+			return nil, "", err
+		}
+	}
+	return out, "", nil
+}
+
+// whether a label is in effect: not a negation, and not expired
+func isActive(lbl *comatproto.LabelDefs_Label, now time.Time) bool {
+	if lbl.Neg != nil && *lbl.Neg {
+		return false
+	}
+	if lbl.Exp != nil {
+		exp, err := syntax.ParseDatetimeLenient(*lbl.Exp)
+		if err == nil && !exp.Time().After(now) {
+			return false
+		}
+	}
+	return true
+}
@@ -245,8 +245,7 @@ func writeMethods(typename string, ts *TypeSchema, w io.Writer) error {
 	case "object", "string":
 		return nil
 	case "subscription":
-		// TODO: should probably have some methods generated for this
-		return nil
+		return ts.WriteSubscription(w, typename)
 	default:
 		return fmt.Errorf("unrecognized lexicon type %q", ts.Type)
 	}
@@ -1,6 +1,12 @@
 package lex
 
-import "testing"
+import (
+	"bytes"
+	"encoding/json"
+	"go/format"
+	"strings"
+	"testing"
+)
 
 func TestParsePackages(t *testing.T) {
 	text := `[{"package": "bsky", "prefix": "app.bsky", "outdir": "api/bsky", "import": "github.com/bluesky-social/indigo/api/bsky"}]`
@@ -17,3 +23,68 @@ func TestParsePackages(t *testing.T) {
 	}
 
 }
+
+func TestWriteSubscription(t *testing.T) {
+	text := `{
+  "lexicon": 1,
+  "id": "com.example.subscribeThings",
+  "defs": {
+    "main": {
+      "type": "subscription",
+      "parameters": {
+        "type": "params",
+        "properties": {
+          "cursor": {"type": "integer", "description": "The last known event seq number to backfill from."}
+        }
+      },
+      "message": {
+        "schema": {"type": "union", "refs": ["#thing", "#info"]}
+      }
+    },
+    "thing": {
+      "type": "object",
+      "required": ["seq", "name"],
+      "properties": {
+        "seq": {"type": "integer"},
+        "name": {"type": "string"}
+      }
+    },
+    "info": {
+      "type": "object",
+      "required": ["name"],
+      "properties": {
+        "name": {"type": "string"}
+      }
+    }
+  }
+}`
+	var s Schema
+	if err := json.Unmarshal([]byte(text), &s); err != nil {
+		t.Fatal(err)
+	}
+	packages := []Package{{"example", "com.example", "api/example", "github.com/example/api/example"}}
+	defmap := BuildExtDefMap([]*Schema{&s}, packages)
+	s.AllTypes("com.example", defmap)
+
+	buf := new(bytes.Buffer)
+	buf.WriteString("package example\n\n")
+	if err := writeMethods("SubscribeThings", s.Defs["main"], buf); err != nil {
+		t.Fatal(err)
+	}
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		t.Fatalf("generated code does not parse: %s\n%s", err, buf.String())
+	}
+	for _, want := range []string{
+		"type SubscribeThings_Callbacks struct",
+		"Thing func(ctx context.Context, evt *SubscribeThings_Thing) error",
+		"case \"#thing\":",
+		"return evt.Seq, nil",
+		"func SubscribeThings(ctx context.Context, c *xrpc.Client, cb *SubscribeThings_Callbacks, cursor int64, opts *xrpc.SubscribeOptions) error",
+		"return c.Subscribe(ctx, \"com.example.subscribeThings\", params, cb, opts)",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("generated code missing %q:\n%s", want, out)
+		}
+	}
+}
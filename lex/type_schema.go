@@ -16,6 +16,11 @@ type InputType struct {
 	Schema   *TypeSchema `json:"schema"`
 }
 
+type MessageType struct {
+	Description string      `json:"description"`
+	Schema      *TypeSchema `json:"schema"`
+}
+
 // TypeSchema is the content of a lexicon schema file "defs" section.
 // https://atproto.com/specs/lexicon
 type TypeSchema struct {
@@ -26,13 +31,14 @@ type TypeSchema struct {
 	needsCbor bool
 	needsType bool
 
-	Type        string      `json:"type"`
-	Key         string      `json:"key"`
-	Description string      `json:"description"`
-	Parameters  *TypeSchema `json:"parameters"`
-	Input       *InputType  `json:"input"`
-	Output      *OutputType `json:"output"`
-	Record      *TypeSchema `json:"record"`
+	Type        string       `json:"type"`
+	Key         string       `json:"key"`
+	Description string       `json:"description"`
+	Parameters  *TypeSchema  `json:"parameters"`
+	Input       *InputType   `json:"input"`
+	Output      *OutputType  `json:"output"`
+	Message     *MessageType `json:"message"`
+	Record      *TypeSchema  `json:"record"`
 
 	Ref        string                 `json:"ref"`
 	Refs       []string               `json:"refs"`
@@ -177,6 +183,126 @@ func (s *TypeSchema) WriteRPC(w io.Writer, typename, inputname string) error {
 	return nil
 }
 
+// WriteSubscription generates a typed callbacks struct (implementing xrpc.StreamHandler) and a helper function for a subscription endpoint.
+func (s *TypeSchema) WriteSubscription(w io.Writer, typename string) error {
+	pf := printerf(w)
+	cbname := typename + "_Callbacks"
+
+	type msgRef struct {
+		ref     string
+		field   string
+		tname   string
+		hasSeq  bool
+		comment string
+	}
+	var msgs []msgRef
+	if s.Message != nil && s.Message.Schema != nil && s.Message.Schema.Type == "union" {
+		for _, r := range s.Message.Schema.Refs {
+			ts, err := s.lookupRef(r)
+			if err != nil {
+				return err
+			}
+			vname, tname := s.namesFromRef(r)
+			seq, ok := ts.Properties["seq"]
+			msgs = append(msgs, msgRef{
+				ref:     r,
+				field:   strings.TrimPrefix(vname, typename+"_"),
+				tname:   tname,
+				hasSeq:  ok && seq.Type == "integer",
+				comment: ts.Description,
+			})
+		}
+	}
+
+	pf("// %s holds typed message handlers for the XRPC subscription %q. It implements xrpc.StreamHandler. Nil callbacks are skipped.\n", cbname, s.id)
+	pf("type %s struct {\n", cbname)
+	for _, m := range msgs {
+		if m.comment != "" {
+			pf("\t// %s\n", m.comment)
+		}
+		pf("\t%s func(ctx context.Context, evt *%s) error\n", m.field, m.tname)
+	}
+	pf("\t// Called for message types not otherwise handled\n")
+	pf("\tUnknown func(ctx context.Context, msgType string, body io.Reader) error\n")
+	pf("}\n\n")
+
+	pf("func (cb *%s) HandleStreamMessage(ctx context.Context, msgType string, body io.Reader) (int64, error) {\n", cbname)
+	pf("\tswitch msgType {\n")
+	for _, m := range msgs {
+		seq := "0"
+		if m.hasSeq {
+			seq = "evt.Seq"
+		}
+		pf("\tcase %q:\n", m.ref)
+		pf("\t\tvar evt %s\n", m.tname)
+		pf("\t\tif err := evt.UnmarshalCBOR(body); err != nil {\n")
+		pf("\t\t\treturn 0, fmt.Errorf(\"decoding %%s message: %%w\", msgType, err)\n")
+		pf("\t\t}\n")
+		pf("\t\tif cb.%s != nil {\n", m.field)
+		pf("\t\t\tif err := cb.%s(ctx, &evt); err != nil {\n", m.field)
+		pf("\t\t\t\treturn %s, err\n", seq)
+		pf("\t\t\t}\n")
+		pf("\t\t}\n")
+		pf("\t\treturn %s, nil\n", seq)
+	}
+	pf("\tdefault:\n")
+	pf("\t\tif cb.Unknown != nil {\n")
+	pf("\t\t\treturn 0, cb.Unknown(ctx, msgType, body)\n")
+	pf("\t\t}\n")
+	pf("\t\treturn 0, nil\n")
+	pf("\t}\n")
+	pf("}\n\n")
+
+	// the "cursor" parameter is optional, and managed by the client on reconnect
+	params := "ctx context.Context, c *xrpc.Client, cb *" + cbname
+	if s.Parameters != nil {
+		if err := orderedMapIter(s.Parameters.Properties, func(name string, t *TypeSchema) error {
+			tn, err := s.typeNameForField(name, "", *t)
+			if err != nil {
+				return err
+			}
+			params = params + fmt.Sprintf(", %s %s", name, tn)
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	params = params + ", opts *xrpc.SubscribeOptions"
+
+	pf("// %s subscribes to the XRPC event stream %q, blocking until the subscription ends.\n", typename, s.id)
+	if s.Parameters != nil && len(s.Parameters.Properties) > 0 {
+		pf("//\n")
+		if err := orderedMapIter(s.Parameters.Properties, func(name string, t *TypeSchema) error {
+			if t.Description != "" {
+				pf("// %s: %s\n", name, t.Description)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	pf("func %s(%s) error {\n", typename, params)
+	pf("\tparams := map[string]interface{}{}\n")
+	if s.Parameters != nil {
+		if err := orderedMapIter(s.Parameters.Properties, func(name string, t *TypeSchema) error {
+			if name == "cursor" && t.Type == "integer" {
+				pf("\tif cursor != 0 {\n")
+				pf("\t\tparams[\"cursor\"] = cursor\n")
+				pf("\t}\n")
+			} else {
+				pf("\tparams[%q] = %s\n", name, name)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	pf("\treturn c.Subscribe(ctx, %q, params, cb, opts)\n", s.id)
+	pf("}\n\n")
+
+	return nil
+}
+
 func (s *TypeSchema) WriteHandlerStub(w io.Writer, fname, shortname, impname string) error {
 	pf := printerf(w)
 	paramtypes := []string{"ctx context.Context"}
@@ -0,0 +1,8 @@
+/*
+Package plcmirror implements a read-only replica of a did:plc directory.
+
+[Mirror] consumes the upstream "/export" stream into an embedded [Store], verifying the signature and chain of every operation (using the [github.com/bluesky-social/indigo/atproto/plc] package) before persisting it. [Server] serves the standard resolution endpoints ("/{did}", "/{did}/data", "/{did}/log", "/{did}/log/audit", "/{did}/log/last") from the store, so that services can point their PLC URL at a local low-latency replica which keeps working through upstream outages.
+
+Write operations (submitting new PLC operations) are not supported; those must go to the upstream directory.
+*/
+package plcmirror
@@ -0,0 +1,31 @@
+package plcmirror
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var opsIngested = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "plcmirror_ops_ingested",
+	Help: "Number of PLC operations verified and stored",
+})
+
+var opsRejected = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "plcmirror_ops_rejected",
+	Help: "Number of PLC operations from upstream which failed verification",
+})
+
+var upstreamErrors = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "plcmirror_upstream_errors",
+	Help: "Number of failed requests to the upstream PLC directory",
+})
+
+var cursorLag = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "plcmirror_cursor_lag_seconds",
+	Help: "Age of the most recently mirrored PLC operation",
+})
+
+var requestsServed = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "plcmirror_requests",
+	Help: "Number of HTTP requests served, by endpoint and status code",
+}, []string{"endpoint", "status"})
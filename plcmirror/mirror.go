@@ -0,0 +1,243 @@
+package plcmirror
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/plc"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/util"
+)
+
+var ErrVerification = errors.New("PLC operation failed verification")
+
+type MirrorConfig struct {
+	// Base URL of the upstream PLC directory, eg "https://plc.directory"
+	UpstreamURL string
+	// Number of entries to request per page of the export stream (upstream maximum is 1000)
+	PageSize int
+	// How long to wait before polling again once caught up with the upstream
+	PollInterval time.Duration
+	UserAgent    string
+}
+
+func DefaultMirrorConfig() MirrorConfig {
+	return MirrorConfig{
+		UpstreamURL:  "https://plc.directory",
+		PageSize:     1000,
+		PollInterval: 5 * time.Second,
+		UserAgent:    "indigo-plcmirror",
+	}
+}
+
+// Consumes the upstream PLC "/export" stream into a local [Store], verifying every operation.
+//
+// The mirror must start from the beginning of the export stream (an empty store): operations for DIDs whose genesis operation was not seen can not be verified, and are skipped.
+type Mirror struct {
+	Config MirrorConfig
+	Store  *Store
+	Client *http.Client
+	Logger *slog.Logger
+
+	// time of the most recent successful upstream request (unix nanoseconds)
+	lastSync atomic.Int64
+}
+
+func NewMirror(config MirrorConfig, store *Store) *Mirror {
+	return &Mirror{
+		Config: config,
+		Store:  store,
+		Client: util.RobustHTTPClient(),
+		Logger: slog.Default().With("system", "plcmirror"),
+	}
+}
+
+// Returns the time of the last successful request to the upstream directory.
+func (m *Mirror) LastSync() time.Time {
+	n := m.lastSync.Load()
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// Runs until the context is cancelled, polling the upstream export stream. Upstream errors are logged and retried.
+func (m *Mirror) Run(ctx context.Context) error {
+	for {
+		n, err := m.SyncPage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			upstreamErrors.Inc()
+			m.Logger.Warn("failed to fetch upstream PLC export page", "err", err)
+		}
+		if err != nil || n == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(m.Config.PollInterval):
+			}
+		}
+	}
+}
+
+// Fetches and ingests a single page of the upstream export stream, starting at the stored cursor. Returns the number of entries received.
+func (m *Mirror) SyncPage(ctx context.Context) (int, error) {
+	cursor, err := m.Store.GetCursor()
+	if err != nil {
+		return 0, err
+	}
+	u, err := url.Parse(m.Config.UpstreamURL + "/export")
+	if err != nil {
+		return 0, err
+	}
+	q := u.Query()
+	q.Set("count", fmt.Sprint(m.Config.PageSize))
+	if cursor != "" {
+		q.Set("after", cursor)
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	if m.Config.UserAgent != "" {
+		req.Header.Set("User-Agent", m.Config.UserAgent)
+	}
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("upstream PLC export HTTP status: %d", resp.StatusCode)
+	}
+
+	count := 0
+	last := ""
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var entry plc.LogEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return count, fmt.Errorf("parsing export line: %w", err)
+		}
+		count++
+		if err := m.IngestEntry(entry); err != nil {
+			if !errors.Is(err, ErrVerification) {
+				return count, err
+			}
+			opsRejected.Inc()
+			m.Logger.Warn("skipping invalid PLC operation", "did", entry.DID, "cid", entry.CID, "err", err)
+		}
+		last = entry.CreatedAt
+	}
+	if err := scanner.Err(); err != nil {
+		return count, err
+	}
+	m.lastSync.Store(time.Now().UnixNano())
+	if last != "" {
+		// also advances the cursor past any rejected entries at the end of the page
+		if err := m.Store.SetCursor(last); err != nil {
+			return count, err
+		}
+		if t, err := syntax.ParseDatetimeLenient(last); err == nil {
+			cursorLag.Set(time.Since(t.Time()).Seconds())
+		}
+	}
+	return count, nil
+}
+
+// Verifies a single export entry against the stored history of the DID, and persists it.
+//
+// Entries which are already stored (by CID) are ignored. Verification failures are returned wrapping [ErrVerification], and the entry is not stored.
+func (m *Mirror) IngestEntry(entry plc.LogEntry) error {
+	if _, err := syntax.ParseDID(entry.DID.String()); err != nil || entry.DID.Method() != "plc" {
+		return fmt.Errorf("%w: invalid DID: %q", ErrVerification, entry.DID)
+	}
+	op, err := plc.ParseOperation(entry.Operation)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrVerification, err)
+	}
+	opCID, err := plc.OpCID(op)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrVerification, err)
+	}
+	if opCID != entry.CID {
+		return fmt.Errorf("%w: CID mismatch (computed %s)", ErrVerification, opCID)
+	}
+	createdAt, err := syntax.ParseDatetimeLenient(entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%w: createdAt: %w", ErrVerification, err)
+	}
+
+	entries, err := m.Store.GetAuditLog(entry.DID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	for _, e := range entries {
+		if e.CID == opCID {
+			return nil
+		}
+	}
+
+	// verification resumes from the stored head state, instead of replaying the whole log
+	v, err := m.Store.loadVerifier(entry.DID, entries)
+	if errors.Is(err, ErrNotFound) {
+		v = plc.NewVerifier(entry.DID)
+	} else if err != nil {
+		return err
+	}
+	nullified, err := v.Apply(op, createdAt.Time())
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrVerification, err)
+	}
+	for _, n := range nullified {
+		for i := range entries {
+			if entries[i].CID == n {
+				entries[i].Nullified = true
+			}
+		}
+	}
+	entry.CID = opCID
+	entry.Nullified = false
+	entries = append(entries, entry)
+	if err := m.Store.PutAuditLog(entry.DID, entries, v.Snapshot(), entry.CreatedAt); err != nil {
+		return err
+	}
+	opsIngested.Inc()
+	return nil
+}
+
+// Rebuilds verifier state from a previously verified audit log. Only needed for logs stored without a verifier snapshot.
+func replay(did syntax.DID, entries []plc.LogEntry) (*plc.Verifier, error) {
+	v := plc.NewVerifier(did)
+	for _, e := range entries {
+		op, err := plc.ParseOperation(e.Operation)
+		if err != nil {
+			return nil, fmt.Errorf("stored operation for %s: %w", did, err)
+		}
+		createdAt, err := syntax.ParseDatetimeLenient(e.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("stored operation for %s: %w", did, err)
+		}
+		if _, err := v.Apply(op, createdAt.Time()); err != nil {
+			return nil, fmt.Errorf("stored operation for %s: %w", did, err)
+		}
+	}
+	return v, nil
+}
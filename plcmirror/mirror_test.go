@@ -0,0 +1,177 @@
+package plcmirror
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/crypto"
+	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/plc"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testEntry(t *testing.T, op plc.Operation, priv crypto.PrivateKey, did syntax.DID, createdAt time.Time) plc.LogEntry {
+	if err := plc.SignOp(op, priv); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := plc.OpCID(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return plc.LogEntry{
+		DID:       did,
+		Operation: b,
+		CID:       c,
+		CreatedAt: createdAt.UTC().Format(syntax.AtprotoDatetimeLayout),
+	}
+}
+
+// serves a static export stream, respecting the "after" and "count" parameters
+func testUpstream(t *testing.T, entries []plc.LogEntry) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/export" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		after := r.URL.Query().Get("after")
+		count := 0
+		fmt.Sscan(r.URL.Query().Get("count"), &count)
+		n := 0
+		for _, e := range entries {
+			if after != "" && e.CreatedAt <= after {
+				continue
+			}
+			if n >= count {
+				break
+			}
+			b, _ := json.Marshal(e)
+			fmt.Fprintln(w, string(b))
+			n++
+		}
+	}))
+}
+
+func TestMirror(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	priv, err := crypto.GeneratePrivateKeyK256()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := priv.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := pub.DIDKey()
+	newOp := func(handle string, prev *string) *plc.RegularOp {
+		return &plc.RegularOp{
+			Type:                "plc_operation",
+			RotationKeys:        []string{key},
+			VerificationMethods: map[string]string{"atproto": key},
+			AlsoKnownAs:         []string{"at://" + handle},
+			Services: map[string]plc.OpService{
+				"atproto_pds": {Type: "AtprotoPersonalDataServer", Endpoint: "https://pds.example.com"},
+			},
+			Prev: prev,
+		}
+	}
+
+	start := time.Now().Add(-time.Hour)
+	genesis := newOp("alice.example.com", nil)
+	e1 := testEntry(t, genesis, priv, "", start)
+	did, err := plc.DIDForGenesis(genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e1.DID = did
+	e2 := testEntry(t, newOp("alice2.example.com", &e1.CID), priv, did, start.Add(time.Minute))
+
+	// signed by an unrelated key
+	otherPriv, err := crypto.GeneratePrivateKeyK256()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad := testEntry(t, newOp("mallory.example.com", &e2.CID), otherPriv, did, start.Add(2*time.Minute))
+	e3 := testEntry(t, newOp("alice3.example.com", &e2.CID), priv, did, start.Add(3*time.Minute))
+
+	upstream := testUpstream(t, []plc.LogEntry{e1, e2, bad, e3})
+	defer upstream.Close()
+
+	store, err := OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	config := DefaultMirrorConfig()
+	config.UpstreamURL = upstream.URL
+	config.PageSize = 2
+	m := NewMirror(config, store)
+	m.Client = upstream.Client()
+
+	// two pages, then caught up
+	n, err := m.SyncPage(ctx)
+	assert.NoError(err)
+	assert.Equal(2, n)
+	n, err = m.SyncPage(ctx)
+	assert.NoError(err)
+	assert.Equal(2, n)
+	n, err = m.SyncPage(ctx)
+	assert.NoError(err)
+	assert.Equal(0, n)
+
+	entries, err := store.GetAuditLog(did)
+	assert.NoError(err)
+	assert.Equal(3, len(entries))
+	cursor, err := store.GetCursor()
+	assert.NoError(err)
+	assert.Equal(e3.CreatedAt, cursor)
+
+	// the verified head state is stored alongside the log
+	vs, err := store.GetVerifierState(did)
+	assert.NoError(err)
+	assert.Equal(e3.CID, vs.Active[len(vs.Active)-1].CID)
+
+	// re-ingesting is a no-op
+	assert.NoError(m.IngestEntry(e2))
+	assert.ErrorIs(m.IngestEntry(bad), ErrVerification)
+
+	srv := httptest.NewServer(NewServer(store, m).Handler())
+	defer srv.Close()
+
+	dir := identity.BaseDirectory{PLCURL: srv.URL, HTTPClient: *srv.Client()}
+	doc, err := dir.ResolveDIDPLC(ctx, did)
+	assert.NoError(err)
+	assert.Equal([]string{"at://alice3.example.com"}, doc.AlsoKnownAs)
+
+	resp, err := http.Get(srv.URL + "/" + did.String() + "/log")
+	assert.NoError(err)
+	var ops []json.RawMessage
+	assert.NoError(json.NewDecoder(resp.Body).Decode(&ops))
+	resp.Body.Close()
+	assert.Equal(3, len(ops))
+
+	resp, err = http.Get(srv.URL + "/" + did.String() + "/data")
+	assert.NoError(err)
+	var data plc.DIDData
+	assert.NoError(json.NewDecoder(resp.Body).Decode(&data))
+	resp.Body.Close()
+	assert.Equal([]string{key}, data.RotationKeys)
+
+	resp, err = http.Get(srv.URL + "/did:plc:aaaaaaaaaaaaaaaaaaaaaaaa")
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+}
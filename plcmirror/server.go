@@ -0,0 +1,69 @@
+package plcmirror
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/plc"
+)
+
+// Serves the read-only subset of the PLC directory HTTP API from a mirrored [Store]. The endpoints themselves are implemented by [plc.ReadServer].
+type Server struct {
+	Store *Store
+	// Optional: used for the health check endpoint, to report upstream sync status
+	Mirror *Mirror
+	Logger *slog.Logger
+}
+
+func NewServer(store *Store, mirror *Mirror) *Server {
+	return &Server{
+		Store:  store,
+		Mirror: mirror,
+		Logger: slog.Default().With("system", "plcmirror"),
+	}
+}
+
+// Returns an HTTP handler for the "/{did}", "/{did}/log", "/{did}/log/audit", "/{did}/log/last", and "/{did}/data" endpoints, and a "/_health" check.
+func (s *Server) Handler() http.Handler {
+	reads := &plc.ReadServer{
+		Logs:   s.Store,
+		Logger: s.Logger,
+		OnRequest: func(endpoint string, status int) {
+			requestsServed.WithLabelValues(endpoint, fmt.Sprint(status)).Inc()
+		},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /_health", s.handleHealth)
+	mux.Handle("/", reads.Handler())
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("failed to write HTTP response", "err", err)
+	}
+}
+
+func writeMessage(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"message": msg})
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	cursor, err := s.Store.GetCursor()
+	if err != nil {
+		writeMessage(w, http.StatusInternalServerError, "store unavailable")
+		return
+	}
+	out := map[string]any{"status": "ok", "cursor": cursor}
+	if s.Mirror != nil {
+		if last := s.Mirror.LastSync(); !last.IsZero() {
+			out["lastSync"] = last.UTC().Format(time.RFC3339)
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
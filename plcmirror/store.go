@@ -0,0 +1,148 @@
+package plcmirror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/bluesky-social/indigo/atproto/plc"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// Wraps [plc.ErrDIDNotFound]
+var ErrNotFound = fmt.Errorf("%w in mirror", plc.ErrDIDNotFound)
+
+var cursorKey = []byte("meta/cursor")
+
+func didKey(did syntax.DID) []byte {
+	return []byte("did/" + did.String())
+}
+
+func stateKey(did syntax.DID) []byte {
+	return []byte("state/" + did.String())
+}
+
+// Embedded (pebble) storage for mirrored PLC audit logs. Each DID's full audit log (including nullified operations) is stored as a single JSON value, along with a snapshot of the verified state at the head of the log.
+type Store struct {
+	db *pebble.DB
+}
+
+func OpenStore(path string) (*Store, error) {
+	db, err := pebble.Open(path, &pebble.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Returns the audit log for a DID, or [ErrNotFound].
+func (s *Store) GetAuditLog(did syntax.DID) ([]plc.LogEntry, error) {
+	val, closer, err := s.db.Get(didKey(did))
+	if errors.Is(err, pebble.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+	var entries []plc.LogEntry
+	if err := json.Unmarshal(val, &entries); err != nil {
+		return nil, fmt.Errorf("corrupt audit log for %s: %w", did, err)
+	}
+	return entries, nil
+}
+
+// Returns the verifier snapshot for the head of a DID's audit log, or [ErrNotFound].
+func (s *Store) GetVerifierState(did syntax.DID) (*plc.VerifierState, error) {
+	val, closer, err := s.db.Get(stateKey(did))
+	if errors.Is(err, pebble.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+	var state plc.VerifierState
+	if err := json.Unmarshal(val, &state); err != nil {
+		return nil, fmt.Errorf("corrupt verifier state for %s: %w", did, err)
+	}
+	return &state, nil
+}
+
+// Returns a verifier for the head of a DID's audit log, or [ErrNotFound]. The verifier is restored from the stored snapshot; logs stored without a snapshot are replayed (using 'entries', if already loaded).
+func (s *Store) loadVerifier(did syntax.DID, entries []plc.LogEntry) (*plc.Verifier, error) {
+	state, err := s.GetVerifierState(did)
+	if err == nil {
+		return plc.RestoreVerifier(state), nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if entries == nil {
+		entries, err = s.GetAuditLog(did)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(entries) == 0 {
+		return nil, ErrNotFound
+	}
+	return replay(did, entries)
+}
+
+// Returns the verified current state of a DID, or [ErrNotFound].
+func (s *Store) GetLogState(did syntax.DID) (*plc.LogState, error) {
+	v, err := s.loadVerifier(did, nil)
+	if err != nil {
+		return nil, err
+	}
+	return v.State(), nil
+}
+
+// Replaces the audit log and verifier snapshot for a DID, and updates the upstream cursor, atomically.
+func (s *Store) PutAuditLog(did syntax.DID, entries []plc.LogEntry, state *plc.VerifierState, cursor string) error {
+	b, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	sb, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	batch := s.db.NewBatch()
+	defer batch.Close()
+	if err := batch.Set(didKey(did), b, nil); err != nil {
+		return err
+	}
+	if err := batch.Set(stateKey(did), sb, nil); err != nil {
+		return err
+	}
+	if err := batch.Set(cursorKey, []byte(cursor), nil); err != nil {
+		return err
+	}
+	return batch.Commit(pebble.NoSync)
+}
+
+// Returns the upstream export cursor (a "createdAt" timestamp), or empty string if nothing has been mirrored yet.
+func (s *Store) GetCursor() (string, error) {
+	val, closer, err := s.db.Get(cursorKey)
+	if errors.Is(err, pebble.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer closer.Close()
+	return string(val), nil
+}
+
+// Updates the upstream cursor, and flushes all writes to disk.
+func (s *Store) SetCursor(cursor string) error {
+	return s.db.Set(cursorKey, []byte(cursor), pebble.Sync)
+}
@@ -9,6 +9,8 @@ import (
 	"time"
 
 	atproto "github.com/bluesky-social/indigo/api/atproto"
+	atplc "github.com/bluesky-social/indigo/atproto/plc"
+	"github.com/bluesky-social/indigo/atproto/syntax"
 	"github.com/bluesky-social/indigo/events"
 	"github.com/bluesky-social/indigo/repo"
 	"github.com/bluesky-social/indigo/xrpc"
@@ -264,7 +266,6 @@ func TestRelayMultiGap(t *testing.T) {
 func TestHandleChange(t *testing.T) {
 	//t.Skip("test too sleepy to run in CI for now")
 	assert := assert.New(t)
-	_ = assert
 	didr := TestPLC(t)
 	p1 := MustSetupPDS(t, ".pdsuno", didr)
 	p1.Run(t)
@@ -289,6 +290,14 @@ func TestHandleChange(t *testing.T) {
 
 	u.ChangeHandle(t, "catbear.pdsuno")
 
+	// the handle update was submitted to the directory as a signed operation
+	entries, err := didr.Directory.AuditLog(syntax.DID(u.DID()))
+	assert.NoError(err)
+	assert.Equal(2, len(entries))
+	state, err := atplc.VerifyAuditLog(syntax.DID(u.DID()), entries)
+	assert.NoError(err)
+	assert.Equal([]string{"at://catbear.pdsuno"}, state.Data.AlsoKnownAs)
+
 	time.Sleep(time.Millisecond * 100)
 
 	initevt := evts.Next()
@@ -0,0 +1,150 @@
+package testing
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bluesky-social/indigo/api"
+	atplc "github.com/bluesky-social/indigo/atproto/plc"
+	"github.com/bluesky-social/indigo/atproto/plc/plctest"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+
+	did "github.com/whyrusleeping/go-did"
+)
+
+// PLC client backed by an in-process PLC directory ([plctest.Server]). DIDs are created and updated with real signed operations, submitted over HTTP.
+//
+// The signing key passed to CreateDID is remembered, and used to sign subsequent handle updates for that DID.
+type TestPLCClient struct {
+	api.PLCServer
+
+	Directory *plctest.Server
+	HTTP      *httptest.Server
+
+	lk   sync.Mutex
+	keys map[string]*did.PrivKey
+}
+
+func TestPLC(t *testing.T) *TestPLCClient {
+	dir := plctest.NewServer()
+	srv := httptest.NewServer(dir.Handler())
+	t.Cleanup(srv.Close)
+	return &TestPLCClient{
+		PLCServer: api.PLCServer{Host: srv.URL, C: srv.Client()},
+		Directory: dir,
+		HTTP:      srv,
+		keys:      make(map[string]*did.PrivKey),
+	}
+}
+
+func signWithPrivKey(op *atplc.RegularOp, sigkey *did.PrivKey) error {
+	b, err := op.UnsignedCBOR()
+	if err != nil {
+		return err
+	}
+	sig, err := sigkey.Sign(b)
+	if err != nil {
+		return err
+	}
+	s := base64.RawURLEncoding.EncodeToString(sig)
+	op.Sig = &s
+	return nil
+}
+
+func (c *TestPLCClient) submit(ctx context.Context, d string, op atplc.Operation) error {
+	body, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.Host+"/"+d, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.C.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("PLC operation rejected (%d): %s", resp.StatusCode, string(b))
+	}
+	return nil
+}
+
+func (c *TestPLCClient) CreateDID(ctx context.Context, sigkey *did.PrivKey, recovery string, handle string, service string) (string, error) {
+	signing := sigkey.Public().DID()
+	rotation := []string{recovery}
+	if recovery != signing {
+		rotation = append(rotation, signing)
+	}
+	// test PDS instances are configured with a bare "host:port"
+	if !strings.HasPrefix(service, "http://") && !strings.HasPrefix(service, "https://") {
+		service = "http://" + service
+	}
+	op := &atplc.RegularOp{
+		Type:                "plc_operation",
+		RotationKeys:        rotation,
+		VerificationMethods: map[string]string{"atproto": signing},
+		AlsoKnownAs:         []string{"at://" + handle},
+		Services: map[string]atplc.OpService{
+			"atproto_pds": {Type: "AtprotoPersonalDataServer", Endpoint: service},
+		},
+	}
+	if err := signWithPrivKey(op, sigkey); err != nil {
+		return "", err
+	}
+	d, err := atplc.DIDForGenesis(op)
+	if err != nil {
+		return "", err
+	}
+	if err := c.submit(ctx, d.String(), op); err != nil {
+		return "", err
+	}
+
+	c.lk.Lock()
+	c.keys[d.String()] = sigkey
+	c.lk.Unlock()
+	return d.String(), nil
+}
+
+func (c *TestPLCClient) UpdateUserHandle(ctx context.Context, didstr string, nhandle string) error {
+	c.lk.Lock()
+	sigkey, ok := c.keys[didstr]
+	c.lk.Unlock()
+	if !ok {
+		return fmt.Errorf("no rotation key known for DID: %s", didstr)
+	}
+
+	state, err := c.Directory.State(syntax.DID(didstr))
+	if err != nil {
+		return err
+	}
+	if state.Tombstoned {
+		return fmt.Errorf("DID has been tombstoned: %s", didstr)
+	}
+	data := state.Data
+	head := state.Head
+	op := &atplc.RegularOp{
+		Type:                "plc_operation",
+		RotationKeys:        data.RotationKeys,
+		VerificationMethods: data.VerificationMethods,
+		AlsoKnownAs:         []string{"at://" + nhandle},
+		Services:            data.Services,
+		Prev:                &head,
+	}
+	if err := signWithPrivKey(op, sigkey); err != nil {
+		return err
+	}
+	return c.submit(ctx, didstr, op)
+}
@@ -492,20 +492,6 @@ func (u *TestUser) ChangeHandle(t *testing.T, nhandle string) {
 	}
 }
 
-func TestPLC(t *testing.T) *plc.FakeDid {
-	// TODO: just do in memory...
-	tdir, err := os.MkdirTemp("", "plcserv")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	db, err := gorm.Open(sqlite.Open(filepath.Join(tdir, "plc.sqlite")))
-	if err != nil {
-		t.Fatal(err)
-	}
-	return plc.NewFakeDid(db)
-}
-
 type TestRelay struct {
 	bgs *bgs.BGS
 	tr  *api.TestHandleResolver
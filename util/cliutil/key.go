@@ -1,6 +1,7 @@
 package cliutil
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -9,18 +10,69 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/bluesky-social/indigo/atproto/crypto"
+
 	"github.com/lestrrat-go/jwx/v2/jwa"
 	"github.com/lestrrat-go/jwx/v2/jwk"
 	"github.com/whyrusleeping/go-did"
+	secp256k1secec "gitlab.com/yawning/secp256k1-voi/secec"
 )
 
-// LoadKeyFromFile reads the private key from file
+// LoadPrivateKeyFile reads an atproto signing key from file. The file can either be an encrypted key file (see [crypto.SaveEncryptedKeyFile]), in which case the passphrase is read from the passphraseEnv environment variable or prompted for on the terminal, or a plaintext multibase private key.
+func LoadPrivateKeyFile(kfile, passphraseEnv string) (crypto.PrivateKeyExportable, error) {
+	kb, err := os.ReadFile(kfile)
+	if err != nil {
+		return nil, err
+	}
+	return parsePrivateKeyFile(kfile, kb, passphraseEnv)
+}
+
+func parsePrivateKeyFile(kfile string, kb []byte, passphraseEnv string) (crypto.PrivateKeyExportable, error) {
+	kb = bytes.TrimSpace(kb)
+	if !bytes.HasPrefix(kb, []byte("{")) {
+		return crypto.ParsePrivateMultibase(string(kb))
+	}
+	if _, err := crypto.ParseKeyFile(kb); err != nil {
+		return nil, err
+	}
+	pass, err := ReadPassphrase(passphraseEnv, fmt.Sprintf("Passphrase for %s: ", kfile), false)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.DecryptPrivateKey(kb, pass)
+}
+
+// converts an atproto/crypto key to a go-did key
+func didPrivKey(priv crypto.PrivateKeyExportable) (*did.PrivKey, error) {
+	signer, err := crypto.GoSigner(priv)
+	if err != nil {
+		return nil, err
+	}
+	switch sk := signer.(type) {
+	case *ecdsa.PrivateKey:
+		return &did.PrivKey{Raw: sk, Type: did.KeyTypeP256}, nil
+	case *secp256k1secec.PrivateKey:
+		return &did.PrivKey{Raw: sk, Type: did.KeyTypeSecp256k1}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %T", priv)
+	}
+}
+
+// LoadKeyFromFile reads the private key from file. The file is either a JWK, or an encrypted key file (with the passphrase from the [KeyPassphraseEnv] environment variable, or prompted for on the terminal).
 func LoadKeyFromFile(kfile string) (*did.PrivKey, error) {
 	kb, err := os.ReadFile(kfile)
 	if err != nil {
 		return nil, err
 	}
 
+	if _, err := crypto.ParseKeyFile(kb); err == nil {
+		priv, err := parsePrivateKeyFile(kfile, kb, KeyPassphraseEnv)
+		if err != nil {
+			return nil, err
+		}
+		return didPrivKey(priv)
+	}
+
 	sk, err := jwk.ParseKey(kb)
 	if err != nil {
 		return nil, err
@@ -5,6 +5,9 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/bluesky-social/indigo/atproto/crypto"
+
+	"github.com/stretchr/testify/assert"
 	"github.com/whyrusleeping/go-did"
 )
 
@@ -28,3 +31,42 @@ func TestKeyGenerationAndLoading(t *testing.T) {
 		t.Fatalf("unexpected type of the key %s", key.KeyType())
 	}
 }
+
+func TestLoadEncryptedKeyFile(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	priv, err := crypto.GeneratePrivateKeyK256()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// plaintext multibase
+	plainPath := filepath.Join(dir, "plain.key")
+	assert.NoError(os.WriteFile(plainPath, []byte(priv.Multibase()+"\n"), 0600))
+	out, err := LoadPrivateKeyFile(plainPath, "")
+	assert.NoError(err)
+	assert.True(priv.Equal(out))
+
+	// encrypted, with passphrase from environment
+	encPath := filepath.Join(dir, "enc.key")
+	assert.NoError(crypto.SaveEncryptedKeyFile(encPath, priv, []byte("hunter2"), crypto.KDFArgon2id))
+	t.Setenv(KeyPassphraseEnv, "hunter2")
+	out, err = LoadPrivateKeyFile(encPath, KeyPassphraseEnv)
+	assert.NoError(err)
+	assert.True(priv.Equal(out))
+
+	// legacy loader converts to go-did key
+	dk, err := LoadKeyFromFile(encPath)
+	assert.NoError(err)
+	assert.Equal(did.KeyTypeSecp256k1, dk.Type)
+	sig, err := dk.Sign([]byte("msg"))
+	assert.NoError(err)
+	pub, err := priv.PublicKey()
+	assert.NoError(err)
+	assert.NoError(pub.HashAndVerify([]byte("msg"), sig))
+
+	t.Setenv(KeyPassphraseEnv, "wrong")
+	_, err = LoadPrivateKeyFile(encPath, KeyPassphraseEnv)
+	assert.ErrorIs(err, crypto.ErrWrongPassphrase)
+}
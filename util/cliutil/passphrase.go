@@ -0,0 +1,52 @@
+package cliutil
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// Environment variable for the passphrase of an encrypted signing key file (see [LoadPrivateKeyFile]).
+const KeyPassphraseEnv = "ATP_KEY_PASSPHRASE"
+
+// Returns a passphrase from the named environment variable if it is set, or otherwise prompts for it on the controlling terminal, without echo. If confirm is true, the prompt is repeated and the two entries must match (eg, when setting a new passphrase).
+func ReadPassphrase(envVar, prompt string, confirm bool) ([]byte, error) {
+	if envVar != "" {
+		if val, ok := os.LookupEnv(envVar); ok {
+			if val == "" {
+				return nil, fmt.Errorf("passphrase environment variable %s is empty", envVar)
+			}
+			return []byte(val), nil
+		}
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("no terminal to prompt for passphrase (set %s instead): %w", envVar, err)
+	}
+	defer tty.Close()
+
+	fmt.Fprint(tty, prompt)
+	pass, err := term.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(tty)
+	if err != nil {
+		return nil, fmt.Errorf("reading passphrase: %w", err)
+	}
+	if len(pass) == 0 {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+	if confirm {
+		fmt.Fprint(tty, "Confirm passphrase: ")
+		again, err := term.ReadPassword(int(tty.Fd()))
+		fmt.Fprintln(tty)
+		if err != nil {
+			return nil, fmt.Errorf("reading passphrase: %w", err)
+		}
+		if !bytes.Equal(again, pass) {
+			return nil, fmt.Errorf("passphrases did not match")
+		}
+	}
+	return pass, nil
+}
@@ -0,0 +1,202 @@
+package xrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/carlmjohnson/versioninfo"
+	"golang.org/x/sync/singleflight"
+	"golang.org/x/time/rate"
+)
+
+// Configures automatic retries in [Client.Do].
+//
+// Throttled requests (HTTP 429) are retried for both queries and procedures, because the server did not process them. Network errors and server errors (HTTP 500, 502, 503, 504) are only retried for queries, which are idempotent.
+type RetryPolicy struct {
+	// Maximum number of retries, not counting the initial attempt.
+	MaxRetries int
+	// Backoff before the first retry of a failed request. Doubles with each attempt, with random jitter.
+	MinBackoff time.Duration
+	// Upper bound on backoff between retries.
+	MaxBackoff time.Duration
+	// Longest time to wait for a rate-limit reset. If the server indicates a later reset time, the throttling error is returned immediately.
+	MaxRatelimitWait time.Duration
+}
+
+// Returns a reasonable retry policy for long-running services.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxRetries:       3,
+		MinBackoff:       500 * time.Millisecond,
+		MaxBackoff:       10 * time.Second,
+		MaxRatelimitWait: 30 * time.Second,
+	}
+}
+
+// Exponential backoff with jitter: a random duration between half and all of the nominal backoff for this attempt.
+func (rp *RetryPolicy) backoff(attempt int) time.Duration {
+	d := rp.MinBackoff
+	for i := 0; i < attempt && d < rp.MaxBackoff; i++ {
+		d *= 2
+	}
+	if rp.MaxBackoff > 0 && d > rp.MaxBackoff {
+		d = rp.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// Determines whether a failed response should be retried, and how long to wait first.
+func (c *Client) retryWait(kind XRPCRequestType, resp *http.Response, attempt int, canResend bool) (time.Duration, bool) {
+	rp := c.Retry
+	if rp == nil || !canResend || attempt >= rp.MaxRetries {
+		return 0, false
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		wait := rp.backoff(attempt)
+		if n, err := strconv.ParseInt(resp.Header.Get("ratelimit-reset"), 10, 64); err == nil {
+			wait = time.Until(time.Unix(n, 0))
+		} else if n, err := strconv.ParseInt(resp.Header.Get("Retry-After"), 10, 64); err == nil {
+			wait = time.Duration(n) * time.Second
+		}
+		if wait < 0 {
+			wait = 0
+		}
+		if wait > rp.MaxRatelimitWait {
+			return 0, false
+		}
+		return wait, true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		if kind != Query {
+			return 0, false
+		}
+		return rp.backoff(attempt), true
+	}
+	return 0, false
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// Client-side token-bucket rate limiter, with a separate bucket for each remote host.
+type HostLimiter struct {
+	lk       sync.Mutex
+	limiters map[string]*rate.Limiter
+	limit    rate.Limit
+	burst    int
+}
+
+// Creates a limiter allowing 'rps' requests per second (sustained) to each host, with bursts up to 'burst' requests.
+func NewHostLimiter(rps float64, burst int) *HostLimiter {
+	return &HostLimiter{
+		limiters: make(map[string]*rate.Limiter),
+		limit:    rate.Limit(rps),
+		burst:    burst,
+	}
+}
+
+// Blocks until a request to the host is permitted, or the context is cancelled.
+func (hl *HostLimiter) Wait(ctx context.Context, host string) error {
+	hl.lk.Lock()
+	lim, ok := hl.limiters[host]
+	if !ok {
+		lim = rate.NewLimiter(hl.limit, hl.burst)
+		hl.limiters[host] = lim
+	}
+	hl.lk.Unlock()
+	return lim.Wait(ctx)
+}
+
+// de-duplicates concurrent refreshes of the same session (keyed by refresh token)
+var refreshGroup singleflight.Group
+
+func (c *Client) canRefresh(method string) bool {
+	if !c.AutoRefresh || c.Signer != nil || method == "com.atproto.server.refreshSession" {
+		return false
+	}
+	auth := c.GetAuth()
+	return auth != nil && auth.RefreshJwt != ""
+}
+
+// Refreshes a legacy (createSession) auth session, updating the client's Auth field and invoking RefreshCallback.
+//
+// 'expiredJwt' is the access token which was rejected. If the session has already been refreshed since that token was used (eg, by a concurrent request), this is a no-op. Concurrent refreshes of the same session are coalesced in to a single request.
+func (c *Client) refreshSession(ctx context.Context, expiredJwt string) error {
+	host := c.Host
+	c.refreshLk.Lock()
+	defer c.refreshLk.Unlock()
+	cur := c.GetAuth()
+	if cur == nil {
+		return fmt.Errorf("no session to refresh")
+	}
+	if cur.AccessJwt != expiredJwt {
+		// already refreshed
+		return nil
+	}
+	prev := *cur
+	v, err, _ := refreshGroup.Do(host+" "+prev.RefreshJwt, func() (any, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", host+"/xrpc/com.atproto.server.refreshSession", nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+prev.RefreshJwt)
+		if c.UserAgent != nil {
+			req.Header.Set("User-Agent", *c.UserAgent)
+		} else {
+			req.Header.Set("User-Agent", "indigo/"+versioninfo.Short())
+		}
+		resp, err := c.getClient().Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("request failed: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != 200 {
+			var xe XRPCError
+			if err := json.NewDecoder(resp.Body).Decode(&xe); err != nil {
+				return nil, errorFromHTTPResponse(resp, fmt.Errorf("failed to decode xrpc error message: %w", err))
+			}
+			return nil, errorFromHTTPResponse(resp, &xe)
+		}
+		var out AuthInfo
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			return nil, fmt.Errorf("decoding refreshSession response: %w", err)
+		}
+		return &out, nil
+	})
+	if err != nil {
+		return err
+	}
+	refreshed := *(v.(*AuthInfo))
+	if refreshed.Did == "" {
+		refreshed.Did = prev.Did
+	}
+	if refreshed.Handle == "" {
+		refreshed.Handle = prev.Handle
+	}
+	c.SetAuth(&refreshed)
+	if c.RefreshCallback != nil {
+		c.RefreshCallback(ctx, &refreshed)
+	}
+	return nil
+}
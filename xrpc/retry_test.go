@@ -0,0 +1,212 @@
+package xrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxRetries:       2,
+		MinBackoff:       time.Millisecond,
+		MaxBackoff:       5 * time.Millisecond,
+		MaxRatelimitWait: 2 * time.Second,
+	}
+}
+
+func TestRetryServerErrors(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	var count atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if count.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_ = json.NewEncoder(w).Encode(XRPCError{ErrStr: "Unavailable"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	}))
+	defer srv.Close()
+
+	// no retries by default
+	c := Client{Client: srv.Client(), Host: srv.URL}
+	var out map[string]string
+	assert.Error(c.Do(ctx, Query, "", "com.example.ping", nil, nil, &out))
+	assert.Equal(int32(1), count.Load())
+
+	count.Store(0)
+	c.Retry = testRetryPolicy()
+	assert.NoError(c.Do(ctx, Query, "", "com.example.ping", nil, nil, &out))
+	assert.Equal("ok", out["status"])
+	assert.Equal(int32(3), count.Load())
+
+	// procedures are not idempotent, so not retried
+	count.Store(0)
+	assert.Error(c.Do(ctx, Procedure, "application/json", "com.example.ping", nil, map[string]string{"a": "b"}, &out))
+	assert.Equal(int32(1), count.Load())
+}
+
+func TestRetryRatelimit(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	var count atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if count.Add(1) == 1 {
+			w.Header().Set("ratelimit-limit", "100")
+			w.Header().Set("ratelimit-remaining", "0")
+			w.Header().Set("ratelimit-reset", strconv.FormatInt(time.Now().Unix(), 10))
+			w.WriteHeader(http.StatusTooManyRequests)
+			_ = json.NewEncoder(w).Encode(XRPCError{ErrStr: "RateLimitExceeded"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	}))
+	defer srv.Close()
+
+	c := Client{Client: srv.Client(), Host: srv.URL, Retry: testRetryPolicy()}
+	var out map[string]string
+	// throttled procedures are retried
+	assert.NoError(c.Do(ctx, Procedure, "application/json", "com.example.ping", nil, map[string]string{"a": "b"}, &out))
+	assert.Equal(int32(2), count.Load())
+
+	// reset too far in the future
+	count.Store(0)
+	c.Retry.MaxRatelimitWait = 0
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count.Add(1)
+		w.Header().Set("ratelimit-limit", "100")
+		w.Header().Set("ratelimit-reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		w.WriteHeader(http.StatusTooManyRequests)
+		_ = json.NewEncoder(w).Encode(XRPCError{ErrStr: "RateLimitExceeded"})
+	})
+	err := c.Do(ctx, Query, "", "com.example.ping", nil, nil, &out)
+	assert.Error(err)
+	xerr, ok := err.(*Error)
+	assert.True(ok)
+	assert.True(xerr.IsThrottled())
+	assert.Equal(int32(1), count.Load())
+}
+
+func TestAutoRefresh(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/xrpc/com.atproto.server.refreshSession":
+			if auth != "Bearer refresh-1" {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(XRPCError{ErrStr: "InvalidToken"})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(AuthInfo{AccessJwt: "access-2", RefreshJwt: "refresh-2", Did: "did:plc:abc111"})
+		default:
+			if auth != "Bearer access-2" {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(XRPCError{ErrStr: "ExpiredToken", Message: "Token has expired"})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+		}
+	}))
+	defer srv.Close()
+
+	c := Client{
+		Client: srv.Client(),
+		Host:   srv.URL,
+		Auth:   &AuthInfo{AccessJwt: "access-1", RefreshJwt: "refresh-1", Did: "did:plc:abc111", Handle: "alice.example.com"},
+	}
+	var out map[string]string
+	assert.Error(c.Do(ctx, Query, "", "com.example.ping", nil, nil, &out))
+
+	var persisted *AuthInfo
+	c.AutoRefresh = true
+	c.RefreshCallback = func(ctx context.Context, auth *AuthInfo) {
+		persisted = auth
+	}
+	assert.NoError(c.Do(ctx, Procedure, "application/json", "com.example.ping", nil, map[string]string{"a": "b"}, &out))
+	assert.Equal("access-2", c.Auth.AccessJwt)
+	assert.Equal("alice.example.com", c.Auth.Handle)
+	assert.NotNil(persisted)
+	assert.Equal("refresh-2", persisted.RefreshJwt)
+}
+
+func TestAutoRefreshConcurrent(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	var refreshes atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/xrpc/com.atproto.server.refreshSession":
+			if auth != "Bearer refresh-1" {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(XRPCError{ErrStr: "InvalidToken"})
+				return
+			}
+			refreshes.Add(1)
+			// give other requests time to fail with the old token
+			time.Sleep(20 * time.Millisecond)
+			_ = json.NewEncoder(w).Encode(AuthInfo{AccessJwt: "access-2", RefreshJwt: "refresh-2"})
+		default:
+			if auth != "Bearer access-2" {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(XRPCError{ErrStr: "ExpiredToken", Message: "Token has expired"})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+		}
+	}))
+	defer srv.Close()
+
+	c := Client{
+		Client:      srv.Client(),
+		Host:        srv.URL,
+		Auth:        &AuthInfo{AccessJwt: "access-1", RefreshJwt: "refresh-1", Did: "did:plc:abc111"},
+		AutoRefresh: true,
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var out map[string]string
+			assert.NoError(c.Do(ctx, Query, "", "com.example.ping", nil, nil, &out))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(int32(1), refreshes.Load())
+	assert.Equal("access-2", c.GetAuth().AccessJwt)
+}
+
+func TestHostLimiter(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	hl := NewHostLimiter(1000, 1)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		assert.NoError(hl.Wait(ctx, "a.example.com"))
+		assert.NoError(hl.Wait(ctx, "b.example.com"))
+	}
+	assert.Less(time.Since(start), time.Second)
+
+	hl = NewHostLimiter(0.001, 1)
+	assert.NoError(hl.Wait(ctx, "a.example.com"))
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.Error(hl.Wait(cctx, "a.example.com"))
+}
@@ -0,0 +1,265 @@
+package xrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/carlmjohnson/versioninfo"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Header "op" value for regular message frames
+	StreamOpMessage = 1
+	// Header "op" value for error frames
+	StreamOpError = -1
+)
+
+// Header of each frame in an XRPC event stream (subscription).
+type StreamHeader struct {
+	Op      int64  `cborgen:"op"`
+	MsgType string `cborgen:"t"`
+}
+
+// Body of an error frame. Servers close the connection after sending one.
+type StreamError struct {
+	ErrStr  string `cborgen:"error"`
+	Message string `cborgen:"message"`
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("stream error frame: %s: %s", e.ErrStr, e.Message)
+}
+
+// Body of an "#info" message. Many atproto subscriptions (eg, subscribeRepos and subscribeLabels) use this to indicate things like "OutdatedCursor".
+type StreamInfo struct {
+	Name    string  `cborgen:"name"`
+	Message *string `cborgen:"message,omitempty"`
+}
+
+// Callback interface for XRPC event stream subscriptions. The Lexicon code generator emits typed implementations of this interface for each subscription endpoint.
+type StreamHandler interface {
+	// Decodes and processes the body of a single message frame. 'msgType' is the header "t" value (eg, "#commit").
+	//
+	// Returns the sequence number of the message, if it has one (otherwise zero). This is used as the cursor when reconnecting. Returning an error ends the subscription (without reconnecting).
+	HandleStreamMessage(ctx context.Context, msgType string, body io.Reader) (int64, error)
+}
+
+// Configures reconnection and keep-alive behavior for [Client.Subscribe].
+type SubscribeOptions struct {
+	// Whether to reconnect (from the last seen cursor) when the connection fails
+	Reconnect bool
+	// Maximum number of consecutive reconnection attempts, or zero for unlimited
+	MaxReconnects int
+	// Backoff before the first reconnection attempt. Doubles with each consecutive failure.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// How often to send websocket pings; zero disables pings
+	PingInterval time.Duration
+}
+
+// Returns reasonable subscription options for long-running consumers: reconnect indefinitely with backoff, and ping every 30 seconds.
+func DefaultSubscribeOptions() *SubscribeOptions {
+	return &SubscribeOptions{
+		Reconnect:    true,
+		MinBackoff:   time.Second,
+		MaxBackoff:   time.Minute,
+		PingInterval: 30 * time.Second,
+	}
+}
+
+// Builds the websocket URL for a subscription endpoint from the client host.
+func (c *Client) streamURL(method string, params map[string]any) (string, error) {
+	u, err := url.Parse(c.Host)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "https", "wss":
+		u.Scheme = "wss"
+	case "http", "ws":
+		u.Scheme = "ws"
+	default:
+		return "", fmt.Errorf("unsupported host URL scheme for subscription: %s", u.Scheme)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/xrpc/" + method
+	if len(params) > 0 {
+		u.RawQuery = makeParams(params)
+	}
+	return u.String(), nil
+}
+
+// Connects to an XRPC subscription (websocket event stream) endpoint, and passes every message to the handler.
+//
+// Header and error frames are processed here. "#info" messages are logged, then passed to the handler like any other message. If 'opts' enables reconnection, dropped connections are re-established with exponential backoff, setting the "cursor" parameter to the last sequence number seen.
+//
+// Blocks until the context is cancelled, the handler returns an error, the server sends an error frame, or reconnection attempts are exhausted. If 'opts' is nil, [DefaultSubscribeOptions] are used.
+func (c *Client) Subscribe(ctx context.Context, method string, params map[string]any, handler StreamHandler, opts *SubscribeOptions) error {
+	if opts == nil {
+		opts = DefaultSubscribeOptions()
+	}
+	// copy, so the cursor can be updated
+	p := make(map[string]any, len(params)+1)
+	for k, v := range params {
+		p[k] = v
+	}
+
+	lastSeq := int64(0)
+	failures := 0
+	backoff := opts.MinBackoff
+	for {
+		if lastSeq > 0 {
+			p["cursor"] = lastSeq
+		}
+		received, err := c.subscribeOnce(ctx, method, p, handler, opts, &lastSeq)
+		if err == nil || ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var serr *StreamError
+		var herr *handlerError
+		if errors.As(err, &herr) {
+			return herr.err
+		}
+		if errors.As(err, &serr) && serr.ErrStr != "ConsumerTooSlow" {
+			return serr
+		}
+		if !opts.Reconnect {
+			return err
+		}
+		if received {
+			failures = 0
+			backoff = opts.MinBackoff
+		}
+		failures++
+		if opts.MaxReconnects > 0 && failures > opts.MaxReconnects {
+			return fmt.Errorf("subscription reconnection attempts exhausted: %w", err)
+		}
+		slog.Warn("subscription connection failed, reconnecting", "method", method, "cursor", lastSeq, "backoff", backoff, "err", err)
+		if err := sleepContext(ctx, backoff); err != nil {
+			return err
+		}
+		backoff *= 2
+		if opts.MaxBackoff > 0 && backoff > opts.MaxBackoff {
+			backoff = opts.MaxBackoff
+		}
+	}
+}
+
+// wraps errors returned by the stream handler, which are always fatal
+type handlerError struct {
+	err error
+}
+
+func (e *handlerError) Error() string {
+	return e.err.Error()
+}
+
+// Runs a single websocket connection until it fails. Returns whether any messages were received.
+func (c *Client) subscribeOnce(ctx context.Context, method string, params map[string]any, handler StreamHandler, opts *SubscribeOptions, lastSeq *int64) (bool, error) {
+	u, err := c.streamURL(method, params)
+	if err != nil {
+		return false, &handlerError{err: err}
+	}
+
+	// re-use regular request header logic (user agent, auth, etc)
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return false, &handlerError{err: err}
+	}
+	if c.UserAgent != nil {
+		req.Header.Set("User-Agent", *c.UserAgent)
+	} else {
+		req.Header.Set("User-Agent", "indigo/"+versioninfo.Short())
+	}
+	for k, v := range c.Headers {
+		req.Header.Set(k, v)
+	}
+	if err := c.setAuthHeader(req, method); err != nil {
+		return false, &handlerError{err: err}
+	}
+
+	con, resp, err := websocket.DefaultDialer.DialContext(ctx, u, req.Header)
+	if err != nil {
+		if resp != nil {
+			return false, fmt.Errorf("subscription dial failed (HTTP %d): %w", resp.StatusCode, err)
+		}
+		return false, fmt.Errorf("subscription dial failed: %w", err)
+	}
+	defer con.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		var tick <-chan time.Time
+		if opts.PingInterval > 0 {
+			t := time.NewTicker(opts.PingInterval)
+			defer t.Stop()
+			tick = t.C
+		}
+		for {
+			select {
+			case <-tick:
+				if err := con.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(10*time.Second)); err != nil {
+					slog.Debug("failed to send subscription ping", "err", err)
+				}
+			case <-ctx.Done():
+				con.Close()
+				return
+			}
+		}
+	}()
+
+	received := false
+	for {
+		mt, r, err := con.NextReader()
+		if err != nil {
+			return received, err
+		}
+		if mt != websocket.BinaryMessage {
+			return received, fmt.Errorf("expected binary message from subscription endpoint")
+		}
+		frame, err := io.ReadAll(r)
+		if err != nil {
+			return received, err
+		}
+		received = true
+		buf := bytes.NewReader(frame)
+
+		var header StreamHeader
+		if err := header.UnmarshalCBOR(buf); err != nil {
+			return received, fmt.Errorf("reading stream frame header: %w", err)
+		}
+		switch header.Op {
+		case StreamOpMessage:
+			if header.MsgType == "#info" {
+				var info StreamInfo
+				if err := info.UnmarshalCBOR(bytes.NewReader(frame[len(frame)-buf.Len():])); err == nil {
+					slog.Info("subscription info message", "method", method, "name", info.Name, "message", info.Message)
+				}
+			}
+			seq, err := handler.HandleStreamMessage(ctx, header.MsgType, buf)
+			if err != nil {
+				return received, &handlerError{err: err}
+			}
+			if seq > 0 {
+				*lastSeq = seq
+			}
+		case StreamOpError:
+			var serr StreamError
+			if err := serr.UnmarshalCBOR(buf); err != nil {
+				return received, fmt.Errorf("reading stream error frame: %w", err)
+			}
+			return received, &serr
+		default:
+			return received, fmt.Errorf("unexpected stream frame op: %d", header.Op)
+		}
+	}
+}
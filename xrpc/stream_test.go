@@ -0,0 +1,119 @@
+package xrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// stream handler which decodes every message body as a StreamInfo, using the "name" as a sequence number
+type testStreamHandler struct {
+	names []string
+	stop  int
+}
+
+func (h *testStreamHandler) HandleStreamMessage(ctx context.Context, msgType string, body io.Reader) (int64, error) {
+	var info StreamInfo
+	if err := info.UnmarshalCBOR(body); err != nil {
+		return 0, err
+	}
+	h.names = append(h.names, info.Name)
+	if h.stop > 0 && len(h.names) >= h.stop {
+		return 0, errors.New("done")
+	}
+	seq, _ := strconv.ParseInt(info.Name, 10, 64)
+	return seq, nil
+}
+
+func writeFrame(t *testing.T, con *websocket.Conn, header StreamHeader, body interface{ MarshalCBOR(io.Writer) error }) {
+	buf := new(bytes.Buffer)
+	if err := header.MarshalCBOR(buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := body.MarshalCBOR(buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := con.WriteMessage(websocket.BinaryMessage, buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSubscribeReconnect(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	var conns atomic.Int32
+	var cursors []string
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/xrpc/com.example.subscribe", r.URL.Path)
+		assert.Equal("Bearer secret", r.Header.Get("Authorization"))
+		cursors = append(cursors, r.URL.Query().Get("cursor"))
+		con, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer con.Close()
+		start := 1
+		if n := conns.Add(1); n > 1 {
+			start = 3
+		}
+		// two messages per connection, then drop
+		for i := start; i < start+2; i++ {
+			writeFrame(t, con, StreamHeader{Op: StreamOpMessage, MsgType: "#info"}, &StreamInfo{Name: strconv.Itoa(i)})
+		}
+	}))
+	defer srv.Close()
+
+	c := Client{Host: srv.URL, Auth: &AuthInfo{AccessJwt: "secret"}}
+	h := testStreamHandler{stop: 4}
+	opts := &SubscribeOptions{Reconnect: true, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+	err := c.Subscribe(ctx, "com.example.subscribe", nil, &h, opts)
+	assert.EqualError(err, "done")
+	assert.Equal([]string{"1", "2", "3", "4"}, h.names)
+	assert.Equal([]string{"", "2"}, cursors)
+}
+
+func TestSubscribeErrorFrame(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	var conns atomic.Int32
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conns.Add(1)
+		con, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer con.Close()
+		writeFrame(t, con, StreamHeader{Op: StreamOpError}, &StreamError{ErrStr: "FutureCursor", Message: "cursor in the future"})
+	}))
+	defer srv.Close()
+
+	c := Client{Host: srv.URL}
+	h := testStreamHandler{}
+	opts := &SubscribeOptions{Reconnect: true, MinBackoff: time.Millisecond}
+	err := c.Subscribe(ctx, "com.example.subscribe", map[string]any{"cursor": 123}, &h, opts)
+	var serr *StreamError
+	assert.True(errors.As(err, &serr))
+	assert.Equal("FutureCursor", serr.ErrStr)
+	assert.Equal(int32(1), conns.Load())
+
+	// dial failures give up after MaxReconnects
+	c.Host = "http://127.0.0.1:1"
+	opts.MaxReconnects = 2
+	assert.Error(c.Subscribe(ctx, "com.example.subscribe", nil, &h, opts))
+}
@@ -11,6 +11,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bluesky-social/indigo/util"
@@ -19,12 +20,52 @@ import (
 
 type Client struct {
 	// Client is an HTTP client to use. If not set, defaults to http.RobustHTTPClient().
-	Client     *http.Client
+	Client *http.Client
+	// Legacy (createSession) auth tokens. If the client is shared between goroutines and AutoRefresh is enabled, use GetAuth and SetAuth to access this field after requests have started.
 	Auth       *AuthInfo
 	AdminToken *string
 	Host       string
 	UserAgent  *string
 	Headers    map[string]string
+	// Signer, if set, authenticates each request individually (eg, OAuth with DPoP-bound tokens). It takes priority over Auth.
+	Signer RequestSigner
+
+	// If true, and Auth includes a refresh token, the session is refreshed automatically when the server returns an "ExpiredToken" error. The request is then retried once.
+	AutoRefresh bool
+	// Optional callback invoked with the new tokens after an automatic session refresh, so callers can persist them.
+	RefreshCallback func(ctx context.Context, auth *AuthInfo)
+	// Optional retry behavior for throttled and failed requests. If nil, requests are never retried.
+	Retry *RetryPolicy
+	// Optional client-side rate limiter, applied per remote host.
+	Limiter *HostLimiter
+
+	// protects Auth, which may be replaced by a session refresh while other requests are in flight
+	authLk sync.RWMutex
+	// serializes session refreshes by this client
+	refreshLk sync.Mutex
+}
+
+// Returns the current legacy auth tokens (the Auth field). Safe for concurrent use with automatic session refresh.
+func (c *Client) GetAuth() *AuthInfo {
+	c.authLk.RLock()
+	defer c.authLk.RUnlock()
+	return c.Auth
+}
+
+// Replaces the legacy auth tokens (the Auth field). Safe for concurrent use with automatic session refresh.
+func (c *Client) SetAuth(auth *AuthInfo) {
+	c.authLk.Lock()
+	defer c.authLk.Unlock()
+	c.Auth = auth
+}
+
+// Interface for request authentication schemes which need to sign every HTTP request individually, such as OAuth with DPoP-bound access tokens.
+type RequestSigner interface {
+	// Adds authentication headers to an outgoing request.
+	SignRequest(req *http.Request) error
+
+	// Inspects an error response. If it returns true, the request is signed and sent again (once). This is used, eg, to update DPoP nonces, or to refresh expired tokens.
+	ShouldRetry(req *http.Request, resp *http.Response) bool
 }
 
 func (c *Client) getClient() *http.Client {
@@ -133,6 +174,21 @@ func makeParams(p map[string]any) string {
 	return params.Encode()
 }
 
+// Sets the "Authorization" header for a request, depending on the client configuration and the endpoint being called.
+func (c *Client) setAuthHeader(req *http.Request, method string) error {
+	// use admin auth if we have it configured and are doing a request that requires it
+	if c.AdminToken != nil && (strings.HasPrefix(method, "com.atproto.admin.") || strings.HasPrefix(method, "tools.ozone.") || method == "com.atproto.server.createInviteCode" || method == "com.atproto.server.createInviteCodes") {
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("admin:"+*c.AdminToken)))
+	} else if c.Signer != nil {
+		if err := c.Signer.SignRequest(req); err != nil {
+			return fmt.Errorf("signing request: %w", err)
+		}
+	} else if auth := c.GetAuth(); auth != nil {
+		req.Header.Set("Authorization", "Bearer "+auth.AccessJwt)
+	}
+	return nil
+}
+
 func (c *Client) Do(ctx context.Context, kind XRPCRequestType, inpenc string, method string, params map[string]interface{}, bodyobj interface{}, out interface{}) error {
 	var body io.Reader
 	if bodyobj != nil {
@@ -183,28 +239,91 @@ func (c *Client) Do(ctx context.Context, kind XRPCRequestType, inpenc string, me
 		}
 	}
 
-	// use admin auth if we have it configured and are doing a request that requires it
-	if c.AdminToken != nil && (strings.HasPrefix(method, "com.atproto.admin.") || strings.HasPrefix(method, "tools.ozone.") || method == "com.atproto.server.createInviteCode" || method == "com.atproto.server.createInviteCodes") {
-		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("admin:"+*c.AdminToken)))
-	} else if c.Auth != nil {
-		req.Header.Set("Authorization", "Bearer "+c.Auth.AccessJwt)
-	}
+	// requests can only be re-sent (for retries) if the body can be re-read
+	canResend := req.Body == nil || req.GetBody != nil
+	signerRetried := false
+	refreshed := false
+	attempt := 0
+	var resp *http.Response
+	for {
+		if attempt > 0 || signerRetried || refreshed {
+			if req.GetBody != nil {
+				req.Body, err = req.GetBody()
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if err := c.setAuthHeader(req, method); err != nil {
+			return err
+		}
+		if c.Limiter != nil {
+			if err := c.Limiter.Wait(ctx, req.URL.Host); err != nil {
+				return err
+			}
+		}
 
-	resp, err := c.getClient().Do(req.WithContext(ctx))
-	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
-	}
+		resp, err = c.getClient().Do(req.WithContext(ctx))
+		if err != nil {
+			// transport-level failures are only retried for idempotent requests
+			if kind == Query && canResend && c.Retry != nil && attempt < c.Retry.MaxRetries {
+				if serr := sleepContext(ctx, c.Retry.backoff(attempt)); serr != nil {
+					return fmt.Errorf("request failed: %w", err)
+				}
+				attempt++
+				continue
+			}
+			return fmt.Errorf("request failed: %w", err)
+		}
+		if resp.StatusCode == 200 {
+			break
+		}
 
-	defer resp.Body.Close()
+		// signers may request a single retry (eg, to pick up a new DPoP nonce)
+		if c.Signer != nil && !signerRetried && canResend && c.Signer.ShouldRetry(req, resp) {
+			resp.Body.Close()
+			signerRetried = true
+			continue
+		}
 
-	if resp.StatusCode != 200 {
+		respBytes, rerr := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		resp.Body.Close()
 		var xe XRPCError
-		if err := json.NewDecoder(resp.Body).Decode(&xe); err != nil {
-			return errorFromHTTPResponse(resp, fmt.Errorf("failed to decode xrpc error message: %w", err))
+		if rerr == nil {
+			rerr = json.Unmarshal(respBytes, &xe)
+		}
+		if rerr != nil {
+			xerr := errorFromHTTPResponse(resp, fmt.Errorf("failed to decode xrpc error message: %w", rerr))
+			if wait, ok := c.retryWait(kind, resp, attempt, canResend); ok {
+				if err := sleepContext(ctx, wait); err != nil {
+					return xerr
+				}
+				attempt++
+				continue
+			}
+			return xerr
+		}
+
+		if xe.ErrStr == "ExpiredToken" && !refreshed && canResend && c.canRefresh(method) {
+			if err := c.refreshSession(ctx, strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")); err != nil {
+				return fmt.Errorf("refreshing expired session: %w", err)
+			}
+			refreshed = true
+			continue
+		}
+
+		if wait, ok := c.retryWait(kind, resp, attempt, canResend); ok {
+			if err := sleepContext(ctx, wait); err != nil {
+				return errorFromHTTPResponse(resp, &xe)
+			}
+			attempt++
+			continue
 		}
 		return errorFromHTTPResponse(resp, &xe)
 	}
 
+	defer resp.Body.Close()
+
 	if out != nil {
 		if buf, ok := out.(*bytes.Buffer); ok {
 			if resp.ContentLength < 0 {